@@ -1,7 +1,7 @@
 package addr
 
 import (
-	"math/big"
+	"squirrel/amount"
 )
 
 // Address db model.
@@ -19,7 +19,7 @@ type Asset struct {
 	ID                  uint
 	Address             string
 	AssetID             string
-	Balance             *big.Float
+	Balance             amount.Amount
 	Transactions        uint64
 	LastTransactionTime uint64
 }
@@ -30,7 +30,7 @@ type AssetInfo struct {
 	CreatedAt           uint64
 	LastTransactionTime uint64
 	AssetID             string
-	Balance             *big.Float
+	Balance             amount.Amount
 }
 
 // Tx model.
@@ -41,3 +41,17 @@ type Tx struct {
 	BlockTime uint64
 	AssetType string
 }
+
+// Contract db model, the verification contract of an address seen in a transaction witness.
+type Contract struct {
+	Address string
+	// Type is one of the verification contract types of package smartcontract.
+	Type      string
+	Threshold int
+	// PublicKeys are hex encoded compressed public keys.
+	PublicKeys []string
+	Script     string
+	// TxID and BlockIndex are of the first transaction witnessed by the contract.
+	TxID       string
+	BlockIndex uint
+}
@@ -0,0 +1,255 @@
+// Package amount provides the fixed-point number type of balances, values and fees.
+package amount
+
+import (
+	"database/sql/driver"
+	"encoding/json"
+	"fmt"
+	"math/big"
+	"strconv"
+	"strings"
+)
+
+// Amount is an exact decimal number stored as integer base units,
+// its value is units / 10^decimals. The zero value is 0.
+// Amounts are immutable, arithmetic methods return new amounts.
+type Amount struct {
+	units    *big.Int
+	decimals uint8
+}
+
+// Zero is the amount 0.
+var Zero = Amount{}
+
+// New returns the amount of units in base units of the given decimals.
+func New(units *big.Int, decimals uint8) Amount {
+	return Amount{
+		units:    new(big.Int).Set(units),
+		decimals: decimals,
+	}
+}
+
+// NewFromInt64 returns the amount of units in base units of the given decimals.
+func NewFromInt64(units int64, decimals uint8) Amount {
+	return Amount{
+		units:    big.NewInt(units),
+		decimals: decimals,
+	}
+}
+
+// Parse parses a decimal string like "-12.345", decimals of the result are the digits after the point.
+func Parse(s string) (Amount, error) {
+	str := s
+	neg := false
+
+	switch {
+	case strings.HasPrefix(str, "-"):
+		neg = true
+		str = str[1:]
+	case strings.HasPrefix(str, "+"):
+		str = str[1:]
+	}
+
+	intPart, fracPart := str, ""
+	if i := strings.IndexByte(str, '.'); i >= 0 {
+		intPart, fracPart = str[:i], str[i+1:]
+	}
+
+	if intPart == "" && fracPart == "" || len(fracPart) > 255 {
+		return Zero, fmt.Errorf("invalid amount: %q", s)
+	}
+
+	digits := intPart + fracPart
+	for _, c := range digits {
+		if c < '0' || c > '9' {
+			return Zero, fmt.Errorf("invalid amount: %q", s)
+		}
+	}
+
+	units, _ := new(big.Int).SetString(digits, 10)
+	if neg {
+		units.Neg(units)
+	}
+
+	return Amount{units: units, decimals: uint8(len(fracPart))}, nil
+}
+
+// MustParse is like Parse but panics if the string can not be parsed.
+func MustParse(s string) Amount {
+	a, err := Parse(s)
+	if err != nil {
+		panic(err)
+	}
+
+	return a
+}
+
+func (a Amount) int() *big.Int {
+	if a.units == nil {
+		return new(big.Int)
+	}
+
+	return a.units
+}
+
+// Units returns base units of the amount.
+func (a Amount) Units() *big.Int {
+	return new(big.Int).Set(a.int())
+}
+
+// Decimals returns the number of digits after the decimal point.
+func (a Amount) Decimals() uint8 {
+	return a.decimals
+}
+
+// Int returns the integer part of the amount, truncated toward zero.
+func (a Amount) Int() *big.Int {
+	return new(big.Int).Quo(a.int(), pow10(a.decimals))
+}
+
+// Rescale returns the amount with the given decimals,
+// extra digits are truncated toward zero when decimals decrease.
+func (a Amount) Rescale(decimals uint8) Amount {
+	units := a.Units()
+
+	switch {
+	case decimals > a.decimals:
+		units.Mul(units, pow10(decimals-a.decimals))
+	case decimals < a.decimals:
+		units.Quo(units, pow10(a.decimals-decimals))
+	}
+
+	return Amount{units: units, decimals: decimals}
+}
+
+// Add returns a + b.
+func (a Amount) Add(b Amount) Amount {
+	x, y := align(a, b)
+	return Amount{units: x.units.Add(x.units, y.units), decimals: x.decimals}
+}
+
+// Sub returns a - b.
+func (a Amount) Sub(b Amount) Amount {
+	x, y := align(a, b)
+	return Amount{units: x.units.Sub(x.units, y.units), decimals: x.decimals}
+}
+
+// Neg returns -a.
+func (a Amount) Neg() Amount {
+	return Amount{units: new(big.Int).Neg(a.int()), decimals: a.decimals}
+}
+
+// Cmp compares a and b and returns -1, 0 or +1.
+func (a Amount) Cmp(b Amount) int {
+	x, y := align(a, b)
+	return x.units.Cmp(y.units)
+}
+
+// Sign returns -1, 0 or +1 according to the sign of the amount.
+func (a Amount) Sign() int {
+	return a.int().Sign()
+}
+
+// IsZero reports whether the amount is 0.
+func (a Amount) IsZero() bool {
+	return a.Sign() == 0
+}
+
+// String returns the amount in decimal notation with all its decimals.
+func (a Amount) String() string {
+	units := a.int()
+	digits := new(big.Int).Abs(units).String()
+
+	sign := ""
+	if units.Sign() < 0 {
+		sign = "-"
+	}
+
+	if a.decimals == 0 {
+		return sign + digits
+	}
+
+	if len(digits) <= int(a.decimals) {
+		digits = strings.Repeat("0", int(a.decimals)-len(digits)+1) + digits
+	}
+
+	point := len(digits) - int(a.decimals)
+	return sign + digits[:point] + "." + digits[point:]
+}
+
+// MarshalJSON encodes the amount as a decimal string, so that no precision is lost by JSON numbers.
+func (a Amount) MarshalJSON() ([]byte, error) {
+	return json.Marshal(a.String())
+}
+
+// UnmarshalJSON accepts both decimal strings and numbers.
+func (a *Amount) UnmarshalJSON(data []byte) error {
+	str := string(data)
+	if str == "null" {
+		return nil
+	}
+
+	if strings.HasPrefix(str, `"`) {
+		if err := json.Unmarshal(data, &str); err != nil {
+			return err
+		}
+	}
+
+	parsed, err := Parse(str)
+	if err != nil {
+		return err
+	}
+
+	*a = parsed
+	return nil
+}
+
+// Scan implements sql.Scanner for decimal columns.
+func (a *Amount) Scan(src interface{}) error {
+	var (
+		parsed Amount
+		err    error
+	)
+
+	switch v := src.(type) {
+	case nil:
+		parsed = Zero
+	case []byte:
+		parsed, err = Parse(string(v))
+	case string:
+		parsed, err = Parse(v)
+	case int64:
+		parsed = NewFromInt64(v, 0)
+	case float64:
+		// Values computed by SQLite itself may come back as REAL.
+		parsed, err = Parse(strconv.FormatFloat(v, 'f', -1, 64))
+	default:
+		err = fmt.Errorf("can not scan %T into amount", src)
+	}
+
+	if err != nil {
+		return err
+	}
+
+	*a = parsed
+	return nil
+}
+
+// Value implements driver.Valuer, the amount is passed as a decimal string.
+func (a Amount) Value() (driver.Value, error) {
+	return a.String(), nil
+}
+
+// align returns copies of a and b with the same decimals.
+func align(a, b Amount) (Amount, Amount) {
+	decimals := a.decimals
+	if b.decimals > decimals {
+		decimals = b.decimals
+	}
+
+	return a.Rescale(decimals), b.Rescale(decimals)
+}
+
+func pow10(n uint8) *big.Int {
+	return new(big.Int).Exp(big.NewInt(10), big.NewInt(int64(n)), nil)
+}
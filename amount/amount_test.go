@@ -0,0 +1,129 @@
+package amount
+
+import (
+	"encoding/json"
+	"math/big"
+	"testing"
+)
+
+func TestParse(t *testing.T) {
+	cases := []struct {
+		str      string
+		units    string
+		decimals uint8
+		text     string
+	}{
+		{"0", "0", 0, "0"},
+		{"100", "100", 0, "100"},
+		{"100.00000000", "10000000000", 8, "100.00000000"},
+		{"0.00000001", "1", 8, "0.00000001"},
+		{"-1.5", "-15", 1, "-1.5"},
+		{".5", "5", 1, "0.5"},
+		{"+2", "2", 0, "2"},
+		{"123456789012345678901234567.12345678", "12345678901234567890123456712345678", 8, "123456789012345678901234567.12345678"},
+	}
+
+	for _, c := range cases {
+		a, err := Parse(c.str)
+		if err != nil {
+			t.Fatalf("Parse(%q) error: %v", c.str, err)
+		}
+		if a.Units().String() != c.units || a.Decimals() != c.decimals {
+			t.Errorf("Parse(%q) = (%s, %d), expected (%s, %d)", c.str, a.Units(), a.Decimals(), c.units, c.decimals)
+		}
+		if a.String() != c.text {
+			t.Errorf("Parse(%q).String() = %s, expected %s", c.str, a.String(), c.text)
+		}
+	}
+
+	for _, str := range []string{"", ".", "-", "1e8", "1.2.3", "0x10", " 1", "NaN"} {
+		if _, err := Parse(str); err == nil {
+			t.Errorf("Parse(%q) succeeds", str)
+		}
+	}
+}
+
+func TestArithmetic(t *testing.T) {
+	a := MustParse("0.1")
+	b := MustParse("0.2")
+
+	if sum := a.Add(b); sum.String() != "0.3" || sum.Cmp(MustParse("0.30000000")) != 0 {
+		t.Errorf("0.1 + 0.2 = %s", sum)
+	}
+
+	// 8-decimal tokens with large supply keep every unit.
+	supply := New(new(big.Int).Exp(big.NewInt(10), big.NewInt(26), nil), 8)
+	one := NewFromInt64(1, 8)
+	if diff := supply.Add(one).Sub(supply); diff.Cmp(one) != 0 {
+		t.Errorf("(supply + 1 unit) - supply = %s", diff)
+	}
+
+	if c := MustParse("1").Cmp(MustParse("0.99999999")); c != 1 {
+		t.Errorf("1 cmp 0.99999999 = %d", c)
+	}
+	if s := MustParse("-0.5").Neg().Sign(); s != 1 {
+		t.Errorf("-(-0.5) sign = %d", s)
+	}
+	if !Zero.Add(Zero).IsZero() || Zero.String() != "0" {
+		t.Error("zero value is not 0")
+	}
+	if i := MustParse("-12.9").Int(); i.Int64() != -12 {
+		t.Errorf("Int(-12.9) = %s", i)
+	}
+	if r := MustParse("1.23456789").Rescale(2); r.String() != "1.23" {
+		t.Errorf("Rescale(1.23456789, 2) = %s", r)
+	}
+	if r := MustParse("5").Rescale(8); r.String() != "5.00000000" {
+		t.Errorf("Rescale(5, 8) = %s", r)
+	}
+}
+
+func TestJSON(t *testing.T) {
+	var v struct {
+		A Amount `json:"a"`
+		B Amount `json:"b"`
+	}
+
+	if err := json.Unmarshal([]byte(`{"a": "12.5", "b": 0.00000001}`), &v); err != nil {
+		t.Fatal(err)
+	}
+	if v.A.String() != "12.5" || v.B.String() != "0.00000001" {
+		t.Fatalf("unmarshaled (%s, %s)", v.A, v.B)
+	}
+
+	data, err := json.Marshal(v)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(data) != `{"a":"12.5","b":"0.00000001"}` {
+		t.Fatalf("marshaled %s", data)
+	}
+}
+
+func TestScan(t *testing.T) {
+	cases := []struct {
+		src  interface{}
+		text string
+	}{
+		{[]byte("100.00000000"), "100.00000000"},
+		{"0.5", "0.5"},
+		{int64(7), "7"},
+		{float64(0.25), "0.25"},
+		{nil, "0"},
+	}
+
+	for _, c := range cases {
+		var a Amount
+		if err := a.Scan(c.src); err != nil {
+			t.Fatalf("Scan(%v) error: %v", c.src, err)
+		}
+		if a.String() != c.text {
+			t.Errorf("Scan(%v) = %s, expected %s", c.src, a, c.text)
+		}
+	}
+
+	var a Amount
+	if err := a.Scan(true); err == nil {
+		t.Error("Scan(bool) succeeds")
+	}
+}
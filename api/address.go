@@ -0,0 +1,177 @@
+package api
+
+import (
+	"net/http"
+	"squirrel/asset"
+	"squirrel/db"
+	"squirrel/util"
+)
+
+const (
+	defaultPageSize = 20
+	maxPageSize     = 100
+)
+
+type addressResponse struct {
+	Address             string          `json:"address"`
+	CreatedAt           uint64          `json:"created_at"`
+	LastTransactionTime uint64          `json:"last_transaction_time"`
+	TransAsset          uint64          `json:"trans_asset"`
+	TransNep5           uint64          `json:"trans_nep5"`
+	Balances            []balanceResult `json:"balances"`
+	// Contract is the verification contract, omitted until the address witnesses a transaction.
+	Contract *contractResult `json:"contract,omitempty"`
+}
+
+type contractResult struct {
+	Type       string   `json:"type"`
+	Threshold  int      `json:"threshold"`
+	PublicKeys []string `json:"public_keys"`
+	Script     string   `json:"script"`
+}
+
+type balanceResult struct {
+	AssetID             string `json:"asset_id"`
+	AssetType           string `json:"asset_type"`
+	Balance             string `json:"balance"`
+	Transactions        uint64 `json:"transactions"`
+	LastTransactionTime uint64 `json:"last_transaction_time"`
+}
+
+type addrTxsResponse struct {
+	Page  uint           `json:"page"`
+	Size  uint           `json:"size"`
+	Total uint64         `json:"total"`
+	Txs   []addrTxResult `json:"txs"`
+}
+
+type addrTxResult struct {
+	TxID      string `json:"txid"`
+	BlockTime uint64 `json:"block_time"`
+	AssetType string `json:"asset_type"`
+}
+
+// handleAddress serves GET /address/{address} and /address/{address}/txs.
+func handleAddress(w http.ResponseWriter, r *http.Request) {
+	if !allowGet(w, r) {
+		return
+	}
+
+	params := getPathParams(r, "/address/")
+	if len(params) == 0 || len(params) > 2 ||
+		(len(params) == 2 && params[1] != "txs") {
+		writeError(w, http.StatusNotFound, "usage: /address/{address} or /address/{address}/txs?page=1&size=20")
+		return
+	}
+
+	address := params[0]
+	if !util.AddressValid(address) {
+		writeError(w, http.StatusBadRequest, "invalid address")
+		return
+	}
+
+	if len(params) == 2 {
+		handleAddrTxs(w, r, address)
+		return
+	}
+
+	a, err := db.GetAddress(address)
+	if err != nil {
+		writeDbError(w, err)
+		return
+	}
+	if a == nil {
+		writeError(w, http.StatusNotFound, "address not found")
+		return
+	}
+
+	addrAssets, err := db.GetAddrAssets(address)
+	if err != nil {
+		writeDbError(w, err)
+		return
+	}
+
+	resp := addressResponse{
+		Address:             a.Address,
+		CreatedAt:           a.CreatedAt,
+		LastTransactionTime: a.LastTransactionTime,
+		TransAsset:          a.TransAsset,
+		TransNep5:           a.TransNep5,
+		Balances:            []balanceResult{},
+	}
+
+	for _, addrAsset := range addrAssets {
+		// Global asset ids are 0x prefixed 32 bytes hashes, nep5 asset ids are 20 bytes script hashes.
+		assetType := asset.ASSET
+		if len(addrAsset.AssetID) == 40 {
+			assetType = asset.NEP5
+		}
+
+		resp.Balances = append(resp.Balances, balanceResult{
+			AssetID:             addrAsset.AssetID,
+			AssetType:           assetType,
+			Balance:             formatAmount(addrAsset.Balance),
+			Transactions:        addrAsset.Transactions,
+			LastTransactionTime: addrAsset.LastTransactionTime,
+		})
+	}
+
+	contract, err := db.GetAddrContract(address)
+	if err != nil {
+		writeDbError(w, err)
+		return
+	}
+	if contract != nil {
+		resp.Contract = &contractResult{
+			Type:       contract.Type,
+			Threshold:  contract.Threshold,
+			PublicKeys: contract.PublicKeys,
+			Script:     contract.Script,
+		}
+	}
+
+	writeJSON(w, http.StatusOK, resp)
+}
+
+func handleAddrTxs(w http.ResponseWriter, r *http.Request, address string) {
+	page, ok := getUintQuery(r, "page", 1)
+	if !ok || page == 0 {
+		writeError(w, http.StatusBadRequest, "page must be a positive integer")
+		return
+	}
+
+	size, ok := getUintQuery(r, "size", defaultPageSize)
+	if !ok || size == 0 || size > maxPageSize {
+		writeError(w, http.StatusBadRequest, "size must be between 1 and 100")
+		return
+	}
+
+	total, err := db.CountAddrTxs(address)
+	if err != nil {
+		writeDbError(w, err)
+		return
+	}
+
+	addrTxs, err := db.GetAddrTxs(address, (page-1)*size, size)
+	if err != nil {
+		writeDbError(w, err)
+		return
+	}
+
+	resp := addrTxsResponse{
+		Page:  page,
+		Size:  size,
+		Total: total,
+		Txs:   []addrTxResult{},
+	}
+
+	for _, t := range addrTxs {
+		resp.Txs = append(resp.Txs, addrTxResult{
+			TxID:      t.TxID,
+			BlockTime: t.BlockTime,
+			AssetType: t.AssetType,
+		})
+	}
+
+	writeJSON(w, http.StatusOK, resp)
+}
@@ -0,0 +1,84 @@
+package api
+
+import (
+	"net/http"
+	"squirrel/block"
+	"squirrel/db"
+	"strconv"
+)
+
+type blockResponse struct {
+	Hash              string       `json:"hash"`
+	Size              int          `json:"size"`
+	Version           uint         `json:"version"`
+	PreviousBlockHash string       `json:"previousblockhash"`
+	MerkleRoot        string       `json:"merkleroot"`
+	Time              uint64       `json:"time"`
+	Index             uint         `json:"index"`
+	Nonce             string       `json:"nonce"`
+	NextConsensus     string       `json:"nextconsensus"`
+	Script            scriptResult `json:"script"`
+	NextBlockHash     string       `json:"nextblockhash"`
+	Tx                []string     `json:"tx"`
+}
+
+type scriptResult struct {
+	Invocation   string `json:"invocation"`
+	Verification string `json:"verification"`
+}
+
+// handleBlock serves GET /block/{index or hash}.
+func handleBlock(w http.ResponseWriter, r *http.Request) {
+	if !allowGet(w, r) {
+		return
+	}
+
+	params := getPathParams(r, "/block/")
+	if len(params) != 1 {
+		writeError(w, http.StatusNotFound, "usage: /block/{index or hash}")
+		return
+	}
+
+	var b *block.Block
+	var err error
+
+	// Hashes without 0x prefix may consist of digits only, they are told apart by length.
+	if index, parseErr := strconv.ParseUint(params[0], 10, 32); parseErr == nil && len(params[0]) < 64 {
+		b, err = db.GetBlock(uint(index))
+	} else {
+		b, err = db.GetBlockByHash(normalizeHash(params[0]))
+	}
+
+	if err != nil {
+		writeDbError(w, err)
+		return
+	}
+	if b == nil {
+		writeError(w, http.StatusNotFound, "block not found")
+		return
+	}
+
+	txIDs, err := db.GetBlockTxIDs(b.Index)
+	if err != nil {
+		writeDbError(w, err)
+		return
+	}
+
+	writeJSON(w, http.StatusOK, blockResponse{
+		Hash:              b.Hash,
+		Size:              b.Size,
+		Version:           b.Version,
+		PreviousBlockHash: b.PreviousBlockHash,
+		MerkleRoot:        b.MerkleRoot,
+		Time:              b.Time,
+		Index:             b.Index,
+		Nonce:             b.Nonce,
+		NextConsensus:     b.NextConsensus,
+		Script: scriptResult{
+			Invocation:   b.ScriptInvocation,
+			Verification: b.ScriptVerification,
+		},
+		NextBlockHash: b.NextBlockhash,
+		Tx:            txIDs,
+	})
+}
@@ -0,0 +1,64 @@
+package api
+
+import (
+	"context"
+	"net/http"
+	"squirrel/config"
+	"squirrel/db"
+	"squirrel/rpc"
+	"squirrel/tasks"
+	"time"
+)
+
+// pingTimeout limits the time spent checking the database in health checks.
+const pingTimeout = 3 * time.Second
+
+// healthResponse is the body of /healthz and /readyz, errors are absent if the check passes.
+type healthResponse struct {
+	Status string   `json:"status"`
+	Errors []string `json:"errors,omitempty"`
+}
+
+// handleHealthz reports if the process is alive and the database is reachable.
+func handleHealthz(w http.ResponseWriter, r *http.Request) {
+	if !allowGet(w, r) {
+		return
+	}
+
+	writeHealth(w, checkDb())
+}
+
+// handleReadyz reports if the indexed data is caught up with the chain.
+func handleReadyz(w http.ResponseWriter, r *http.Request) {
+	if !allowGet(w, r) {
+		return
+	}
+
+	errs := checkDb()
+	if rpc.AvailableServers() == 0 {
+		errs = append(errs, "no rpc server available")
+	}
+	errs = append(errs, tasks.Lagging(config.GetReadyBlockLag(), config.GetReadyTaskLag())...)
+
+	writeHealth(w, errs)
+}
+
+func checkDb() []string {
+	ctx, cancel := context.WithTimeout(context.Background(), pingTimeout)
+	defer cancel()
+
+	if err := db.Ping(ctx); err != nil {
+		return []string{"database unreachable: " + err.Error()}
+	}
+
+	return nil
+}
+
+func writeHealth(w http.ResponseWriter, errs []string) {
+	if len(errs) > 0 {
+		writeJSON(w, http.StatusServiceUnavailable, healthResponse{Status: "unavailable", Errors: errs})
+		return
+	}
+
+	writeJSON(w, http.StatusOK, healthResponse{Status: "ok"})
+}
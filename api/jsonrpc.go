@@ -0,0 +1,159 @@
+package api
+
+import (
+	"encoding/json"
+	"net/http"
+	"squirrel/amount"
+	"squirrel/log"
+	"squirrel/rpc"
+	"squirrel/util"
+	"strings"
+)
+
+// Error codes defined by JSON-RPC 2.0.
+const (
+	rpcParseError     = -32700
+	rpcInvalidRequest = -32600
+	rpcMethodNotFound = -32601
+	rpcInvalidParams  = -32602
+	rpcInternalError  = -32603
+)
+
+type rpcRequest struct {
+	JSONRPC string            `json:"jsonrpc"`
+	Method  string            `json:"method"`
+	Params  []json.RawMessage `json:"params"`
+	ID      int               `json:"id"`
+}
+
+type rpcResponse struct {
+	rpc.JSONRPCResponse
+	Result interface{} `json:"result,omitempty"`
+	Error  *rpcError   `json:"error,omitempty"`
+}
+
+type rpcError struct {
+	Code    int    `json:"code"`
+	Message string `json:"message"`
+}
+
+type rpcMethod func(params []json.RawMessage) (interface{}, *rpcError)
+
+// rpcMethods are the NEO node methods answered from the index.
+var rpcMethods = map[string]rpcMethod{
+	"getbalance":        getBalance,
+	"getunspents":       getUnspents,
+	"getclaimable":      getClaimable,
+	"getunclaimed":      getUnclaimed,
+	"getnep5balances":   getNep5Balances,
+	"getnep5transfers":  getNep5Transfers,
+	"getrawtransaction": getRawTransaction,
+}
+
+// handleJSONRPC serves NEO-node-compatible JSON-RPC requests on POST /.
+func handleJSONRPC(w http.ResponseWriter, r *http.Request) {
+	if r.URL.Path != "/" {
+		writeError(w, http.StatusNotFound, "not found")
+		return
+	}
+	if r.Method != http.MethodPost {
+		writeError(w, http.StatusMethodNotAllowed, "only POST method is allowed")
+		return
+	}
+
+	req := rpcRequest{}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		writeRPCError(w, 0, rpcParseError, "parse error")
+		return
+	}
+
+	method, ok := rpcMethods[req.Method]
+	if !ok {
+		writeRPCError(w, req.ID, rpcMethodNotFound, "method not found")
+		return
+	}
+
+	result, rpcErr := method(req.Params)
+	if rpcErr != nil {
+		writeRPCError(w, req.ID, rpcErr.Code, rpcErr.Message)
+		return
+	}
+
+	writeJSON(w, http.StatusOK, rpcResponse{
+		JSONRPCResponse: rpc.JSONRPCResponse{JSONRPC: "2.0", ID: req.ID},
+		Result:          result,
+	})
+}
+
+func writeRPCError(w http.ResponseWriter, id int, code int, msg string) {
+	writeJSON(w, http.StatusOK, rpcResponse{
+		JSONRPCResponse: rpc.JSONRPCResponse{JSONRPC: "2.0", ID: id},
+		Error:           &rpcError{Code: code, Message: msg},
+	})
+}
+
+func invalidParams(msg string) *rpcError {
+	return &rpcError{Code: rpcInvalidParams, Message: msg}
+}
+
+func internalError(err error) *rpcError {
+	log.Error.Println(err)
+	return &rpcError{Code: rpcInternalError, Message: "failed to query database"}
+}
+
+// stringParam returns the string parameter at index i.
+func stringParam(params []json.RawMessage, i int) (string, bool) {
+	if i >= len(params) {
+		return "", false
+	}
+
+	var s string
+	if err := json.Unmarshal(params[i], &s); err != nil {
+		return "", false
+	}
+
+	return s, true
+}
+
+// uintParam returns the unsigned integer parameter at index i, or defaultValue if absent.
+func uintParam(params []json.RawMessage, i int, defaultValue uint64) (uint64, bool) {
+	if i >= len(params) {
+		return defaultValue, true
+	}
+
+	var v uint64
+	if err := json.Unmarshal(params[i], &v); err != nil {
+		return 0, false
+	}
+
+	return v, true
+}
+
+// addressParam returns the valid address parameter at index i.
+func addressParam(params []json.RawMessage, i int) (string, *rpcError) {
+	address, ok := stringParam(params, i)
+	if !ok {
+		return "", invalidParams("address is required")
+	}
+	if !util.AddressValid(address) {
+		return "", invalidParams("invalid address")
+	}
+
+	return address, nil
+}
+
+// normalizeAssetID returns asset id in the form stored in db:
+// 0x prefixed for global assets, no prefix for nep5 assets.
+func normalizeAssetID(assetID string) string {
+	assetID = strings.ToLower(strings.TrimPrefix(assetID, "0x"))
+	if len(assetID) == 64 {
+		return "0x" + assetID
+	}
+
+	return assetID
+}
+
+// numberAmount returns the amount as a json number.
+func numberAmount(v amount.Amount) json.Number {
+	return json.Number(formatAmount(v))
+}
@@ -0,0 +1,336 @@
+package api
+
+import (
+	"encoding/json"
+	"squirrel/amount"
+	"squirrel/asset"
+	"squirrel/db"
+	"squirrel/gas"
+	"squirrel/rpc"
+	"strings"
+)
+
+type getBalanceResult struct {
+	Balance   string `json:"balance"`
+	Confirmed string `json:"confirmed"`
+}
+
+type unspentsResult struct {
+	Balance []unspentAsset `json:"balance"`
+	Address string         `json:"address"`
+}
+
+type unspentAsset struct {
+	Unspent     []unspentOutput `json:"unspent"`
+	AssetHash   string          `json:"asset_hash"`
+	Asset       string          `json:"asset"`
+	AssetSymbol string          `json:"asset_symbol"`
+	Amount      json.Number     `json:"amount"`
+}
+
+type unspentOutput struct {
+	TxID  string      `json:"txid"`
+	N     uint16      `json:"n"`
+	Value json.Number `json:"value"`
+}
+
+type claimableResult struct {
+	Claimable []claimableOutput `json:"claimable"`
+	Address   string            `json:"address"`
+	Unclaimed json.Number       `json:"unclaimed"`
+}
+
+type claimableOutput struct {
+	TxID        string      `json:"txid"`
+	N           uint16      `json:"n"`
+	Value       json.Number `json:"value"`
+	StartHeight uint        `json:"start_height"`
+	EndHeight   uint        `json:"end_height"`
+	Generated   json.Number `json:"generated"`
+	SysFee      json.Number `json:"sys_fee"`
+	Unclaimed   json.Number `json:"unclaimed"`
+}
+
+type unclaimedResult struct {
+	Available   json.Number `json:"available"`
+	Unavailable json.Number `json:"unavailable"`
+	Unclaimed   json.Number `json:"unclaimed"`
+}
+
+type rawTransactionResult struct {
+	rpc.RawTx
+	BlockHash     string `json:"blockhash"`
+	Confirmations int    `json:"confirmations"`
+	BlockTime     uint64 `json:"blocktime"`
+}
+
+// getBalance: [asset_id, address] returns global asset balance of the address.
+func getBalance(params []json.RawMessage) (interface{}, *rpcError) {
+	assetID, ok := stringParam(params, 0)
+	if !ok {
+		return nil, invalidParams("asset_id is required")
+	}
+	address, rpcErr := addressParam(params, 1)
+	if rpcErr != nil {
+		return nil, rpcErr
+	}
+
+	assetID = normalizeAssetID(assetID)
+	addrAssets, err := db.GetAddrAssets(address)
+	if err != nil {
+		return nil, internalError(err)
+	}
+
+	balance := amount.Zero
+	for _, addrAsset := range addrAssets {
+		if addrAsset.AssetID == assetID {
+			balance = addrAsset.Balance
+			break
+		}
+	}
+
+	return getBalanceResult{
+		Balance:   formatAmount(balance),
+		Confirmed: formatAmount(balance),
+	}, nil
+}
+
+// getUnspents: [address] returns unspent outputs of the address grouped by asset.
+func getUnspents(params []json.RawMessage) (interface{}, *rpcError) {
+	address, rpcErr := addressParam(params, 0)
+	if rpcErr != nil {
+		return nil, rpcErr
+	}
+
+	utxos, err := db.GetUnspentUTXOs(address, "")
+	if err != nil {
+		return nil, internalError(err)
+	}
+
+	result := unspentsResult{
+		Balance: []unspentAsset{},
+		Address: address,
+	}
+
+	assetIndex := make(map[string]int)
+	amounts := []amount.Amount{}
+
+	for _, utxo := range utxos {
+		i, ok := assetIndex[utxo.AssetID]
+		if !ok {
+			name, err := getAssetName(utxo.AssetID)
+			if err != nil {
+				return nil, internalError(err)
+			}
+
+			i = len(result.Balance)
+			assetIndex[utxo.AssetID] = i
+			amounts = append(amounts, amount.Zero)
+			result.Balance = append(result.Balance, unspentAsset{
+				Unspent:     []unspentOutput{},
+				AssetHash:   strings.TrimPrefix(utxo.AssetID, "0x"),
+				Asset:       name,
+				AssetSymbol: name,
+			})
+		}
+
+		amounts[i] = amounts[i].Add(utxo.Value)
+		result.Balance[i].Unspent = append(result.Balance[i].Unspent, unspentOutput{
+			TxID:  strings.TrimPrefix(utxo.TxID, "0x"),
+			N:     utxo.N,
+			Value: numberAmount(utxo.Value),
+		})
+	}
+
+	for i, value := range amounts {
+		result.Balance[i].Amount = numberAmount(value)
+	}
+
+	return result, nil
+}
+
+// getClaimable: [address] returns spent and unclaimed NEO outputs with their GAS bonus.
+func getClaimable(params []json.RawMessage) (interface{}, *rpcError) {
+	address, rpcErr := addressParam(params, 0)
+	if rpcErr != nil {
+		return nil, rpcErr
+	}
+
+	utxos, err := db.GetClaimableUTXOs(address)
+	if err != nil {
+		return nil, internalError(err)
+	}
+
+	result := claimableResult{
+		Claimable: []claimableOutput{},
+		Address:   address,
+	}
+
+	total := amount.Zero
+	sysFeeAmount := cachedSysFeeAmount()
+
+	for _, utxo := range utxos {
+		bonus, err := gas.Calculate(utxo.Value, utxo.BlockIndex, utxo.SpentBlockIndex, sysFeeAmount)
+		if err != nil {
+			return nil, internalError(err)
+		}
+
+		total = total.Add(bonus.Unclaimed)
+		result.Claimable = append(result.Claimable, claimableOutput{
+			TxID:        strings.TrimPrefix(utxo.TxID, "0x"),
+			N:           utxo.N,
+			Value:       numberAmount(utxo.Value),
+			StartHeight: utxo.BlockIndex,
+			EndHeight:   utxo.SpentBlockIndex,
+			Generated:   numberAmount(bonus.Generated),
+			SysFee:      numberAmount(bonus.SysFee),
+			Unclaimed:   numberAmount(bonus.Unclaimed),
+		})
+	}
+
+	result.Unclaimed = numberAmount(total)
+
+	return result, nil
+}
+
+// getUnclaimed: [address] returns claimable GAS and GAS still generating by unspent NEO.
+func getUnclaimed(params []json.RawMessage) (interface{}, *rpcError) {
+	address, rpcErr := addressParam(params, 0)
+	if rpcErr != nil {
+		return nil, rpcErr
+	}
+
+	// Unspent NEO keeps generating GAS till the next block.
+	unclaimed, err := db.GetUnclaimedGas(address, uint(db.GetLastHeight()+1))
+	if err != nil {
+		return nil, internalError(err)
+	}
+
+	return unclaimedResult{
+		Available:   numberAmount(unclaimed.Available),
+		Unavailable: numberAmount(unclaimed.Unavailable),
+		Unclaimed:   numberAmount(unclaimed.Available.Add(unclaimed.Unavailable)),
+	}, nil
+}
+
+// getRawTransaction: [txid, verbose] returns the verbose transaction.
+// Only the verbose form is supported since raw transactions are not stored.
+func getRawTransaction(params []json.RawMessage) (interface{}, *rpcError) {
+	txID, ok := stringParam(params, 0)
+	if !ok {
+		return nil, invalidParams("txid is required")
+	}
+	verbose, ok := uintParam(params, 1, 0)
+	if !ok || verbose == 0 {
+		return nil, invalidParams("only verbose mode is supported")
+	}
+
+	txID = normalizeHash(txID)
+	t, err := db.GetTx(txID)
+	if err != nil {
+		return nil, internalError(err)
+	}
+	if t == nil {
+		return nil, &rpcError{Code: -100, Message: "Unknown transaction"}
+	}
+
+	b, err := db.GetBlock(t.BlockIndex)
+	if err != nil {
+		return nil, internalError(err)
+	}
+
+	result := rawTransactionResult{
+		RawTx: rpc.RawTx{
+			TxID:       t.TxID,
+			Size:       t.Size,
+			Type:       t.Type,
+			Version:    t.Version,
+			Attributes: []rpc.RawTxAttribute{},
+			Vin:        []rpc.RawTxVin{},
+			Vout:       []rpc.RawTxVout{},
+			SysFee:     t.SysFee,
+			NetFee:     t.NetFee,
+			Scripts:    []rpc.RawTxScript{},
+		},
+		Confirmations: db.GetLastHeight() - int(t.BlockIndex) + 1,
+		BlockTime:     t.BlockTime,
+	}
+
+	if b != nil {
+		result.BlockHash = b.Hash
+	}
+	if t.Type == "InvocationTransaction" {
+		result.Script = t.Script
+		result.Gas = t.Gas
+	}
+	if t.Type == "MinerTransaction" {
+		result.Nonce = t.Nonce
+	}
+
+	attrs, err := db.GetTxAttrs(txID)
+	if err != nil {
+		return nil, internalError(err)
+	}
+	for _, attr := range attrs {
+		result.Attributes = append(result.Attributes, rpc.RawTxAttribute{Usage: attr.Usage, Data: attr.Data})
+	}
+
+	vinMap, voutMap, err := db.GetVinVout([]string{txID})
+	if err != nil {
+		return nil, internalError(err)
+	}
+	for _, vin := range vinMap[txID] {
+		result.Vin = append(result.Vin, rpc.RawTxVin{TxID: vin.TxID, Vout: vin.Vout})
+	}
+	for _, vout := range voutMap[txID] {
+		result.Vout = append(result.Vout, rpc.RawTxVout{
+			N:       vout.N,
+			Asset:   vout.AssetID,
+			Value:   vout.Value,
+			Address: vout.Address,
+		})
+	}
+
+	scripts, err := db.GetTxScripts(txID)
+	if err != nil {
+		return nil, internalError(err)
+	}
+	for _, script := range scripts {
+		result.Scripts = append(result.Scripts, rpc.RawTxScript{
+			Invocation:   script.Invocation,
+			Verification: script.Verification,
+		})
+	}
+
+	return result, nil
+}
+
+func getAssetName(assetID string) (string, error) {
+	switch assetID {
+	case asset.NEOAssetID:
+		return asset.NEO, nil
+	case asset.GASAssetID:
+		return asset.GAS, nil
+	}
+
+	return db.GetAssetName(assetID)
+}
+
+// cachedSysFeeAmount returns a SysFeeAmountFunc caching results within a single request.
+func cachedSysFeeAmount() gas.SysFeeAmountFunc {
+	sysFees := make(map[uint]int64)
+
+	return func(height uint) (int64, error) {
+		if value, ok := sysFees[height]; ok {
+			return value, nil
+		}
+
+		value, err := db.GetSysFeeAmount(height)
+		if err != nil {
+			return 0, err
+		}
+
+		sysFees[height] = value
+		return value, nil
+	}
+}
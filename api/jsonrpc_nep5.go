@@ -0,0 +1,137 @@
+package api
+
+import (
+	"encoding/json"
+	"squirrel/amount"
+	"squirrel/db"
+	"squirrel/nep5"
+	"time"
+)
+
+// maxNep5Transfers limits transfers returned in each direction.
+const maxNep5Transfers = 1000
+
+type nep5BalancesResult struct {
+	Balance []nep5Balance `json:"balance"`
+	Address string        `json:"address"`
+}
+
+type nep5Balance struct {
+	AssetHash        string `json:"asset_hash"`
+	Amount           string `json:"amount"`
+	LastUpdatedBlock uint   `json:"last_updated_block"`
+}
+
+type nep5TransfersResult struct {
+	Sent     []nep5Transfer `json:"sent"`
+	Received []nep5Transfer `json:"received"`
+	Address  string         `json:"address"`
+}
+
+type nep5Transfer struct {
+	Timestamp           uint64 `json:"timestamp"`
+	AssetHash           string `json:"asset_hash"`
+	TransferAddress     string `json:"transfer_address"`
+	Amount              string `json:"amount"`
+	BlockIndex          uint   `json:"block_index"`
+	TransferNotifyIndex uint   `json:"transfer_notify_index"`
+	TxHash              string `json:"tx_hash"`
+}
+
+// getNep5Balances: [address] returns nep5 balances of the address in integer units.
+func getNep5Balances(params []json.RawMessage) (interface{}, *rpcError) {
+	address, rpcErr := addressParam(params, 0)
+	if rpcErr != nil {
+		return nil, rpcErr
+	}
+
+	addrAssets, err := db.GetAddrAssets(address)
+	if err != nil {
+		return nil, internalError(err)
+	}
+
+	lastBlocks, err := db.GetAddrNep5LastBlocks(address)
+	if err != nil {
+		return nil, internalError(err)
+	}
+
+	decimals := db.GetNep5AssetDecimals()
+	result := nep5BalancesResult{
+		Balance: []nep5Balance{},
+		Address: address,
+	}
+
+	for _, addrAsset := range addrAssets {
+		dec, ok := decimals[addrAsset.AssetID]
+		if !ok {
+			// Global assets.
+			continue
+		}
+
+		result.Balance = append(result.Balance, nep5Balance{
+			AssetHash:        "0x" + addrAsset.AssetID,
+			Amount:           toIntegerAmount(addrAsset.Balance, dec),
+			LastUpdatedBlock: lastBlocks[addrAsset.AssetID],
+		})
+	}
+
+	return result, nil
+}
+
+// getNep5Transfers: [address, start_time, end_time] returns nep5 transfers of the address,
+// from the last 7 days by default.
+func getNep5Transfers(params []json.RawMessage) (interface{}, *rpcError) {
+	address, rpcErr := addressParam(params, 0)
+	if rpcErr != nil {
+		return nil, rpcErr
+	}
+
+	now := uint64(time.Now().Unix())
+	startTime, ok := uintParam(params, 1, now-7*24*3600)
+	if !ok {
+		return nil, invalidParams("invalid start time")
+	}
+	endTime, ok := uintParam(params, 2, now)
+	if !ok || endTime < startTime {
+		return nil, invalidParams("invalid end time")
+	}
+
+	decimals := db.GetNep5AssetDecimals()
+	result := nep5TransfersResult{Address: address}
+
+	sent, err := db.GetAddrNep5Transfers(address, true, startTime, endTime, maxNep5Transfers)
+	if err != nil {
+		return nil, internalError(err)
+	}
+	result.Sent = toNep5Transfers(sent, decimals, func(t *nep5.Transaction) string { return t.To })
+
+	received, err := db.GetAddrNep5Transfers(address, false, startTime, endTime, maxNep5Transfers)
+	if err != nil {
+		return nil, internalError(err)
+	}
+	result.Received = toNep5Transfers(received, decimals, func(t *nep5.Transaction) string { return t.From })
+
+	return result, nil
+}
+
+func toNep5Transfers(txs []*nep5.Transaction, decimals map[string]uint8, counterparty func(*nep5.Transaction) string) []nep5Transfer {
+	transfers := []nep5Transfer{}
+
+	for _, t := range txs {
+		transfers = append(transfers, nep5Transfer{
+			Timestamp:       t.BlockTime,
+			AssetHash:       "0x" + t.AssetID,
+			TransferAddress: counterparty(t),
+			Amount:          toIntegerAmount(t.Value, decimals[t.AssetID]),
+			BlockIndex:      t.BlockIndex,
+			TxHash:          t.TxID,
+		})
+	}
+
+	return transfers
+}
+
+// toIntegerAmount converts decimal amount stored in db to integer units of the nep5 asset.
+func toIntegerAmount(v amount.Amount, decimals uint8) string {
+	return v.Rescale(decimals).Units().String()
+}
@@ -0,0 +1,361 @@
+package api
+
+import (
+	"bytes"
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"squirrel/addr"
+	"squirrel/amount"
+	"squirrel/asset"
+	"squirrel/block"
+	"squirrel/db"
+	"squirrel/nep5"
+	"squirrel/tx"
+	"strings"
+	"testing"
+)
+
+const testToken = "ecc6b20d3ccac1ee9ef109af5a7cdb85706b1df9"
+
+var testInvokeTxID = fmt.Sprintf("0x%064x", 0xb2)
+
+type rpcTestResponse struct {
+	ID     int             `json:"id"`
+	Result json.RawMessage `json:"result"`
+	Error  *rpcError       `json:"error"`
+}
+
+// addTestNep5 stores block 2, where token X with 2 decimals is registered with 1000 tokens of address A
+// and 100 of them are sent to address B.
+func addTestNep5(t *testing.T) {
+	invoke := &tx.Transaction{
+		BlockIndex: 2,
+		BlockTime:  testStart + 30,
+		TxID:       testInvokeTxID,
+		Type:       "InvocationTransaction",
+		SysFee:     amount.Zero,
+		NetFee:     amount.Zero,
+		Gas:        amount.Zero,
+	}
+	blocks := []*block.Block{{Hash: testBlockHash(2), Index: 2, Time: invoke.BlockTime, Nonce: "0", PreviousBlockHash: testBlockHash(1)}}
+	if err := db.InsertBlock(2, blocks, &tx.Bulk{TXs: []*tx.Transaction{invoke}}); err != nil {
+		t.Fatal(err)
+	}
+
+	stored, err := db.GetTx(invoke.TxID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	invoke.ID = stored.ID
+
+	value := func(v int64) amount.Amount {
+		return amount.NewFromInt64(v, 0)
+	}
+	x := &nep5.Nep5{AssetID: testToken, AdminAddress: testAddrA, Name: "X", Symbol: "X", Decimals: 2, TotalSupply: value(1000),
+		TxID: invoke.TxID, BlockIndex: 2, BlockTime: invoke.BlockTime, Addresses: 1, HoldingAddresses: 1}
+	if err := db.InsertNep5Asset(invoke, x, &nep5.RegInfo{}, &addr.Asset{Address: testAddrA, AssetID: testToken, Balance: value(1000)}, 2); err != nil {
+		t.Fatal(err)
+	}
+	if err := db.InsertNep5transaction(invoke, 0, testToken, testAddrA, value(900), testAddrB, value(100), value(100), nil); err != nil {
+		t.Fatal(err)
+	}
+}
+
+// call sends the JSON-RPC request to handleJSONRPC and decodes its result into v if it succeeds.
+func call(t *testing.T, v interface{}, method string, params ...interface{}) *rpcError {
+	t.Helper()
+
+	body, err := json.Marshal(map[string]interface{}{"jsonrpc": "2.0", "id": 7, "method": method, "params": params})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	resp := postJSONRPC(t, "/", string(body))
+	if resp.ID != 7 {
+		t.Fatalf("%s returns response of id %d", method, resp.ID)
+	}
+	if resp.Error != nil {
+		return resp.Error
+	}
+
+	if err := json.Unmarshal(resp.Result, v); err != nil {
+		t.Fatalf("invalid result of %s: %v", method, err)
+	}
+	return nil
+}
+
+func postJSONRPC(t *testing.T, path string, body string) rpcTestResponse {
+	t.Helper()
+
+	w := httptest.NewRecorder()
+	handleJSONRPC(w, httptest.NewRequest(http.MethodPost, path, bytes.NewBufferString(body)))
+
+	resp := rpcTestResponse{}
+	if err := json.NewDecoder(w.Body).Decode(&resp); err != nil {
+		t.Fatalf("invalid response of %s: %v", body, err)
+	}
+	return resp
+}
+
+// expectRPCError checks that the call fails with the code.
+func expectRPCError(t *testing.T, code int, method string, params ...interface{}) {
+	t.Helper()
+
+	var result interface{}
+	if rpcErr := call(t, &result, method, params...); rpcErr == nil || rpcErr.Code != code {
+		t.Errorf("%s%v returns (%v, %v), expected error %d", method, params, result, rpcErr, code)
+	}
+}
+
+func TestHandleJSONRPC(t *testing.T) {
+	defer newTestDB(t)()
+
+	if resp := postJSONRPC(t, "/", "{"); resp.Error == nil || resp.Error.Code != rpcParseError {
+		t.Errorf("invalid json returns %+v", resp)
+	}
+	if resp := postJSONRPC(t, "/", `{"jsonrpc": "2.0", "id": 3, "method": "sendrawtransaction", "params": []}`); resp.ID != 3 ||
+		resp.Error == nil || resp.Error.Code != rpcMethodNotFound {
+		t.Errorf("unknown method returns %+v", resp)
+	}
+
+	w := httptest.NewRecorder()
+	handleJSONRPC(w, httptest.NewRequest(http.MethodGet, "/", nil))
+	if w.Code != http.StatusMethodNotAllowed {
+		t.Errorf("GET / returns %d", w.Code)
+	}
+
+	w = httptest.NewRecorder()
+	handleJSONRPC(w, httptest.NewRequest(http.MethodPost, "/rpc", bytes.NewBufferString("{}")))
+	if w.Code != http.StatusNotFound {
+		t.Errorf("POST /rpc returns %d", w.Code)
+	}
+}
+
+func TestGetBalance(t *testing.T) {
+	defer newTestDB(t)()
+
+	cases := []struct {
+		assetID  string
+		address  string
+		expected string
+	}{
+		{asset.NEOAssetID, testAddrA, "40.00000000"},
+		{strings.ToUpper(strings.TrimPrefix(asset.GASAssetID, "0x")), testAddrA, "10.00000000"},
+		{asset.GASAssetID, testAddrB, "0.00000000"},
+		{asset.NEOAssetID, testAddrC, "0.00000000"},
+	}
+	for _, c := range cases {
+		result := getBalanceResult{}
+		if rpcErr := call(t, &result, "getbalance", c.assetID, c.address); rpcErr != nil {
+			t.Fatal(rpcErr)
+		}
+		if result.Balance != c.expected || result.Confirmed != c.expected {
+			t.Errorf("getbalance of %s in %s returns %+v, expected %s", c.address, c.assetID, result, c.expected)
+		}
+	}
+
+	expectRPCError(t, rpcInvalidParams, "getbalance")
+	expectRPCError(t, rpcInvalidParams, "getbalance", asset.NEOAssetID)
+	expectRPCError(t, rpcInvalidParams, "getbalance", asset.NEOAssetID, "invalid")
+	expectRPCError(t, rpcInvalidParams, "getbalance", 1, testAddrA)
+}
+
+func TestGetUnspents(t *testing.T) {
+	defer newTestDB(t)()
+
+	result := unspentsResult{}
+	if rpcErr := call(t, &result, "getunspents", testAddrA); rpcErr != nil {
+		t.Fatal(rpcErr)
+	}
+	if result.Address != testAddrA || len(result.Balance) != 2 {
+		t.Fatalf("getunspents returns %+v", result)
+	}
+
+	expected := map[string]struct {
+		name   string
+		txID   string
+		n      uint16
+		amount string
+	}{
+		strings.TrimPrefix(asset.NEOAssetID, "0x"): {asset.NEO, testSendTxID, 1, "40.00000000"},
+		strings.TrimPrefix(asset.GASAssetID, "0x"): {asset.GAS, testIssueTxID, 1, "10.00000000"},
+	}
+	for _, b := range result.Balance {
+		e, ok := expected[b.AssetHash]
+		if !ok || b.Asset != e.name || b.AssetSymbol != e.name || b.Amount.String() != e.amount || len(b.Unspent) != 1 ||
+			b.Unspent[0].TxID != strings.TrimPrefix(e.txID, "0x") || b.Unspent[0].N != e.n || b.Unspent[0].Value.String() != e.amount {
+			t.Errorf("getunspents returns %+v for asset %s", b, b.AssetHash)
+		}
+	}
+
+	result = unspentsResult{}
+	if rpcErr := call(t, &result, "getunspents", testAddrC); rpcErr != nil || len(result.Balance) != 0 {
+		t.Errorf("getunspents of unknown address returns (%+v, %v)", result, rpcErr)
+	}
+
+	expectRPCError(t, rpcInvalidParams, "getunspents")
+	expectRPCError(t, rpcInvalidParams, "getunspents", "invalid")
+}
+
+func TestGetClaimable(t *testing.T) {
+	defer newTestDB(t)()
+
+	result := claimableResult{}
+	if rpcErr := call(t, &result, "getclaimable", testAddrA); rpcErr != nil {
+		t.Fatal(rpcErr)
+	}
+	if len(result.Claimable) != 1 {
+		t.Fatalf("getclaimable returns %+v", result)
+	}
+
+	// 100 of 100,000,000 NEO held for one block share its 8 GAS.
+	c := result.Claimable[0]
+	if c.TxID != strings.TrimPrefix(testIssueTxID, "0x") || c.N != 0 || c.Value.String() != "100.00000000" ||
+		c.StartHeight != 0 || c.EndHeight != 1 || c.Generated.String() != "0.00000800" ||
+		c.Unclaimed.String() != "0.00000800" || result.Unclaimed.String() != "0.00000800" {
+		t.Errorf("getclaimable returns %+v", result)
+	}
+
+	result = claimableResult{}
+	if rpcErr := call(t, &result, "getclaimable", testAddrB); rpcErr != nil || len(result.Claimable) != 0 || result.Unclaimed.String() != "0.00000000" {
+		t.Errorf("getclaimable of address without spent NEO returns (%+v, %v)", result, rpcErr)
+	}
+
+	expectRPCError(t, rpcInvalidParams, "getclaimable")
+	expectRPCError(t, rpcInvalidParams, "getclaimable", "invalid")
+}
+
+func TestGetUnclaimed(t *testing.T) {
+	defer newTestDB(t)()
+
+	// The spent output of A generated GAS in block 0, the unspent one generates in block 1 till the next block.
+	expected := unclaimedResult{Available: "0.00000800", Unavailable: "0.00000320", Unclaimed: "0.00001120"}
+	expect := func(name string) {
+		t.Helper()
+
+		result := unclaimedResult{}
+		if rpcErr := call(t, &result, "getunclaimed", testAddrA); rpcErr != nil {
+			t.Fatal(rpcErr)
+		}
+		if result != expected {
+			t.Errorf("getunclaimed of %s returns %+v, expected %+v", name, result, expected)
+		}
+	}
+	expect("recorded outputs")
+
+	// Outputs stored before their GAS bonus was recorded are calculated one by one.
+	conn, err := sql.Open("sqlite3", "file:"+testDBPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+	if _, err := conn.Exec("UPDATE utxo SET start_gas = NULL, claim_gas = NULL"); err != nil {
+		t.Fatal(err)
+	}
+	expect("legacy outputs")
+
+	result := unclaimedResult{}
+	if rpcErr := call(t, &result, "getunclaimed", testAddrC); rpcErr != nil || result.Unclaimed.String() != "0.00000000" {
+		t.Errorf("getunclaimed of unknown address returns (%+v, %v)", result, rpcErr)
+	}
+
+	expectRPCError(t, rpcInvalidParams, "getunclaimed")
+	expectRPCError(t, rpcInvalidParams, "getunclaimed", "invalid")
+}
+
+func TestGetNep5Balances(t *testing.T) {
+	defer newTestDB(t)()
+	addTestNep5(t)
+
+	cases := []struct {
+		address string
+		amount  string
+	}{
+		{testAddrA, "90000"},
+		{testAddrB, "10000"},
+	}
+	for _, c := range cases {
+		result := nep5BalancesResult{}
+		if rpcErr := call(t, &result, "getnep5balances", c.address); rpcErr != nil {
+			t.Fatal(rpcErr)
+		}
+
+		// Global assets of A are not listed.
+		if result.Address != c.address || len(result.Balance) != 1 || result.Balance[0].AssetHash != "0x"+testToken ||
+			result.Balance[0].Amount != c.amount || result.Balance[0].LastUpdatedBlock != 2 {
+			t.Errorf("getnep5balances of %s returns %+v, expected amount %s", c.address, result, c.amount)
+		}
+	}
+
+	result := nep5BalancesResult{}
+	if rpcErr := call(t, &result, "getnep5balances", testAddrC); rpcErr != nil || len(result.Balance) != 0 {
+		t.Errorf("getnep5balances of unknown address returns (%+v, %v)", result, rpcErr)
+	}
+
+	expectRPCError(t, rpcInvalidParams, "getnep5balances")
+	expectRPCError(t, rpcInvalidParams, "getnep5balances", "invalid")
+}
+
+func TestGetNep5Transfers(t *testing.T) {
+	defer newTestDB(t)()
+	addTestNep5(t)
+
+	result := nep5TransfersResult{}
+	if rpcErr := call(t, &result, "getnep5transfers", testAddrA, testStart, testStart+60); rpcErr != nil {
+		t.Fatal(rpcErr)
+	}
+	if len(result.Sent) != 1 || len(result.Received) != 0 {
+		t.Fatalf("getnep5transfers returns %+v", result)
+	}
+
+	sent := result.Sent[0]
+	if sent.AssetHash != "0x"+testToken || sent.TransferAddress != testAddrB || sent.Amount != "10000" ||
+		sent.BlockIndex != 2 || sent.Timestamp != testStart+30 || sent.TxHash != testInvokeTxID {
+		t.Errorf("getnep5transfers returns sent %+v", sent)
+	}
+
+	result = nep5TransfersResult{}
+	if rpcErr := call(t, &result, "getnep5transfers", testAddrB, testStart, testStart+60); rpcErr != nil ||
+		len(result.Sent) != 0 || len(result.Received) != 1 || result.Received[0].TransferAddress != testAddrA {
+		t.Errorf("getnep5transfers of receiver returns (%+v, %v)", result, rpcErr)
+	}
+
+	// Transfers of the last 7 days are returned by default.
+	result = nep5TransfersResult{}
+	if rpcErr := call(t, &result, "getnep5transfers", testAddrA); rpcErr != nil || len(result.Sent) != 0 {
+		t.Errorf("getnep5transfers without time range returns (%+v, %v)", result, rpcErr)
+	}
+
+	expectRPCError(t, rpcInvalidParams, "getnep5transfers", testAddrA, testStart+60, testStart)
+	expectRPCError(t, rpcInvalidParams, "getnep5transfers", testAddrA, "yesterday")
+	expectRPCError(t, rpcInvalidParams, "getnep5transfers", testAddrA, -1)
+	expectRPCError(t, rpcInvalidParams, "getnep5transfers", "invalid")
+}
+
+func TestGetRawTransaction(t *testing.T) {
+	defer newTestDB(t)()
+
+	result := rawTransactionResult{}
+	if rpcErr := call(t, &result, "getrawtransaction", strings.TrimPrefix(testSendTxID, "0x"), 1); rpcErr != nil {
+		t.Fatal(rpcErr)
+	}
+	if result.TxID != testSendTxID || result.Type != "ContractTransaction" || result.BlockHash != testBlockHash(1) ||
+		result.Confirmations != 1 || result.BlockTime != testStart+15 {
+		t.Errorf("getrawtransaction returns %+v", result)
+	}
+	if len(result.Vin) != 1 || result.Vin[0].TxID != testIssueTxID || len(result.Vout) != 2 ||
+		result.Vout[1].Value.Cmp(amount.NewFromInt64(40, 0)) != 0 || result.Vout[1].Address != testAddrA {
+		t.Errorf("getrawtransaction returns vin %+v and vout %+v", result.Vin, result.Vout)
+	}
+	if len(result.Attributes) != 1 || result.Attributes[0].Data != "6869" || len(result.Scripts) != 1 || result.Scripts[0].Invocation != "40aa" {
+		t.Errorf("getrawtransaction returns attributes %+v and scripts %+v", result.Attributes, result.Scripts)
+	}
+
+	expectRPCError(t, -100, "getrawtransaction", "0x"+strings.Repeat("f", 64), 1)
+	expectRPCError(t, rpcInvalidParams, "getrawtransaction", testSendTxID)
+	expectRPCError(t, rpcInvalidParams, "getrawtransaction", testSendTxID, 0)
+	expectRPCError(t, rpcInvalidParams, "getrawtransaction")
+}
@@ -0,0 +1,147 @@
+package api
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"squirrel/amount"
+	"squirrel/config"
+	"squirrel/log"
+	"squirrel/mail"
+	"squirrel/metrics"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// errorResponse is the body of all failed api requests.
+type errorResponse struct {
+	Error string `json:"error"`
+}
+
+// server is the running http api, nil if not configured.
+// metricsServer serves only metrics on their own listener, nil if not configured.
+var server, metricsServer *http.Server
+
+// Run starts the read-only http query api and the metrics server if their listen addresses are configured.
+func Run() {
+	if listen := config.GetAPIListen(); listen != "" {
+		mux := http.NewServeMux()
+		mux.HandleFunc("/block/", handleBlock)
+		mux.HandleFunc("/tx/", handleTx)
+		mux.HandleFunc("/address/", handleAddress)
+		mux.HandleFunc("/healthz", handleHealthz)
+		mux.HandleFunc("/readyz", handleReadyz)
+		mux.Handle("/metrics", metrics.Handler())
+		mux.HandleFunc("/", handleJSONRPC)
+
+		server = listenAndServe("Http api", listen, mux)
+	}
+
+	if listen := config.GetMetricsListen(); listen != "" {
+		mux := http.NewServeMux()
+		mux.Handle("/metrics", metrics.Handler())
+
+		metricsServer = listenAndServe("Metrics", listen, mux)
+	}
+}
+
+func listenAndServe(name string, listen string, handler http.Handler) *http.Server {
+	s := &http.Server{
+		Addr:         listen,
+		Handler:      handler,
+		ReadTimeout:  10 * time.Second,
+		WriteTimeout: 30 * time.Second,
+	}
+
+	go func() {
+		defer mail.AlertIfErr()
+
+		log.Printf("%s listening on %s\n", name, listen)
+		if err := s.ListenAndServe(); err != nil && err != http.ErrServerClosed {
+			panic(err)
+		}
+	}()
+
+	return s
+}
+
+// Shutdown stops accepting requests and waits for active ones until ctx is done.
+func Shutdown(ctx context.Context) error {
+	for _, s := range []*http.Server{server, metricsServer} {
+		if s == nil {
+			continue
+		}
+
+		if err := s.Shutdown(ctx); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// getPathParams returns non-empty path segments after the given prefix.
+func getPathParams(r *http.Request, prefix string) []string {
+	params := []string{}
+
+	for _, p := range strings.Split(strings.TrimPrefix(r.URL.Path, prefix), "/") {
+		if p != "" {
+			params = append(params, p)
+		}
+	}
+
+	return params
+}
+
+// getUintQuery returns the unsigned integer query parameter, or defaultValue if absent.
+func getUintQuery(r *http.Request, key string, defaultValue uint) (uint, bool) {
+	str := r.URL.Query().Get(key)
+	if str == "" {
+		return defaultValue, true
+	}
+
+	v, err := strconv.ParseUint(str, 10, 32)
+	if err != nil {
+		return 0, false
+	}
+
+	return uint(v), true
+}
+
+// normalizeHash returns the lower case 0x prefixed form of hashes stored in db.
+func normalizeHash(hash string) string {
+	return "0x" + strings.TrimPrefix(strings.ToLower(hash), "0x")
+}
+
+// formatAmount returns the decimal string of amounts stored in db.
+func formatAmount(v amount.Amount) string {
+	return v.Rescale(8).String()
+}
+
+func allowGet(w http.ResponseWriter, r *http.Request) bool {
+	if r.Method == http.MethodGet {
+		return true
+	}
+
+	writeError(w, http.StatusMethodNotAllowed, "only GET method is allowed")
+	return false
+}
+
+func writeJSON(w http.ResponseWriter, status int, v interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+
+	if err := json.NewEncoder(w).Encode(v); err != nil {
+		log.Error.Println(err)
+	}
+}
+
+func writeError(w http.ResponseWriter, status int, msg string) {
+	writeJSON(w, status, errorResponse{Error: msg})
+}
+
+func writeDbError(w http.ResponseWriter, err error) {
+	log.Error.Println(err)
+	writeError(w, http.StatusInternalServerError, "failed to query database")
+}
@@ -0,0 +1,293 @@
+package api
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"squirrel/amount"
+	"squirrel/asset"
+	"squirrel/block"
+	"squirrel/cache"
+	"squirrel/db"
+	"squirrel/log"
+	"squirrel/tx"
+	"strings"
+	"testing"
+)
+
+const (
+	testAddrA = "AKkkumHbBipZ46UMZJoFynJMXzSRnBvKcs"
+	testAddrB = "APyEx5f4Zm4oCHwFWiSTaph1fPBxZacYVR"
+	// testAddrC is valid but never used.
+	testAddrC = "AQVh2pG732YvtNaxEGkQUei3YA4cvo7d2i"
+	testStart = 1546300800
+)
+
+var (
+	testIssueTxID = fmt.Sprintf("0x%064x", 0xa0)
+	testSendTxID  = fmt.Sprintf("0x%064x", 0xa1)
+
+	// testDBPath is the database file of the running test.
+	testDBPath string
+)
+
+func testBlockHash(index int) string {
+	return fmt.Sprintf("0x%064x", index+1)
+}
+
+// newTestDB stores two blocks in a temporary SQLite database used by db:
+// block 0 issues 100 NEO and 10 GAS to address A, block 1 sends 60 of the NEO to address B.
+func newTestDB(t *testing.T) func() {
+	log.Init()
+
+	dir, err := ioutil.TempDir("", "squirrel")
+	if err != nil {
+		t.Fatal(err)
+	}
+	testDBPath = filepath.Join(dir, "test.db")
+	closeDB := db.InitSQLite(testDBPath)
+	cleanup := func() {
+		closeDB()
+		os.RemoveAll(dir)
+		os.Remove("error.log")
+	}
+
+	value := func(v int64) amount.Amount {
+		return amount.NewFromInt64(v, 0)
+	}
+	newTx := func(txID string, blockIndex uint, txType string) *tx.Transaction {
+		return &tx.Transaction{
+			BlockIndex: blockIndex,
+			BlockTime:  testStart + uint64(blockIndex)*15,
+			TxID:       txID,
+			Size:       100,
+			Type:       txType,
+			SysFee:     amount.Zero,
+			NetFee:     amount.Zero,
+			Gas:        amount.Zero,
+		}
+	}
+
+	issue := newTx(testIssueTxID, 0, "IssueTransaction")
+	send := newTx(testSendTxID, 1, "ContractTransaction")
+	issueVouts := []*tx.TransactionVout{
+		{TxID: issue.TxID, N: 0, AssetID: asset.NEOAssetID, Value: value(100), Address: testAddrA},
+		{TxID: issue.TxID, N: 1, AssetID: asset.GASAssetID, Value: value(10), Address: testAddrA},
+	}
+	sendVins := []*tx.TransactionVin{{From: send.TxID, TxID: issue.TxID, Vout: 0}}
+	sendVouts := []*tx.TransactionVout{
+		{TxID: send.TxID, N: 0, AssetID: asset.NEOAssetID, Value: value(60), Address: testAddrB},
+		{TxID: send.TxID, N: 1, AssetID: asset.NEOAssetID, Value: value(40), Address: testAddrA},
+	}
+
+	bulks := []*tx.Bulk{
+		{
+			TXs:     []*tx.Transaction{issue},
+			TXVouts: issueVouts,
+			Assets: []*asset.Asset{
+				{AssetID: asset.NEOAssetID, Type: "GoverningToken", Amount: value(100000000), Available: amount.Zero},
+				{AssetID: asset.GASAssetID, Type: "UtilityToken", Amount: value(100000000), Available: amount.Zero},
+			},
+		},
+		{
+			TXs:     []*tx.Transaction{send},
+			TXAttrs: []*tx.TransactionAttribute{{TxID: send.TxID, Usage: "Remark", Data: "6869"}},
+			TXVins:  sendVins,
+			TXVouts: sendVouts,
+			TXScripts: []*tx.TransactionScripts{
+				{TxID: send.TxID, Invocation: "40aa", Verification: "21bb"},
+			},
+		},
+	}
+
+	// The counter row is created on first read.
+	db.GetLastTxPkCounter()
+	for i, bulk := range bulks {
+		blocks := []*block.Block{{
+			Hash:              testBlockHash(i),
+			Index:             uint(i),
+			Time:              testStart + uint64(i)*15,
+			Nonce:             "0",
+			PreviousBlockHash: testBlockHash(i - 1),
+		}}
+		if err := db.InsertBlock(i, blocks, bulk); err != nil {
+			cleanup()
+			t.Fatal(err)
+		}
+	}
+
+	cache.LoadAddrAssetInfo(db.GetAddrAssetInfo())
+
+	applies := []struct {
+		t     *tx.Transaction
+		vins  []*tx.TransactionVin
+		vouts []*tx.TransactionVout
+	}{
+		{issue, nil, issueVouts},
+		{send, sendVins, sendVouts},
+	}
+	for _, a := range applies {
+		stored, err := db.GetTx(a.t.TxID)
+		if err != nil {
+			cleanup()
+			t.Fatal(err)
+		}
+		a.t.ID = stored.ID
+
+		if err := db.ApplyVinsVouts(a.t, a.vins, a.vouts); err != nil {
+			cleanup()
+			t.Fatal(err)
+		}
+	}
+
+	return cleanup
+}
+
+// get requests the path from the handler and decodes the json response into v.
+func get(t *testing.T, handler http.HandlerFunc, path string, v interface{}) int {
+	t.Helper()
+
+	w := httptest.NewRecorder()
+	handler(w, httptest.NewRequest(http.MethodGet, path, nil))
+
+	if err := json.NewDecoder(w.Body).Decode(v); err != nil {
+		t.Fatalf("invalid response of %s: %v", path, err)
+	}
+
+	return w.Code
+}
+
+// expectError requests the path and checks the status of the error response.
+func expectError(t *testing.T, handler http.HandlerFunc, path string, status int) {
+	t.Helper()
+
+	resp := errorResponse{}
+	if code := get(t, handler, path, &resp); code != status || resp.Error == "" {
+		t.Errorf("%s returns %d %q, expected %d with an error", path, code, resp.Error, status)
+	}
+}
+
+func TestHandleBlock(t *testing.T) {
+	defer newTestDB(t)()
+
+	paths := []string{
+		"/block/1",
+		"/block/" + testBlockHash(1),
+		"/block/" + strings.ToUpper(strings.TrimPrefix(testBlockHash(1), "0x")),
+	}
+	for _, path := range paths {
+		resp := blockResponse{}
+		if code := get(t, handleBlock, path, &resp); code != http.StatusOK {
+			t.Fatalf("%s returns %d", path, code)
+		}
+		if resp.Index != 1 || resp.Hash != testBlockHash(1) || resp.PreviousBlockHash != testBlockHash(0) ||
+			len(resp.Tx) != 1 || resp.Tx[0] != testSendTxID {
+			t.Errorf("%s returns %+v", path, resp)
+		}
+	}
+
+	expectError(t, handleBlock, "/block/2", http.StatusNotFound)
+	expectError(t, handleBlock, "/block/0x"+strings.Repeat("f", 64), http.StatusNotFound)
+	expectError(t, handleBlock, "/block/", http.StatusNotFound)
+	expectError(t, handleBlock, "/block/1/tx", http.StatusNotFound)
+
+	w := httptest.NewRecorder()
+	handleBlock(w, httptest.NewRequest(http.MethodPost, "/block/1", nil))
+	if w.Code != http.StatusMethodNotAllowed {
+		t.Errorf("POST /block/1 returns %d", w.Code)
+	}
+}
+
+func TestHandleTx(t *testing.T) {
+	defer newTestDB(t)()
+
+	resp := txResponse{}
+	path := "/tx/" + strings.TrimPrefix(testSendTxID, "0x")
+	if code := get(t, handleTx, path, &resp); code != http.StatusOK {
+		t.Fatalf("%s returns %d", path, code)
+	}
+	if resp.TxID != testSendTxID || resp.BlockIndex != 1 || resp.Type != "ContractTransaction" || resp.SysFee != "0.00000000" {
+		t.Errorf("%s returns %+v", path, resp)
+	}
+	if len(resp.Vin) != 1 || resp.Vin[0].TxID != testIssueTxID || resp.Vin[0].Vout != 0 {
+		t.Errorf("%s returns vin %+v", path, resp.Vin)
+	}
+	if len(resp.Vout) != 2 || resp.Vout[0].Value != "60.00000000" || resp.Vout[0].Address != testAddrB {
+		t.Errorf("%s returns vout %+v", path, resp.Vout)
+	}
+	if len(resp.Attributes) != 1 || resp.Attributes[0].Usage != "Remark" ||
+		len(resp.Scripts) != 1 || resp.Scripts[0].Verification != "21bb" {
+		t.Errorf("%s returns attributes %+v and scripts %+v", path, resp.Attributes, resp.Scripts)
+	}
+
+	expectError(t, handleTx, "/tx/0x"+strings.Repeat("f", 64), http.StatusNotFound)
+	expectError(t, handleTx, "/tx/", http.StatusNotFound)
+	expectError(t, handleTx, "/tx/"+testSendTxID+"/vout", http.StatusNotFound)
+}
+
+func TestHandleAddress(t *testing.T) {
+	defer newTestDB(t)()
+
+	resp := addressResponse{}
+	if code := get(t, handleAddress, "/address/"+testAddrA, &resp); code != http.StatusOK {
+		t.Fatalf("/address/%s returns %d", testAddrA, code)
+	}
+	if resp.Address != testAddrA || resp.CreatedAt != testStart || resp.TransAsset != 2 {
+		t.Errorf("/address/%s returns %+v", testAddrA, resp)
+	}
+
+	balances := make(map[string]string)
+	for _, b := range resp.Balances {
+		balances[b.AssetID] = b.Balance
+	}
+	if len(balances) != 2 || balances[asset.NEOAssetID] != "40.00000000" || balances[asset.GASAssetID] != "10.00000000" {
+		t.Errorf("/address/%s returns balances %+v", testAddrA, resp.Balances)
+	}
+
+	expectError(t, handleAddress, "/address/"+testAddrC, http.StatusNotFound)
+	expectError(t, handleAddress, "/address/invalid", http.StatusBadRequest)
+	expectError(t, handleAddress, "/address/", http.StatusNotFound)
+	expectError(t, handleAddress, "/address/"+testAddrA+"/balances", http.StatusNotFound)
+}
+
+func TestHandleAddrTxs(t *testing.T) {
+	defer newTestDB(t)()
+
+	cases := []struct {
+		path     string
+		page     uint
+		size     uint
+		total    uint64
+		expected []string
+	}{
+		{"/address/" + testAddrA + "/txs", 1, defaultPageSize, 2, []string{testSendTxID, testIssueTxID}},
+		{"/address/" + testAddrA + "/txs?page=2&size=1", 2, 1, 2, []string{testIssueTxID}},
+		{"/address/" + testAddrA + "/txs?page=3&size=1", 3, 1, 2, []string{}},
+		{"/address/" + testAddrB + "/txs", 1, defaultPageSize, 1, []string{testSendTxID}},
+		{"/address/" + testAddrC + "/txs", 1, defaultPageSize, 0, []string{}},
+	}
+	for _, c := range cases {
+		resp := addrTxsResponse{}
+		if code := get(t, handleAddress, c.path, &resp); code != http.StatusOK {
+			t.Fatalf("%s returns %d", c.path, code)
+		}
+
+		txIDs := []string{}
+		for _, addrTx := range resp.Txs {
+			txIDs = append(txIDs, addrTx.TxID)
+		}
+		if resp.Page != c.page || resp.Size != c.size || resp.Total != c.total ||
+			fmt.Sprint(txIDs) != fmt.Sprint(c.expected) {
+			t.Errorf("%s returns %+v, expected txs %v", c.path, resp, c.expected)
+		}
+	}
+
+	for _, query := range []string{"page=0", "page=x", "page=-1", "size=0", "size=101"} {
+		expectError(t, handleAddress, "/address/"+testAddrA+"/txs?"+query, http.StatusBadRequest)
+	}
+	expectError(t, handleAddress, "/address/invalid/txs", http.StatusBadRequest)
+}
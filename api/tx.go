@@ -0,0 +1,133 @@
+package api
+
+import (
+	"net/http"
+	"squirrel/db"
+)
+
+type txResponse struct {
+	TxID       string         `json:"txid"`
+	BlockIndex uint           `json:"block_index"`
+	BlockTime  uint64         `json:"block_time"`
+	Size       uint           `json:"size"`
+	Type       string         `json:"type"`
+	Version    uint           `json:"version"`
+	SysFee     string         `json:"sys_fee"`
+	NetFee     string         `json:"net_fee"`
+	Nonce      int64          `json:"nonce"`
+	Script     string         `json:"script"`
+	Gas        string         `json:"gas"`
+	Attributes []attrResult   `json:"attributes"`
+	Vin        []vinResult    `json:"vin"`
+	Vout       []voutResult   `json:"vout"`
+	Scripts    []scriptResult `json:"scripts"`
+
+	// InvalidWitness is the reason why a witness does not verify, see config verify_witnesses.
+	InvalidWitness string `json:"invalid_witness,omitempty"`
+}
+
+type attrResult struct {
+	Usage string `json:"usage"`
+	Data  string `json:"data"`
+}
+
+type vinResult struct {
+	TxID string `json:"txid"`
+	Vout uint16 `json:"vout"`
+}
+
+type voutResult struct {
+	N       uint16 `json:"n"`
+	Asset   string `json:"asset"`
+	Value   string `json:"value"`
+	Address string `json:"address"`
+}
+
+// handleTx serves GET /tx/{txid}.
+func handleTx(w http.ResponseWriter, r *http.Request) {
+	if !allowGet(w, r) {
+		return
+	}
+
+	params := getPathParams(r, "/tx/")
+	if len(params) != 1 {
+		writeError(w, http.StatusNotFound, "usage: /tx/{txid}")
+		return
+	}
+
+	txID := normalizeHash(params[0])
+
+	t, err := db.GetTx(txID)
+	if err != nil {
+		writeDbError(w, err)
+		return
+	}
+	if t == nil {
+		writeError(w, http.StatusNotFound, "transaction not found")
+		return
+	}
+
+	resp := txResponse{
+		TxID:       t.TxID,
+		BlockIndex: t.BlockIndex,
+		BlockTime:  t.BlockTime,
+		Size:       t.Size,
+		Type:       t.Type,
+		Version:    t.Version,
+		SysFee:     formatAmount(t.SysFee),
+		NetFee:     formatAmount(t.NetFee),
+		Nonce:      t.Nonce,
+		Script:     t.Script,
+		Gas:        formatAmount(t.Gas),
+		Attributes: []attrResult{},
+		Vin:        []vinResult{},
+		Vout:       []voutResult{},
+		Scripts:    []scriptResult{},
+	}
+
+	attrs, err := db.GetTxAttrs(txID)
+	if err != nil {
+		writeDbError(w, err)
+		return
+	}
+	for _, attr := range attrs {
+		resp.Attributes = append(resp.Attributes, attrResult{Usage: attr.Usage, Data: attr.Data})
+	}
+
+	vinMap, voutMap, err := db.GetVinVout([]string{txID})
+	if err != nil {
+		writeDbError(w, err)
+		return
+	}
+	for _, vin := range vinMap[txID] {
+		resp.Vin = append(resp.Vin, vinResult{TxID: vin.TxID, Vout: vin.Vout})
+	}
+	for _, vout := range voutMap[txID] {
+		resp.Vout = append(resp.Vout, voutResult{
+			N:       vout.N,
+			Asset:   vout.AssetID,
+			Value:   formatAmount(vout.Value),
+			Address: vout.Address,
+		})
+	}
+
+	scripts, err := db.GetTxScripts(txID)
+	if err != nil {
+		writeDbError(w, err)
+		return
+	}
+	for _, script := range scripts {
+		resp.Scripts = append(resp.Scripts, scriptResult{
+			Invocation:   script.Invocation,
+			Verification: script.Verification,
+		})
+	}
+
+	resp.InvalidWitness, err = db.GetInvalidWitness(txID)
+	if err != nil {
+		writeDbError(w, err)
+		return
+	}
+
+	writeJSON(w, http.StatusOK, resp)
+}
@@ -1,6 +1,6 @@
 package asset
 
-import "math/big"
+import "squirrel/amount"
 
 // Constants.
 const (
@@ -23,8 +23,8 @@ type Asset struct {
 	AssetID      string
 	Type         string
 	Name         string
-	Amount       *big.Float
-	Available    *big.Float
+	Amount       amount.Amount
+	Available    amount.Amount
 	Precision    uint8
 	Owner        string
 	Admin        string
@@ -13,7 +13,10 @@ type BlockBuffer struct {
 	// nextHeight indicates the next block height to fetch,
 	// used before blockchain fully synchronized.
 	nextHeight int
-	buffer     map[int]*rpc.RawBlock
+	// epoch increases every time the buffer is reset,
+	// blocks downloaded in previous epochs will be rejected.
+	epoch  uint
+	buffer map[int]*rpc.RawBlock
 }
 
 // NewBuffer inits a new block buffer.
@@ -45,24 +48,51 @@ func (b *BlockBuffer) GetHighest() int {
 	return b.maxHeight
 }
 
-// GetNextPending returns the next fetching block index.
-func (b *BlockBuffer) GetNextPending() int {
+// GetNextPending reserves the next count fetching block indexes and returns the first one.
+func (b *BlockBuffer) GetNextPending(count int) int {
 	b.mu.Lock()
 	defer b.mu.Unlock()
 
-	b.nextHeight++
-	return b.nextHeight
+	start := b.nextHeight + 1
+	b.nextHeight += count
+	return start
+}
+
+// Epoch returns the current epoch of the buffer.
+func (b *BlockBuffer) Epoch() uint {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	return b.epoch
 }
 
 // Put adds the given block into buffer and update maxHeight.
-func (b *BlockBuffer) Put(block *rpc.RawBlock) {
+// Blocks downloaded before the latest reset are dropped.
+func (b *BlockBuffer) Put(block *rpc.RawBlock, epoch uint) bool {
 	b.mu.Lock()
 	defer b.mu.Unlock()
 
+	if epoch != b.epoch {
+		return false
+	}
+
 	b.buffer[int(block.Index)] = block
 	if b.maxHeight < int(block.Index) {
 		b.maxHeight = int(block.Index)
 	}
+
+	return true
+}
+
+// Reset drops all buffered blocks and restarts fetching from the given height.
+func (b *BlockBuffer) Reset(height int) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	b.epoch++
+	b.maxHeight = height
+	b.nextHeight = height
+	b.buffer = make(map[int]*rpc.RawBlock)
 }
 
 // Size returns size of current buffer.
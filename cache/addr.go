@@ -1,8 +1,8 @@
 package cache
 
 import (
-	"math/big"
 	"squirrel/addr"
+	"squirrel/amount"
 	"sync"
 )
 
@@ -15,7 +15,7 @@ type AddrCacheItem struct {
 
 // AddrAssetCacheItem records balance of address assets.
 type AddrAssetCacheItem struct {
-	Balance *big.Float
+	Balance amount.Amount
 	// This balance is 'up to date' till 'BlockIndex'.
 	BlockIndex uint
 }
@@ -72,7 +72,7 @@ func MigrateNEP5(newAssetAdmin, oldAssetID, newAssetID string) (uint, uint) {
 
 		if old, ok := item.AddrAssetCache[getAssetAlias(oldAssetID)]; ok {
 			item.AddrAssetCache[getAssetAlias(newAssetID)] = &AddrAssetCacheItem{
-				Balance:    new(big.Float).Copy(old.Balance),
+				Balance:    old.Balance,
 				BlockIndex: old.BlockIndex,
 			}
 
@@ -188,7 +188,7 @@ func (cache *AddrCacheItem) GetAddrAsset(assetID string) (*AddrAssetCacheItem, b
 }
 
 // GetAddrAssetOrCreate gets or creates address asset cache.
-func (cache *AddrCacheItem) GetAddrAssetOrCreate(assetID string, balance *big.Float) (*AddrAssetCacheItem, bool) {
+func (cache *AddrCacheItem) GetAddrAssetOrCreate(assetID string, balance amount.Amount) (*AddrAssetCacheItem, bool) {
 	addrCacheLock.Lock()
 	defer addrCacheLock.Unlock()
 
@@ -207,7 +207,7 @@ func (cache *AddrCacheItem) GetAddrAssetOrCreate(assetID string, balance *big.Fl
 }
 
 // CreateAddrAsset creates address asset cache.
-func CreateAddrAsset(address string, assetID string, balance *big.Float, blockIndex uint) {
+func CreateAddrAsset(address string, assetID string, balance amount.Amount, blockIndex uint) {
 	addrCacheLock.Lock()
 	defer addrCacheLock.Unlock()
 
@@ -219,7 +219,7 @@ func CreateAddrAsset(address string, assetID string, balance *big.Float, blockIn
 }
 
 // UpdateBalance updates balance of address asset.
-func (addrAssetCache *AddrAssetCacheItem) UpdateBalance(balance *big.Float, blockIndex uint) bool {
+func (addrAssetCache *AddrAssetCacheItem) UpdateBalance(balance amount.Amount, blockIndex uint) bool {
 	addrCacheLock.Lock()
 	defer addrCacheLock.Unlock()
 
@@ -238,8 +238,8 @@ func (addrAssetCache *AddrAssetCacheItem) UpdateBalance(balance *big.Float, bloc
 }
 
 // AddBalance increases balance at the given blockIndex.
-func (addrAssetCache *AddrAssetCacheItem) AddBalance(delta *big.Float, blockIndex uint) bool {
-	if delta.Cmp(big.NewFloat(0)) == 0 {
+func (addrAssetCache *AddrAssetCacheItem) AddBalance(delta amount.Amount, blockIndex uint) bool {
+	if delta.IsZero() {
 		return false
 	}
 
@@ -252,13 +252,13 @@ func (addrAssetCache *AddrAssetCacheItem) AddBalance(delta *big.Float, blockInde
 
 	addrAssetCache.BlockIndex = blockIndex
 
-	addrAssetCache.Balance = new(big.Float).Add(addrAssetCache.Balance, delta)
+	addrAssetCache.Balance = addrAssetCache.Balance.Add(delta)
 	return true
 }
 
 // SubtractBalance decreases balance at the given blockIndex.
-func (addrAssetCache *AddrAssetCacheItem) SubtractBalance(delta *big.Float, blockIndex uint) bool {
-	if delta.Cmp(big.NewFloat(0)) == 0 {
+func (addrAssetCache *AddrAssetCacheItem) SubtractBalance(delta amount.Amount, blockIndex uint) bool {
+	if delta.IsZero() {
 		return false
 	}
 
@@ -271,6 +271,6 @@ func (addrAssetCache *AddrAssetCacheItem) SubtractBalance(delta *big.Float, bloc
 
 	addrAssetCache.BlockIndex = blockIndex
 
-	addrAssetCache.Balance = new(big.Float).Sub(addrAssetCache.Balance, delta)
+	addrAssetCache.Balance = addrAssetCache.Balance.Sub(delta)
 	return true
 }
@@ -0,0 +1,38 @@
+package cache
+
+import (
+	"squirrel/metrics"
+
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+func init() {
+	metrics.MustRegister(
+		prometheus.NewGaugeFunc(prometheus.GaugeOpts{
+			Namespace: metrics.Namespace,
+			Subsystem: "cache",
+			Name:      "addresses",
+			Help:      "Number of addresses in the address asset cache.",
+		}, func() float64 { return float64(addrCacheSize()) }),
+		prometheus.NewGaugeFunc(prometheus.GaugeOpts{
+			Namespace: metrics.Namespace,
+			Subsystem: "cache",
+			Name:      "total_supplies",
+			Help:      "Number of assets in the total supply cache.",
+		}, func() float64 { return float64(totalSupplyCacheSize()) }),
+	)
+}
+
+func addrCacheSize() int {
+	addrCacheLock.Lock()
+	defer addrCacheLock.Unlock()
+
+	return len(addrCache)
+}
+
+func totalSupplyCacheSize() int {
+	assetCacheLock.Lock()
+	defer assetCacheLock.Unlock()
+
+	return len(totalSupplyCache)
+}
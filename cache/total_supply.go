@@ -1,13 +1,13 @@
 package cache
 
 import (
-	"math/big"
+	"squirrel/amount"
 	"sync"
 )
 
 // AssetTotalSupplyCacheItem caches assetID with its total supply.
 type AssetTotalSupplyCacheItem struct {
-	TotalSupply *big.Float
+	TotalSupply amount.Amount
 	BlockIndex  uint
 }
 
@@ -17,20 +17,20 @@ var (
 )
 
 // GetAssetTotalSupply returns assetID total supply cache record.
-func GetAssetTotalSupply(assetID string) (*big.Float, uint, bool) {
+func GetAssetTotalSupply(assetID string) (amount.Amount, uint, bool) {
 	assetCacheLock.Lock()
 	defer assetCacheLock.Unlock()
 
 	rec, ok := totalSupplyCache[assetID]
 	if !ok {
-		return nil, 0, false
+		return amount.Zero, 0, false
 	}
 
 	return rec.TotalSupply, rec.BlockIndex, true
 }
 
 // UpdateAssetTotalSupply updates or sets total supply for assetID.
-func UpdateAssetTotalSupply(assetID string, totalSupply *big.Float, blockIndex uint) bool {
+func UpdateAssetTotalSupply(assetID string, totalSupply amount.Amount, blockIndex uint) bool {
 	assetCacheLock.Lock()
 	defer assetCacheLock.Unlock()
 
@@ -47,3 +47,15 @@ func UpdateAssetTotalSupply(assetID string, totalSupply *big.Float, blockIndex u
 
 	return true
 }
+
+// ResetAssetTotalSupply drops total supplies cached after the height, they may come from rolled back blocks.
+func ResetAssetTotalSupply(height uint) {
+	assetCacheLock.Lock()
+	defer assetCacheLock.Unlock()
+
+	for assetID, rec := range totalSupplyCache {
+		if rec.BlockIndex > height {
+			delete(totalSupplyCache, assetID)
+		}
+	}
+}
@@ -1,11 +1,13 @@
 package config
 
 import (
+	"encoding/hex"
 	"encoding/json"
 	"errors"
 	"fmt"
 	"net"
 	"net/url"
+	"squirrel/asset"
 	"squirrel/log"
 	"strings"
 
@@ -14,7 +16,10 @@ import (
 )
 
 type config struct {
-	// MySQL configs.
+	// Driver is the database driver, "mysql" (default), "postgres" or "sqlite3".
+	Driver string
+
+	// Database configs, only Database is used by sqlite3 as the path of database file.
 	User     string
 	Password string
 	Hostname string
@@ -26,10 +31,50 @@ type config struct {
 
 	RPCs []string `mapstructure:"rpc_url"`
 
+	// RPCLatencyWeight sets how strongly rpc servers with lower latency are preferred,
+	// the selection weight of a server is proportional to latency^-RPCLatencyWeight.
+	// 0 (default) selects servers regardless of latency, recommended value: 1.
+	RPCLatencyWeight float64 `mapstructure:"rpc_latency_weight"`
+
+	// BlockEncoding selects how blocks are downloaded, "json" (default) requests verbose blocks,
+	// "binary" requests serialized blocks which are smaller and decoded locally.
+	BlockEncoding string `mapstructure:"block_encoding"`
+
+	// SystemFees are the system fees in GAS of transaction types used to decode binary blocks,
+	// keyed by type names like "RegisterTransaction". They differ between networks,
+	// so they are required if BlockEncoding is "binary".
+	SystemFees map[string]int64 `mapstructure:"system_fees"`
+
+	// VerifyWitnesses enables signature verification of standard signature and multi-signature witnesses,
+	// transactions whose witnesses do not verify are recorded in table invalid_witness.
+	VerifyWitnesses bool `mapstructure:"verify_witnesses"`
+
+	// BalanceAssets are ids of global assets whose daily balances of addresses are recorded
+	// in table addr_asset_balance, defaults to GAS. The balance task has to be restarted from
+	// the beginning after the list is changed, otherwise history of added assets is incomplete.
+	BalanceAssets []string `mapstructure:"balance_assets"`
+
 	// Workers sets the number of goroutines that will be created for data processing.
 	// Recommend value: 3.
 	Workers int
 
+	// APIListen is the optional listen address of the http query api, e.g. "127.0.0.1:8080".
+	// The api server is disabled if it is empty, it also serves Prometheus metrics at /metrics.
+	APIListen string `mapstructure:"api_listen"`
+
+	// MetricsListen is the optional listen address serving only Prometheus metrics at /metrics,
+	// e.g. "127.0.0.1:9100". It exposes metrics of indexers running without the api,
+	// it must differ from APIListen.
+	MetricsListen string `mapstructure:"metrics_listen"`
+
+	// ReadyBlockLag is the number of blocks the database may be behind the best rpc height
+	// while /readyz still reports ready, defaults to 10.
+	ReadyBlockLag uint `mapstructure:"ready_block_lag"`
+
+	// ReadyTaskLag is the number of tx pks an indexing task may be behind its highest position
+	// while /readyz still reports ready, defaults to 5000.
+	ReadyTaskLag uint `mapstructure:"ready_task_lag"`
+
 	// AliyunMail is an optional config which will be used in mail alert package.
 	AliyunMail AliyunMailConfig `mapstructure:"aliyun_mail"`
 }
@@ -96,8 +141,32 @@ func update() {
 	}
 }
 
-// GetDbConnStr returns mysql connection string.
+// GetDbDriver returns the configured database driver.
+func GetDbDriver() string {
+	if cfg.Driver == "" {
+		return "mysql"
+	}
+
+	return cfg.Driver
+}
+
+// GetDbConnStr returns connection string of the configured database driver.
 func GetDbConnStr() string {
+	switch GetDbDriver() {
+	case "sqlite3":
+		// Immediate transactions wait for the write lock instead of failing on upgrade.
+		return fmt.Sprintf("file:%s?_journal_mode=WAL&_busy_timeout=10000&_txlock=immediate", cfg.Database)
+	case "postgres":
+		return fmt.Sprintf(
+			"host=%s port=%s user=%s password=%s dbname=%s sslmode=disable",
+			cfg.Hostname,
+			cfg.Port,
+			cfg.User,
+			cfg.Password,
+			cfg.Database,
+		)
+	}
+
 	str := fmt.Sprintf(
 		"%s:%s@tcp(%s:%s)/%s",
 		cfg.User,
@@ -112,7 +181,6 @@ func GetDbConnStr() string {
 		"parseTime=True",
 		"loc=Local",
 		"maxAllowedPacket=52428800",
-		"multiStatements=True",
 	}
 
 	if len(params) > 0 {
@@ -132,11 +200,77 @@ func GetRPCs() []string {
 	return cfg.RPCs
 }
 
+// GetRPCLatencyWeight returns the latency exponent of rpc server selection weights.
+func GetRPCLatencyWeight() float64 {
+	return cfg.RPCLatencyWeight
+}
+
+// GetBlockEncoding returns the encoding of downloaded blocks.
+func GetBlockEncoding() string {
+	if cfg.BlockEncoding == "" {
+		return "json"
+	}
+
+	return cfg.BlockEncoding
+}
+
+// GetSystemFees returns the configured system fees of transaction types, nil if not configured.
+func GetSystemFees() map[string]int64 {
+	return cfg.SystemFees
+}
+
+// GetVerifyWitnesses reports whether witnesses of downloaded transactions are verified.
+func GetVerifyWitnesses() bool {
+	return cfg.VerifyWitnesses
+}
+
+// GetBalanceAssets returns ids of assets whose daily balances are recorded, with 0x prefix in lower case.
+func GetBalanceAssets() []string {
+	if len(cfg.BalanceAssets) == 0 {
+		return []string{asset.GASAssetID}
+	}
+
+	ids := make([]string, len(cfg.BalanceAssets))
+	for i, id := range cfg.BalanceAssets {
+		ids[i] = "0x" + strings.TrimPrefix(strings.ToLower(id), "0x")
+	}
+
+	return ids
+}
+
 // GetGoroutines returns the number of working goroutines.
 func GetGoroutines() int {
 	return cfg.Workers
 }
 
+// GetAPIListen returns listen address of the http query api.
+func GetAPIListen() string {
+	return cfg.APIListen
+}
+
+// GetMetricsListen returns listen address of the metrics server.
+func GetMetricsListen() string {
+	return cfg.MetricsListen
+}
+
+// GetReadyBlockLag returns the allowed block storage lag of a ready instance.
+func GetReadyBlockLag() uint {
+	if cfg.ReadyBlockLag == 0 {
+		return 10
+	}
+
+	return cfg.ReadyBlockLag
+}
+
+// GetReadyTaskLag returns the allowed lag of indexing tasks of a ready instance.
+func GetReadyTaskLag() uint {
+	if cfg.ReadyTaskLag == 0 {
+		return 5000
+	}
+
+	return cfg.ReadyTaskLag
+}
+
 // LoadAliyunMailConfig performs a basic check on aliyun mail config.
 func LoadAliyunMailConfig() error {
 	if err := checkAliyunMail(); err != nil {
@@ -152,6 +286,18 @@ func GetAliyunMailConfig() AliyunMailConfig {
 }
 
 func check() error {
+	if err := checkDriver(); err != nil {
+		return err
+	}
+
+	if err := checkBlockEncoding(); err != nil {
+		return err
+	}
+
+	if err := checkBalanceAssets(); err != nil {
+		return err
+	}
+
 	if err := checkWorker(); err != nil {
 		return err
 	}
@@ -160,6 +306,43 @@ func check() error {
 		return err
 	}
 
+	if err := checkMetricsListen(); err != nil {
+		return err
+	}
+
+	return nil
+}
+
+func checkDriver() error {
+	switch GetDbDriver() {
+	case "mysql", "postgres", "sqlite3":
+		return nil
+	default:
+		return fmt.Errorf("unsupported database driver '%s'", cfg.Driver)
+	}
+}
+
+func checkBlockEncoding() error {
+	switch GetBlockEncoding() {
+	case "json":
+		return nil
+	case "binary":
+		if len(cfg.SystemFees) == 0 {
+			return fmt.Errorf("system_fees of the network are required by binary block encoding")
+		}
+		return nil
+	default:
+		return fmt.Errorf("unsupported block encoding '%s'", cfg.BlockEncoding)
+	}
+}
+
+func checkBalanceAssets() error {
+	for _, id := range GetBalanceAssets() {
+		if _, err := hex.DecodeString(id[2:]); err != nil || len(id) != 66 {
+			return fmt.Errorf("invalid asset id '%s' in balance_assets", id)
+		}
+	}
+
 	return nil
 }
 
@@ -193,6 +376,14 @@ func checkRPCs() error {
 	return nil
 }
 
+func checkMetricsListen() error {
+	if cfg.MetricsListen != "" && cfg.MetricsListen == cfg.APIListen {
+		return errors.New("metrics_listen must differ from api_listen, which serves /metrics already")
+	}
+
+	return nil
+}
+
 func checkAliyunMail() error {
 	m := cfg.AliyunMail
 
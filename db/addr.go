@@ -2,21 +2,19 @@ package db
 
 import (
 	"database/sql"
-	"fmt"
 	"squirrel/addr"
 	"squirrel/asset"
 	"squirrel/cache"
 	"squirrel/log"
-	"squirrel/util"
+	"strings"
 )
 
-// GetAddrAssetInfo returns all addresses with it's assets.
-func GetAddrAssetInfo() []*addr.AssetInfo {
+func (s *sqlStorage) GetAddrAssetInfo() []*addr.AssetInfo {
 	const query = "SELECT `address`.`address`, `address`.`created_at`, `address`.`last_transaction_time`, `addr_asset`.`asset_id`, `addr_asset`.`balance` FROM `addr_asset` LEFT JOIN `address` ON `address`.`address`=`addr_asset`.`address`"
 
 	result := []*addr.AssetInfo{}
 
-	rows, err := wrappedQuery(query)
+	rows, err := s.query(query)
 	if err != nil {
 		panic(err)
 	}
@@ -24,29 +22,26 @@ func GetAddrAssetInfo() []*addr.AssetInfo {
 
 	for rows.Next() {
 		m := &addr.AssetInfo{}
-		var balanceStr string
 
 		err := rows.Scan(
 			&m.Address,
 			&m.CreatedAt,
 			&m.LastTransactionTime,
 			&m.AssetID,
-			&balanceStr,
+			&m.Balance,
 		)
 
 		if err != nil {
 			panic(err)
 		}
 
-		m.Balance = util.StrToBigFloat(balanceStr)
-
 		result = append(result, m)
 	}
 
 	return result
 }
 
-func updateAddrInfo(tx *sql.Tx, blockTime uint64, txID string, addr string, assetType string) error {
+func updateAddrInfo(tx *txn, blockTime uint64, txID string, addr string, assetType string) error {
 	var incrAsset, incrNep5 = 0, 0
 	switch assetType {
 	case asset.ASSET:
@@ -67,18 +62,22 @@ func updateAddrInfo(tx *sql.Tx, blockTime uint64, txID string, addr string, asse
 			return err
 		}
 	} else {
-		query := fmt.Sprintf("UPDATE `address` SET `trans_asset` = `trans_asset` + %d, `trans_nep5` = `trans_nep5` + %d", incrAsset, incrNep5)
+		query := "UPDATE `address` SET `trans_asset` = `trans_asset` + ?, `trans_nep5` = `trans_nep5` + ?"
+		args := []interface{}{incrAsset, incrNep5}
 		// Because task tx and task nep5 run in parallel,
 		// maybe one task executes before the other one with a bigger blockTime.
 		if addrCache.UpdateCreatedTime(blockTime) {
-			query += fmt.Sprintf(", `created_at` = %d", blockTime)
+			query += ", `created_at` = ?"
+			args = append(args, blockTime)
 		}
 		if addrCache.UpdateLastTxTime(blockTime) {
-			query += fmt.Sprintf(", `last_transaction_time` = %d", blockTime)
+			query += ", `last_transaction_time` = ?"
+			args = append(args, blockTime)
 		}
-		query += fmt.Sprintf(" WHERE `address` = '%s' LIMIT 1", addr)
+		query += " WHERE `address` = ? LIMIT 1"
+		args = append(args, addr)
 
-		_, err := tx.Exec(query)
+		_, err := tx.Exec(query, args...)
 		if err != nil {
 			return err
 		}
@@ -86,7 +85,7 @@ func updateAddrInfo(tx *sql.Tx, blockTime uint64, txID string, addr string, asse
 	return nil
 }
 
-func createAddrInfoIfNotExist(tx *sql.Tx, blockTime uint64, addr string) error {
+func createAddrInfoIfNotExist(tx *txn, blockTime uint64, addr string) error {
 	_, created := cache.GetAddrOrCreate(addr, blockTime)
 	if created {
 		const createAddrQuery = "INSERT INTO `address` (`address`, `created_at`, `last_transaction_time`, `trans_asset`, `trans_nep5`) VALUES (?, ?, ?, ?, ?)"
@@ -99,3 +98,129 @@ func createAddrInfoIfNotExist(tx *sql.Tx, blockTime uint64, addr string) error {
 
 	return nil
 }
+
+func (s *sqlStorage) GetAddress(address string) (*addr.Address, error) {
+	const query = "SELECT `id`, `address`, `created_at`, `last_transaction_time`, `trans_asset`, `trans_nep5` FROM `address` WHERE `address` = ? LIMIT 1"
+	rows, err := s.query(query, address)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	if !rows.Next() {
+		return nil, rows.Err()
+	}
+
+	var a addr.Address
+	err = rows.Scan(
+		&a.ID,
+		&a.Address,
+		&a.CreatedAt,
+		&a.LastTransactionTime,
+		&a.TransAsset,
+		&a.TransNep5,
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	return &a, nil
+}
+
+func (s *sqlStorage) GetAddrContract(address string) (*addr.Contract, error) {
+	const query = "SELECT `address`, `type`, `threshold`, `public_keys`, `script`, `txid`, `block_index` FROM `addr_contract` WHERE `address` = ? LIMIT 1"
+
+	var c addr.Contract
+	var publicKeys string
+	err := s.queryRow(query, address).Scan(
+		&c.Address,
+		&c.Type,
+		&c.Threshold,
+		&publicKeys,
+		&c.Script,
+		&c.TxID,
+		&c.BlockIndex,
+	)
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	c.PublicKeys = []string{}
+	if publicKeys != "" {
+		c.PublicKeys = strings.Split(publicKeys, ",")
+	}
+
+	return &c, nil
+}
+
+func (s *sqlStorage) GetAddrAssets(address string) ([]*addr.Asset, error) {
+	const query = "SELECT `id`, `address`, `asset_id`, `balance`, `transactions`, `last_transaction_time` FROM `addr_asset` WHERE `address` = ? ORDER BY `id` ASC"
+	rows, err := s.query(query, address)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	result := []*addr.Asset{}
+
+	for rows.Next() {
+		a := new(addr.Asset)
+
+		err := rows.Scan(
+			&a.ID,
+			&a.Address,
+			&a.AssetID,
+			&a.Balance,
+			&a.Transactions,
+			&a.LastTransactionTime,
+		)
+		if err != nil {
+			return nil, err
+		}
+
+		result = append(result, a)
+	}
+
+	return result, rows.Err()
+}
+
+func (s *sqlStorage) GetAddrTxs(address string, offset uint, limit uint) ([]*addr.Tx, error) {
+	const query = "SELECT `id`, `txid`, `address`, `block_time`, `asset_type` FROM `addr_tx` WHERE `address` = ? ORDER BY `block_time` DESC, `id` DESC LIMIT ? OFFSET ?"
+	rows, err := s.query(query, address, limit, offset)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	result := []*addr.Tx{}
+
+	for rows.Next() {
+		t := new(addr.Tx)
+		if err := rows.Scan(&t.ID, &t.TxID, &t.Address, &t.BlockTime, &t.AssetType); err != nil {
+			return nil, err
+		}
+		result = append(result, t)
+	}
+
+	return result, rows.Err()
+}
+
+func (s *sqlStorage) CountAddrTxs(address string) (uint64, error) {
+	const query = "SELECT COUNT(`id`) FROM `addr_tx` WHERE `address` = ?"
+
+	var cnt uint64
+	rows, err := s.query(query, address)
+	if err != nil {
+		return 0, err
+	}
+	defer rows.Close()
+
+	if rows.Next() {
+		err = rows.Scan(&cnt)
+	}
+
+	return cnt, err
+}
@@ -0,0 +1,19 @@
+package db
+
+func (s *sqlStorage) GetAssetName(assetID string) (string, error) {
+	const query = "SELECT `name` FROM `asset` WHERE `asset_id` = ? LIMIT 1"
+	rows, err := s.query(query, assetID)
+	if err != nil {
+		return "", err
+	}
+	defer rows.Close()
+
+	name := ""
+	if rows.Next() {
+		if err := rows.Scan(&name); err != nil {
+			return "", err
+		}
+	}
+
+	return name, rows.Err()
+}
@@ -0,0 +1,128 @@
+package db
+
+import (
+	"database/sql"
+	"sort"
+	"squirrel/amount"
+	"squirrel/config"
+	"squirrel/tx"
+	"time"
+)
+
+// balanceDate returns the UTC date of the block time, which daily balances are recorded by.
+func balanceDate(blockTime uint64) string {
+	return time.Unix(int64(blockTime), 0).UTC().Format("2006-01-02")
+}
+
+func (s *sqlStorage) ApplyAddrAssetBalances(t *tx.Transaction, changes map[string]map[string]amount.Amount) error {
+	date := balanceDate(t.BlockTime)
+
+	return s.transact(func(trans *txn) error {
+		// Sort keys to avoid potential deadlock.
+		addrs := make([]string, 0, len(changes))
+		for address := range changes {
+			addrs = append(addrs, address)
+		}
+		sort.Strings(addrs)
+
+		for _, address := range addrs {
+			assetIDs := make([]string, 0, len(changes[address]))
+			for assetID := range changes[address] {
+				assetIDs = append(assetIDs, assetID)
+			}
+			sort.Strings(assetIDs)
+
+			for _, assetID := range assetIDs {
+				if err := applyAddrAssetBalance(trans, address, assetID, date, changes[address][assetID]); err != nil {
+					return err
+				}
+			}
+		}
+
+		return updateCounter(trans, "last_tx_pk_asset_balance", int64(t.ID))
+	})
+}
+
+// applyAddrAssetBalance adds the change to the balance of the date,
+// the row of the date starts from the balance of the last recorded date.
+func applyAddrAssetBalance(trans *txn, address, assetID, date string, change amount.Amount) error {
+	// Drivers return DATE columns as time.Time.
+	var lastDate time.Time
+	var balance amount.Amount
+
+	const query = "SELECT `date`, `balance` FROM `addr_asset_balance` WHERE `address` = ? AND `asset_id` = ? ORDER BY `date` DESC LIMIT 1"
+	err := trans.QueryRow(query, address, assetID).Scan(&lastDate, &balance)
+	switch {
+	case err == sql.ErrNoRows:
+		balance = amount.Zero
+	case err != nil:
+		return err
+	case lastDate.Format("2006-01-02") == date:
+		const updateQuery = "UPDATE `addr_asset_balance` SET `balance` = ? WHERE `address` = ? AND `asset_id` = ? AND `date` = ? LIMIT 1"
+		_, err := trans.Exec(updateQuery, decimalArg(balance.Add(change)), address, assetID, date)
+		return err
+	}
+
+	const insertQuery = "INSERT INTO `addr_asset_balance` (`address`, `asset_id`, `date`, `balance`) VALUES (?, ?, ?, ?)"
+	_, err = trans.Exec(insertQuery, address, assetID, date, decimalArg(balance.Add(change)))
+	return err
+}
+
+// revertAddrAssetBalances subtracts balance changes of removed transactions applied by the balance task,
+// only assets configured by balance_assets are recorded.
+func (s *sqlStorage) revertAddrAssetBalances(trans *txn, removed []*tx.Transaction, vinMap map[string][]*tx.TransactionVin, voutMap map[string][]*tx.TransactionVout) error {
+	assets := make(map[string]bool)
+	for _, id := range config.GetBalanceAssets() {
+		assets[id] = true
+	}
+
+	var lastPk uint
+	const counterQuery = "SELECT `last_tx_pk_asset_balance` FROM `counter` WHERE `id` = 1 LIMIT 1"
+	if err := trans.QueryRow(counterQuery).Scan(&lastPk); err != nil {
+		return err
+	}
+
+	for _, t := range removed {
+		if t.ID > lastPk {
+			continue
+		}
+
+		spent := []*tx.TransactionVout{}
+		for _, vin := range vinMap[t.TxID] {
+			vout, err := getVout(trans, vin.TxID, vin.Vout)
+			if err != nil {
+				return err
+			}
+			if vout != nil {
+				spent = append(spent, vout)
+			}
+		}
+
+		date := balanceDate(t.BlockTime)
+		for address, assetChanges := range tx.BalanceChanges(spent, voutMap[t.TxID]) {
+			for assetID, change := range assetChanges {
+				if !assets[assetID] {
+					continue
+				}
+
+				const query = "UPDATE `addr_asset_balance` SET `balance` = `balance` - ? WHERE `address` = ? AND `asset_id` = ? AND `date` >= ?"
+				if _, err := trans.Exec(query, decimalArg(change), address, assetID, date); err != nil {
+					return err
+				}
+			}
+		}
+	}
+
+	return nil
+}
+
+func (s *sqlStorage) GetAddrAssetBalance(address, assetID, date string) (amount.Amount, error) {
+	var balance amount.Amount
+	const query = "SELECT `balance` FROM `addr_asset_balance` WHERE `address` = ? AND `asset_id` = ? AND `date` <= ? ORDER BY `date` DESC LIMIT 1"
+	err := s.queryRow(query, address, assetID, date).Scan(&balance)
+	if err == sql.ErrNoRows {
+		return amount.Zero, nil
+	}
+
+	return balance, err
+}
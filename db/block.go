@@ -1,42 +1,42 @@
 package db
 
 import (
-	"database/sql"
-	"fmt"
+	"squirrel/addr"
 	"squirrel/asset"
 	"squirrel/block"
 	"squirrel/tx"
 	"strings"
 )
 
-// InsertBlock inserts raw block data into database.
-func InsertBlock(maxIndex int, blocks []*block.Block, txBulk *tx.Bulk) error {
-	insertBlocksCmd := generateInsertCmdForBlock(blocks)
-	insertTxsCmd := generateInsertCmdForTxs(txBulk.TXs)
-	insertTxAttrsCmd := generateInsertCmdForTxAttrs(txBulk.TXAttrs)
-	insertTxVinsCmd := generateInsertCmdForTxVins(txBulk.TXVins)
-	insertTxVoutsCmd := generateInsertCmdForTxVouts(txBulk.TXVouts)
-	insertTxScriptsCmd := generateInsertCmdForTxScripts(txBulk.TXScripts)
-	insertAssetsCmd := generateInsertCmdForAssets(txBulk.Assets)
-	insertClaims := generateInsertCmdForClaims(txBulk.Claims)
-
-	cmdList := []string{
-		insertBlocksCmd,
-		insertTxsCmd,
-		insertTxAttrsCmd,
-		insertTxVinsCmd,
-		insertTxVoutsCmd,
-		insertTxScriptsCmd,
-		insertAssetsCmd,
-		insertClaims,
-	}
-
-	return transact(func(tx *sql.Tx) error {
+func (s *sqlStorage) InsertBlock(maxIndex int, blocks []*block.Block, txBulk *tx.Bulk) error {
+	cmdList := []*bulkInsert{
+		generateInsertCmdForBlock(blocks),
+		generateInsertCmdForTxs(txBulk.TXs),
+		generateInsertCmdForTxAttrs(txBulk.TXAttrs),
+		generateInsertCmdForTxVins(txBulk.TXVins),
+		generateInsertCmdForTxVouts(txBulk.TXVouts),
+		generateInsertCmdForTxScripts(txBulk.TXScripts),
+		generateInsertCmdForAssets(txBulk.Assets),
+		generateInsertCmdForClaims(txBulk.Claims),
+		generateInsertCmdForInvalidWitnesses(txBulk.InvalidWitnesses),
+		generateInsertCmdForAddrContracts(txBulk.AddrContracts),
+	}
+
+	return s.transact(func(tx *txn) error {
 		for _, cmd := range cmdList {
-			if cmd == "" {
-				continue
+			if err := cmd.exec(tx); err != nil {
+				return err
 			}
-			if _, err := tx.Exec(cmd); err != nil {
+		}
+
+		if err := insertBlockFees(tx, txBulk.BlockFees); err != nil {
+			return err
+		}
+
+		// Blocks stored before their successors were known lack the next block hash.
+		if len(blocks) > 0 && blocks[0].Index > 0 {
+			const query = "UPDATE `block` SET `nextblockhash` = ? WHERE `index` = ?"
+			if _, err := tx.Exec(query, blocks[0].Hash, blocks[0].Index-1); err != nil {
 				return err
 			}
 		}
@@ -55,119 +55,106 @@ func InsertBlock(maxIndex int, blocks []*block.Block, txBulk *tx.Bulk) error {
 	})
 }
 
-func generateInsertCmdForBlock(blocks []*block.Block) string {
-	if len(blocks) == 0 {
-		return ""
-	}
-
-	var strBuilder strings.Builder
-	strBuilder.WriteString("INSERT INTO `block` (`hash`, `size`, `version`, `previousblockhash`, `merkleroot`, `time`, `index`, `nonce`, `nextconsensus`, `script_invocation`, `script_verification`, `nextblockhash`) VALUES ")
+func generateInsertCmdForBlock(blocks []*block.Block) *bulkInsert {
+	cmd := newBulkInsert("block", "hash", "size", "version", "previousblockhash", "merkleroot", "time", "index", "nonce", "nextconsensus", "script_invocation", "script_verification", "nextblockhash")
 
 	for _, b := range blocks {
-		strBuilder.WriteString(fmt.Sprintf("('%s', %d, %d, '%s', '%s', %d, %d, '%s', '%s', '%s', '%s', '%s'),", b.Hash, b.Size, b.Version, b.PreviousBlockHash, b.MerkleRoot, b.Time, b.Index, b.Nonce, b.NextConsensus, b.ScriptInvocation, b.ScriptVerification, b.NextBlockhash))
+		cmd.addRow(b.Hash, b.Size, b.Version, b.PreviousBlockHash, b.MerkleRoot, b.Time, b.Index, b.Nonce, b.NextConsensus, b.ScriptInvocation, b.ScriptVerification, b.NextBlockhash)
 	}
 
-	return strings.TrimSuffix(strBuilder.String(), ",")
+	return cmd
 }
 
-func generateInsertCmdForTxs(txs []*tx.Transaction) string {
-	if len(txs) == 0 {
-		return ""
-	}
-
-	var strBuilder strings.Builder
-	strBuilder.WriteString("INSERT INTO `tx` (`block_index`, `block_time`, `txid`, `size`, `type`, `version`, `sys_fee`, `net_fee`, `nonce`, `script`, `gas`) VALUES ")
+func generateInsertCmdForTxs(txs []*tx.Transaction) *bulkInsert {
+	cmd := newBulkInsert("tx", "block_index", "block_time", "txid", "size", "type", "version", "sys_fee", "net_fee", "nonce", "script", "gas")
 
 	for _, tx := range txs {
-		strBuilder.WriteString(fmt.Sprintf("(%d, %d, '%s', %d, '%s', %d, %.8f, %.8f, %d, '%s', %.8f),", tx.BlockIndex, tx.BlockTime, tx.TxID, tx.Size, tx.Type, tx.Version, tx.SysFee, tx.NetFee, tx.Nonce, tx.Script, tx.Gas))
+		cmd.addRow(tx.BlockIndex, tx.BlockTime, tx.TxID, tx.Size, tx.Type, tx.Version, decimalArg(tx.SysFee), decimalArg(tx.NetFee), tx.Nonce, tx.Script, decimalArg(tx.Gas))
 	}
-	return strings.TrimSuffix(strBuilder.String(), ",")
-}
 
-func generateInsertCmdForTxAttrs(txAttrs []*tx.TransactionAttribute) string {
-	if len(txAttrs) == 0 {
-		return ""
-	}
+	return cmd
+}
 
-	var strBuilder strings.Builder
-	strBuilder.WriteString("INSERT INTO `tx_attr` (`txid`, `usage`, `data`) VALUES ")
+func generateInsertCmdForTxAttrs(txAttrs []*tx.TransactionAttribute) *bulkInsert {
+	cmd := newBulkInsert("tx_attr", "txid", "usage", "data")
 
 	for _, attr := range txAttrs {
-		strBuilder.WriteString(fmt.Sprintf("('%s', '%s', '%s'),", attr.TxID, attr.Usage, attr.Data))
+		cmd.addRow(attr.TxID, attr.Usage, attr.Data)
 	}
 
-	return strings.TrimSuffix(strBuilder.String(), ",")
+	return cmd
 }
 
-func generateInsertCmdForTxVins(txVins []*tx.TransactionVin) string {
-	if len(txVins) == 0 {
-		return ""
-	}
-
-	var strBuilder strings.Builder
-	strBuilder.WriteString("INSERT INTO `tx_vin` (`from`, `txid`, `vout`) VALUES ")
+func generateInsertCmdForTxVins(txVins []*tx.TransactionVin) *bulkInsert {
+	cmd := newBulkInsert("tx_vin", "from", "txid", "vout")
 
 	for _, vin := range txVins {
-		strBuilder.WriteString(fmt.Sprintf("('%s', '%s', %d),", vin.From, vin.TxID, vin.Vout))
+		cmd.addRow(vin.From, vin.TxID, vin.Vout)
 	}
 
-	return strings.TrimSuffix(strBuilder.String(), ",")
+	return cmd
 }
 
-func generateInsertCmdForTxVouts(txVouts []*tx.TransactionVout) string {
-	if len(txVouts) == 0 {
-		return ""
-	}
-
-	var strBuilder strings.Builder
-	strBuilder.WriteString("INSERT INTO `tx_vout` (`txid`, `n`, `asset_id`, `value`, `address`) VALUES ")
+func generateInsertCmdForTxVouts(txVouts []*tx.TransactionVout) *bulkInsert {
+	cmd := newBulkInsert("tx_vout", "txid", "n", "asset_id", "value", "address")
 
 	for _, vout := range txVouts {
-		strBuilder.WriteString(fmt.Sprintf("('%s', %d, '%s', %.8f, '%s'),", vout.TxID, vout.N, vout.AssetID, vout.Value, vout.Address))
+		cmd.addRow(vout.TxID, vout.N, vout.AssetID, decimalArg(vout.Value), vout.Address)
 	}
 
-	return strings.TrimSuffix(strBuilder.String(), ",")
+	return cmd
 }
 
-func generateInsertCmdForTxScripts(txScripts []*tx.TransactionScripts) string {
-	if len(txScripts) == 0 {
-		return ""
-	}
-	var strBuilder strings.Builder
-	strBuilder.WriteString("INSERT INTO `tx_scripts` (`txid`, `invocation`, `verification`) VALUES ")
+func generateInsertCmdForTxScripts(txScripts []*tx.TransactionScripts) *bulkInsert {
+	cmd := newBulkInsert("tx_scripts", "txid", "invocation", "verification")
 
 	for _, script := range txScripts {
-		strBuilder.WriteString(fmt.Sprintf("('%s', '%s', '%s'),", script.TxID, script.Invocation, script.Verification))
+		cmd.addRow(script.TxID, script.Invocation, script.Verification)
 	}
-	return strings.TrimSuffix(strBuilder.String(), ",")
+
+	return cmd
 }
 
-func generateInsertCmdForAssets(assets []*asset.Asset) string {
-	if len(assets) == 0 {
-		return ""
+func generateInsertCmdForAssets(assets []*asset.Asset) *bulkInsert {
+	cmd := newBulkInsert("asset", "block_index", "block_time", "version", "asset_id", "type", "name", "amount", "available", "precision", "owner", "admin", "issuer", "expiration", "frozen", "addresses", "transactions")
+
+	for _, asset := range assets {
+		cmd.addRow(asset.BlockIndex, asset.BlockTime, asset.Version, asset.AssetID, asset.Type, asset.Name, decimalArg(asset.Amount), decimalArg(asset.Available), asset.Precision, asset.Owner, asset.Admin, asset.Issuer, asset.Expiration, asset.Frozen, asset.Addresses, asset.Transactions)
 	}
 
-	var strBuilder strings.Builder
-	strBuilder.WriteString("INSERT INTO `asset` (`block_index`, `block_time`, `version`, `asset_id`, `type`, `name`, `amount`, `available`, `precision`, `owner`, `admin`, `issuer`, `expiration`, `frozen`, `addresses`, `transactions`) VALUES ")
+	return cmd
+}
 
-	for _, asset := range assets {
-		strBuilder.WriteString(fmt.Sprintf("(%d, %d, %d, '%s', '%s', '%s', %.8f, %.8f, %d, '%s', '%s', '%s', %d, %t, %d, %d),", asset.BlockIndex, asset.BlockTime, asset.Version, asset.AssetID, asset.Type, asset.Name, asset.Amount, asset.Available, asset.Precision, asset.Owner, asset.Admin, asset.Issuer, asset.Expiration, asset.Frozen, asset.Addresses, asset.Transactions))
+func generateInsertCmdForClaims(claims []*tx.TransactionClaims) *bulkInsert {
+	cmd := newBulkInsert("tx_claims", "from", "txid", "vout")
+
+	for _, claim := range claims {
+		cmd.addRow(claim.From, claim.TxID, claim.Vout)
 	}
-	return strings.TrimSuffix(strBuilder.String(), ",")
+
+	return cmd
 }
 
-func generateInsertCmdForClaims(claims []*tx.TransactionClaims) string {
-	if len(claims) == 0 {
-		return ""
+func generateInsertCmdForInvalidWitnesses(witnesses []*tx.InvalidWitness) *bulkInsert {
+	cmd := newBulkInsert("invalid_witness", "txid", "block_index", "reason")
+
+	for _, w := range witnesses {
+		cmd.addRow(w.TxID, w.BlockIndex, w.Reason)
 	}
 
-	var strBuilder strings.Builder
-	strBuilder.WriteString("INSERT INTO `tx_claims` (`txid`, `vout`) VALUES ")
+	return cmd
+}
 
-	for _, claim := range claims {
-		strBuilder.WriteString(fmt.Sprintf("('%s', %d),", claim.TxID, claim.Vout))
+func generateInsertCmdForAddrContracts(contracts []*addr.Contract) *bulkInsert {
+	cmd := newBulkInsert("addr_contract", "address", "type", "threshold", "public_keys", "script", "txid", "block_index")
+	// Contracts of addresses seen in earlier blocks are kept.
+	cmd.ignoreDuplicates = true
+
+	for _, c := range contracts {
+		cmd.addRow(c.Address, c.Type, c.Threshold, strings.Join(c.PublicKeys, ","), c.Script, c.TxID, c.BlockIndex)
 	}
-	return strings.TrimSuffix(strBuilder.String(), ",")
+
+	return cmd
 }
 
 func countTxTypes(txs []*tx.Transaction) map[int]int {
@@ -196,3 +183,68 @@ func countTxTypes(txs []*tx.Transaction) map[int]int {
 
 	return txTypeCounter
 }
+
+func (s *sqlStorage) GetBlock(index uint) (*block.Block, error) {
+	const query = "SELECT `id`, `hash`, `size`, `version`, `previousblockhash`, `merkleroot`, `time`, `index`, `nonce`, `nextconsensus`, `script_invocation`, `script_verification`, `nextblockhash` FROM `block` WHERE `index` = ? LIMIT 1"
+	return s.queryBlock(query, index)
+}
+
+func (s *sqlStorage) GetBlockByHash(hash string) (*block.Block, error) {
+	const query = "SELECT `id`, `hash`, `size`, `version`, `previousblockhash`, `merkleroot`, `time`, `index`, `nonce`, `nextconsensus`, `script_invocation`, `script_verification`, `nextblockhash` FROM `block` WHERE `hash` = ? LIMIT 1"
+	return s.queryBlock(query, hash)
+}
+
+func (s *sqlStorage) queryBlock(query string, args ...interface{}) (*block.Block, error) {
+	rows, err := s.query(query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	if !rows.Next() {
+		return nil, rows.Err()
+	}
+
+	var b block.Block
+	err = rows.Scan(
+		&b.ID,
+		&b.Hash,
+		&b.Size,
+		&b.Version,
+		&b.PreviousBlockHash,
+		&b.MerkleRoot,
+		&b.Time,
+		&b.Index,
+		&b.Nonce,
+		&b.NextConsensus,
+		&b.ScriptInvocation,
+		&b.ScriptVerification,
+		&b.NextBlockhash,
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	return &b, nil
+}
+
+func (s *sqlStorage) GetBlockTxIDs(index uint) ([]string, error) {
+	const query = "SELECT `txid` FROM `tx` WHERE `block_index` = ? ORDER BY `id` ASC"
+	rows, err := s.query(query, index)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	txIDs := []string{}
+
+	for rows.Next() {
+		var txID string
+		if err := rows.Scan(&txID); err != nil {
+			return nil, err
+		}
+		txIDs = append(txIDs, txID)
+	}
+
+	return txIDs, rows.Err()
+}
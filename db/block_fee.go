@@ -0,0 +1,137 @@
+package db
+
+import (
+	"database/sql"
+	"squirrel/amount"
+	"squirrel/tx"
+)
+
+// insertBlockFees inserts fees of consecutive blocks, cumulative system fees continue from the preceding block.
+// Rows existing already are kept, which happens when the backfill task and block storage overlap.
+func insertBlockFees(trans *txn, fees []*tx.BlockFee) error {
+	if len(fees) == 0 {
+		return nil
+	}
+
+	cumulative, err := cumulativeSysFeeBefore(trans, fees[0].BlockIndex)
+	if err != nil {
+		return err
+	}
+
+	cmd := newBulkInsert("block_fee", "block_index", "sys_fee", "net_fee", "cumulative_sys_fee")
+	cmd.ignoreDuplicates = true
+
+	for _, fee := range fees {
+		cumulative = cumulative.Add(fee.SysFee)
+		fee.CumulativeSysFee = cumulative
+		cmd.addRow(fee.BlockIndex, decimalArg(fee.SysFee), decimalArg(fee.NetFee), decimalArg(fee.CumulativeSysFee))
+	}
+
+	return cmd.exec(trans)
+}
+
+// cumulativeSysFeeBefore returns the system fee of all blocks before the given one.
+// The transactions are summed if the preceding block has no fee recorded yet.
+func cumulativeSysFeeBefore(trans *txn, index uint) (amount.Amount, error) {
+	if index == 0 {
+		return amount.Zero, nil
+	}
+
+	var cumulative amount.Amount
+	const query = "SELECT `cumulative_sys_fee` FROM `block_fee` WHERE `block_index` = ? LIMIT 1"
+	err := trans.QueryRow(query, index-1).Scan(&cumulative)
+	if err != sql.ErrNoRows {
+		return cumulative, err
+	}
+
+	const sumQuery = "SELECT COALESCE(SUM(`sys_fee`), 0) FROM `tx` WHERE `block_index` < ?"
+	err = trans.QueryRow(sumQuery, index).Scan(&cumulative)
+	return cumulative, err
+}
+
+// cumulativeSysFee returns the recorded system fee of all blocks till the given one,
+// false if the block has no fee recorded.
+func (s *sqlStorage) cumulativeSysFee(index uint) (amount.Amount, bool, error) {
+	var cumulative amount.Amount
+	const query = "SELECT `cumulative_sys_fee` FROM `block_fee` WHERE `block_index` = ? LIMIT 1"
+	err := s.queryRow(query, index).Scan(&cumulative)
+	switch err {
+	case nil:
+		return cumulative, true, nil
+	case sql.ErrNoRows:
+		return amount.Zero, false, nil
+	default:
+		return amount.Zero, false, err
+	}
+}
+
+func (s *sqlStorage) GetBlockFee(index uint) (*tx.BlockFee, error) {
+	fee := tx.BlockFee{BlockIndex: index}
+	const query = "SELECT `sys_fee`, `net_fee`, `cumulative_sys_fee` FROM `block_fee` WHERE `block_index` = ? LIMIT 1"
+	err := s.queryRow(query, index).Scan(&fee.SysFee, &fee.NetFee, &fee.CumulativeSysFee)
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	return &fee, nil
+}
+
+func (s *sqlStorage) GetBlockFeeBackfillEnd() (uint, error) {
+	backfilled := s.GetBlockFeeBackfillIndex()
+
+	var end sql.NullInt64
+	const query = "SELECT MIN(`block_index`) FROM `block_fee` WHERE `block_index` >= ?"
+	if err := s.queryRow(query, backfilled).Scan(&end); err != nil {
+		return 0, err
+	}
+	if end.Valid {
+		return uint(end.Int64), nil
+	}
+
+	// No block is stored since the migration.
+	return uint(s.GetLastHeight() + 1), nil
+}
+
+func (s *sqlStorage) GetBlockFees(start, end uint) ([]*tx.BlockFee, error) {
+	fees := make([]*tx.BlockFee, 0, end-start)
+	for index := start; index < end; index++ {
+		fees = append(fees, &tx.BlockFee{
+			BlockIndex: index,
+			SysFee:     amount.Zero,
+			NetFee:     amount.Zero,
+		})
+	}
+
+	const query = "SELECT `block_index`, COALESCE(SUM(`sys_fee`), 0), COALESCE(SUM(`net_fee`), 0) FROM `tx` WHERE `block_index` >= ? AND `block_index` < ? GROUP BY `block_index`"
+	rows, err := s.query(query, start, end)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var index uint
+		var sysFee, netFee amount.Amount
+		if err := rows.Scan(&index, &sysFee, &netFee); err != nil {
+			return nil, err
+		}
+
+		fees[index-start].SysFee = sysFee
+		fees[index-start].NetFee = netFee
+	}
+
+	return fees, rows.Err()
+}
+
+func (s *sqlStorage) BackfillBlockFees(fees []*tx.BlockFee, next uint) error {
+	return s.transact(func(trans *txn) error {
+		if err := insertBlockFees(trans, fees); err != nil {
+			return err
+		}
+
+		return updateCounter(trans, "block_fee_backfill_index", int64(next))
+	})
+}
@@ -24,15 +24,23 @@ type Counter struct {
 	CntTxClaim         uint
 	CntTxPublish       uint
 	CntTxEnrollment    uint
+	// BlockFeeBackfillIndex is the next block whose fee is backfilled.
+	BlockFeeBackfillIndex uint
+	LastTxPkAssetBalance  uint
+	// UTXOGasBackfillPk is the last utxo pk whose GAS bonus is backfilled,
+	// outputs up to UTXOGasBackfillEnd were stored without it.
+	UTXOGasBackfillPk  uint
+	UTXOGasBackfillEnd uint
+	// Nep5BalanceStartIndex is the first block whose nep5 balance changes are all recorded.
+	Nep5BalanceStartIndex uint
 }
 
-// GetLastHeight returns the highest block index stored in database.
-func GetLastHeight() int {
-	counter := getCounterInstance()
+func (s *sqlStorage) GetLastHeight() int {
+	counter := s.getCounterInstance()
 	return counter.LastBlockIndex
 }
 
-func initCounterInstance() Counter {
+func (s *sqlStorage) initCounterInstance() Counter {
 	c := Counter{
 		ID:                 1,
 		LastBlockIndex:     -1,
@@ -53,7 +61,7 @@ func initCounterInstance() Counter {
 	}
 	const query = "INSERT INTO `counter` (`id`, `last_block_index`, `last_tx_pk`, `last_asset_tx_pk`, `last_tx_pk_for_nep5`, `app_log_idx`, `nep5_tx_pk_for_addr_tx`, `last_tx_pk_gas_balance`, `cnt_tx_reg`, `cnt_tx_miner`, `cnt_tx_issue`, `cnt_tx_invocation`, `cnt_tx_contract`, `cnt_tx_claim`, `cnt_tx_publish`, `cnt_tx_enrollment`) VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)"
 
-	_, err := db.Exec(query,
+	_, err := s.exec(query,
 		c.ID,
 		c.LastBlockIndex,
 		c.LastTxPk,
@@ -78,11 +86,11 @@ func initCounterInstance() Counter {
 	return c
 }
 
-func getCounterInstance() Counter {
-	const query = "SELECT `id`, `last_block_index`, `last_tx_pk`, `last_asset_tx_pk`, `last_tx_pk_for_nep5`, `app_log_idx`, `nep5_tx_pk_for_addr_tx`, `last_tx_pk_gas_balance` FROM `counter` WHERE `id` = 1 LIMIT 1"
+func (s *sqlStorage) getCounterInstance() Counter {
+	const query = "SELECT `id`, `last_block_index`, `last_tx_pk`, `last_asset_tx_pk`, `last_tx_pk_for_nep5`, `app_log_idx`, `nep5_tx_pk_for_addr_tx`, `last_tx_pk_gas_balance`, `block_fee_backfill_index`, `last_tx_pk_asset_balance`, `utxo_gas_backfill_pk`, `utxo_gas_backfill_end`, `nep5_balance_start_index` FROM `counter` WHERE `id` = 1 LIMIT 1"
 
 	var counter Counter
-	err := db.QueryRow(query).Scan(
+	err := s.queryRow(query).Scan(
 		&counter.ID,
 		&counter.LastBlockIndex,
 		&counter.LastTxPk,
@@ -91,73 +99,81 @@ func getCounterInstance() Counter {
 		&counter.AppLogIdx,
 		&counter.Nep5TxPkForAddrTx,
 		&counter.LastTxPkGasBalacne,
+		&counter.BlockFeeBackfillIndex,
+		&counter.LastTxPkAssetBalance,
+		&counter.UTXOGasBackfillPk,
+		&counter.UTXOGasBackfillEnd,
+		&counter.Nep5BalanceStartIndex,
 	)
 	switch err {
 	case sql.ErrNoRows:
-		return initCounterInstance()
+		return s.initCounterInstance()
 	case nil:
 		return counter
 	default:
-		reconnect()
-		return getCounterInstance()
+		s.reconnect()
+		return s.getCounterInstance()
 	}
 }
 
-// GetLastTxPkCounter returns the last resolved pk of transaction in counter.
-func GetLastTxPkCounter() uint {
-	counter := getCounterInstance()
+func (s *sqlStorage) GetLastTxPkCounter() uint {
+	counter := s.getCounterInstance()
 	return counter.LastTxPk
 }
 
-// GetLastAssetTxPkCounter returns the last resolved pk of asset transaction in counter.
-func GetLastAssetTxPkCounter() uint {
-	counter := getCounterInstance()
+func (s *sqlStorage) GetLastAssetTxPkCounter() uint {
+	counter := s.getCounterInstance()
 	return counter.LastAssetTxPk
 }
 
-// GetLastTxPkForNep5 returns counter info of last processed nep5 transactions.
-func GetLastTxPkForNep5() (uint, int) {
-	counter := getCounterInstance()
+func (s *sqlStorage) GetLastTxPkForNep5() (uint, int) {
+	counter := s.getCounterInstance()
 	return counter.LastTxPkForNep5, counter.AppLogIdx
 }
 
-// GetLastTxPkForGasBalance returns the last resolved pk of gas balance task.
-func GetLastTxPkForGasBalance() uint {
-	counter := getCounterInstance()
-	return counter.LastTxPkGasBalacne
+func (s *sqlStorage) GetLastTxPkForAssetBalance() uint {
+	counter := s.getCounterInstance()
+	return counter.LastTxPkAssetBalance
 }
 
-// GetNep5TxPkForAddrTx returns last pk of handled nep5 tx records.
-func GetNep5TxPkForAddrTx() uint {
-	counter := getCounterInstance()
+func (s *sqlStorage) GetBlockFeeBackfillIndex() uint {
+	counter := s.getCounterInstance()
+	return counter.BlockFeeBackfillIndex
+}
+
+func (s *sqlStorage) GetUTXOGasBackfillPk() (uint, uint) {
+	counter := s.getCounterInstance()
+	return counter.UTXOGasBackfillPk, counter.UTXOGasBackfillEnd
+}
+
+func (s *sqlStorage) GetNep5TxPkForAddrTx() uint {
+	counter := s.getCounterInstance()
 	return counter.Nep5TxPkForAddrTx
 }
 
-// UpdateLastTxPk updates last pk of processed transaction.
-func UpdateLastTxPk(txPk uint) error {
+func (s *sqlStorage) UpdateLastTxPk(txPk uint) error {
 	const updateCounterSQL = "UPDATE `counter` SET `last_tx_pk` = ? WHERE `id` = 1 LIMIT 1"
-	_, err := db.Exec(updateCounterSQL, txPk)
+	_, err := s.exec(updateCounterSQL, txPk)
 	return err
 }
 
-// UpdateLastTxPkForNep5 updates counter info of last processed nep5 transactions.
-func UpdateLastTxPkForNep5(currentTxPk uint, applogIdx int) error {
+func (s *sqlStorage) UpdateLastTxPkForNep5(currentTxPk uint, applogIdx int) error {
 	const updateCounterSQL = "UPDATE `counter` SET `last_tx_pk_for_nep5` = ?, `app_log_idx` = ? WHERE `id` = 1 LIMIT 1"
-	_, err := db.Exec(updateCounterSQL, currentTxPk, applogIdx)
+	_, err := s.exec(updateCounterSQL, currentTxPk, applogIdx)
 	return err
 }
 
-func updateCounter(tx *sql.Tx, key string, value int64) error {
-	sql := fmt.Sprintf("UPDATE `counter` SET %s = %d WHERE `id`=1", key, value)
+func updateCounter(tx *txn, key string, value int64) error {
+	sql := fmt.Sprintf("UPDATE `counter` SET `%s` = ? WHERE `id` = 1", key)
 
-	_, err := tx.Exec(sql)
+	_, err := tx.Exec(sql, value)
 	if err != nil {
 		return err
 	}
 	return nil
 }
 
-func updateNep5Counter(tx *sql.Tx, lastTxPkForNep5 uint, appLogIdx int) error {
+func updateNep5Counter(tx *txn, lastTxPkForNep5 uint, appLogIdx int) error {
 	const sql = "UPDATE `counter` SET `last_tx_pk_for_nep5` = ?, `app_log_idx` = ? WHERE `id` = 1 LIMIT 1"
 	_, err := tx.Exec(sql, lastTxPkForNep5, appLogIdx)
 	if err != nil {
@@ -166,14 +182,14 @@ func updateNep5Counter(tx *sql.Tx, lastTxPkForNep5 uint, appLogIdx int) error {
 	return nil
 }
 
-// UpdateNep5TxPkForAddrTx updates last pk of handled nep5 tx records.
-func UpdateNep5TxPkForAddrTx(tx *sql.Tx, pk uint) error {
+// updateNep5TxPkForAddrTx updates last pk of handled nep5 tx records.
+func updateNep5TxPkForAddrTx(tx *txn, pk uint) error {
 	const query = "UPDATE `counter` SET `nep5_tx_pk_for_addr_tx` = ? WHERE `id` = 1 LIMIT 1"
 	_, err := tx.Exec(query, pk)
 	return err
 }
 
-func updateTxCounter(trans *sql.Tx, txType int, cnt int) error {
+func updateTxCounter(trans *txn, txType int, cnt int) error {
 	query := ""
 
 	switch txType {
@@ -1,50 +1,113 @@
 package db
 
 import (
+	"context"
 	"database/sql"
+	"fmt"
+	"squirrel/amount"
 	"squirrel/config"
 	"squirrel/log"
-	"strings"
+	"squirrel/migrations"
 	"sync/atomic"
 	"time"
-
-	"github.com/go-sql-driver/mysql"
 )
 
-var (
-	db     *sql.DB
-	locker uint32
-)
+var storage Storage
 
-// Init connects to the configured mysql database.
+// Init connects to the configured database.
 func Init() {
-	var err error
+	var d dialect
+
+	switch config.GetDbDriver() {
+	case "mysql":
+		d = mysqlDialect{}
+	case "postgres":
+		d = postgresDialect{}
+	case "sqlite3":
+		d = sqliteDialect{}
+	default:
+		panic(fmt.Errorf("unsupported database driver: %s", config.GetDbDriver()))
+	}
+
+	s := newSQLStorage(d, config.GetDbConnStr)
+
+	// Fail fast instead of indexing into a schema this build does not understand.
+	version, err := migrations.Up(s.conn, config.GetDbDriver())
+	if err != nil {
+		panic(err)
+	}
+	log.Printf("Database schema version: %d\n", version)
+
+	storage = s
+}
+
+// InitSQLite uses the SQLite database file at path instead of the configured database,
+// so that tests of packages querying db run against a real schema. It returns a function closing the database.
+func InitSQLite(path string) func() {
+	connStr := "file:" + path + "?_journal_mode=WAL&_busy_timeout=10000&_txlock=immediate"
+	s := newSQLStorage(sqliteDialect{}, func() string { return connStr })
+
+	if _, err := migrations.Up(s.conn, "sqlite3"); err != nil {
+		panic(err)
+	}
+
+	storage = s
+	return func() {
+		s.conn.Close()
+		storage = nil
+	}
+}
+
+// sqlStorage implements Storage on a database/sql connection,
+// queries are written in MySQL syntax and translated by the dialect.
+type sqlStorage struct {
+	conn    *sql.DB
+	dialect dialect
+	connStr func() string
+	locker  uint32
+	// txSysFee accumulates system fees for the tx task.
+	txSysFee sysFeeCache
+}
+
+// txn wraps sql.Tx so that queries executed in transactions are translated by the dialect.
+type txn struct {
+	*sql.Tx
+	dialect dialect
+}
 
-	db, err = sql.Open("mysql", config.GetDbConnStr())
+func newSQLStorage(d dialect, connStr func() string) *sqlStorage {
+	conn, err := sql.Open(d.driverName(), connStr())
 	if err != nil {
 		panic(err)
 	}
+
+	return &sqlStorage{
+		conn:     conn,
+		dialect:  d,
+		connStr:  connStr,
+		txSysFee: sysFeeCache{height: -1, sysFee: amount.Zero},
+	}
 }
 
-func reconnect() {
-	if !atomic.CompareAndSwapUint32(&locker, 0, 1) {
+func (s *sqlStorage) reconnect() {
+	if !atomic.CompareAndSwapUint32(&s.locker, 0, 1) {
 		for {
 			// Lock was held by others, wait till lock released.
 			time.Sleep(20 * time.Millisecond)
 			// Lock was released.
-			if atomic.LoadUint32(&locker) != 1 {
+			if atomic.LoadUint32(&s.locker) != 1 {
 				return
 			}
 		}
 	}
 
-	defer atomic.StoreUint32(&locker, 0)
+	defer atomic.StoreUint32(&s.locker, 0)
 
 	for {
 		log.Printf("Try Reconnecting to database...")
-		db, _ = sql.Open("mysql", config.GetDbConnStr())
+		s.conn, _ = sql.Open(s.dialect.driverName(), s.connStr())
 
-		if err := db.Ping(); err == nil {
+		if err := s.conn.Ping(); err == nil {
 			return
 		}
 
@@ -53,65 +116,98 @@ func reconnect() {
 	}
 }
 
-func wrappedQuery(query string, args ...interface{}) (*sql.Rows, error) {
+func (s *sqlStorage) Ping(ctx context.Context) error {
+	return s.conn.PingContext(ctx)
+}
+
+// query runs the query and reconnects on connection errors.
+func (s *sqlStorage) query(query string, args ...interface{}) (*sql.Rows, error) {
 	for {
-		rows, err := db.Query(query, args...)
+		rows, err := s.conn.Query(s.dialect.rebind(query), args...)
 		if err == nil {
 			return rows, err
 		}
 
-		if !connErr(err) {
-			rows.Close()
+		if !s.connErr(err) {
 			return nil, err
 		}
 
-		reconnect()
+		s.reconnect()
+	}
+}
+
+func (s *sqlStorage) queryRow(query string, args ...interface{}) *sql.Row {
+	return s.conn.QueryRow(s.dialect.rebind(query), args...)
+}
+
+func (s *sqlStorage) exec(query string, args ...interface{}) (sql.Result, error) {
+	return s.conn.Exec(s.dialect.rebind(query), args...)
+}
+
+func (s *sqlStorage) begin() (*txn, error) {
+	tx, err := s.conn.Begin()
+	if err != nil {
+		return nil, err
 	}
+
+	return &txn{Tx: tx, dialect: s.dialect}, nil
 }
 
-func transact(txFunc func(*sql.Tx) error) (err error) {
-	tx, err := db.Begin()
+func (s *sqlStorage) transact(txFunc func(*txn) error) (err error) {
+	tx, err := s.begin()
 	if err != nil {
-		if !connErr(err) {
+		if !s.connErr(err) {
 			return err
 		}
 
-		reconnect()
-		return transact(txFunc)
+		s.reconnect()
+		return s.transact(txFunc)
 	}
 
+	start := time.Now()
 	defer func() {
+		committed := false
 		if p := recover(); p != nil {
 			tx.Rollback()
 		} else if err != nil {
 			tx.Rollback()
 		} else {
 			err = tx.Commit()
+			committed = err == nil
 		}
+		observeTransaction(start, committed)
 	}()
 
 	err = txFunc(tx)
-	if err == nil || !connErr(err) {
+	if err == nil || !s.connErr(err) {
 		return err
 	}
 
-	reconnect()
-	return transact(txFunc)
+	s.reconnect()
+	return s.transact(txFunc)
 }
 
-func connErr(err error) bool {
+func (s *sqlStorage) connErr(err error) bool {
 	if err == nil {
 		return false
 	}
 
 	log.Println(err)
 
-	if err == mysql.ErrInvalidConn ||
-		strings.HasSuffix(err.Error(), "operation timed out") ||
-		strings.HasSuffix(err.Error(), "Server shutdown in progress") ||
-		strings.HasPrefix(err.Error(), "Error 1290") {
-		return true
-	}
+	return s.dialect.connErr(err)
+}
+
+// Exec executes the query translated by the dialect.
+func (tx *txn) Exec(query string, args ...interface{}) (sql.Result, error) {
+	return tx.Tx.Exec(tx.dialect.rebind(query), args...)
+}
+
+// Query executes the query translated by the dialect.
+func (tx *txn) Query(query string, args ...interface{}) (*sql.Rows, error) {
+	return tx.Tx.Query(tx.dialect.rebind(query), args...)
+}
 
-	return false
+// QueryRow executes the query translated by the dialect.
+func (tx *txn) QueryRow(query string, args ...interface{}) *sql.Row {
+	return tx.Tx.QueryRow(tx.dialect.rebind(query), args...)
 }
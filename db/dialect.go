@@ -0,0 +1,53 @@
+package db
+
+import "strings"
+
+// dialect adapts queries written in MySQL syntax to a database driver.
+type dialect interface {
+	driverName() string
+	// rebind translates the query into the syntax of the database.
+	rebind(query string) string
+	// ignoreDuplicates appends the clause skipping rows violating unique keys to an insert query.
+	ignoreDuplicates(insertQuery string) string
+	// insertID executes the insert query and returns the auto increment id of the new row.
+	insertID(tx *txn, insertQuery string, args ...interface{}) (int64, error)
+	connErr(err error) bool
+}
+
+// stripUpdateLimit removes 'LIMIT 1' of UPDATE and DELETE statements, which is MySQL only syntax.
+func stripUpdateLimit(query string) string {
+	statements := splitStatements(query)
+
+	for i, stmt := range statements {
+		trimmed := strings.TrimSpace(stmt)
+		upper := strings.ToUpper(trimmed)
+
+		if (strings.HasPrefix(upper, "UPDATE") || strings.HasPrefix(upper, "DELETE")) &&
+			strings.HasSuffix(upper, "LIMIT 1") {
+			statements[i] = strings.TrimSpace(trimmed[:len(trimmed)-len("LIMIT 1")])
+		}
+	}
+
+	return strings.Join(statements, ";")
+}
+
+// splitStatements splits the query by semicolons outside of string literals.
+func splitStatements(query string) []string {
+	statements := []string{}
+	inLiteral := false
+	start := 0
+
+	for i := 0; i < len(query); i++ {
+		switch query[i] {
+		case '\'':
+			inLiteral = !inLiteral
+		case ';':
+			if !inLiteral {
+				statements = append(statements, query[start:i])
+				start = i + 1
+			}
+		}
+	}
+
+	return append(statements, query[start:])
+}
@@ -0,0 +1,29 @@
+package db
+
+import (
+	"squirrel/metrics"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+var transactionDuration = prometheus.NewHistogramVec(prometheus.HistogramOpts{
+	Namespace: metrics.Namespace,
+	Subsystem: "db",
+	Name:      "transaction_duration_seconds",
+	Help:      "Duration of database transactions, result is either commit or rollback.",
+	Buckets:   []float64{.001, .005, .01, .025, .05, .1, .25, .5, 1, 2.5, 5, 10, 30},
+}, []string{"result"})
+
+func init() {
+	metrics.MustRegister(transactionDuration)
+}
+
+func observeTransaction(start time.Time, committed bool) {
+	result := "rollback"
+	if committed {
+		result = "commit"
+	}
+
+	transactionDuration.WithLabelValues(result).Observe(time.Since(start).Seconds())
+}
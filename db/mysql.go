@@ -0,0 +1,41 @@
+package db
+
+import (
+	"strings"
+
+	"github.com/go-sql-driver/mysql"
+)
+
+type mysqlDialect struct{}
+
+func (mysqlDialect) driverName() string {
+	return "mysql"
+}
+
+func (mysqlDialect) rebind(query string) string {
+	return query
+}
+
+func (mysqlDialect) ignoreDuplicates(insertQuery string) string {
+	return insertQuery + " ON DUPLICATE KEY UPDATE `id`=`id`"
+}
+
+func (mysqlDialect) insertID(tx *txn, insertQuery string, args ...interface{}) (int64, error) {
+	res, err := tx.Exec(insertQuery, args...)
+	if err != nil {
+		return 0, err
+	}
+
+	return res.LastInsertId()
+}
+
+func (mysqlDialect) connErr(err error) bool {
+	if err == mysql.ErrInvalidConn ||
+		strings.HasSuffix(err.Error(), "operation timed out") ||
+		strings.HasSuffix(err.Error(), "Server shutdown in progress") ||
+		strings.HasPrefix(err.Error(), "Error 1290") {
+		return true
+	}
+
+	return false
+}
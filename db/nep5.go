@@ -2,28 +2,24 @@ package db
 
 import (
 	"database/sql"
-	"fmt"
-	"math/big"
 	"sort"
 	"squirrel/addr"
+	"squirrel/amount"
 	"squirrel/asset"
 	"squirrel/cache"
 	"squirrel/log"
 	"squirrel/nep5"
 	"squirrel/tx"
-	"squirrel/util"
-	"strings"
 )
 
 type addrInfo struct {
 	addr    string
-	balance *big.Float
+	balance amount.Amount
 }
 
-// GetInvocationTxs returns invocation transactions.
-func GetInvocationTxs(startPk uint, limit uint) []*tx.Transaction {
+func (s *sqlStorage) GetInvocationTxs(startPk uint, limit uint) []*tx.Transaction {
 	const query = "SELECT `id`, `block_index`, `block_time`, `txid`, `size`, `type`, `version`, `sys_fee`, `net_fee`, `nonce`, `script`, `gas` FROM `tx` WHERE `id` >= ? AND `type` = ? ORDER BY ID ASC LIMIT ?"
-	rows, err := wrappedQuery(query, startPk, "InvocationTransaction", limit)
+	rows, err := s.query(query, startPk, "InvocationTransaction", limit)
 	if err != nil {
 		panic(err)
 	}
@@ -33,9 +29,6 @@ func GetInvocationTxs(startPk uint, limit uint) []*tx.Transaction {
 
 	for rows.Next() {
 		var t tx.Transaction
-		sysFeeStr := ""
-		netFeeStr := ""
-		gasStr := ""
 
 		err := rows.Scan(
 			&t.ID,
@@ -45,32 +38,27 @@ func GetInvocationTxs(startPk uint, limit uint) []*tx.Transaction {
 			&t.Size,
 			&t.Type,
 			&t.Version,
-			&sysFeeStr,
-			&netFeeStr,
+			&t.SysFee,
+			&t.NetFee,
 			&t.Nonce,
 			&t.Script,
-			&gasStr,
+			&t.Gas,
 		)
 
 		if err != nil {
 			panic(err)
 		}
 
-		t.SysFee = util.StrToBigFloat(sysFeeStr)
-		t.NetFee = util.StrToBigFloat(netFeeStr)
-		t.Gas = util.StrToBigFloat(gasStr)
-
 		result = append(result, &t)
 	}
 
 	return result
 }
 
-// GetNep5AssetDecimals returns all nep5 asset_id with decimal.
-func GetNep5AssetDecimals() map[string]uint8 {
+func (s *sqlStorage) GetNep5AssetDecimals() map[string]uint8 {
 	nep5Decimals := make(map[string]uint8)
 	const query = "SELECT `asset_id`, `decimals` FROM `nep5`"
-	rows, err := wrappedQuery(query)
+	rows, err := s.query(query)
 	if err != nil {
 		panic(err)
 	}
@@ -88,11 +76,10 @@ func GetNep5AssetDecimals() map[string]uint8 {
 	return nep5Decimals
 }
 
-// GetTxScripts returns script string of transaction.
-func GetTxScripts(txID string) ([]*tx.TransactionScripts, error) {
+func (s *sqlStorage) GetTxScripts(txID string) ([]*tx.TransactionScripts, error) {
 	var txScripts []*tx.TransactionScripts
 	const query = "SELECT `id`, `txid`, `invocation`, `verification` FROM `tx_scripts` WHERE `txid` = ?"
-	rows, err := wrappedQuery(query, txID)
+	rows, err := s.query(query, txID)
 	if err != nil {
 		return nil, err
 	}
@@ -112,16 +99,10 @@ func GetTxScripts(txID string) ([]*tx.TransactionScripts, error) {
 	return txScripts, nil
 }
 
-// InsertNep5Asset inserts new nep5 asset into db.
-func InsertNep5Asset(trans *tx.Transaction, nep5 *nep5.Nep5, regInfo *nep5.RegInfo, addrAsset *addr.Asset, atHeight uint) error {
-	return transact(func(tx *sql.Tx) error {
-		insertNep5Sql := fmt.Sprintf("INSERT INTO `nep5` (`asset_id`, `admin_address`, `name`, `symbol`, `decimals`, `total_supply`, `txid`, `block_index`, `block_time`, `addresses`, `holding_addresses`, `transfers`) VALUES('%s', '%s', '%s', '%s', %d, %.8f, '%s', %d, %d, %d, %d, %d)", nep5.AssetID, nep5.AdminAddress, nep5.Name, nep5.Symbol, nep5.Decimals, nep5.TotalSupply, nep5.TxID, nep5.BlockIndex, nep5.BlockTime, nep5.Addresses, nep5.HoldingAddresses, nep5.Transfers)
-		res, err := tx.Exec(insertNep5Sql)
-		if err != nil {
-			return err
-		}
-
-		newPK, err := res.LastInsertId()
+func (s *sqlStorage) InsertNep5Asset(trans *tx.Transaction, nep5 *nep5.Nep5, regInfo *nep5.RegInfo, addrAsset *addr.Asset, atHeight uint) error {
+	return s.transact(func(tx *txn) error {
+		const insertNep5Sql = "INSERT INTO `nep5` (`asset_id`, `admin_address`, `name`, `symbol`, `decimals`, `total_supply`, `txid`, `block_index`, `block_time`, `addresses`, `holding_addresses`, `transfers`) VALUES(?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)"
+		newPK, err := s.dialect.insertID(tx, insertNep5Sql, nep5.AssetID, nep5.AdminAddress, nep5.Name, nep5.Symbol, nep5.Decimals, decimalArg(nep5.TotalSupply), nep5.TxID, nep5.BlockIndex, nep5.BlockTime, nep5.Addresses, nep5.HoldingAddresses, nep5.Transfers)
 		if err != nil {
 			return err
 		}
@@ -129,7 +110,14 @@ func InsertNep5Asset(trans *tx.Transaction, nep5 *nep5.Nep5, regInfo *nep5.RegIn
 		if _, err := tx.Exec(insertNep5RegInfo, newPK, regInfo.Name, regInfo.Version, regInfo.Author, regInfo.Email, regInfo.Description, regInfo.NeedStorage, regInfo.ParameterList, regInfo.ReturnType); err != nil {
 			return err
 		}
+		if err := recordNep5TotalSupply(tx, nep5.AssetID, nep5.BlockIndex, nep5.TotalSupply); err != nil {
+			return err
+		}
 		if addrAsset != nil {
+			if err := recordNep5Balance(tx, addrAsset.Address, addrAsset.AssetID, trans.BlockIndex, trans.BlockTime, addrAsset.Balance); err != nil {
+				return err
+			}
+
 			if err := createAddrInfoIfNotExist(tx, trans.BlockTime, addrAsset.Address); err != nil {
 				log.Error.Printf("TxID: %s, nep5Info: %+v, regInfo=%+v, addrAsset=%+v, atHeight=%d\n", trans.TxID, nep5, regInfo, addrAsset, atHeight)
 				return err
@@ -137,8 +125,8 @@ func InsertNep5Asset(trans *tx.Transaction, nep5 *nep5.Nep5, regInfo *nep5.RegIn
 
 			if _, ok := cache.GetAddrAsset(addrAsset.Address, addrAsset.AssetID); !ok {
 				cache.CreateAddrAsset(addrAsset.Address, addrAsset.AssetID, addrAsset.Balance, atHeight)
-				insertAddrAssetQuery := fmt.Sprintf("INSERT INTO `addr_asset` (`address`, `asset_id`, `balance`, `transactions`, `last_transaction_time`) VALUES ('%s', '%s', %.8f, %d, %d)", addrAsset.Address, addrAsset.AssetID, addrAsset.Balance, addrAsset.Transactions, addrAsset.LastTransactionTime)
-				if _, err := tx.Exec(insertAddrAssetQuery); err != nil {
+				const insertAddrAssetQuery = "INSERT INTO `addr_asset` (`address`, `asset_id`, `balance`, `transactions`, `last_transaction_time`) VALUES (?, ?, ?, ?, ?)"
+				if _, err := tx.Exec(insertAddrAssetQuery, addrAsset.Address, addrAsset.AssetID, decimalArg(addrAsset.Balance), addrAsset.Transactions, addrAsset.LastTransactionTime); err != nil {
 					return err
 				}
 			}
@@ -149,10 +137,13 @@ func InsertNep5Asset(trans *tx.Transaction, nep5 *nep5.Nep5, regInfo *nep5.RegIn
 	})
 }
 
-// UpdateNep5TotalSupplyAndAddrAsset updates nep5 total supply and admin balance.
-func UpdateNep5TotalSupplyAndAddrAsset(blockTime uint64, blockIndex uint, addr string, balance *big.Float, assetID string, totalSupply *big.Float) error {
-	return transact(func(tx *sql.Tx) error {
-		if balance.Cmp(big.NewFloat(0)) == 1 {
+func (s *sqlStorage) UpdateNep5TotalSupplyAndAddrAsset(blockTime uint64, blockIndex uint, addr string, balance amount.Amount, assetID string, totalSupply amount.Amount) error {
+	return s.transact(func(tx *txn) error {
+		if err := recordNep5Balance(tx, addr, assetID, blockIndex, blockTime, balance); err != nil {
+			return err
+		}
+
+		if balance.Sign() == 1 {
 			if err := createAddrInfoIfNotExist(tx, blockTime, addr); err != nil {
 				log.Error.Printf("blockTime=%d, blockIndex=%d, addr=%s, balance=%v, assetID=%s, totalSupply=%v\n",
 					blockTime, blockIndex, addr, balance, assetID, totalSupply)
@@ -163,8 +154,8 @@ func UpdateNep5TotalSupplyAndAddrAsset(blockTime uint64, blockIndex uint, addr s
 			addrAssetCache, created := cachedAddr.GetAddrAssetOrCreate(assetID, balance)
 
 			if created {
-				insertAddrAssetQuery := fmt.Sprintf("INSERT INTO `addr_asset` (`address`, `asset_id`, `balance`, `transactions`, `last_transaction_time`) VALUES ('%s', '%s', %.8f, %d, %d)", addr, assetID, balance, 0, blockTime)
-				if _, err := tx.Exec(insertAddrAssetQuery); err != nil {
+				const insertAddrAssetQuery = "INSERT INTO `addr_asset` (`address`, `asset_id`, `balance`, `transactions`, `last_transaction_time`) VALUES (?, ?, ?, ?, ?)"
+				if _, err := tx.Exec(insertAddrAssetQuery, addr, assetID, decimalArg(balance), 0, blockTime); err != nil {
 					return err
 				}
 				const incrNep5AddrQuery = "UPDATE `nep5` SET `addresses` = `addresses` + 1, `holding_addresses` = `holding_addresses` + 1 WHERE `asset_id` = ? LIMIT 1"
@@ -173,8 +164,8 @@ func UpdateNep5TotalSupplyAndAddrAsset(blockTime uint64, blockIndex uint, addr s
 				}
 			} else {
 				if addrAssetCache.UpdateBalance(balance, blockIndex) {
-					query := fmt.Sprintf("UPDATE `addr_asset` SET `balance` = %.8f WHERE `address` = '%s' AND `asset_id` = '%s' LIMIT 1", balance, addr, assetID)
-					if _, err := tx.Exec(query); err != nil {
+					const query = "UPDATE `addr_asset` SET `balance` = ? WHERE `address` = ? AND `asset_id` = ? LIMIT 1"
+					if _, err := tx.Exec(query, decimalArg(balance), addr, assetID); err != nil {
 						return err
 					}
 				}
@@ -193,22 +184,55 @@ func UpdateNep5TotalSupplyAndAddrAsset(blockTime uint64, blockIndex uint, addr s
 		}
 
 		// Update nep5 total supply.
-		return UpdateNep5TotalSupply(tx, assetID, totalSupply)
+		return updateNep5TotalSupply(tx, assetID, blockIndex, totalSupply)
 	})
 }
 
-// UpdateNep5TotalSupply updates total supply of nep5 asset.
-func UpdateNep5TotalSupply(tx *sql.Tx, assetID string, totalSupply *big.Float) error {
-	query := fmt.Sprintf("UPDATE `nep5` SET `total_supply` = %.8f WHERE `asset_id` = '%s' LIMIT 1", totalSupply, assetID)
+// updateNep5TotalSupply updates total supply of nep5 asset and records it in nep5_total_supply if it changed.
+func updateNep5TotalSupply(tx *txn, assetID string, blockIndex uint, totalSupply amount.Amount) error {
+	var current amount.Amount
+	const currentQuery = "SELECT `total_supply` FROM `nep5` WHERE `asset_id` = ? LIMIT 1"
+	err := tx.QueryRow(currentQuery, assetID).Scan(&current)
+	if err == sql.ErrNoRows || err == nil && current.Cmp(totalSupply) == 0 {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+
+	// Assets registered before the history was recorded keep the supply before this change as a baseline.
+	var id uint
+	const historyQuery = "SELECT `id` FROM `nep5_total_supply` WHERE `asset_id` = ? LIMIT 1"
+	err = tx.QueryRow(historyQuery, assetID).Scan(&id)
+	if err != nil && err != sql.ErrNoRows {
+		return err
+	}
+	if err == sql.ErrNoRows && blockIndex > 0 {
+		if err := recordNep5TotalSupply(tx, assetID, blockIndex-1, current); err != nil {
+			return err
+		}
+	}
+
+	if err := recordNep5TotalSupply(tx, assetID, blockIndex, totalSupply); err != nil {
+		return err
+	}
+
+	const query = "UPDATE `nep5` SET `total_supply` = ? WHERE `asset_id` = ? LIMIT 1"
+	_, err = tx.Exec(query, decimalArg(totalSupply), assetID)
+
+	return err
+}
 
-	_, err := tx.Exec(query)
+// recordNep5TotalSupply appends the total supply of nep5 asset after the block.
+func recordNep5TotalSupply(tx *txn, assetID string, blockIndex uint, totalSupply amount.Amount) error {
+	const query = "INSERT INTO `nep5_total_supply` (`asset_id`, `block_index`, `total_supply`) VALUES (?, ?, ?)"
+	_, err := tx.Exec(query, assetID, blockIndex, decimalArg(totalSupply))
 
 	return err
 }
 
-// InsertNep5transaction inserts new nep5 transaction into db.
-func InsertNep5transaction(trans *tx.Transaction, appLogIdx int, assetID string, fromAddr string, fromBalance *big.Float, toAddr string, toBalance *big.Float, transferValue *big.Float, totalSupply *big.Float) error {
-	return transact(func(tx *sql.Tx) error {
+func (s *sqlStorage) InsertNep5transaction(trans *tx.Transaction, appLogIdx int, assetID string, fromAddr string, fromBalance amount.Amount, toAddr string, toBalance amount.Amount, transferValue amount.Amount, totalSupply *amount.Amount) error {
+	return s.transact(func(tx *txn) error {
 		addrsOffset := 0
 		holdingAddrsOffset := 0
 
@@ -235,6 +259,10 @@ func InsertNep5transaction(trans *tx.Transaction, appLogIdx int, assetID string,
 				continue
 			}
 
+			if err := recordNep5Balance(tx, addr, assetID, trans.BlockIndex, trans.BlockTime, balance); err != nil {
+				return err
+			}
+
 			if err := updateAddrInfo(tx, trans.BlockTime, trans.TxID, addr, asset.NEP5); err != nil {
 				return err
 			}
@@ -242,12 +270,12 @@ func InsertNep5transaction(trans *tx.Transaction, appLogIdx int, assetID string,
 			cachedAddr, _ := cache.GetAddrOrCreate(addr, trans.BlockTime)
 			addrAssetCache, created := cachedAddr.GetAddrAssetOrCreate(assetID, balance)
 
-			if balance.Cmp(big.NewFloat(0)) == 1 {
-				if created || addrAssetCache.Balance.Cmp(big.NewFloat(0)) == 0 {
+			if balance.Sign() == 1 {
+				if created || addrAssetCache.Balance.IsZero() {
 					holdingAddrsOffset++
 				}
 			} else { // have no balance currently.
-				if !created && addrAssetCache.Balance.Cmp(big.NewFloat(0)) == 1 {
+				if !created && addrAssetCache.Balance.Sign() == 1 {
 					holdingAddrsOffset--
 				}
 			}
@@ -258,32 +286,36 @@ func InsertNep5transaction(trans *tx.Transaction, appLogIdx int, assetID string,
 
 			// Insert addr_asset record if not exist or update record.
 			if created {
-				insertAddrAssetQuery := fmt.Sprintf("INSERT INTO `addr_asset` (`address`, `asset_id`, `balance`, `transactions`, `last_transaction_time`) VALUES ('%s', '%s', %.8f, %d, %d)", addr, assetID, balance, 1, trans.BlockTime)
-				if _, err := tx.Exec(insertAddrAssetQuery); err != nil {
+				const insertAddrAssetQuery = "INSERT INTO `addr_asset` (`address`, `asset_id`, `balance`, `transactions`, `last_transaction_time`) VALUES (?, ?, ?, ?, ?)"
+				if _, err := tx.Exec(insertAddrAssetQuery, addr, assetID, decimalArg(balance), 1, trans.BlockTime); err != nil {
 					return err
 				}
 			} else {
 				addrAssetCache.UpdateBalance(balance, trans.BlockIndex)
-				updateAddrAssetQuery := fmt.Sprintf("UPDATE `addr_asset` SET `balance` = %.8f, `transactions` = `transactions` + 1, `last_transaction_time` = %d WHERE `address` = '%s' AND `asset_id` = '%s' LIMIT 1", balance, trans.BlockTime, addr, assetID)
-				if _, err := tx.Exec(updateAddrAssetQuery); err != nil {
+				const updateAddrAssetQuery = "UPDATE `addr_asset` SET `balance` = ?, `transactions` = `transactions` + 1, `last_transaction_time` = ? WHERE `address` = ? AND `asset_id` = ? LIMIT 1"
+				if _, err := tx.Exec(updateAddrAssetQuery, decimalArg(balance), trans.BlockTime, addr, assetID); err != nil {
 					return err
 				}
 			}
 		}
 
 		// Update nep5 transactions and addresses counter.
-		txSQL := fmt.Sprintf("UPDATE `nep5` SET `addresses` = `addresses` + %d, `holding_addresses` = `holding_addresses` + %d, `transfers` = `transfers` + 1 WHERE `asset_id` = '%s' LIMIT 1;", addrsOffset, holdingAddrsOffset, assetID)
+		const updateNep5Query = "UPDATE `nep5` SET `addresses` = `addresses` + ?, `holding_addresses` = `holding_addresses` + ?, `transfers` = `transfers` + 1 WHERE `asset_id` = ? LIMIT 1"
+		if _, err := tx.Exec(updateNep5Query, addrsOffset, holdingAddrsOffset, assetID); err != nil {
+			return err
+		}
 
 		// Insert nep5 transaction record.
-		txSQL += fmt.Sprintf("INSERT INTO `nep5_tx` (`txid`, `asset_id`, `from`, `to`, `value`, `block_index`, `block_time`) VALUES ('%s', '%s', '%s', '%s', %.8f, %d, %d);", trans.TxID, assetID, fromAddr, toAddr, transferValue, trans.BlockIndex, trans.BlockTime)
+		const insertNep5TxQuery = "INSERT INTO `nep5_tx` (`txid`, `asset_id`, `from`, `to`, `value`, `block_index`, `block_time`) VALUES (?, ?, ?, ?, ?, ?, ?)"
+		if _, err := tx.Exec(insertNep5TxQuery, trans.TxID, assetID, fromAddr, toAddr, decimalArg(transferValue), trans.BlockIndex, trans.BlockTime); err != nil {
+			return err
+		}
 
 		// Handle resultant of storage injection attach.
 		if totalSupply != nil {
-			txSQL += fmt.Sprintf("UPDATE `nep5` SET `total_supply` = %.8f WHERE `asset_id` = '%s' LIMIT 1;", totalSupply, assetID)
-		}
-
-		if _, err := tx.Exec(txSQL); err != nil {
-			return err
+			if err := updateNep5TotalSupply(tx, assetID, trans.BlockIndex, *totalSupply); err != nil {
+				return err
+			}
 		}
 
 		err := updateNep5Counter(tx, trans.ID, appLogIdx)
@@ -291,27 +323,25 @@ func InsertNep5transaction(trans *tx.Transaction, appLogIdx int, assetID string,
 	})
 }
 
-// GetMaxNonEmptyScriptTxPk returns largest pk of invocation transaction.
-func GetMaxNonEmptyScriptTxPk() uint {
+func (s *sqlStorage) GetMaxNonEmptyScriptTxPk() uint {
 	const query = "SELECT `id` from `tx` WHERE `type` = ? ORDER BY `id` DESC LIMIT 1"
 
 	var pk uint
-	err := db.QueryRow(query, "InvocationTransaction").Scan(&pk)
+	err := s.queryRow(query, "InvocationTransaction").Scan(&pk)
 	if err != nil && err != sql.ErrNoRows {
-		if !connErr(err) {
+		if !s.connErr(err) {
 			panic(err)
 		}
-		reconnect()
-		return GetMaxNonEmptyScriptTxPk()
+		s.reconnect()
+		return s.GetMaxNonEmptyScriptTxPk()
 	}
 
 	return pk
 }
 
-// GetNep5TxRecords returns paged nep5 transactions from db.
-func GetNep5TxRecords(pk uint, limit int) ([]*nep5.Transaction, error) {
+func (s *sqlStorage) GetNep5TxRecords(pk uint, limit int) ([]*nep5.Transaction, error) {
 	const query = "SELECT `id`, `txid`, `asset_id`, `from`, `to`, `value`, `block_index`, `block_time` FROM `nep5_tx` WHERE `id` > ? ORDER BY `id` ASC LIMIT ?"
-	rows, err := wrappedQuery(query, pk, limit)
+	rows, err := s.query(query, pk, limit)
 	if err != nil {
 		panic(err)
 	}
@@ -325,11 +355,11 @@ func GetNep5TxRecords(pk uint, limit int) ([]*nep5.Transaction, error) {
 		var assetID string
 		var from string
 		var to string
-		var valueStr string
+		var value amount.Amount
 		var blockIndex uint
 		var blockTime uint64
 
-		err := rows.Scan(&id, &txID, &assetID, &from, &to, &valueStr, &blockIndex, &blockTime)
+		err := rows.Scan(&id, &txID, &assetID, &from, &to, &value, &blockIndex, &blockTime)
 		if err != nil {
 			return nil, err
 		}
@@ -340,7 +370,7 @@ func GetNep5TxRecords(pk uint, limit int) ([]*nep5.Transaction, error) {
 			AssetID:    assetID,
 			From:       from,
 			To:         to,
-			Value:      util.StrToBigFloat(valueStr),
+			Value:      value,
 			BlockIndex: blockIndex,
 			BlockTime:  blockTime,
 		}
@@ -350,37 +380,107 @@ func GetNep5TxRecords(pk uint, limit int) ([]*nep5.Transaction, error) {
 	return records, nil
 }
 
-// InsertNep5AddrTxRec inserts addr_tx record of nep5 transactions.
-func InsertNep5AddrTxRec(nep5TxRecs []*nep5.Transaction, lastPk uint) error {
+func (s *sqlStorage) GetMaxNep5TxPk() uint {
+	const query = "SELECT `id` FROM `nep5_tx` ORDER BY `id` DESC LIMIT 1"
+
+	var pk uint
+	err := s.queryRow(query).Scan(&pk)
+	if err != nil && err != sql.ErrNoRows {
+		if !s.connErr(err) {
+			panic(err)
+		}
+		s.reconnect()
+		return s.GetMaxNep5TxPk()
+	}
+
+	return pk
+}
+
+func (s *sqlStorage) InsertNep5AddrTxRec(nep5TxRecs []*nep5.Transaction, lastPk uint) error {
 	if len(nep5TxRecs) == 0 {
 		return nil
 	}
 
-	return transact(func(tx *sql.Tx) error {
-		var strBuilder strings.Builder
-
-		strBuilder.WriteString("INSERT INTO `addr_tx` (`txid`, `address`, `block_time`, `asset_type`) VALUES ")
+	return s.transact(func(tx *txn) error {
+		cmd := newBulkInsert("addr_tx", "txid", "address", "block_time", "asset_type")
+		cmd.ignoreDuplicates = true
 
 		for _, rec := range nep5TxRecs {
 			if len(rec.From) > 0 {
-				strBuilder.WriteString(fmt.Sprintf("('%s', '%s', %d, '%s'),", rec.TxID, rec.From, rec.BlockTime, asset.NEP5))
+				cmd.addRow(rec.TxID, rec.From, rec.BlockTime, asset.NEP5)
 			}
 			if len(rec.To) > 0 {
-				strBuilder.WriteString(fmt.Sprintf("('%s', '%s', %d, '%s'),", rec.TxID, rec.To, rec.BlockTime, asset.NEP5))
+				cmd.addRow(rec.TxID, rec.To, rec.BlockTime, asset.NEP5)
 			}
 		}
-		var query = strBuilder.String()
-		if query[len(query)-1] != ',' {
+		if cmd.empty() {
 			return nil
 		}
 
-		query = strings.TrimSuffix(query, ",")
-		query += "ON DUPLICATE KEY UPDATE `address`=`address`"
-
-		if _, err := tx.Exec(query); err != nil {
+		if err := cmd.exec(tx); err != nil {
 			return err
 		}
 
-		return UpdateNep5TxPkForAddrTx(tx, lastPk)
+		return updateNep5TxPkForAddrTx(tx, lastPk)
 	})
 }
+
+func (s *sqlStorage) GetAddrNep5LastBlocks(address string) (map[string]uint, error) {
+	const query = "SELECT `asset_id`, MAX(`block_index`) FROM `nep5_tx` WHERE `from` = ? OR `to` = ? GROUP BY `asset_id`"
+	rows, err := s.query(query, address, address)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	result := make(map[string]uint)
+
+	for rows.Next() {
+		var assetID string
+		var blockIndex uint
+		if err := rows.Scan(&assetID, &blockIndex); err != nil {
+			return nil, err
+		}
+		result[assetID] = blockIndex
+	}
+
+	return result, rows.Err()
+}
+
+func (s *sqlStorage) GetAddrNep5Transfers(address string, sent bool, startTime, endTime uint64, limit uint) ([]*nep5.Transaction, error) {
+	column := "`to`"
+	if sent {
+		column = "`from`"
+	}
+
+	query := "SELECT `id`, `txid`, `asset_id`, `from`, `to`, `value`, `block_index`, `block_time` FROM `nep5_tx` WHERE " + column + " = ? AND `block_time` >= ? AND `block_time` <= ? ORDER BY `id` ASC LIMIT ?"
+	rows, err := s.query(query, address, startTime, endTime, limit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	result := []*nep5.Transaction{}
+
+	for rows.Next() {
+		t := new(nep5.Transaction)
+
+		err := rows.Scan(
+			&t.ID,
+			&t.TxID,
+			&t.AssetID,
+			&t.From,
+			&t.To,
+			&t.Value,
+			&t.BlockIndex,
+			&t.BlockTime,
+		)
+		if err != nil {
+			return nil, err
+		}
+
+		result = append(result, t)
+	}
+
+	return result, rows.Err()
+}
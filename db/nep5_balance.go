@@ -0,0 +1,284 @@
+package db
+
+import (
+	"database/sql"
+	"errors"
+	"squirrel/amount"
+	"time"
+)
+
+// ErrNep5HistoryUnavailable is returned for balances before nep5 balance history was recorded.
+var ErrNep5HistoryUnavailable = errors.New("nep5 balance history is unavailable at this height")
+
+// recordNep5Balance appends the balance of the address after the block if it changed,
+// and sets the daily balance of the block date in addr_asset_balance.
+// It must be called before addr_asset is updated, which holds the previous balance.
+func recordNep5Balance(trans *txn, address, assetID string, blockIndex uint, blockTime uint64, balance amount.Amount) error {
+	var last amount.Amount
+	const lastQuery = "SELECT `balance` FROM `nep5_balance` WHERE `address` = ? AND `asset_id` = ? ORDER BY `block_index` DESC, `id` DESC LIMIT 1"
+	err := trans.QueryRow(lastQuery, address, assetID).Scan(&last)
+	if err == sql.ErrNoRows {
+		last, err = recordNep5Baseline(trans, address, assetID)
+	}
+	if err != nil {
+		return err
+	}
+	if last.Cmp(balance) == 0 {
+		return nil
+	}
+
+	const insertQuery = "INSERT INTO `nep5_balance` (`address`, `asset_id`, `block_index`, `block_time`, `balance`) VALUES (?, ?, ?, ?, ?)"
+	if _, err := trans.Exec(insertQuery, address, assetID, blockIndex, blockTime, decimalArg(balance)); err != nil {
+		return err
+	}
+
+	return setDailyBalance(trans, address, assetID, balanceDate(blockTime), balance)
+}
+
+// recordNep5Baseline records the balance held before the history started as the balance
+// after the block before the start index, and returns it. Nothing is recorded for zero balances.
+func recordNep5Baseline(trans *txn, address, assetID string) (amount.Amount, error) {
+	var start uint
+	const startQuery = "SELECT `nep5_balance_start_index` FROM `counter` WHERE `id` = 1 LIMIT 1"
+	err := trans.QueryRow(startQuery).Scan(&start)
+	if err == sql.ErrNoRows || err == nil && start == 0 {
+		return amount.Zero, nil
+	}
+	if err != nil {
+		return amount.Zero, err
+	}
+
+	var balance amount.Amount
+	const balanceQuery = "SELECT `balance` FROM `addr_asset` WHERE `address` = ? AND `asset_id` = ? LIMIT 1"
+	err = trans.QueryRow(balanceQuery, address, assetID).Scan(&balance)
+	if err == sql.ErrNoRows || err == nil && balance.IsZero() {
+		return amount.Zero, nil
+	}
+	if err != nil {
+		return amount.Zero, err
+	}
+
+	var blockTime uint64
+	const timeQuery = "SELECT `time` FROM `block` WHERE `index` = ? LIMIT 1"
+	if err := trans.QueryRow(timeQuery, start-1).Scan(&blockTime); err != nil {
+		return amount.Zero, err
+	}
+
+	const insertQuery = "INSERT INTO `nep5_balance` (`address`, `asset_id`, `block_index`, `block_time`, `balance`) VALUES (?, ?, ?, ?, ?)"
+	if _, err := trans.Exec(insertQuery, address, assetID, start-1, blockTime, decimalArg(balance)); err != nil {
+		return amount.Zero, err
+	}
+
+	return balance, setDailyBalance(trans, address, assetID, balanceDate(blockTime), balance)
+}
+
+// setDailyBalance sets the balance of the address in the asset at the end of the date.
+func setDailyBalance(trans *txn, address, assetID, date string, balance amount.Amount) error {
+	var id uint
+	const query = "SELECT `id` FROM `addr_asset_balance` WHERE `address` = ? AND `asset_id` = ? AND `date` = ? LIMIT 1"
+	err := trans.QueryRow(query, address, assetID, date).Scan(&id)
+	if err != nil && err != sql.ErrNoRows {
+		return err
+	}
+
+	if err == nil {
+		const updateQuery = "UPDATE `addr_asset_balance` SET `balance` = ? WHERE `address` = ? AND `asset_id` = ? AND `date` = ? LIMIT 1"
+		_, err := trans.Exec(updateQuery, decimalArg(balance), address, assetID, date)
+		return err
+	}
+
+	const insertQuery = "INSERT INTO `addr_asset_balance` (`address`, `asset_id`, `date`, `balance`) VALUES (?, ?, ?, ?)"
+	_, err = trans.Exec(insertQuery, address, assetID, date, decimalArg(balance))
+	return err
+}
+
+// rollbackNep5Balances removes balances after the height, daily balances from the first affected date
+// are removed and the one of that date is restored from the remaining history. Balances in addr_asset
+// of the affected addresses and of all holders of the migrated assets are restored as well.
+// It returns the assets whose balances are restored.
+func rollbackNep5Balances(trans *txn, height int, migrated map[string]bool) (map[string]bool, error) {
+	const query = "SELECT `address`, `asset_id`, MIN(`block_time`) FROM `nep5_balance` WHERE `block_index` > ? GROUP BY `address`, `asset_id`"
+	rows, err := trans.Query(query, height)
+	if err != nil {
+		return nil, err
+	}
+
+	type affected struct {
+		address   string
+		assetID   string
+		blockTime uint64
+	}
+	var pairs []affected
+	for rows.Next() {
+		var a affected
+		if err := rows.Scan(&a.address, &a.assetID, &a.blockTime); err != nil {
+			rows.Close()
+			return nil, err
+		}
+		pairs = append(pairs, a)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	const deleteQuery = "DELETE FROM `nep5_balance` WHERE `block_index` > ?"
+	if _, err := trans.Exec(deleteQuery, height); err != nil {
+		return nil, err
+	}
+
+	changed := make(map[string]bool)
+	restored := make(map[string]map[string]bool)
+	restore := func(address, assetID string) error {
+		if restored[assetID][address] {
+			return nil
+		}
+		if _, ok := restored[assetID]; !ok {
+			restored[assetID] = make(map[string]bool)
+		}
+		restored[assetID][address] = true
+		changed[assetID] = true
+
+		return restoreNep5AddrAsset(trans, address, assetID)
+	}
+
+	for _, a := range pairs {
+		date := balanceDate(a.blockTime)
+		const deleteDailyQuery = "DELETE FROM `addr_asset_balance` WHERE `address` = ? AND `asset_id` = ? AND `date` >= ?"
+		if _, err := trans.Exec(deleteDailyQuery, a.address, a.assetID, date); err != nil {
+			return nil, err
+		}
+
+		if err := restore(a.address, a.assetID); err != nil {
+			return nil, err
+		}
+
+		dayStart, _ := time.Parse("2006-01-02", date)
+		var balance amount.Amount
+		const lastQuery = "SELECT `balance` FROM `nep5_balance` WHERE `address` = ? AND `asset_id` = ? AND `block_time` >= ? ORDER BY `block_index` DESC, `id` DESC LIMIT 1"
+		err := trans.QueryRow(lastQuery, a.address, a.assetID, dayStart.Unix()).Scan(&balance)
+		if err == sql.ErrNoRows {
+			continue
+		}
+		if err != nil {
+			return nil, err
+		}
+
+		if err := setDailyBalance(trans, a.address, a.assetID, date, balance); err != nil {
+			return nil, err
+		}
+	}
+
+	for assetID := range migrated {
+		addresses, err := getNep5HistoryAddresses(trans, assetID)
+		if err != nil {
+			return nil, err
+		}
+
+		for _, address := range addresses {
+			if err := restore(address, assetID); err != nil {
+				return nil, err
+			}
+		}
+		changed[assetID] = true
+	}
+
+	return changed, nil
+}
+
+// getNep5HistoryAddresses returns addresses with balance history in the asset.
+func getNep5HistoryAddresses(trans *txn, assetID string) ([]string, error) {
+	const query = "SELECT DISTINCT `address` FROM `nep5_balance` WHERE `asset_id` = ?"
+	rows, err := trans.Query(query, assetID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var addresses []string
+	for rows.Next() {
+		var address string
+		if err := rows.Scan(&address); err != nil {
+			return nil, err
+		}
+		addresses = append(addresses, address)
+	}
+
+	return addresses, rows.Err()
+}
+
+// restoreNep5AddrAsset sets the balance of the address in addr_asset to the last one in its history.
+// Without history the address held nothing, its addr_asset is removed unless it has remaining transfers.
+func restoreNep5AddrAsset(trans *txn, address, assetID string) error {
+	balance := amount.Zero
+	const lastQuery = "SELECT `balance` FROM `nep5_balance` WHERE `address` = ? AND `asset_id` = ? ORDER BY `block_index` DESC, `id` DESC LIMIT 1"
+	err := trans.QueryRow(lastQuery, address, assetID).Scan(&balance)
+	if err != nil && err != sql.ErrNoRows {
+		return err
+	}
+	hasHistory := err == nil
+
+	var transfers int
+	var lastTime uint64
+	const transfersQuery = "SELECT COUNT(`id`), COALESCE(MAX(`block_time`), 0) FROM `nep5_tx` WHERE `asset_id` = ? AND (`from` = ? OR `to` = ?)"
+	if err := trans.QueryRow(transfersQuery, assetID, address, address).Scan(&transfers, &lastTime); err != nil {
+		return err
+	}
+
+	var id uint
+	const existQuery = "SELECT `id` FROM `addr_asset` WHERE `address` = ? AND `asset_id` = ? LIMIT 1"
+	err = trans.QueryRow(existQuery, address, assetID).Scan(&id)
+	if err != nil && err != sql.ErrNoRows {
+		return err
+	}
+	exists := err == nil
+
+	switch {
+	case !hasHistory && transfers == 0:
+		const deleteQuery = "DELETE FROM `addr_asset` WHERE `address` = ? AND `asset_id` = ?"
+		_, err = trans.Exec(deleteQuery, address, assetID)
+	case exists:
+		const updateQuery = "UPDATE `addr_asset` SET `balance` = ? WHERE `address` = ? AND `asset_id` = ? LIMIT 1"
+		_, err = trans.Exec(updateQuery, decimalArg(balance), address, assetID)
+	default:
+		const insertQuery = "INSERT INTO `addr_asset` (`address`, `asset_id`, `balance`, `transactions`, `last_transaction_time`) VALUES (?, ?, ?, ?, ?)"
+		_, err = trans.Exec(insertQuery, address, assetID, decimalArg(balance), transfers, lastTime)
+	}
+
+	return err
+}
+
+func (s *sqlStorage) GetNep5BalanceAt(address, assetID string, height uint) (amount.Amount, error) {
+	var balance amount.Amount
+	const query = "SELECT `balance` FROM `nep5_balance` WHERE `address` = ? AND `asset_id` = ? AND `block_index` <= ? ORDER BY `block_index` DESC, `id` DESC LIMIT 1"
+	err := s.queryRow(query, address, assetID, height).Scan(&balance)
+	if err != sql.ErrNoRows {
+		return balance, err
+	}
+
+	start := s.getCounterInstance().Nep5BalanceStartIndex
+	if start == 0 {
+		return amount.Zero, nil
+	}
+	if height+1 < start {
+		return amount.Zero, ErrNep5HistoryUnavailable
+	}
+
+	// Balances held since before the start are recorded as a baseline on the first change,
+	// so the address held nothing at the height if it has later history.
+	var id uint
+	const historyQuery = "SELECT `id` FROM `nep5_balance` WHERE `address` = ? AND `asset_id` = ? LIMIT 1"
+	err = s.queryRow(historyQuery, address, assetID).Scan(&id)
+	if err != sql.ErrNoRows {
+		return amount.Zero, err
+	}
+
+	// The balance has not changed since the history started.
+	const balanceQuery = "SELECT `balance` FROM `addr_asset` WHERE `address` = ? AND `asset_id` = ? LIMIT 1"
+	err = s.queryRow(balanceQuery, address, assetID).Scan(&balance)
+	if err == sql.ErrNoRows {
+		return amount.Zero, nil
+	}
+
+	return balance, err
+}
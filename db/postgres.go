@@ -0,0 +1,81 @@
+package db
+
+import (
+	"database/sql/driver"
+	"io"
+	"net"
+	"strconv"
+	"strings"
+
+	"github.com/lib/pq"
+)
+
+type postgresDialect struct{}
+
+func (postgresDialect) driverName() string {
+	return "postgres"
+}
+
+// rebind translates MySQL syntax used in this package:
+// backtick quoted identifiers, '?' placeholders and 'LIMIT 1' of UPDATE and DELETE statements.
+// String literals are kept as is.
+func (postgresDialect) rebind(query string) string {
+	query = stripUpdateLimit(query)
+
+	var b strings.Builder
+	b.Grow(len(query) + 16)
+	inLiteral := false
+	n := 0
+
+	for i := 0; i < len(query); i++ {
+		c := query[i]
+
+		switch {
+		case c == '\'':
+			inLiteral = !inLiteral
+			b.WriteByte(c)
+		case inLiteral:
+			b.WriteByte(c)
+		case c == '`':
+			b.WriteByte('"')
+		case c == '?':
+			n++
+			b.WriteByte('$')
+			b.WriteString(strconv.Itoa(n))
+		default:
+			b.WriteByte(c)
+		}
+	}
+
+	return b.String()
+}
+
+func (postgresDialect) ignoreDuplicates(insertQuery string) string {
+	return insertQuery + " ON CONFLICT DO NOTHING"
+}
+
+func (postgresDialect) insertID(tx *txn, insertQuery string, args ...interface{}) (int64, error) {
+	var id int64
+	err := tx.QueryRow(insertQuery+" RETURNING `id`", args...).Scan(&id)
+	return id, err
+}
+
+func (postgresDialect) connErr(err error) bool {
+	if err == driver.ErrBadConn || err == io.EOF || err == io.ErrUnexpectedEOF {
+		return true
+	}
+
+	if _, ok := err.(net.Error); ok {
+		return true
+	}
+
+	if pqErr, ok := err.(*pq.Error); ok {
+		// Class 08: connection exception, 57P01-57P03: server shutdown or not ready.
+		return pqErr.Code.Class() == "08" ||
+			pqErr.Code == "57P01" ||
+			pqErr.Code == "57P02" ||
+			pqErr.Code == "57P03"
+	}
+
+	return false
+}
@@ -0,0 +1,37 @@
+package db
+
+import "testing"
+
+func TestPostgresRebind(t *testing.T) {
+	cases := []struct {
+		query    string
+		expected string
+	}{
+		{
+			"SELECT `id` FROM `tx` WHERE `txid` = ? AND `n` = ?",
+			`SELECT "id" FROM "tx" WHERE "txid" = $1 AND "n" = $2`,
+		},
+		{
+			"UPDATE `counter` SET `last_tx_pk` = ? WHERE `id` = 1 LIMIT 1",
+			`UPDATE "counter" SET "last_tx_pk" = $1 WHERE "id" = 1`,
+		},
+		{
+			"SELECT `hash` FROM `block` WHERE `index` = ? LIMIT 1",
+			`SELECT "hash" FROM "block" WHERE "index" = $1 LIMIT 1`,
+		},
+		{
+			"UPDATE `nep5` SET `transfers` = `transfers` + 1 WHERE `asset_id` = 'a' LIMIT 1;INSERT INTO `nep5_tx` (`from`) VALUES ('what?; `x` LIMIT 1');",
+			`UPDATE "nep5" SET "transfers" = "transfers" + 1 WHERE "asset_id" = 'a';INSERT INTO "nep5_tx" ("from") VALUES ('what?; ` + "`x`" + ` LIMIT 1');`,
+		},
+		{
+			"INSERT INTO `nep5` (`name`) VALUES ('it''s ?')",
+			`INSERT INTO "nep5" ("name") VALUES ('it''s ?')`,
+		},
+	}
+
+	for _, c := range cases {
+		if got := (postgresDialect{}).rebind(c.query); got != c.expected {
+			t.Errorf("rebind(%q)\n got: %s\nwant: %s", c.query, got, c.expected)
+		}
+	}
+}
@@ -0,0 +1,94 @@
+package db
+
+import (
+	"fmt"
+	"squirrel/amount"
+	"strings"
+)
+
+// maxPlaceholders limits placeholders of a single statement,
+// SQLite allows at most 32766 while MySQL and PostgreSQL allow 65535.
+const maxPlaceholders = 32766
+
+// bulkInsert builds multi-row INSERT statements with placeholders,
+// rows are split into several statements when there are too many of them.
+type bulkInsert struct {
+	table   string
+	columns []string
+	args    []interface{}
+	// ignoreDuplicates skips rows violating unique keys.
+	ignoreDuplicates bool
+}
+
+func newBulkInsert(table string, columns ...string) *bulkInsert {
+	return &bulkInsert{
+		table:   table,
+		columns: columns,
+	}
+}
+
+// addRow appends a row, values must match columns in order.
+func (b *bulkInsert) addRow(values ...interface{}) {
+	if len(values) != len(b.columns) {
+		panic(fmt.Errorf("%d values given for %d columns of table %s", len(values), len(b.columns), b.table))
+	}
+
+	b.args = append(b.args, values...)
+}
+
+func (b *bulkInsert) empty() bool {
+	return len(b.args) == 0
+}
+
+func (b *bulkInsert) exec(tx *txn) error {
+	rowSize := len(b.columns)
+	rowsPerStmt := maxPlaceholders / rowSize
+
+	for start := 0; start < len(b.args); start += rowsPerStmt * rowSize {
+		end := start + rowsPerStmt*rowSize
+		if end > len(b.args) {
+			end = len(b.args)
+		}
+
+		query := b.query((end - start) / rowSize)
+		if b.ignoreDuplicates {
+			query = tx.dialect.ignoreDuplicates(query)
+		}
+
+		if _, err := tx.Exec(query, b.args[start:end]...); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func (b *bulkInsert) query(rows int) string {
+	row := "(" + placeholders(len(b.columns)) + ")"
+
+	var strBuilder strings.Builder
+	strBuilder.WriteString("INSERT INTO `" + b.table + "` (`" + strings.Join(b.columns, "`, `") + "`) VALUES ")
+	strBuilder.WriteString(strings.TrimSuffix(strings.Repeat(row+", ", rows), ", "))
+
+	return strBuilder.String()
+}
+
+// decimalArg formats the amount as a query argument with 8 decimal places,
+// which is the scale of all decimal columns.
+func decimalArg(value amount.Amount) string {
+	return value.Rescale(8).String()
+}
+
+// placeholders returns n comma separated placeholders for an IN clause.
+func placeholders(n int) string {
+	return strings.TrimSuffix(strings.Repeat("?, ", n), ", ")
+}
+
+func stringArgs(values []string) []interface{} {
+	args := make([]interface{}, len(values))
+	for i, v := range values {
+		args[i] = v
+	}
+
+	return args
+}
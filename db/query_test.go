@@ -0,0 +1,255 @@
+package db
+
+import (
+	"fmt"
+	"squirrel/addr"
+	"squirrel/amount"
+	"squirrel/asset"
+	"squirrel/block"
+	"squirrel/cache"
+	"squirrel/nep5"
+	"squirrel/tx"
+	"testing"
+)
+
+// hostileStrings are stored verbatim, they must never change the statements they are passed to.
+var hostileStrings = []string{
+	"O'Reilly",
+	"'); DROP TABLE `block`; --",
+	`\'; DELETE FROM counter; --`,
+	"\" OR \"1\"=\"1",
+	"`nep5`",
+	"?, ?, ?",
+	"$1",
+	"Token; UPDATE `nep5` SET `visible` = 0",
+	"\x00\n\t\\",
+	"币'名",
+}
+
+func TestBulkInsertQuery(t *testing.T) {
+	cmd := newBulkInsert("tx_vin", "from", "txid", "vout")
+	cmd.addRow("a", "b", 0)
+	cmd.addRow("c", "d", 1)
+
+	expected := "INSERT INTO `tx_vin` (`from`, `txid`, `vout`) VALUES (?, ?, ?), (?, ?, ?)"
+	if q := cmd.query(2); q != expected {
+		t.Fatalf("query() = %s, expected %s", q, expected)
+	}
+}
+
+func TestBulkInsertSplitsStatements(t *testing.T) {
+	s, cleanup := newTestSQLiteStorage(t)
+	defer cleanup()
+
+	claims := []*tx.TransactionClaims{}
+	cnt := maxPlaceholders/2*2 + 10
+	for i := 0; i < cnt; i++ {
+		claims = append(claims, &tx.TransactionClaims{TxID: fmt.Sprintf("0x%064x", i), Vout: uint16(i)})
+	}
+
+	err := s.transact(func(trans *txn) error {
+		return generateInsertCmdForClaims(claims).exec(trans)
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var stored int
+	if err := s.queryRow("SELECT COUNT(*) FROM `tx_claims`").Scan(&stored); err != nil {
+		t.Fatal(err)
+	}
+	if stored != cnt {
+		t.Fatalf("%d claims stored, expected %d", stored, cnt)
+	}
+}
+
+func TestHostileBlockInsert(t *testing.T) {
+	s, cleanup := newTestSQLiteStorage(t)
+	defer cleanup()
+	s.GetLastHeight()
+
+	blocks := []*block.Block{}
+	bulk := &tx.Bulk{}
+
+	for i, str := range hostileStrings {
+		txID := fmt.Sprintf("0x%064x", i)
+		blocks = append(blocks, &block.Block{
+			Hash:             str,
+			Index:            uint(i),
+			Nonce:            str,
+			ScriptInvocation: str,
+		})
+		bulk.TXs = append(bulk.TXs, &tx.Transaction{
+			BlockIndex: uint(i),
+			TxID:       txID,
+			Type:       "InvocationTransaction",
+			Script:     str,
+			SysFee:     amount.NewFromInt64(0, 0),
+			NetFee:     amount.NewFromInt64(0, 0),
+			Gas:        amount.NewFromInt64(0, 0),
+		})
+		bulk.TXAttrs = append(bulk.TXAttrs, &tx.TransactionAttribute{TxID: txID, Usage: str, Data: str})
+		bulk.TXVouts = append(bulk.TXVouts, &tx.TransactionVout{TxID: txID, AssetID: asset.NEOAssetID, Value: amount.NewFromInt64(1, 0), Address: str})
+		bulk.Assets = append(bulk.Assets, &asset.Asset{
+			AssetID:   txID,
+			Name:      str,
+			Owner:     str,
+			Amount:    amount.NewFromInt64(1, 0),
+			Available: amount.NewFromInt64(0, 0),
+		})
+	}
+
+	if err := s.InsertBlock(len(blocks)-1, blocks, bulk); err != nil {
+		t.Fatal(err)
+	}
+
+	for i, str := range hostileStrings {
+		b, err := s.GetBlock(uint(i))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if b == nil || b.Hash != str || b.Nonce != str || b.ScriptInvocation != str {
+			t.Fatalf("GetBlock(%d) returns %+v, expected hash %q", i, b, str)
+		}
+
+		txID := fmt.Sprintf("0x%064x", i)
+		attrs, err := s.GetTxAttrs(txID)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(attrs) != 1 || attrs[0].Usage != str || attrs[0].Data != str {
+			t.Fatalf("GetTxAttrs(%s) returns %+v, expected %q", txID, attrs, str)
+		}
+
+		name, err := s.GetAssetName(txID)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if name != str {
+			t.Fatalf("GetAssetName(%s) = %q, expected %q", txID, name, str)
+		}
+	}
+
+	if h := s.GetLastHeight(); h != len(blocks)-1 {
+		t.Fatalf("GetLastHeight = %d, expected %d", h, len(blocks)-1)
+	}
+}
+
+func TestHostileNep5Insert(t *testing.T) {
+	s, cleanup := newTestSQLiteStorage(t)
+	defer cleanup()
+	s.GetLastHeight()
+	cache.LoadAddrAssetInfo(s.GetAddrAssetInfo())
+
+	for i, str := range hostileStrings {
+		assetID := fmt.Sprintf("%040x", i)
+		trans := &tx.Transaction{
+			ID:        uint(i + 1),
+			TxID:      fmt.Sprintf("0x%064x", i),
+			BlockTime: 1500000000,
+		}
+		admin := "A" + str
+
+		token := &nep5.Nep5{
+			AssetID:      assetID,
+			AdminAddress: admin,
+			Name:         str,
+			Symbol:       str,
+			Decimals:     8,
+			TotalSupply:  amount.NewFromInt64(100, 0),
+			TxID:         trans.TxID,
+		}
+		regInfo := &nep5.RegInfo{Name: str, Version: str, Author: str, Email: str, Description: str}
+		addrAsset := &addr.Asset{Address: admin, AssetID: assetID, Balance: amount.NewFromInt64(100, 0)}
+
+		if err := s.InsertNep5Asset(trans, token, regInfo, addrAsset, 0); err != nil {
+			t.Fatal(err)
+		}
+
+		receiver := str + "B"
+		err := s.InsertNep5transaction(trans, 0, assetID, admin, amount.NewFromInt64(90, 0), receiver, amount.NewFromInt64(10, 0), amount.NewFromInt64(10, 0), nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		var name, symbol string
+		err = s.queryRow("SELECT `name`, `symbol` FROM `nep5` WHERE `asset_id` = ?", assetID).Scan(&name, &symbol)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if name != str || symbol != str {
+			t.Fatalf("nep5 stored as (%q, %q), expected %q", name, symbol, str)
+		}
+
+		assets, err := s.GetAddrAssets(receiver)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(assets) != 1 || assets[0].Balance.Cmp(amount.NewFromInt64(10, 0)) != 0 {
+			t.Fatalf("GetAddrAssets(%q) returns %+v", receiver, assets)
+		}
+	}
+
+	records, err := s.GetNep5TxRecords(0, 100)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(records) != len(hostileStrings) {
+		t.Fatalf("%d nep5 transfers stored, expected %d", len(records), len(hostileStrings))
+	}
+	if err := s.InsertNep5AddrTxRec(records, records[len(records)-1].ID); err != nil {
+		t.Fatal(err)
+	}
+	// Duplicated records are skipped.
+	if err := s.InsertNep5AddrTxRec(records, records[len(records)-1].ID); err != nil {
+		t.Fatal(err)
+	}
+
+	cnt, err := s.CountAddrTxs(records[0].To)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if cnt != 1 {
+		t.Fatalf("CountAddrTxs(%q) = %d, expected 1", records[0].To, cnt)
+	}
+
+	var visible int
+	if err := s.queryRow("SELECT COUNT(*) FROM `nep5` WHERE `visible` = 1").Scan(&visible); err != nil {
+		t.Fatal(err)
+	}
+	if visible != len(hostileStrings) {
+		t.Fatalf("%d visible nep5 assets, expected %d", visible, len(hostileStrings))
+	}
+}
+
+func TestHostileAssetBalance(t *testing.T) {
+	s, cleanup := newTestSQLiteStorage(t)
+	defer cleanup()
+	s.GetLastHeight()
+
+	const address = "A'); DROP TABLE `utxo`; --a"
+	const assetID = "0x'; --"
+
+	// 2019-01-01 00:00:00, 12:00:00 and 2019-01-02 00:00:00 UTC.
+	for i, blockTime := range []uint64{1546300800, 1546344000, 1546387200} {
+		trans := &tx.Transaction{ID: uint(i + 1), BlockTime: blockTime}
+		changes := map[string]map[string]amount.Amount{address: {assetID: amount.NewFromInt64(1, 0)}}
+		if err := s.ApplyAddrAssetBalances(trans, changes); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	for date, expected := range map[string]int64{"2018-12-31": 0, "2019-01-01": 2, "2019-01-02": 3, "2019-02-01": 3} {
+		balance, err := s.GetAddrAssetBalance(address, assetID, date)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if balance.Cmp(amount.NewFromInt64(expected, 0)) != 0 {
+			t.Fatalf("balance on %s is %s, expected %d", date, balance, expected)
+		}
+	}
+
+	if pk := s.GetLastTxPkForAssetBalance(); pk != 3 {
+		t.Fatalf("GetLastTxPkForAssetBalance = %d, expected 3", pk)
+	}
+}
@@ -0,0 +1,553 @@
+package db
+
+import (
+	"database/sql"
+	"fmt"
+	"sort"
+	"squirrel/amount"
+	"squirrel/asset"
+	"squirrel/tx"
+)
+
+func (s *sqlStorage) GetBlockHash(index int) string {
+	const query = "SELECT `hash` FROM `block` WHERE `index` = ? LIMIT 1"
+
+	var hash string
+	err := s.queryRow(query, index).Scan(&hash)
+	if err != nil && err != sql.ErrNoRows {
+		if !s.connErr(err) {
+			panic(err)
+		}
+		s.reconnect()
+		return s.GetBlockHash(index)
+	}
+
+	return hash
+}
+
+func (s *sqlStorage) RollbackBlocks(height int) ([]*tx.Transaction, error) {
+	var removed []*tx.Transaction
+
+	err := s.transact(func(trans *txn) error {
+		var err error
+
+		removed, err = getTxsAbove(trans, height)
+		if err != nil {
+			return err
+		}
+
+		if len(removed) > 0 {
+			if err := s.rollbackTxs(trans, removed); err != nil {
+				return err
+			}
+		}
+
+		migrated, err := rollbackNep5Migrations(trans, removed)
+		if err != nil {
+			return err
+		}
+
+		if err := rollbackNep5Txs(trans, height); err != nil {
+			return err
+		}
+
+		changed, err := rollbackNep5Balances(trans, height, migrated)
+		if err != nil {
+			return err
+		}
+
+		if err := rollbackNep5TotalSupply(trans, height); err != nil {
+			return err
+		}
+
+		if err := rollbackNep5Assets(trans, height); err != nil {
+			return err
+		}
+
+		for assetID := range changed {
+			if err := recountNep5Addresses(trans, assetID); err != nil {
+				return err
+			}
+		}
+
+		const deleteAssetsQuery = "DELETE FROM `asset` WHERE `block_index` > ?"
+		if _, err := trans.Exec(deleteAssetsQuery, height); err != nil {
+			return err
+		}
+
+		const deleteWitnessesQuery = "DELETE FROM `invalid_witness` WHERE `block_index` > ?"
+		if _, err := trans.Exec(deleteWitnessesQuery, height); err != nil {
+			return err
+		}
+
+		const deleteContractsQuery = "DELETE FROM `addr_contract` WHERE `block_index` > ?"
+		if _, err := trans.Exec(deleteContractsQuery, height); err != nil {
+			return err
+		}
+
+		const deleteFeesQuery = "DELETE FROM `block_fee` WHERE `block_index` > ?"
+		if _, err := trans.Exec(deleteFeesQuery, height); err != nil {
+			return err
+		}
+
+		const backfillQuery = "UPDATE `counter` SET `block_fee_backfill_index` = ? WHERE `id` = 1 AND `block_fee_backfill_index` > ?"
+		if _, err := trans.Exec(backfillQuery, height+1, height+1); err != nil {
+			return err
+		}
+
+		const deleteTxsQuery = "DELETE FROM `tx` WHERE `block_index` > ?"
+		if _, err := trans.Exec(deleteTxsQuery, height); err != nil {
+			return err
+		}
+
+		const deleteBlocksQuery = "DELETE FROM `block` WHERE `index` > ?"
+		if _, err := trans.Exec(deleteBlocksQuery, height); err != nil {
+			return err
+		}
+
+		const unlinkQuery = "UPDATE `block` SET `nextblockhash` = '' WHERE `index` = ?"
+		if _, err := trans.Exec(unlinkQuery, height); err != nil {
+			return err
+		}
+
+		return updateCounter(trans, "last_block_index", int64(height))
+	})
+
+	if err != nil {
+		return nil, err
+	}
+
+	s.txSysFee.reset(height)
+
+	return removed, nil
+}
+
+func getTxsAbove(trans *txn, height int) ([]*tx.Transaction, error) {
+	const query = "SELECT `id`, `block_index`, `block_time`, `txid`, `type` FROM `tx` WHERE `block_index` > ? ORDER BY `id` DESC"
+	rows, err := trans.Query(query, height)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	result := []*tx.Transaction{}
+
+	for rows.Next() {
+		var t tx.Transaction
+		if err := rows.Scan(&t.ID, &t.BlockIndex, &t.BlockTime, &t.TxID, &t.Type); err != nil {
+			return nil, err
+		}
+		result = append(result, &t)
+	}
+
+	return result, rows.Err()
+}
+
+func (s *sqlStorage) rollbackTxs(trans *txn, removed []*tx.Transaction) error {
+	var lastTxPk uint
+	const counterQuery = "SELECT `last_tx_pk` FROM `counter` WHERE `id` = 1 LIMIT 1"
+	if err := trans.QueryRow(counterQuery).Scan(&lastTxPk); err != nil {
+		return err
+	}
+
+	txIDs := []string{}
+	for _, t := range removed {
+		txIDs = append(txIDs, t.TxID)
+	}
+
+	vinMap, voutMap, err := getVinVout(trans, txIDs)
+	if err != nil {
+		return err
+	}
+
+	// Transactions are sorted in descending order, revert the latest one first.
+	for _, t := range removed {
+		if t.ID > lastTxPk {
+			continue
+		}
+
+		if err := s.revertVinsVouts(trans, t, vinMap[t.TxID], voutMap[t.TxID]); err != nil {
+			return err
+		}
+	}
+
+	if err := s.revertAddrAssetBalances(trans, removed, vinMap, voutMap); err != nil {
+		return err
+	}
+
+	txTypeCounter := countTxTypes(removed)
+	for txType, cnt := range txTypeCounter {
+		if err := updateTxCounter(trans, txType, -cnt); err != nil {
+			return err
+		}
+	}
+
+	inTxIDs := placeholders(len(txIDs))
+	args := stringArgs(txIDs)
+
+	cmdList := []string{
+		"DELETE FROM `utxo` WHERE `txid` IN (%s)",
+		"DELETE FROM `tx_attr` WHERE `txid` IN (%s)",
+		"DELETE FROM `tx_vin` WHERE `from` IN (%s)",
+		"DELETE FROM `tx_claims` WHERE `from` IN (%s)",
+		"DELETE FROM `tx_vout` WHERE `txid` IN (%s)",
+		"DELETE FROM `tx_scripts` WHERE `txid` IN (%s)",
+		"DELETE FROM `asset_tx` WHERE `txid` IN (%s)",
+		"DELETE FROM `addr_tx` WHERE `txid` IN (%s)",
+	}
+
+	for _, cmd := range cmdList {
+		if _, err := trans.Exec(fmt.Sprintf(cmd, inTxIDs), args...); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func (s *sqlStorage) revertVinsVouts(trans *txn, t *tx.Transaction, vins []*tx.TransactionVin, vouts []*tx.TransactionVout) error {
+	cachedVinVouts := []*tx.TransactionVout{}
+
+	for _, vin := range vins {
+		const enableUTXOSQL = "UPDATE `utxo` SET `used_in_tx` = NULL, `claim_gas` = NULL WHERE `txid` = ? AND `n` = ? LIMIT 1"
+		if _, err := trans.Exec(enableUTXOSQL, vin.TxID, vin.Vout); err != nil {
+			return err
+		}
+
+		vinVout, err := getVout(trans, vin.TxID, vin.Vout)
+		if err != nil {
+			return err
+		}
+		if vinVout == nil {
+			continue
+		}
+		cachedVinVouts = append(cachedVinVouts, vinVout)
+
+		const incrAddrAsset = "UPDATE `addr_asset` SET `balance` = `balance` + ? WHERE `address` = ? AND `asset_id` = ? LIMIT 1"
+		if _, err := trans.Exec(incrAddrAsset, decimalArg(vinVout.Value), vinVout.Address, vinVout.AssetID); err != nil {
+			return err
+		}
+	}
+
+	for _, vout := range vouts {
+		const reduceAddrAsset = "UPDATE `addr_asset` SET `balance` = `balance` - ? WHERE `address` = ? AND `asset_id` = ? LIMIT 1"
+		if _, err := trans.Exec(reduceAddrAsset, decimalArg(vout.Value), vout.Address, vout.AssetID); err != nil {
+			return err
+		}
+	}
+
+	assetIDs, addrAssetPair := countTxInfo(cachedVinVouts, vouts)
+
+	var addrs []string
+	for k := range addrAssetPair {
+		addrs = append(addrs, k)
+	}
+	// Sort address to avoid potential deadlock.
+	sort.Strings(addrs)
+
+	for _, addr := range addrs {
+		const decrAddrTxs = "UPDATE `address` SET `trans_asset` = `trans_asset` - 1 WHERE `address` = ? LIMIT 1"
+		if _, err := trans.Exec(decrAddrTxs, addr); err != nil {
+			return err
+		}
+
+		for assetID := range addrAssetPair[addr] {
+			const decrAddrAssetTxs = "UPDATE `addr_asset` SET `transactions` = `transactions` - 1 WHERE `address` = ? AND `asset_id` = ? LIMIT 1"
+			if _, err := trans.Exec(decrAddrAssetTxs, addr, assetID); err != nil {
+				return err
+			}
+		}
+	}
+
+	for assetID := range assetIDs {
+		const decrAssetTxs = "UPDATE `asset` SET `transactions` = `transactions` - 1 WHERE `asset_id` = ? LIMIT 1"
+		if _, err := trans.Exec(decrAssetTxs, assetID); err != nil {
+			return err
+		}
+	}
+
+	return revertAvailable(trans, t, vouts)
+}
+
+// revertAvailable reverts asset availability increased by claim and issue transactions.
+func revertAvailable(trans *txn, t *tx.Transaction, vouts []*tx.TransactionVout) error {
+	if t.Type != "ClaimTransaction" && t.Type != "IssueTransaction" {
+		return nil
+	}
+
+	issued := make(map[string]amount.Amount)
+
+	for _, vout := range vouts {
+		isGAS := vout.AssetID == asset.GASAssetID
+		if (t.Type == "ClaimTransaction") != isGAS {
+			continue
+		}
+
+		if _, ok := issued[vout.AssetID]; !ok {
+			issued[vout.AssetID] = vout.Value
+		} else {
+			issued[vout.AssetID] = issued[vout.AssetID].Add(vout.Value)
+		}
+	}
+
+	for assetID, amount := range issued {
+		const query = "UPDATE `asset` SET `available` = `available` - ? WHERE `asset_id` = ? LIMIT 1"
+		if _, err := trans.Exec(query, decimalArg(amount), assetID); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// rollbackNep5Migrations reverts nep5 migrations made by the removed transactions,
+// and returns the old and new assets of them.
+func rollbackNep5Migrations(trans *txn, removed []*tx.Transaction) (map[string]bool, error) {
+	migrated := make(map[string]bool)
+	if len(removed) == 0 {
+		return migrated, nil
+	}
+
+	txIDs := []string{}
+	for _, t := range removed {
+		txIDs = append(txIDs, t.TxID)
+	}
+	inTxIDs := placeholders(len(txIDs))
+	args := stringArgs(txIDs)
+
+	query := fmt.Sprintf("SELECT `old_asset_id`, `new_asset_id` FROM `nep5_migrate` WHERE `migrate_txid` IN (%s)", inTxIDs)
+	rows, err := trans.Query(query, args...)
+	if err != nil {
+		return nil, err
+	}
+
+	oldAssetIDs := []string{}
+	for rows.Next() {
+		var oldAssetID, newAssetID string
+		if err := rows.Scan(&oldAssetID, &newAssetID); err != nil {
+			rows.Close()
+			return nil, err
+		}
+		oldAssetIDs = append(oldAssetIDs, oldAssetID)
+		migrated[oldAssetID] = true
+		migrated[newAssetID] = true
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	for _, assetID := range oldAssetIDs {
+		const showQuery = "UPDATE `nep5` SET `visible` = TRUE WHERE `asset_id` = ? LIMIT 1"
+		if _, err := trans.Exec(showQuery, assetID); err != nil {
+			return nil, err
+		}
+	}
+
+	deleteQuery := fmt.Sprintf("DELETE FROM `nep5_migrate` WHERE `migrate_txid` IN (%s)", inTxIDs)
+	if _, err := trans.Exec(deleteQuery, args...); err != nil {
+		return nil, err
+	}
+
+	return migrated, nil
+}
+
+func rollbackNep5Txs(trans *txn, height int) error {
+	const query = "SELECT `asset_id`, `from`, `to` FROM `nep5_tx` WHERE `block_index` > ?"
+	rows, err := trans.Query(query, height)
+	if err != nil {
+		return err
+	}
+
+	transfers := make(map[string]int)
+	// addrTransfers counts transfers of addresses in each asset.
+	addrTransfers := make(map[string]map[string]int)
+	for rows.Next() {
+		var assetID, from, to string
+		if err := rows.Scan(&assetID, &from, &to); err != nil {
+			rows.Close()
+			return err
+		}
+		transfers[assetID]++
+
+		addrs := []string{from}
+		if to != from {
+			addrs = append(addrs, to)
+		}
+		for _, addr := range addrs {
+			if len(addr) == 0 {
+				continue
+			}
+			if _, ok := addrTransfers[addr]; !ok {
+				addrTransfers[addr] = make(map[string]int)
+			}
+			addrTransfers[addr][assetID]++
+		}
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return err
+	}
+
+	for assetID, cnt := range transfers {
+		const decrTransfers = "UPDATE `nep5` SET `transfers` = `transfers` - ? WHERE `asset_id` = ? LIMIT 1"
+		if _, err := trans.Exec(decrTransfers, cnt, assetID); err != nil {
+			return err
+		}
+	}
+
+	var addrs []string
+	for addr := range addrTransfers {
+		addrs = append(addrs, addr)
+	}
+	// Sort address to avoid potential deadlock.
+	sort.Strings(addrs)
+
+	for _, addr := range addrs {
+		total := 0
+		for assetID, cnt := range addrTransfers[addr] {
+			total += cnt
+			const decrAddrAssetTxs = "UPDATE `addr_asset` SET `transactions` = `transactions` - ? WHERE `address` = ? AND `asset_id` = ? LIMIT 1"
+			if _, err := trans.Exec(decrAddrAssetTxs, cnt, addr, assetID); err != nil {
+				return err
+			}
+		}
+
+		const decrAddrTxs = "UPDATE `address` SET `trans_nep5` = `trans_nep5` - ? WHERE `address` = ? LIMIT 1"
+		if _, err := trans.Exec(decrAddrTxs, total, addr); err != nil {
+			return err
+		}
+	}
+
+	const deleteNep5Txs = "DELETE FROM `nep5_tx` WHERE `block_index` > ?"
+	_, err = trans.Exec(deleteNep5Txs, height)
+	return err
+}
+
+// rollbackNep5TotalSupply restores total supplies of nep5 assets changed after the height.
+func rollbackNep5TotalSupply(trans *txn, height int) error {
+	const query = "SELECT DISTINCT `asset_id` FROM `nep5_total_supply` WHERE `block_index` > ?"
+	rows, err := trans.Query(query, height)
+	if err != nil {
+		return err
+	}
+
+	var assetIDs []string
+	for rows.Next() {
+		var assetID string
+		if err := rows.Scan(&assetID); err != nil {
+			rows.Close()
+			return err
+		}
+		assetIDs = append(assetIDs, assetID)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return err
+	}
+
+	supplies := make(map[string]amount.Amount)
+	for _, assetID := range assetIDs {
+		var totalSupply amount.Amount
+		const lastQuery = "SELECT `total_supply` FROM `nep5_total_supply` WHERE `asset_id` = ? AND `block_index` <= ? ORDER BY `block_index` DESC, `id` DESC LIMIT 1"
+		err := trans.QueryRow(lastQuery, assetID, height).Scan(&totalSupply)
+		if err == sql.ErrNoRows {
+			// The history of assets registered before it was recorded starts with
+			// the supply before their first change, which is the supply at the height.
+			const firstQuery = "SELECT `total_supply` FROM `nep5_total_supply` WHERE `asset_id` = ? ORDER BY `block_index` ASC, `id` ASC LIMIT 1"
+			err = trans.QueryRow(firstQuery, assetID).Scan(&totalSupply)
+		}
+		if err != nil {
+			return err
+		}
+		supplies[assetID] = totalSupply
+	}
+
+	const deleteQuery = "DELETE FROM `nep5_total_supply` WHERE `block_index` > ?"
+	if _, err := trans.Exec(deleteQuery, height); err != nil {
+		return err
+	}
+
+	for assetID, totalSupply := range supplies {
+		const updateQuery = "UPDATE `nep5` SET `total_supply` = ? WHERE `asset_id` = ? LIMIT 1"
+		if _, err := trans.Exec(updateQuery, decimalArg(totalSupply), assetID); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// rollbackNep5Assets removes nep5 assets registered after the height with their holders.
+func rollbackNep5Assets(trans *txn, height int) error {
+	const query = "SELECT `id`, `asset_id` FROM `nep5` WHERE `block_index` > ?"
+	rows, err := trans.Query(query, height)
+	if err != nil {
+		return err
+	}
+
+	var ids []interface{}
+	var assetIDs []string
+	for rows.Next() {
+		var id uint
+		var assetID string
+		if err := rows.Scan(&id, &assetID); err != nil {
+			rows.Close()
+			return err
+		}
+		ids = append(ids, id)
+		assetIDs = append(assetIDs, assetID)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return err
+	}
+
+	if len(ids) == 0 {
+		return nil
+	}
+
+	deleteRegInfo := fmt.Sprintf("DELETE FROM `nep5_reg_info` WHERE `nep5_id` IN (%s)", placeholders(len(ids)))
+	if _, err := trans.Exec(deleteRegInfo, ids...); err != nil {
+		return err
+	}
+
+	deleteAddrAssets := fmt.Sprintf("DELETE FROM `addr_asset` WHERE `asset_id` IN (%s)", placeholders(len(assetIDs)))
+	if _, err := trans.Exec(deleteAddrAssets, stringArgs(assetIDs)...); err != nil {
+		return err
+	}
+
+	const deleteNep5 = "DELETE FROM `nep5` WHERE `block_index` > ?"
+	_, err = trans.Exec(deleteNep5, height)
+	return err
+}
+
+// recountNep5Addresses sets the number of addresses and holding addresses of nep5 asset from addr_asset.
+func recountNep5Addresses(trans *txn, assetID string) error {
+	const query = "SELECT `balance` FROM `addr_asset` WHERE `asset_id` = ?"
+	rows, err := trans.Query(query, assetID)
+	if err != nil {
+		return err
+	}
+
+	addresses, holdingAddresses := 0, 0
+	for rows.Next() {
+		var balance amount.Amount
+		if err := rows.Scan(&balance); err != nil {
+			rows.Close()
+			return err
+		}
+		addresses++
+		if balance.Sign() == 1 {
+			holdingAddresses++
+		}
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return err
+	}
+
+	const updateQuery = "UPDATE `nep5` SET `addresses` = ?, `holding_addresses` = ? WHERE `asset_id` = ? LIMIT 1"
+	_, err = trans.Exec(updateQuery, addresses, holdingAddresses, assetID)
+	return err
+}
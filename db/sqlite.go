@@ -0,0 +1,148 @@
+package db
+
+import (
+	"database/sql"
+	"errors"
+	"regexp"
+	"squirrel/amount"
+
+	sqlite3 "github.com/mattn/go-sqlite3"
+)
+
+// sqliteDriver is the sqlite3 driver with decimal functions registered on every connection.
+const sqliteDriver = "sqlite3_decimal"
+
+func init() {
+	sql.Register(sqliteDriver, &sqlite3.SQLiteDriver{ConnectHook: registerDecimalFuncs})
+}
+
+// sqliteDialect stores data in a single database file.
+// SQLite has no decimal type, amounts are stored as TEXT and summed or
+// added by decimal_sum, decimal_add and decimal_sub so no precision is lost.
+type sqliteDialect struct{}
+
+func (sqliteDialect) driverName() string {
+	return sqliteDriver
+}
+
+var (
+	sumPattern = regexp.MustCompile(`\bSUM\(`)
+	addPattern = regexp.MustCompile("(`\\w+`) \\+ \\?")
+	subPattern = regexp.MustCompile("(`\\w+`) - \\?")
+)
+
+// rebind removes 'LIMIT 1' of UPDATE and DELETE statements and replaces
+// SUM and column arithmetic with their decimal counterparts,
+// backtick quoted identifiers and '?' placeholders are supported by SQLite.
+func (sqliteDialect) rebind(query string) string {
+	query = stripUpdateLimit(query)
+	query = sumPattern.ReplaceAllString(query, "decimal_sum(")
+	query = addPattern.ReplaceAllString(query, "decimal_add($1, ?)")
+	return subPattern.ReplaceAllString(query, "decimal_sub($1, ?)")
+}
+
+func (sqliteDialect) ignoreDuplicates(insertQuery string) string {
+	return insertQuery + " ON CONFLICT DO NOTHING"
+}
+
+func (sqliteDialect) insertID(tx *txn, insertQuery string, args ...interface{}) (int64, error) {
+	res, err := tx.Exec(insertQuery, args...)
+	if err != nil {
+		return 0, err
+	}
+
+	return res.LastInsertId()
+}
+
+// connErr always returns false since there is no connection to lose.
+func (sqliteDialect) connErr(err error) bool {
+	return false
+}
+
+func registerDecimalFuncs(conn *sqlite3.SQLiteConn) error {
+	if err := conn.RegisterFunc("decimal_add", decimalAdd, true); err != nil {
+		return err
+	}
+	if err := conn.RegisterFunc("decimal_sub", decimalSub, true); err != nil {
+		return err
+	}
+
+	return conn.RegisterAggregator("decimal_sum", newDecimalSum, true)
+}
+
+// isNull reports if a function argument is NULL, which is passed as a nil byte slice.
+func isNull(v interface{}) bool {
+	b, ok := v.([]byte)
+	return v == nil || ok && b == nil
+}
+
+func scanDecimal(v interface{}) (amount.Amount, error) {
+	if isNull(v) {
+		return amount.Zero, errors.New("decimal arithmetic on NULL")
+	}
+
+	var a amount.Amount
+	err := a.Scan(v)
+	return a, err
+}
+
+func decimalAdd(x, y interface{}) (string, error) {
+	a, err := scanDecimal(x)
+	if err != nil {
+		return "", err
+	}
+	b, err := scanDecimal(y)
+	if err != nil {
+		return "", err
+	}
+
+	return a.Add(b).String(), nil
+}
+
+func decimalSub(x, y interface{}) (string, error) {
+	a, err := scanDecimal(x)
+	if err != nil {
+		return "", err
+	}
+	b, err := scanDecimal(y)
+	if err != nil {
+		return "", err
+	}
+
+	return a.Sub(b).String(), nil
+}
+
+// decimalSum is the decimal_sum aggregator, NULL values are skipped
+// and the sum of no values is NULL like SUM.
+type decimalSum struct {
+	sum   amount.Amount
+	empty bool
+	err   error
+}
+
+func newDecimalSum() *decimalSum {
+	return &decimalSum{sum: amount.Zero, empty: true}
+}
+
+func (s *decimalSum) Step(v interface{}) {
+	if isNull(v) || s.err != nil {
+		return
+	}
+
+	a, err := scanDecimal(v)
+	if err != nil {
+		s.err = err
+		return
+	}
+	s.sum = s.sum.Add(a)
+	s.empty = false
+}
+
+// Done returns the sum as bytes, since a nil byte slice is the only way to return NULL.
+func (s *decimalSum) Done() ([]byte, error) {
+	if s.empty || s.err != nil {
+		return nil, s.err
+	}
+
+	return []byte(s.sum.String()), nil
+}
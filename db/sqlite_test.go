@@ -0,0 +1,936 @@
+package db
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"squirrel/addr"
+	"squirrel/amount"
+	"squirrel/asset"
+	"squirrel/block"
+	"squirrel/cache"
+	"squirrel/gas"
+	"squirrel/log"
+	"squirrel/migrations"
+	"squirrel/nep5"
+	"squirrel/tx"
+	"testing"
+)
+
+func newTestSQLiteStorage(t *testing.T) (*sqlStorage, func()) {
+	log.Init()
+
+	dir, err := ioutil.TempDir("", "squirrel")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	connStr := "file:" + filepath.Join(dir, "test.db") + "?_journal_mode=WAL&_busy_timeout=10000&_txlock=immediate"
+	s := newSQLStorage(sqliteDialect{}, func() string { return connStr })
+	if _, err := migrations.Up(s.conn, "sqlite3"); err != nil {
+		t.Fatal(err)
+	}
+
+	return s, func() {
+		s.conn.Close()
+		os.RemoveAll(dir)
+		os.Remove("error.log")
+	}
+}
+
+func TestSQLiteStorage(t *testing.T) {
+	s, cleanup := newTestSQLiteStorage(t)
+	defer cleanup()
+
+	// Applying migrations again is a no-op.
+	if _, err := migrations.Up(s.conn, "sqlite3"); err != nil {
+		t.Fatal(err)
+	}
+
+	if h := s.GetLastHeight(); h != -1 {
+		t.Fatalf("GetLastHeight of empty db = %d, expected -1", h)
+	}
+
+	const txID = "0x0000000000000000000000000000000000000000000000000000000000000001"
+	const address = "AKkkumHbBipZ46UMZJoFynJMXzSRnBvKcs"
+
+	blocks := []*block.Block{{
+		Hash:              "0x00000000000000000000000000000000000000000000000000000000000000aa",
+		PreviousBlockHash: "0x0000000000000000000000000000000000000000000000000000000000000000",
+		Index:             0,
+		Time:              1468595301,
+		Nonce:             "000000007c2bac1d",
+	}}
+	trans := &tx.Transaction{
+		BlockIndex: 0,
+		BlockTime:  1468595301,
+		TxID:       txID,
+		Type:       "MinerTransaction",
+		SysFee:     amount.NewFromInt64(0, 0),
+		NetFee:     amount.NewFromInt64(0, 0),
+		Gas:        amount.NewFromInt64(0, 0),
+	}
+	vout := &tx.TransactionVout{
+		TxID:    txID,
+		N:       0,
+		AssetID: asset.NEOAssetID,
+		Value:   amount.NewFromInt64(100, 0),
+		Address: address,
+	}
+	bulk := &tx.Bulk{
+		TXs:     []*tx.Transaction{trans},
+		TXVouts: []*tx.TransactionVout{vout},
+	}
+
+	if err := s.InsertBlock(0, blocks, bulk); err != nil {
+		t.Fatal(err)
+	}
+	if h := s.GetLastHeight(); h != 0 {
+		t.Fatalf("GetLastHeight = %d, expected 0", h)
+	}
+
+	b, err := s.GetBlock(0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if b == nil || b.Hash != blocks[0].Hash {
+		t.Fatalf("GetBlock returns %+v", b)
+	}
+
+	stored, err := s.GetTx(txID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if stored == nil {
+		t.Fatal("GetTx returns nil")
+	}
+	trans.ID = stored.ID
+
+	cache.LoadAddrAssetInfo(s.GetAddrAssetInfo())
+	if err := s.ApplyVinsVouts(trans, nil, []*tx.TransactionVout{vout}); err != nil {
+		t.Fatal(err)
+	}
+
+	utxos, err := s.GetUnspentUTXOs(address, asset.NEOAssetID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(utxos) != 1 || utxos[0].Value.Cmp(amount.NewFromInt64(100, 0)) != 0 {
+		t.Fatalf("GetUnspentUTXOs returns %d utxos", len(utxos))
+	}
+
+	addrAssets, err := s.GetAddrAssets(address)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(addrAssets) != 1 || addrAssets[0].Balance.Cmp(amount.NewFromInt64(100, 0)) != 0 || addrAssets[0].Transactions != 1 {
+		t.Fatalf("GetAddrAssets returns %+v", addrAssets)
+	}
+
+	if pk := s.GetLastTxPkCounter(); pk != trans.ID {
+		t.Fatalf("GetLastTxPkCounter = %d, expected %d", pk, trans.ID)
+	}
+}
+
+func TestSQLiteInvalidWitness(t *testing.T) {
+	s, cleanup := newTestSQLiteStorage(t)
+	defer cleanup()
+
+	const txID = "0x0000000000000000000000000000000000000000000000000000000000000002"
+
+	blocks := []*block.Block{
+		{Hash: "0x00000000000000000000000000000000000000000000000000000000000000aa", Index: 0, Nonce: "0"},
+		{Hash: "0x00000000000000000000000000000000000000000000000000000000000000bb", Index: 1, Nonce: "0"},
+	}
+	bulk := &tx.Bulk{
+		InvalidWitnesses: []*tx.InvalidWitness{{TxID: txID, BlockIndex: 1, Reason: "signature 0 does not verify"}},
+	}
+
+	if err := s.InsertBlock(0, blocks, bulk); err != nil {
+		t.Fatal(err)
+	}
+
+	reason, err := s.GetInvalidWitness(txID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if reason != "signature 0 does not verify" {
+		t.Fatalf("GetInvalidWitness returns %q", reason)
+	}
+
+	if _, err := s.RollbackBlocks(0); err != nil {
+		t.Fatal(err)
+	}
+
+	reason, err = s.GetInvalidWitness(txID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if reason != "" {
+		t.Fatalf("GetInvalidWitness returns %q after rollback", reason)
+	}
+}
+
+func TestSQLiteAddrContract(t *testing.T) {
+	s, cleanup := newTestSQLiteStorage(t)
+	defer cleanup()
+
+	const address = "APyEx5f4Zm4oCHwFWiSTaph1fPBxZacYVR"
+
+	contract := &addr.Contract{
+		Address:    address,
+		Type:       "multisig",
+		Threshold:  2,
+		PublicKeys: []string{"02aa", "03bb"},
+		Script:     "5221...52ae",
+		TxID:       "0x0000000000000000000000000000000000000000000000000000000000000003",
+		BlockIndex: 1,
+	}
+	blocks := []*block.Block{
+		{Hash: "0x00000000000000000000000000000000000000000000000000000000000000aa", Index: 0, Nonce: "0"},
+		{Hash: "0x00000000000000000000000000000000000000000000000000000000000000bb", Index: 1, Nonce: "0"},
+	}
+	if err := s.InsertBlock(1, blocks, &tx.Bulk{AddrContracts: []*addr.Contract{contract}}); err != nil {
+		t.Fatal(err)
+	}
+
+	// The contract of the first witnessed transaction is kept.
+	later := *contract
+	later.TxID = "0x0000000000000000000000000000000000000000000000000000000000000004"
+	later.BlockIndex = 2
+	blocks = []*block.Block{{Hash: "0x00000000000000000000000000000000000000000000000000000000000000cc", Index: 2, Nonce: "0"}}
+	if err := s.InsertBlock(2, blocks, &tx.Bulk{AddrContracts: []*addr.Contract{&later}}); err != nil {
+		t.Fatal(err)
+	}
+
+	stored, err := s.GetAddrContract(address)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if stored == nil || stored.TxID != contract.TxID || stored.Threshold != 2 || len(stored.PublicKeys) != 2 || stored.PublicKeys[1] != "03bb" {
+		t.Fatalf("GetAddrContract returns %+v", stored)
+	}
+
+	if _, err := s.RollbackBlocks(0); err != nil {
+		t.Fatal(err)
+	}
+
+	stored, err = s.GetAddrContract(address)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if stored != nil {
+		t.Fatalf("GetAddrContract returns %+v after rollback", stored)
+	}
+}
+
+func TestSQLiteUnclaimedGas(t *testing.T) {
+	s, cleanup := newTestSQLiteStorage(t)
+	defer cleanup()
+
+	const addrA = "AKkkumHbBipZ46UMZJoFynJMXzSRnBvKcs"
+	const addrB = "APyEx5f4Zm4oCHwFWiSTaph1fPBxZacYVR"
+
+	newTx := func(txID string, blockIndex uint, txType string, sysFee int64) *tx.Transaction {
+		return &tx.Transaction{
+			BlockIndex: blockIndex,
+			TxID:       txID,
+			Type:       txType,
+			SysFee:     amount.NewFromInt64(sysFee, 0),
+			NetFee:     amount.NewFromInt64(0, 0),
+			Gas:        amount.NewFromInt64(0, 0),
+		}
+	}
+	neo := amount.NewFromInt64(100, 0)
+
+	// Block 0 sends 100 NEO to A, block 2 pays 1000 GAS system fee and A sends the NEO to B in block 5.
+	issue := newTx("0x00000000000000000000000000000000000000000000000000000000000000a0", 0, "IssueTransaction", 0)
+	invoke := newTx("0x00000000000000000000000000000000000000000000000000000000000000a2", 2, "InvocationTransaction", 1000)
+	spend := newTx("0x00000000000000000000000000000000000000000000000000000000000000a5", 5, "ContractTransaction", 0)
+	issueVout := &tx.TransactionVout{TxID: issue.TxID, N: 0, AssetID: asset.NEOAssetID, Value: neo, Address: addrA}
+	spendVin := &tx.TransactionVin{From: spend.TxID, TxID: issue.TxID, Vout: 0}
+	spendVout := &tx.TransactionVout{TxID: spend.TxID, N: 0, AssetID: asset.NEOAssetID, Value: neo, Address: addrB}
+
+	txs := map[uint]*tx.Transaction{0: issue, 2: invoke, 5: spend}
+	for i := uint(0); i <= 5; i++ {
+		blocks := []*block.Block{{Hash: fmt.Sprintf("0x%064x", i+1), Index: i, Nonce: "0"}}
+		bulk := &tx.Bulk{}
+		if t, ok := txs[i]; ok {
+			bulk.TXs = []*tx.Transaction{t}
+		}
+		if i == 0 {
+			bulk.TXVouts = []*tx.TransactionVout{issueVout}
+		}
+		if i == 5 {
+			bulk.TXVins = []*tx.TransactionVin{spendVin}
+			bulk.TXVouts = []*tx.TransactionVout{spendVout}
+		}
+		if err := s.InsertBlock(int(i), blocks, bulk); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	for _, trans := range []*tx.Transaction{issue, invoke, spend} {
+		stored, err := s.GetTx(trans.TxID)
+		if err != nil {
+			t.Fatal(err)
+		}
+		trans.ID = stored.ID
+	}
+
+	expect := func(address string, end uint, available, unavailable amount.Amount) {
+		t.Helper()
+
+		unclaimed, err := s.GetUnclaimedGas(address, end)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if unclaimed.Available.Cmp(available) != 0 || unclaimed.Unavailable.Cmp(unavailable) != 0 {
+			t.Fatalf("GetUnclaimedGas(%s, %d) = %s, %s, expected %s, %s", address, end,
+				unclaimed.Available, unclaimed.Unavailable, available, unavailable)
+		}
+	}
+	bonus := func(start, end uint) amount.Amount {
+		t.Helper()
+
+		b, err := gas.Calculate(neo, start, end, s.GetSysFeeAmount)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return b.Unclaimed
+	}
+
+	// The counter row is created on first read.
+	s.GetLastTxPkCounter()
+	cache.LoadAddrAssetInfo(s.GetAddrAssetInfo())
+	if err := s.ApplyVinsVouts(issue, nil, []*tx.TransactionVout{issueVout}); err != nil {
+		t.Fatal(err)
+	}
+	expect(addrA, 4, amount.Zero, bonus(0, 4))
+
+	if err := s.ApplyVinsVouts(invoke, nil, nil); err != nil {
+		t.Fatal(err)
+	}
+	if err := s.ApplyVinsVouts(spend, []*tx.TransactionVin{spendVin}, []*tx.TransactionVout{spendVout}); err != nil {
+		t.Fatal(err)
+	}
+	expect(addrA, 6, bonus(0, 5), amount.Zero)
+	expect(addrB, 6, amount.Zero, bonus(5, 6))
+
+	// Outputs stored before schema version 5 are calculated until they are backfilled.
+	if _, err := s.exec("UPDATE `utxo` SET `start_gas` = NULL, `claim_gas` = NULL"); err != nil {
+		t.Fatal(err)
+	}
+	expect(addrA, 6, bonus(0, 5), amount.Zero)
+	expect(addrB, 6, amount.Zero, bonus(5, 6))
+
+	legacy, err := s.GetUTXOsWithoutGas(0, 100, 10)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(legacy) != 2 || legacy[0].BlockIndex != 0 || legacy[1].BlockIndex != 5 {
+		t.Fatalf("GetUTXOsWithoutGas returns %d outputs", len(legacy))
+	}
+	if err := s.BackfillUTXOGas(legacy, 100); err != nil {
+		t.Fatal(err)
+	}
+	if pk, _ := s.GetUTXOGasBackfillPk(); pk != 100 {
+		t.Fatalf("GetUTXOGasBackfillPk = %d, expected 100", pk)
+	}
+	if legacy, err := s.GetUTXOsWithoutGas(0, 100, 10); err != nil || len(legacy) != 0 {
+		t.Fatalf("GetUTXOsWithoutGas after backfill returns (%d, %v)", len(legacy), err)
+	}
+	expect(addrA, 6, bonus(0, 5), amount.Zero)
+	expect(addrB, 6, amount.Zero, bonus(5, 6))
+
+	if _, err := s.RollbackBlocks(4); err != nil {
+		t.Fatal(err)
+	}
+	expect(addrA, 5, amount.Zero, bonus(0, 5))
+	expect(addrB, 5, amount.Zero, amount.Zero)
+}
+
+func TestSQLiteBlockFee(t *testing.T) {
+	s, cleanup := newTestSQLiteStorage(t)
+	defer cleanup()
+
+	s.GetLastTxPkCounter()
+
+	insert := func(index uint, sysFee int64, fees bool) {
+		t.Helper()
+
+		trans := &tx.Transaction{
+			BlockIndex: index,
+			TxID:       fmt.Sprintf("0x%064x", index+0xa0),
+			Type:       "MinerTransaction",
+			SysFee:     amount.NewFromInt64(sysFee, 0),
+			NetFee:     amount.NewFromInt64(1, 0),
+			Gas:        amount.NewFromInt64(0, 0),
+		}
+		bulk := &tx.Bulk{TXs: []*tx.Transaction{trans}}
+		if fees {
+			bulk.BlockFees = []*tx.BlockFee{{BlockIndex: index, SysFee: trans.SysFee, NetFee: trans.NetFee}}
+		}
+
+		blocks := []*block.Block{{Hash: fmt.Sprintf("0x%064x", index+1), Index: index, Nonce: "0"}}
+		if err := s.InsertBlock(int(index), blocks, bulk); err != nil {
+			t.Fatal(err)
+		}
+	}
+	expectCumulative := func(index uint, expected int64) {
+		t.Helper()
+
+		fee, err := s.GetBlockFee(index)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if fee == nil || fee.CumulativeSysFee.Cmp(amount.NewFromInt64(expected, 0)) != 0 {
+			t.Fatalf("GetBlockFee(%d) returns %+v, expected cumulative system fee %d", index, fee, expected)
+		}
+	}
+
+	// Blocks 0 to 2 are stored before block fees were recorded.
+	insert(0, 0, false)
+	insert(1, 10, false)
+	insert(2, 20, false)
+	insert(3, 30, true)
+	expectCumulative(3, 60)
+
+	end, err := s.GetBlockFeeBackfillEnd()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if end != 3 {
+		t.Fatalf("GetBlockFeeBackfillEnd = %d, expected 3", end)
+	}
+
+	fees, err := s.GetBlockFees(0, end)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(fees) != 3 || fees[1].SysFee.Cmp(amount.NewFromInt64(10, 0)) != 0 || fees[1].NetFee.Cmp(amount.NewFromInt64(1, 0)) != 0 {
+		t.Fatalf("GetBlockFees returns %d fees", len(fees))
+	}
+	if err := s.BackfillBlockFees(fees, end); err != nil {
+		t.Fatal(err)
+	}
+	expectCumulative(1, 10)
+	expectCumulative(2, 30)
+
+	if index := s.GetBlockFeeBackfillIndex(); index != 3 {
+		t.Fatalf("GetBlockFeeBackfillIndex = %d, expected 3", index)
+	}
+	if sysFee, err := s.GetSysFeeAmount(2); err != nil || sysFee != 30 {
+		t.Fatalf("GetSysFeeAmount(2) = %d, %v", sysFee, err)
+	}
+
+	if _, err := s.RollbackBlocks(1); err != nil {
+		t.Fatal(err)
+	}
+	if fee, err := s.GetBlockFee(2); err != nil || fee != nil {
+		t.Fatalf("GetBlockFee(2) returns %+v, %v after rollback", fee, err)
+	}
+	if index := s.GetBlockFeeBackfillIndex(); index != 2 {
+		t.Fatalf("GetBlockFeeBackfillIndex = %d after rollback, expected 2", index)
+	}
+
+	insert(2, 5, true)
+	expectCumulative(2, 15)
+}
+
+func TestSQLiteAddrAssetBalance(t *testing.T) {
+	s, cleanup := newTestSQLiteStorage(t)
+	defer cleanup()
+
+	const addrA = "AKkkumHbBipZ46UMZJoFynJMXzSRnBvKcs"
+	const addrB = "APyEx5f4Zm4oCHwFWiSTaph1fPBxZacYVR"
+	const date = "2019-01-01"
+	value := amount.NewFromInt64(100, 0)
+
+	// GAS is recorded by default, NEO rows are left by a previous balance_assets config.
+	issue := &tx.Transaction{BlockIndex: 0, BlockTime: 1546300800, TxID: fmt.Sprintf("0x%064x", 0xa0), Type: "IssueTransaction"}
+	send := &tx.Transaction{BlockIndex: 1, BlockTime: 1546304400, TxID: fmt.Sprintf("0x%064x", 0xa1), Type: "ContractTransaction"}
+	issueVouts := []*tx.TransactionVout{
+		{TxID: issue.TxID, N: 0, AssetID: asset.GASAssetID, Value: value, Address: addrA},
+		{TxID: issue.TxID, N: 1, AssetID: asset.NEOAssetID, Value: value, Address: addrA},
+	}
+	sendVins := []*tx.TransactionVin{
+		{From: send.TxID, TxID: issue.TxID, Vout: 0},
+		{From: send.TxID, TxID: issue.TxID, Vout: 1},
+	}
+	sendVouts := []*tx.TransactionVout{
+		{TxID: send.TxID, N: 0, AssetID: asset.GASAssetID, Value: value, Address: addrB},
+		{TxID: send.TxID, N: 1, AssetID: asset.NEOAssetID, Value: value, Address: addrB},
+	}
+
+	for i, trans := range []*tx.Transaction{issue, send} {
+		trans.SysFee = amount.NewFromInt64(0, 0)
+		trans.NetFee = amount.NewFromInt64(0, 0)
+		trans.Gas = amount.NewFromInt64(0, 0)
+
+		bulk := &tx.Bulk{TXs: []*tx.Transaction{trans}, TXVouts: issueVouts}
+		if i == 1 {
+			bulk.TXVins = sendVins
+			bulk.TXVouts = sendVouts
+		}
+
+		blocks := []*block.Block{{Hash: fmt.Sprintf("0x%064x", i+1), Index: uint(i), Nonce: "0"}}
+		if err := s.InsertBlock(i, blocks, bulk); err != nil {
+			t.Fatal(err)
+		}
+
+		stored, err := s.GetTx(trans.TxID)
+		if err != nil {
+			t.Fatal(err)
+		}
+		trans.ID = stored.ID
+	}
+
+	s.GetLastTxPkCounter()
+	if err := s.ApplyAddrAssetBalances(issue, tx.BalanceChanges(nil, issueVouts)); err != nil {
+		t.Fatal(err)
+	}
+	if err := s.ApplyAddrAssetBalances(send, tx.BalanceChanges(issueVouts, sendVouts)); err != nil {
+		t.Fatal(err)
+	}
+
+	expect := func(address, assetID string, expected amount.Amount) {
+		t.Helper()
+
+		balance, err := s.GetAddrAssetBalance(address, assetID, date)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if balance.Cmp(expected) != 0 {
+			t.Fatalf("balance of %s in %s is %s, expected %s", address, assetID, balance, expected)
+		}
+	}
+	for _, assetID := range []string{asset.GASAssetID, asset.NEOAssetID} {
+		expect(addrA, assetID, amount.Zero)
+		expect(addrB, assetID, value)
+	}
+
+	if _, err := s.RollbackBlocks(0); err != nil {
+		t.Fatal(err)
+	}
+	expect(addrA, asset.GASAssetID, value)
+	expect(addrB, asset.GASAssetID, amount.Zero)
+	expect(addrA, asset.NEOAssetID, amount.Zero)
+	expect(addrB, asset.NEOAssetID, value)
+}
+
+func TestSQLiteNep5Balance(t *testing.T) {
+	s, cleanup := newTestSQLiteStorage(t)
+	defer cleanup()
+
+	const address = "AKkkumHbBipZ46UMZJoFynJMXzSRnBvKcs"
+	const assetID = "ecc6b20d3ccac1ee9ef109af5a7cdb85706b1df9"
+	const day = 86400
+	const start = 1546300800 // 2019-01-01
+
+	records := []struct {
+		blockIndex uint
+		blockTime  uint64
+		balance    int64
+	}{
+		{1, start, 0},
+		{2, start + 60, 10},
+		{3, start + 120, 10},
+		{4, start + 180, 7},
+		{5, start + day, 12},
+		{6, start + day + 60, 20},
+	}
+	for _, r := range records {
+		err := s.transact(func(trans *txn) error {
+			return recordNep5Balance(trans, address, assetID, r.blockIndex, r.blockTime, amount.NewFromInt64(r.balance, 0))
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	var rows int
+	if err := s.queryRow("SELECT COUNT(*) FROM `nep5_balance`").Scan(&rows); err != nil {
+		t.Fatal(err)
+	}
+	if rows != 4 {
+		t.Fatalf("%d balance rows recorded, expected 4", rows)
+	}
+
+	expectAt := func(height uint, expected int64) {
+		t.Helper()
+
+		balance, err := s.GetNep5BalanceAt(address, assetID, height)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if balance.Cmp(amount.NewFromInt64(expected, 0)) != 0 {
+			t.Fatalf("balance at %d is %s, expected %d", height, balance, expected)
+		}
+	}
+	expectOn := func(date string, expected int64) {
+		t.Helper()
+
+		balance, err := s.GetAddrAssetBalance(address, assetID, date)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if balance.Cmp(amount.NewFromInt64(expected, 0)) != 0 {
+			t.Fatalf("balance on %s is %s, expected %d", date, balance, expected)
+		}
+	}
+
+	expectAt(1, 0)
+	expectAt(3, 10)
+	expectAt(4, 7)
+	expectAt(100, 20)
+	expectOn("2018-12-31", 0)
+	expectOn("2019-01-01", 7)
+	expectOn("2019-01-02", 20)
+
+	if _, err := s.RollbackBlocks(5); err != nil {
+		t.Fatal(err)
+	}
+	expectAt(100, 12)
+	expectOn("2019-01-02", 12)
+
+	if _, err := s.RollbackBlocks(3); err != nil {
+		t.Fatal(err)
+	}
+	expectAt(100, 10)
+	expectOn("2019-01-01", 10)
+	expectOn("2019-01-02", 10)
+}
+
+func TestSQLiteNep5BalanceStart(t *testing.T) {
+	s, cleanup := newTestSQLiteStorage(t)
+	defer cleanup()
+
+	const addrA = "AKkkumHbBipZ46UMZJoFynJMXzSRnBvKcs"
+	const addrB = "APyEx5f4Zm4oCHwFWiSTaph1fPBxZacYVR"
+	const addrC = "AQVh2pG732YvtNaxEGkQUei3YA4cvo7d2i"
+	const assetID = "ecc6b20d3ccac1ee9ef109af5a7cdb85706b1df9"
+	const start = 1546300800 // 2019-01-01
+
+	for i := uint(0); i < 12; i++ {
+		blocks := []*block.Block{{Hash: fmt.Sprintf("0x%064x", i+1), Index: i, Time: start + uint64(i)*60, Nonce: "0"}}
+		if err := s.InsertBlock(int(i), blocks, &tx.Bulk{}); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	// History is recorded from block 10, A and C held the asset before.
+	s.GetLastTxPkCounter()
+	if _, err := s.exec("UPDATE `counter` SET `nep5_balance_start_index` = 10"); err != nil {
+		t.Fatal(err)
+	}
+	const insertQuery = "INSERT INTO `addr_asset` (`address`, `asset_id`, `balance`, `transactions`, `last_transaction_time`) VALUES (?, ?, ?, 1, 0)"
+	for address, balance := range map[string]int64{addrA: 50, addrC: 30} {
+		if _, err := s.exec(insertQuery, address, assetID, decimalArg(amount.NewFromInt64(balance, 0))); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	expectAt := func(address string, height uint, expected int64) {
+		t.Helper()
+
+		balance, err := s.GetNep5BalanceAt(address, assetID, height)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if balance.Cmp(amount.NewFromInt64(expected, 0)) != 0 {
+			t.Fatalf("balance of %s at %d is %s, expected %d", address, height, balance, expected)
+		}
+	}
+
+	if _, err := s.GetNep5BalanceAt(addrA, assetID, 8); err != ErrNep5HistoryUnavailable {
+		t.Fatalf("GetNep5BalanceAt before the history returns %v", err)
+	}
+	expectAt(addrA, 9, 50)
+	expectAt(addrC, 11, 30)
+
+	for address, balance := range map[string]int64{addrA: 40, addrB: 5} {
+		err := s.transact(func(trans *txn) error {
+			return recordNep5Balance(trans, address, assetID, 11, start+11*60, amount.NewFromInt64(balance, 0))
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	expectAt(addrA, 9, 50)
+	expectAt(addrA, 10, 50)
+	expectAt(addrA, 11, 40)
+	expectAt(addrB, 10, 0)
+	expectAt(addrB, 11, 5)
+}
+
+func TestSQLiteDecimal(t *testing.T) {
+	s, cleanup := newTestSQLiteStorage(t)
+	defer cleanup()
+
+	// 35 significant digits, far beyond the 15 digits of a REAL.
+	large := amount.MustParse("123456789012345678901234567.12345678")
+	small := amount.MustParse("0.00000001")
+
+	const insertQuery = "INSERT INTO `addr_asset` (`address`, `asset_id`, `balance`, `transactions`, `last_transaction_time`) VALUES (?, ?, ?, 0, 0)"
+	for _, address := range []string{"a", "b"} {
+		if _, err := s.exec(insertQuery, address, "asset", decimalArg(large)); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	var balance amount.Amount
+	if err := s.queryRow("SELECT `balance` FROM `addr_asset` WHERE `address` = ?", "a").Scan(&balance); err != nil {
+		t.Fatal(err)
+	}
+	if balance.Cmp(large) != 0 {
+		t.Fatalf("balance = %s, expected %s", balance, large)
+	}
+
+	if _, err := s.exec("UPDATE `addr_asset` SET `balance` = `balance` + ? WHERE `address` = ? LIMIT 1", decimalArg(small), "a"); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := s.exec("UPDATE `addr_asset` SET `balance` = `balance` - ? WHERE `address` = ? LIMIT 1", decimalArg(small), "b"); err != nil {
+		t.Fatal(err)
+	}
+
+	expected := map[string]amount.Amount{
+		"a": large.Add(small),
+		"b": large.Sub(small),
+	}
+	for address, want := range expected {
+		if err := s.queryRow("SELECT `balance` FROM `addr_asset` WHERE `address` = ?", address).Scan(&balance); err != nil {
+			t.Fatal(err)
+		}
+		if balance.Cmp(want) != 0 {
+			t.Fatalf("balance of %s = %s, expected %s", address, balance, want)
+		}
+	}
+
+	var sum amount.Amount
+	if err := s.queryRow("SELECT SUM(`balance`) FROM `addr_asset`").Scan(&sum); err != nil {
+		t.Fatal(err)
+	}
+	if want := large.Add(large); sum.Cmp(want) != 0 {
+		t.Fatalf("SUM(balance) = %s, expected %s", sum, want)
+	}
+
+	// NULL values are skipped and the sum of no values is NULL.
+	var null interface{}
+	if err := s.queryRow("SELECT SUM(`claim_gas`) FROM `utxo`").Scan(&null); err != nil || null != nil {
+		t.Fatalf("SUM of no values = (%v, %v), expected NULL", null, err)
+	}
+}
+
+func TestSQLiteRollbackBlocks(t *testing.T) {
+	s, cleanup := newTestSQLiteStorage(t)
+	defer cleanup()
+
+	const addrA = "AKkkumHbBipZ46UMZJoFynJMXzSRnBvKcs"
+	const addrB = "APyEx5f4Zm4oCHwFWiSTaph1fPBxZacYVR"
+	const addrC = "AQVh2pG732YvtNaxEGkQUei3YA4cvo7d2i"
+	const tokenX = "ecc6b20d3ccac1ee9ef109af5a7cdb85706b1df9"
+	const tokenY = "b9d7ea3062e6aeeb3e8ad9548220c4ba1361d263"
+	const start = 1546300800 // 2019-01-01
+
+	newTx := func(n int, blockIndex uint, txType string) *tx.Transaction {
+		return &tx.Transaction{
+			BlockIndex: blockIndex,
+			BlockTime:  start + uint64(blockIndex)*60,
+			TxID:       fmt.Sprintf("0x%064x", n),
+			Type:       txType,
+			SysFee:     amount.Zero,
+			NetFee:     amount.Zero,
+			Gas:        amount.Zero,
+		}
+	}
+	value := func(v int64) amount.Amount {
+		return amount.NewFromInt64(v, 0)
+	}
+
+	// Block 1 sends the issued NEO from A to B and registers token X, block 2 is rolled back:
+	// A claims GAS, B sends the NEO to C, token X is transferred and minted and token Y is registered.
+	issue := newTx(0xa0, 0, "IssueTransaction")
+	send := newTx(0xa1, 1, "ContractTransaction")
+	register := newTx(0xb1, 1, "InvocationTransaction")
+	claim := newTx(0xa2, 2, "ClaimTransaction")
+	resend := newTx(0xa3, 2, "ContractTransaction")
+	invoke := newTx(0xb2, 2, "InvocationTransaction")
+
+	issueVouts := []*tx.TransactionVout{
+		{TxID: issue.TxID, N: 0, AssetID: asset.NEOAssetID, Value: value(100), Address: addrA},
+		{TxID: issue.TxID, N: 1, AssetID: asset.GASAssetID, Value: value(10), Address: addrA},
+	}
+	sendVin := &tx.TransactionVin{From: send.TxID, TxID: issue.TxID, Vout: 0}
+	sendVout := &tx.TransactionVout{TxID: send.TxID, N: 0, AssetID: asset.NEOAssetID, Value: value(100), Address: addrB}
+	claimVout := &tx.TransactionVout{TxID: claim.TxID, N: 0, AssetID: asset.GASAssetID, Value: value(5), Address: addrA}
+	resendVin := &tx.TransactionVin{From: resend.TxID, TxID: send.TxID, Vout: 0}
+	resendVout := &tx.TransactionVout{TxID: resend.TxID, N: 0, AssetID: asset.NEOAssetID, Value: value(100), Address: addrC}
+
+	bulks := []*tx.Bulk{
+		{
+			TXs:     []*tx.Transaction{issue},
+			TXVouts: issueVouts,
+			Assets: []*asset.Asset{
+				{AssetID: asset.NEOAssetID, Type: "GoverningToken", Amount: value(100000000), Available: amount.Zero},
+				{AssetID: asset.GASAssetID, Type: "UtilityToken", Amount: value(100000000), Available: amount.Zero},
+			},
+		},
+		{
+			TXs:     []*tx.Transaction{send, register},
+			TXVins:  []*tx.TransactionVin{sendVin},
+			TXVouts: []*tx.TransactionVout{sendVout},
+		},
+		{
+			TXs:     []*tx.Transaction{claim, resend, invoke},
+			TXVins:  []*tx.TransactionVin{resendVin},
+			TXVouts: []*tx.TransactionVout{claimVout, resendVout},
+			Claims:  []*tx.TransactionClaims{{From: claim.TxID, TxID: issue.TxID, Vout: 0}},
+		},
+	}
+	// The counter row is created on first read.
+	s.GetLastTxPkCounter()
+	for i, bulk := range bulks {
+		blocks := []*block.Block{{Hash: fmt.Sprintf("0x%064x", i+1), Index: uint(i), Time: start + uint64(i)*60, Nonce: "0"}}
+		if err := s.InsertBlock(i, blocks, bulk); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	for _, trans := range []*tx.Transaction{issue, send, register, claim, resend, invoke} {
+		stored, err := s.GetTx(trans.TxID)
+		if err != nil {
+			t.Fatal(err)
+		}
+		trans.ID = stored.ID
+	}
+
+	cache.LoadAddrAssetInfo(s.GetAddrAssetInfo())
+
+	applies := []struct {
+		t     *tx.Transaction
+		vins  []*tx.TransactionVin
+		vouts []*tx.TransactionVout
+	}{
+		{issue, nil, issueVouts},
+		{send, []*tx.TransactionVin{sendVin}, []*tx.TransactionVout{sendVout}},
+		{register, nil, nil},
+		{claim, nil, []*tx.TransactionVout{claimVout}},
+		{resend, []*tx.TransactionVin{resendVin}, []*tx.TransactionVout{resendVout}},
+		{invoke, nil, nil},
+	}
+	for _, a := range applies {
+		if err := s.ApplyVinsVouts(a.t, a.vins, a.vouts); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	x := &nep5.Nep5{AssetID: tokenX, AdminAddress: addrA, Name: "X", Symbol: "X", TotalSupply: value(1000),
+		TxID: register.TxID, BlockIndex: 1, BlockTime: register.BlockTime, Addresses: 1, HoldingAddresses: 1}
+	if err := s.InsertNep5Asset(register, x, &nep5.RegInfo{}, &addr.Asset{Address: addrA, AssetID: tokenX, Balance: value(1000)}, 1); err != nil {
+		t.Fatal(err)
+	}
+	if err := s.InsertNep5transaction(register, 0, tokenX, addrA, value(900), addrB, value(100), value(100), nil); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := s.InsertNep5transaction(invoke, 0, tokenX, addrA, value(850), addrB, value(150), value(50), nil); err != nil {
+		t.Fatal(err)
+	}
+	if err := s.UpdateNep5TotalSupplyAndAddrAsset(invoke.BlockTime, 2, addrC, value(500), tokenX, value(1500)); err != nil {
+		t.Fatal(err)
+	}
+	y := &nep5.Nep5{AssetID: tokenY, AdminAddress: addrA, Name: "Y", Symbol: "Y", TotalSupply: value(10),
+		TxID: invoke.TxID, BlockIndex: 2, BlockTime: invoke.BlockTime, Addresses: 1, HoldingAddresses: 1}
+	if err := s.InsertNep5Asset(invoke, y, &nep5.RegInfo{}, &addr.Asset{Address: addrA, AssetID: tokenY, Balance: value(10)}, 2); err != nil {
+		t.Fatal(err)
+	}
+
+	count := func(query string, args ...interface{}) int {
+		t.Helper()
+
+		var cnt int
+		if err := s.queryRow(query, args...).Scan(&cnt); err != nil {
+			t.Fatal(err)
+		}
+		return cnt
+	}
+	expectAmount := func(name string, query string, expected amount.Amount, args ...interface{}) {
+		t.Helper()
+
+		var actual amount.Amount
+		if err := s.queryRow(query, args...).Scan(&actual); err != nil {
+			t.Fatalf("%s: %v", name, err)
+		}
+		if actual.Cmp(expected) != 0 {
+			t.Fatalf("%s is %s, expected %s", name, actual, expected)
+		}
+	}
+	const balanceQuery = "SELECT `balance` FROM `addr_asset` WHERE `address` = ? AND `asset_id` = ?"
+	const availableQuery = "SELECT `available` FROM `asset` WHERE `asset_id` = ?"
+
+	if cnt := count("SELECT COUNT(*) FROM `tx_claims`"); cnt != 1 {
+		t.Fatalf("%d claims stored, expected 1", cnt)
+	}
+	expectAmount("GAS available", availableQuery, value(5), asset.GASAssetID)
+	expectAmount("X total supply", "SELECT `total_supply` FROM `nep5` WHERE `asset_id` = ?", value(1500), tokenX)
+
+	removed, err := s.RollbackBlocks(1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(removed) != 3 {
+		t.Fatalf("%d transactions removed, expected 3", len(removed))
+	}
+
+	// Outputs.
+	if cnt := count("SELECT COUNT(*) FROM `utxo`"); cnt != 3 {
+		t.Fatalf("%d utxos remain, expected 3", cnt)
+	}
+	if cnt := count("SELECT COUNT(*) FROM `utxo` WHERE `txid` = ? AND `used_in_tx` IS NULL", send.TxID); cnt != 1 {
+		t.Fatal("output spent by a removed transaction is not unspent")
+	}
+	if cnt := count("SELECT COUNT(*) FROM `tx_claims`"); cnt != 0 {
+		t.Fatalf("%d claims remain, expected 0", cnt)
+	}
+
+	// Global assets.
+	expectAmount("NEO of A", balanceQuery, amount.Zero, addrA, asset.NEOAssetID)
+	expectAmount("NEO of B", balanceQuery, value(100), addrB, asset.NEOAssetID)
+	expectAmount("NEO of C", balanceQuery, amount.Zero, addrC, asset.NEOAssetID)
+	expectAmount("GAS of A", balanceQuery, value(10), addrA, asset.GASAssetID)
+	expectAmount("NEO available", availableQuery, value(100), asset.NEOAssetID)
+	expectAmount("GAS available", availableQuery, amount.Zero, asset.GASAssetID)
+
+	// NEP5 assets.
+	expectAmount("X of A", balanceQuery, value(900), addrA, tokenX)
+	expectAmount("X of B", balanceQuery, value(100), addrB, tokenX)
+	if cnt := count("SELECT COUNT(*) FROM `addr_asset` WHERE `asset_id` = ? AND `address` = ?", tokenX, addrC); cnt != 0 {
+		t.Fatal("X of C is not removed")
+	}
+	if cnt := count("SELECT `transactions` FROM `addr_asset` WHERE `address` = ? AND `asset_id` = ?", addrA, tokenX); cnt != 1 {
+		t.Fatalf("A has %d X transactions, expected 1", cnt)
+	}
+	expectAmount("X total supply", "SELECT `total_supply` FROM `nep5` WHERE `asset_id` = ?", value(1000), tokenX)
+	if cnt := count("SELECT `addresses` + 10 * `holding_addresses` + 100 * `transfers` FROM `nep5` WHERE `asset_id` = ?", tokenX); cnt != 122 {
+		t.Fatalf("X addresses, holding addresses and transfers are %d, expected 122", cnt)
+	}
+	for _, table := range []string{"nep5", "nep5_reg_info"} {
+		if cnt := count("SELECT COUNT(*) FROM `" + table + "`"); cnt != 1 {
+			t.Fatalf("%d rows remain in %s, expected 1", cnt, table)
+		}
+	}
+	if cnt := count("SELECT COUNT(*) FROM `addr_asset` WHERE `asset_id` = ?", tokenY); cnt != 0 {
+		t.Fatal("holders of removed token are not removed")
+	}
+
+	// Counters.
+	if cnt := count("SELECT `cnt_tx_issue` + 10 * `cnt_tx_contract` + 100 * `cnt_tx_claim` + 1000 * `cnt_tx_invocation` FROM `counter`"); cnt != 1011 {
+		t.Fatalf("transaction counters are %d, expected 1011", cnt)
+	}
+	if h := s.GetLastHeight(); h != 1 {
+		t.Fatalf("GetLastHeight = %d, expected 1", h)
+	}
+}
@@ -0,0 +1,422 @@
+package db
+
+import (
+	"context"
+	"squirrel/addr"
+	"squirrel/amount"
+	"squirrel/block"
+	"squirrel/gas"
+	"squirrel/nep5"
+	"squirrel/tx"
+)
+
+// Storage is the persistence layer used by tasks and the api,
+// implemented for MySQL and PostgreSQL by sqlStorage with the corresponding dialect.
+type Storage interface {
+	// Connection.
+	Ping(ctx context.Context) error
+
+	// Blocks.
+	InsertBlock(maxIndex int, blocks []*block.Block, txBulk *tx.Bulk) error
+	GetBlock(index uint) (*block.Block, error)
+	GetBlockByHash(hash string) (*block.Block, error)
+	GetBlockTxIDs(index uint) ([]string, error)
+
+	// Chain reorganization.
+	GetBlockHash(index int) string
+	RollbackBlocks(height int) ([]*tx.Transaction, error)
+
+	// Transactions.
+	GetTxs(txPk uint, limit int, txType string) []*tx.Transaction
+	GetVinVout(txIDs []string) (map[string][]*tx.TransactionVin, map[string][]*tx.TransactionVout, error)
+	GetVins(txIDs []string) (map[string][]*tx.TransactionVin, error)
+	GetVouts(txIDs []string) (map[string][]*tx.TransactionVout, error)
+	RecordAddrAssetIDTx(records []tx.AddrAssetIDTx, txPK int64) error
+	ApplyVinsVoutBulk(txs []*tx.Transaction, vins map[string][]*tx.TransactionVin, vouts map[string][]*tx.TransactionVout) error
+	ApplyVinsVouts(t *tx.Transaction, vins []*tx.TransactionVin, vouts []*tx.TransactionVout) error
+	GetVout(txID string, n uint16) (*tx.TransactionVout, error)
+	GetHighestTxPk() uint
+	GetTx(txID string) (*tx.Transaction, error)
+	GetTxAttrs(txID string) ([]*tx.TransactionAttribute, error)
+	GetInvalidWitness(txID string) (string, error)
+
+	// Addresses.
+	GetAddrAssetInfo() []*addr.AssetInfo
+	GetAddress(address string) (*addr.Address, error)
+	GetAddrAssets(address string) ([]*addr.Asset, error)
+	GetAddrContract(address string) (*addr.Contract, error)
+	GetAddrTxs(address string, offset uint, limit uint) ([]*addr.Tx, error)
+	CountAddrTxs(address string) (uint64, error)
+
+	// Unspent outputs and GAS claims.
+	GetUnspentUTXOs(address string, assetID string) ([]*tx.UTXO, error)
+	GetClaimableUTXOs(address string) ([]*tx.UTXO, error)
+	GetUnclaimedGas(address string, end uint) (*gas.Unclaimed, error)
+	GetSysFeeAmount(height uint) (int64, error)
+	GetUTXOGasBackfillPk() (uint, uint)
+	GetUTXOsWithoutGas(afterPk, endPk uint, limit int) ([]*tx.UTXO, error)
+	BackfillUTXOGas(utxos []*tx.UTXO, nextPk uint) error
+
+	// Block fees.
+	GetBlockFee(index uint) (*tx.BlockFee, error)
+	GetBlockFeeBackfillIndex() uint
+	GetBlockFeeBackfillEnd() (uint, error)
+	GetBlockFees(start, end uint) ([]*tx.BlockFee, error)
+	BackfillBlockFees(fees []*tx.BlockFee, next uint) error
+
+	// Global assets.
+	GetAssetName(assetID string) (string, error)
+
+	// NEP5 assets.
+	GetInvocationTxs(startPk uint, limit uint) []*tx.Transaction
+	GetNep5AssetDecimals() map[string]uint8
+	GetTxScripts(txID string) ([]*tx.TransactionScripts, error)
+	InsertNep5Asset(trans *tx.Transaction, nep5 *nep5.Nep5, regInfo *nep5.RegInfo, addrAsset *addr.Asset, atHeight uint) error
+	UpdateNep5TotalSupplyAndAddrAsset(blockTime uint64, blockIndex uint, addr string, balance amount.Amount, assetID string, totalSupply amount.Amount) error
+	InsertNep5transaction(trans *tx.Transaction, appLogIdx int, assetID string, fromAddr string, fromBalance amount.Amount, toAddr string, toBalance amount.Amount, transferValue amount.Amount, totalSupply *amount.Amount) error
+	GetMaxNonEmptyScriptTxPk() uint
+	GetNep5TxRecords(pk uint, limit int) ([]*nep5.Transaction, error)
+	GetMaxNep5TxPk() uint
+	InsertNep5AddrTxRec(nep5TxRecs []*nep5.Transaction, lastPk uint) error
+	GetAddrNep5LastBlocks(address string) (map[string]uint, error)
+	GetAddrNep5Transfers(address string, sent bool, startTime, endTime uint64, limit uint) ([]*nep5.Transaction, error)
+	GetNep5BalanceAt(address, assetID string, height uint) (amount.Amount, error)
+
+	// nep5_migrate
+	HandleNEP5Migrate(newAssetAdmin, oldAssetID, newAssetID string, txPK uint, txID string) error
+
+	// Daily balances of global assets.
+	ApplyAddrAssetBalances(t *tx.Transaction, changes map[string]map[string]amount.Amount) error
+	GetAddrAssetBalance(address, assetID, date string) (amount.Amount, error)
+
+	// Counters.
+	GetLastHeight() int
+	GetLastTxPkCounter() uint
+	GetLastAssetTxPkCounter() uint
+	GetLastTxPkForNep5() (uint, int)
+	GetLastTxPkForAssetBalance() uint
+	GetNep5TxPkForAddrTx() uint
+	UpdateLastTxPk(txPk uint) error
+	UpdateLastTxPkForNep5(currentTxPk uint, applogIdx int) error
+}
+
+// Ping checks if the database is reachable.
+func Ping(ctx context.Context) error {
+	return storage.Ping(ctx)
+}
+
+// InsertBlock inserts raw block data into database.
+func InsertBlock(maxIndex int, blocks []*block.Block, txBulk *tx.Bulk) error {
+	return storage.InsertBlock(maxIndex, blocks, txBulk)
+}
+
+// GetBlock returns the stored block of the given index, nil if not exists.
+func GetBlock(index uint) (*block.Block, error) {
+	return storage.GetBlock(index)
+}
+
+// GetBlockByHash returns the stored block of the given hash, nil if not exists.
+func GetBlockByHash(hash string) (*block.Block, error) {
+	return storage.GetBlockByHash(hash)
+}
+
+// GetBlockTxIDs returns txids of all transactions in the given block.
+func GetBlockTxIDs(index uint) ([]string, error) {
+	return storage.GetBlockTxIDs(index)
+}
+
+// GetBlockHash returns hash of the stored block at the given index.
+func GetBlockHash(index int) string {
+	return storage.GetBlockHash(index)
+}
+
+// RollbackBlocks deletes all blocks above the given height with their transactions,
+// reverts utxo and address asset changes already applied by the tx task,
+// and returns the removed transactions.
+// NEP5 balances are not reverted here, they are refreshed from contract storage by later transfers.
+func RollbackBlocks(height int) ([]*tx.Transaction, error) {
+	return storage.RollbackBlocks(height)
+}
+
+// GetTxs returns transactions of given tx pk range.
+func GetTxs(txPk uint, limit int, txType string) []*tx.Transaction {
+	return storage.GetTxs(txPk, limit, txType)
+}
+
+// GetVinVout returns correspond vouts of vins.
+func GetVinVout(txIDs []string) (map[string][]*tx.TransactionVin, map[string][]*tx.TransactionVout, error) {
+	return storage.GetVinVout(txIDs)
+}
+
+// GetVins returns all vins of the given txID.
+func GetVins(txIDs []string) (map[string][]*tx.TransactionVin, error) {
+	return storage.GetVins(txIDs)
+}
+
+// GetVouts returns all vins of the given txID.
+func GetVouts(txIDs []string) (map[string][]*tx.TransactionVout, error) {
+	return storage.GetVouts(txIDs)
+}
+
+// RecordAddrAssetIDTx records {address, asset_id, txid}.
+func RecordAddrAssetIDTx(records []tx.AddrAssetIDTx, txPK int64) error {
+	return storage.RecordAddrAssetIDTx(records, txPK)
+}
+
+// ApplyVinsVoutBulk process transaction and update related db table info.
+func ApplyVinsVoutBulk(txs []*tx.Transaction, vins map[string][]*tx.TransactionVin, vouts map[string][]*tx.TransactionVout) error {
+	return storage.ApplyVinsVoutBulk(txs, vins, vouts)
+}
+
+// ApplyVinsVouts process transaction and update related db table info.
+func ApplyVinsVouts(t *tx.Transaction, vins []*tx.TransactionVin, vouts []*tx.TransactionVout) error {
+	return storage.ApplyVinsVouts(t, vins, vouts)
+}
+
+// GetVout returns vouts of a transaction.
+func GetVout(txID string, n uint16) (*tx.TransactionVout, error) {
+	return storage.GetVout(txID, n)
+}
+
+// GetHighestTxPk returns maximum pk of tx.
+func GetHighestTxPk() uint {
+	return storage.GetHighestTxPk()
+}
+
+// GetTx returns the transaction of the given txid, nil if not exists.
+func GetTx(txID string) (*tx.Transaction, error) {
+	return storage.GetTx(txID)
+}
+
+// GetTxAttrs returns attributes of the given transaction.
+func GetTxAttrs(txID string) ([]*tx.TransactionAttribute, error) {
+	return storage.GetTxAttrs(txID)
+}
+
+// GetInvalidWitness returns the reason why a witness of the transaction does not verify,
+// empty if it is not flagged.
+func GetInvalidWitness(txID string) (string, error) {
+	return storage.GetInvalidWitness(txID)
+}
+
+// GetAddrAssetInfo returns all addresses with it's assets.
+func GetAddrAssetInfo() []*addr.AssetInfo {
+	return storage.GetAddrAssetInfo()
+}
+
+// GetAddress returns address info, nil if not exists.
+func GetAddress(address string) (*addr.Address, error) {
+	return storage.GetAddress(address)
+}
+
+// GetAddrContract returns the verification contract of the address, nil if it never witnessed a transaction.
+func GetAddrContract(address string) (*addr.Contract, error) {
+	return storage.GetAddrContract(address)
+}
+
+// GetAddrAssets returns all global asset and nep5 balances of the given address.
+func GetAddrAssets(address string) ([]*addr.Asset, error) {
+	return storage.GetAddrAssets(address)
+}
+
+// GetAddrTxs returns paged transactions of the given address, latest first.
+func GetAddrTxs(address string, offset uint, limit uint) ([]*addr.Tx, error) {
+	return storage.GetAddrTxs(address, offset, limit)
+}
+
+// CountAddrTxs returns the number of transactions of the given address.
+func CountAddrTxs(address string) (uint64, error) {
+	return storage.CountAddrTxs(address)
+}
+
+// GetUnspentUTXOs returns unspent outputs of the given address, of all assets if assetID is empty.
+func GetUnspentUTXOs(address string, assetID string) ([]*tx.UTXO, error) {
+	return storage.GetUnspentUTXOs(address, assetID)
+}
+
+// GetClaimableUTXOs returns spent NEO outputs of the given address whose GAS has not been claimed yet.
+func GetClaimableUTXOs(address string) ([]*tx.UTXO, error) {
+	return storage.GetClaimableUTXOs(address)
+}
+
+// GetUnclaimedGas returns the claimable GAS of spent NEO of the given address
+// and the GAS generated by its unspent NEO till block `end` (exclusive).
+// GAS of NEO outputs stored before schema version 5 is calculated from block fees until it is backfilled.
+func GetUnclaimedGas(address string, end uint) (*gas.Unclaimed, error) {
+	return storage.GetUnclaimedGas(address, end)
+}
+
+// GetSysFeeAmount returns the accumulated system fee (in whole GAS) of all blocks till the given height.
+func GetSysFeeAmount(height uint) (int64, error) {
+	return storage.GetSysFeeAmount(height)
+}
+
+// GetUTXOGasBackfillPk returns the last utxo pk whose GAS bonus is backfilled
+// and the last pk of outputs stored without it.
+func GetUTXOGasBackfillPk() (uint, uint) {
+	return storage.GetUTXOGasBackfillPk()
+}
+
+// GetUTXOsWithoutGas returns at most limit NEO outputs after afterPk till endPk whose GAS bonus is not recorded.
+func GetUTXOsWithoutGas(afterPk, endPk uint, limit int) ([]*tx.UTXO, error) {
+	return storage.GetUTXOsWithoutGas(afterPk, endPk, limit)
+}
+
+// BackfillUTXOGas records the GAS bonus of the outputs and moves the backfill pk to nextPk.
+func BackfillUTXOGas(utxos []*tx.UTXO, nextPk uint) error {
+	return storage.BackfillUTXOGas(utxos, nextPk)
+}
+
+// GetBlockFee returns fees of the given block, nil if not recorded.
+func GetBlockFee(index uint) (*tx.BlockFee, error) {
+	return storage.GetBlockFee(index)
+}
+
+// GetBlockFeeBackfillIndex returns the next block whose fee is backfilled.
+func GetBlockFeeBackfillIndex() uint {
+	return storage.GetBlockFeeBackfillIndex()
+}
+
+// GetBlockFeeBackfillEnd returns the block where backfilling fees stops,
+// which is the lowest block with fee recorded at storage at or above the backfill index.
+func GetBlockFeeBackfillEnd() (uint, error) {
+	return storage.GetBlockFeeBackfillEnd()
+}
+
+// GetBlockFees sums fees of stored transactions of blocks from start (inclusive) to end (exclusive).
+func GetBlockFees(start, end uint) ([]*tx.BlockFee, error) {
+	return storage.GetBlockFees(start, end)
+}
+
+// BackfillBlockFees records fees of consecutive blocks and moves the backfill index to next.
+func BackfillBlockFees(fees []*tx.BlockFee, next uint) error {
+	return storage.BackfillBlockFees(fees, next)
+}
+
+// GetAssetName returns name of the given global asset, empty if not exists.
+func GetAssetName(assetID string) (string, error) {
+	return storage.GetAssetName(assetID)
+}
+
+// GetInvocationTxs returns invocation transactions.
+func GetInvocationTxs(startPk uint, limit uint) []*tx.Transaction {
+	return storage.GetInvocationTxs(startPk, limit)
+}
+
+// GetNep5AssetDecimals returns all nep5 asset_id with decimal.
+func GetNep5AssetDecimals() map[string]uint8 {
+	return storage.GetNep5AssetDecimals()
+}
+
+// GetTxScripts returns script string of transaction.
+func GetTxScripts(txID string) ([]*tx.TransactionScripts, error) {
+	return storage.GetTxScripts(txID)
+}
+
+// InsertNep5Asset inserts new nep5 asset into db.
+func InsertNep5Asset(trans *tx.Transaction, nep5 *nep5.Nep5, regInfo *nep5.RegInfo, addrAsset *addr.Asset, atHeight uint) error {
+	return storage.InsertNep5Asset(trans, nep5, regInfo, addrAsset, atHeight)
+}
+
+// UpdateNep5TotalSupplyAndAddrAsset updates nep5 total supply and admin balance.
+func UpdateNep5TotalSupplyAndAddrAsset(blockTime uint64, blockIndex uint, addr string, balance amount.Amount, assetID string, totalSupply amount.Amount) error {
+	return storage.UpdateNep5TotalSupplyAndAddrAsset(blockTime, blockIndex, addr, balance, assetID, totalSupply)
+}
+
+// InsertNep5transaction inserts new nep5 transaction into db.
+func InsertNep5transaction(trans *tx.Transaction, appLogIdx int, assetID string, fromAddr string, fromBalance amount.Amount, toAddr string, toBalance amount.Amount, transferValue amount.Amount, totalSupply *amount.Amount) error {
+	return storage.InsertNep5transaction(trans, appLogIdx, assetID, fromAddr, fromBalance, toAddr, toBalance, transferValue, totalSupply)
+}
+
+// GetMaxNonEmptyScriptTxPk returns largest pk of invocation transaction.
+func GetMaxNonEmptyScriptTxPk() uint {
+	return storage.GetMaxNonEmptyScriptTxPk()
+}
+
+// GetNep5TxRecords returns paged nep5 transactions from db.
+func GetNep5TxRecords(pk uint, limit int) ([]*nep5.Transaction, error) {
+	return storage.GetNep5TxRecords(pk, limit)
+}
+
+// GetMaxNep5TxPk returns largest pk of nep5 transaction records.
+func GetMaxNep5TxPk() uint {
+	return storage.GetMaxNep5TxPk()
+}
+
+// InsertNep5AddrTxRec inserts addr_tx record of nep5 transactions.
+func InsertNep5AddrTxRec(nep5TxRecs []*nep5.Transaction, lastPk uint) error {
+	return storage.InsertNep5AddrTxRec(nep5TxRecs, lastPk)
+}
+
+// GetAddrNep5LastBlocks returns the latest block index of nep5 transfers of the given address, grouped by asset_id.
+func GetAddrNep5LastBlocks(address string) (map[string]uint, error) {
+	return storage.GetAddrNep5LastBlocks(address)
+}
+
+// GetAddrNep5Transfers returns nep5 transfers sent (or received) by the given address within the time range.
+func GetAddrNep5Transfers(address string, sent bool, startTime, endTime uint64, limit uint) ([]*nep5.Transaction, error) {
+	return storage.GetAddrNep5Transfers(address, sent, startTime, endTime, limit)
+}
+
+// GetNep5BalanceAt returns the balance of the address in the nep5 asset after the block of the given height,
+// ErrNep5HistoryUnavailable is returned for heights before the history was recorded.
+func GetNep5BalanceAt(address, assetID string, height uint) (amount.Amount, error) {
+	return storage.GetNep5BalanceAt(address, assetID, height)
+}
+
+// HandleNEP5Migrate handles nep5 contract migration.
+func HandleNEP5Migrate(newAssetAdmin, oldAssetID, newAssetID string, txPK uint, txID string) error {
+	return storage.HandleNEP5Migrate(newAssetAdmin, oldAssetID, newAssetID, txPK, txID)
+}
+
+// ApplyAddrAssetBalances adds balance changes of addresses by asset made by the transaction
+// to daily balances of the UTC date of its block.
+func ApplyAddrAssetBalances(t *tx.Transaction, changes map[string]map[string]amount.Amount) error {
+	return storage.ApplyAddrAssetBalances(t, changes)
+}
+
+// GetAddrAssetBalance returns the balance of the address in the asset at the end of the given UTC date,
+// formatted as 2006-01-02, zero if nothing is recorded till then. Nep5 assets are given by their script hash.
+func GetAddrAssetBalance(address, assetID, date string) (amount.Amount, error) {
+	return storage.GetAddrAssetBalance(address, assetID, date)
+}
+
+// GetLastHeight returns the highest block index stored in database.
+func GetLastHeight() int {
+	return storage.GetLastHeight()
+}
+
+// GetLastTxPkCounter returns the last resolved pk of transaction in counter.
+func GetLastTxPkCounter() uint {
+	return storage.GetLastTxPkCounter()
+}
+
+// GetLastAssetTxPkCounter returns the last resolved pk of asset transaction in counter.
+func GetLastAssetTxPkCounter() uint {
+	return storage.GetLastAssetTxPkCounter()
+}
+
+// GetLastTxPkForNep5 returns counter info of last processed nep5 transactions.
+func GetLastTxPkForNep5() (uint, int) {
+	return storage.GetLastTxPkForNep5()
+}
+
+// GetLastTxPkForAssetBalance returns the last resolved pk of the balance task.
+func GetLastTxPkForAssetBalance() uint {
+	return storage.GetLastTxPkForAssetBalance()
+}
+
+// GetNep5TxPkForAddrTx returns last pk of handled nep5 tx records.
+func GetNep5TxPkForAddrTx() uint {
+	return storage.GetNep5TxPkForAddrTx()
+}
+
+// UpdateLastTxPk updates last pk of processed transaction.
+func UpdateLastTxPk(txPk uint) error {
+	return storage.UpdateLastTxPk(txPk)
+}
+
+// UpdateLastTxPkForNep5 updates counter info of last processed nep5 transactions.
+func UpdateLastTxPkForNep5(currentTxPk uint, applogIdx int) error {
+	return storage.UpdateLastTxPkForNep5(currentTxPk, applogIdx)
+}
@@ -2,27 +2,27 @@ package db
 
 import (
 	"database/sql"
-	"fmt"
-	"math/big"
 	"sort"
+	"squirrel/amount"
 	"squirrel/asset"
 	"squirrel/cache"
+	"squirrel/gas"
 	"squirrel/tx"
-	"squirrel/util"
-	"strings"
 )
 
-// GetTxs returns transactions of given tx pk range.
-func GetTxs(txPk uint, limit int, txType string) []*tx.Transaction {
+func (s *sqlStorage) GetTxs(txPk uint, limit int, txType string) []*tx.Transaction {
 	txSQL := "SELECT `id`, `block_index`, `block_time`, `txid`, `size`, `type`, `version`, `sys_fee`, `net_fee`, `nonce`, `script`, `gas` FROM `tx` WHERE `id` >= ?"
+	args := []interface{}{txPk}
 
 	if txType != "" {
-		txSQL += fmt.Sprintf(" AND `type` = %s", txType)
+		txSQL += " AND `type` = ?"
+		args = append(args, txType)
 	}
 
 	txSQL += " AND (EXISTS(SELECT `id` FROM `tx_vin` WHERE `from`=`tx`.`txid` LIMIT 1) OR EXISTS (SELECT `id` FROM `tx_vout` WHERE `txid`=`tx`.`txid` LIMIT 1)) ORDER BY ID ASC LIMIT ?"
+	args = append(args, limit)
 
-	rows, err := wrappedQuery(txSQL, txPk, limit)
+	rows, err := s.query(txSQL, args...)
 	if err != nil {
 		panic(err)
 	}
@@ -32,9 +32,6 @@ func GetTxs(txPk uint, limit int, txType string) []*tx.Transaction {
 
 	for rows.Next() {
 		var t tx.Transaction
-		sysFeeStr := ""
-		netFeeStr := ""
-		gasStr := ""
 
 		err := rows.Scan(
 			&t.ID,
@@ -44,35 +41,30 @@ func GetTxs(txPk uint, limit int, txType string) []*tx.Transaction {
 			&t.Size,
 			&t.Type,
 			&t.Version,
-			&sysFeeStr,
-			&netFeeStr,
+			&t.SysFee,
+			&t.NetFee,
 			&t.Nonce,
 			&t.Script,
-			&gasStr,
+			&t.Gas,
 		)
 
 		if err != nil {
 			panic(err)
 		}
 
-		t.SysFee = util.StrToBigFloat(sysFeeStr)
-		t.NetFee = util.StrToBigFloat(netFeeStr)
-		t.Gas = util.StrToBigFloat(gasStr)
-
 		result = append(result, &t)
 	}
 
 	return result
 }
 
-// GetVinVout returns correspond vouts of vins.
-func GetVinVout(txIDs []string) (map[string][]*tx.TransactionVin, map[string][]*tx.TransactionVout, error) {
-	vinMap, err := GetVins(txIDs)
+func (s *sqlStorage) GetVinVout(txIDs []string) (map[string][]*tx.TransactionVin, map[string][]*tx.TransactionVout, error) {
+	vinMap, err := s.GetVins(txIDs)
 	if err != nil {
 		return nil, nil, err
 	}
 
-	voutMap, err := GetVouts(txIDs)
+	voutMap, err := s.GetVouts(txIDs)
 	if err != nil {
 		return nil, nil, err
 	}
@@ -80,20 +72,51 @@ func GetVinVout(txIDs []string) (map[string][]*tx.TransactionVin, map[string][]*
 	return vinMap, voutMap, nil
 }
 
-// GetVins returns all vins of the given txID.
-func GetVins(txIDs []string) (map[string][]*tx.TransactionVin, error) {
-	query := "SELECT `from`, `txid`, `vout` FROM `tx_vin` WHERE `from` IN ('"
-	query += strings.Join(txIDs, "', '")
-	query += "')"
+// getVinVout is GetVinVout reading through the transaction.
+func getVinVout(trans *txn, txIDs []string) (map[string][]*tx.TransactionVin, map[string][]*tx.TransactionVout, error) {
+	rows, err := trans.Query(vinsQuery(len(txIDs)), stringArgs(txIDs)...)
+	if err != nil {
+		return nil, nil, err
+	}
+	vinMap, err := scanVins(rows)
+	if err != nil {
+		return nil, nil, err
+	}
 
-	vinMap := make(map[string][]*tx.TransactionVin)
+	rows, err = trans.Query(voutsQuery(len(txIDs)), stringArgs(txIDs)...)
+	if err != nil {
+		return nil, nil, err
+	}
+	voutMap, err := scanVouts(rows)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	return vinMap, voutMap, nil
+}
+
+func vinsQuery(n int) string {
+	return "SELECT `from`, `txid`, `vout` FROM `tx_vin` WHERE `from` IN (" + placeholders(n) + ")"
+}
+
+func voutsQuery(n int) string {
+	return "SELECT `txid`, `n`, `asset_id`, `value`, `address` FROM `tx_vout` WHERE `txid` IN (" + placeholders(n) + ")"
+}
 
-	rows, err := wrappedQuery(query)
+func (s *sqlStorage) GetVins(txIDs []string) (map[string][]*tx.TransactionVin, error) {
+	rows, err := s.query(vinsQuery(len(txIDs)), stringArgs(txIDs)...)
 	if err != nil {
 		return nil, err
 	}
+
+	return scanVins(rows)
+}
+
+func scanVins(rows *sql.Rows) (map[string][]*tx.TransactionVin, error) {
 	defer rows.Close()
 
+	vinMap := make(map[string][]*tx.TransactionVin)
+
 	for rows.Next() {
 		vin := new(tx.TransactionVin)
 		err := rows.Scan(
@@ -109,46 +132,44 @@ func GetVins(txIDs []string) (map[string][]*tx.TransactionVin, error) {
 		vinMap[vin.From] = append(vinMap[vin.From], vin)
 	}
 
-	return vinMap, nil
+	return vinMap, rows.Err()
 }
 
-// GetVouts returns all vins of the given txID.
-func GetVouts(txIDs []string) (map[string][]*tx.TransactionVout, error) {
-	query := "SELECT `txid`, `n`, `asset_id`, `value`, `address` FROM `tx_vout` WHERE `txid` IN ('"
-	query += strings.Join(txIDs, "', '")
-	query += "')"
-
-	voutMap := make(map[string][]*tx.TransactionVout)
-
-	rows, err := wrappedQuery(query)
+func (s *sqlStorage) GetVouts(txIDs []string) (map[string][]*tx.TransactionVout, error) {
+	rows, err := s.query(voutsQuery(len(txIDs)), stringArgs(txIDs)...)
 	if err != nil {
 		return nil, err
 	}
+
+	return scanVouts(rows)
+}
+
+func scanVouts(rows *sql.Rows) (map[string][]*tx.TransactionVout, error) {
 	defer rows.Close()
 
+	voutMap := make(map[string][]*tx.TransactionVout)
+
 	for rows.Next() {
 		vout := new(tx.TransactionVout)
-		valueStr := ""
 		err := rows.Scan(
 			// &vout.ID,
 			&vout.TxID,
 			&vout.N,
 			&vout.AssetID,
-			&valueStr,
+			&vout.Value,
 			&vout.Address,
 		)
 		if err != nil {
 			panic(err)
 		}
 
-		vout.Value = util.StrToBigFloat(valueStr)
-
 		voutMap[vout.TxID] = append(voutMap[vout.TxID], vout)
 	}
-	return voutMap, nil
+
+	return voutMap, rows.Err()
 }
 
-func handleVins(blockIndex uint, tx *sql.Tx, vins []*tx.TransactionVin, cachedVinVouts *[]*tx.TransactionVout) error {
+func (s *sqlStorage) handleVins(blockIndex uint, accumulated int64, tx *txn, vins []*tx.TransactionVin, cachedVinVouts *[]*tx.TransactionVout) error {
 	for _, vin := range vins {
 		const disableUTXOSQL = "UPDATE `utxo` SET `used_in_tx` = ? WHERE `txid` = ? AND `n` = ? LIMIT 1"
 		_, err := tx.Exec(disableUTXOSQL, vin.From, vin.TxID, vin.Vout)
@@ -156,19 +177,25 @@ func handleVins(blockIndex uint, tx *sql.Tx, vins []*tx.TransactionVin, cachedVi
 			return err
 		}
 
-		vinVout, err := GetVout(vin.TxID, vin.Vout)
+		vinVout, err := getVout(tx, vin.TxID, vin.Vout)
 		if err != nil {
 			return err
 		}
 		*cachedVinVouts = append(*cachedVinVouts, vinVout)
 
+		if vinVout.AssetID == asset.NEOAssetID {
+			if err := recordClaimGas(tx, vinVout, accumulated); err != nil {
+				return err
+			}
+		}
+
 		// 'last_transaction_time' will be updated later.
 		if addrAssetCache, ok := cache.GetAddrAsset(vinVout.Address, vinVout.AssetID); ok {
 			// This subtraction will always be executed.
 			addrAssetCache.SubtractBalance(vinVout.Value, blockIndex)
 		}
-		reduceAddrAssetSQL := fmt.Sprintf("UPDATE `addr_asset` SET `balance` = `balance` - %.8f WHERE `address` = '%s' AND `asset_id` = '%s' LIMIT 1", vinVout.Value, vinVout.Address, vinVout.AssetID)
-		_, err = tx.Exec(reduceAddrAssetSQL)
+		const reduceAddrAssetSQL = "UPDATE `addr_asset` SET `balance` = `balance` - ? WHERE `address` = ? AND `asset_id` = ? LIMIT 1"
+		_, err = tx.Exec(reduceAddrAssetSQL, decimalArg(vinVout.Value), vinVout.Address, vinVout.AssetID)
 		if err != nil {
 			return err
 		}
@@ -177,10 +204,41 @@ func handleVins(blockIndex uint, tx *sql.Tx, vins []*tx.TransactionVin, cachedVi
 	return nil
 }
 
-func handleVouts(blockIndex uint, blockTime uint64, tx *sql.Tx, vouts []*tx.TransactionVout) error {
+// recordClaimGas records the GAS bonus of a spent NEO output, which is its share of GAS accumulated
+// when it is spent minus its share at the start. It is left null for outputs stored without the start share,
+// which are filled by the utxo_gas task.
+func recordClaimGas(tx *txn, vout *tx.TransactionVout, accumulated int64) error {
+	var startGas interface{}
+	const query = "SELECT `start_gas` FROM `utxo` WHERE `txid` = ? AND `n` = ? LIMIT 1"
+	err := tx.QueryRow(query, vout.TxID, vout.N).Scan(&startGas)
+	if err != nil && err != sql.ErrNoRows {
+		return err
+	}
+	if startGas == nil {
+		return nil
+	}
+
+	var start amount.Amount
+	if err := start.Scan(startGas); err != nil {
+		return err
+	}
+
+	claimGas := gas.Share(vout.Value, accumulated).Sub(start)
+	const updateQuery = "UPDATE `utxo` SET `claim_gas` = ? WHERE `txid` = ? AND `n` = ? LIMIT 1"
+	_, err = tx.Exec(updateQuery, decimalArg(claimGas), vout.TxID, vout.N)
+	return err
+}
+
+func handleVouts(blockIndex uint, blockTime uint64, accumulated int64, tx *txn, vouts []*tx.TransactionVout) error {
 	for _, vout := range vouts {
-		insertUTXOQuery := fmt.Sprintf("INSERT INTO `utxo` (`address`, `txid`, `n`, `asset_id`, `value`, `used_in_tx`) VALUES ('%s', '%s', %d, '%s', %.8f, null)", vout.Address, vout.TxID, vout.N, vout.AssetID, vout.Value)
-		if _, err := tx.Exec(insertUTXOQuery); err != nil {
+		// The share of accumulated GAS is only kept for NEO, which generates GAS.
+		var startGas interface{}
+		if vout.AssetID == asset.NEOAssetID {
+			startGas = decimalArg(gas.Share(vout.Value, accumulated))
+		}
+
+		const insertUTXOQuery = "INSERT INTO `utxo` (`address`, `txid`, `n`, `asset_id`, `value`, `used_in_tx`, `start_gas`) VALUES (?, ?, ?, ?, ?, null, ?)"
+		if _, err := tx.Exec(insertUTXOQuery, vout.Address, vout.TxID, vout.N, vout.AssetID, decimalArg(vout.Value), startGas); err != nil {
 			return err
 		}
 
@@ -189,20 +247,20 @@ func handleVouts(blockIndex uint, blockTime uint64, tx *sql.Tx, vouts []*tx.Tran
 
 		if created {
 			// Transactions counter and last transaction time will be updated later, currently set its initial value to 0.
-			insertAddrAssetQuery := fmt.Sprintf("INSERT INTO `addr_asset` (`address`, `asset_id`, `balance`, `transactions`, `last_transaction_time`) VALUES ('%s', '%s', %.8f, %d, %d)", vout.Address, vout.AssetID, vout.Value, 0, 0)
-			if _, err := tx.Exec(insertAddrAssetQuery); err != nil {
+			const insertAddrAssetQuery = "INSERT INTO `addr_asset` (`address`, `asset_id`, `balance`, `transactions`, `last_transaction_time`) VALUES (?, ?, ?, ?, ?)"
+			if _, err := tx.Exec(insertAddrAssetQuery, vout.Address, vout.AssetID, decimalArg(vout.Value), 0, 0); err != nil {
 				return err
 			}
 			// Increase asset addresses count.
-			incrAssetAddrCount := fmt.Sprintf("UPDATE `asset` SET `addresses` = `addresses` + 1 WHERE `asset_id` = '%s' LIMIT 1", vout.AssetID)
-			if _, err := tx.Exec(incrAssetAddrCount); err != nil {
+			const incrAssetAddrCount = "UPDATE `asset` SET `addresses` = `addresses` + 1 WHERE `asset_id` = ? LIMIT 1"
+			if _, err := tx.Exec(incrAssetAddrCount, vout.AssetID); err != nil {
 				return err
 			}
 		} else {
 			addrAssetCache.AddBalance(vout.Value, blockIndex)
 			// 'last_transaction_time' will be updated later.
-			incrAddrAsset := fmt.Sprintf("UPDATE `addr_asset` SET `balance` = `balance` + %.8f WHERE `address` = '%s' AND `asset_id` = '%s' LIMIT 1", vout.Value, vout.Address, vout.AssetID)
-			if _, err := tx.Exec(incrAddrAsset); err != nil {
+			const incrAddrAsset = "UPDATE `addr_asset` SET `balance` = `balance` + ? WHERE `address` = ? AND `asset_id` = ? LIMIT 1"
+			if _, err := tx.Exec(incrAddrAsset, decimalArg(vout.Value), vout.Address, vout.AssetID); err != nil {
 				return err
 			}
 		}
@@ -211,33 +269,19 @@ func handleVouts(blockIndex uint, blockTime uint64, tx *sql.Tx, vouts []*tx.Tran
 	return nil
 }
 
-// RecordAddrAssetIDTx records {address, asset_id, txid}.
-func RecordAddrAssetIDTx(records []tx.AddrAssetIDTx, txPK int64) error {
+func (s *sqlStorage) RecordAddrAssetIDTx(records []tx.AddrAssetIDTx, txPK int64) error {
 	if len(records) == 0 {
 		return nil
 	}
 
-	return transact(func(trans *sql.Tx) error {
-		piece := 100
-
-		for start := 0; start < len(records); start += piece {
-			query := "INSERT INTO `asset_tx` (`address`, `asset_id`, `txid`) VALUES "
-			for i := start; i < start+piece; i++ {
-				if i >= len(records) {
-					break
-				}
-				query += fmt.Sprintf("('%s', '%s', '%s'), ", records[i].Address, records[i].AssetID, records[i].TxID)
-			}
-
-			if !strings.HasSuffix(query, ", ") {
-				break
-			}
+	return s.transact(func(trans *txn) error {
+		cmd := newBulkInsert("asset_tx", "address", "asset_id", "txid")
+		for _, record := range records {
+			cmd.addRow(record.Address, record.AssetID, record.TxID)
+		}
 
-			query = query[:len(query)-2]
-			_, err := trans.Exec(query)
-			if err != nil {
-				return err
-			}
+		if err := cmd.exec(trans); err != nil {
+			return err
 		}
 
 		err := updateCounter(trans, "last_asset_tx_pk", txPK)
@@ -249,16 +293,15 @@ func RecordAddrAssetIDTx(records []tx.AddrAssetIDTx, txPK int64) error {
 	})
 }
 
-// ApplyVinsVoutBulk process transaction and update related db table info.
-func ApplyVinsVoutBulk(txs []*tx.Transaction, vins map[string][]*tx.TransactionVin, vouts map[string][]*tx.TransactionVout) error {
-	trans, err := db.Begin()
+func (s *sqlStorage) ApplyVinsVoutBulk(txs []*tx.Transaction, vins map[string][]*tx.TransactionVin, vouts map[string][]*tx.TransactionVout) error {
+	trans, err := s.begin()
 	if err != nil {
-		if !connErr(err) {
+		if !s.connErr(err) {
 			return err
 		}
 
-		reconnect()
-		return ApplyVinsVoutBulk(txs, vins, vouts)
+		s.reconnect()
+		return s.ApplyVinsVoutBulk(txs, vins, vouts)
 	}
 
 	defer func() {
@@ -272,7 +315,7 @@ func ApplyVinsVoutBulk(txs []*tx.Transaction, vins map[string][]*tx.TransactionV
 	}()
 
 	for _, t := range txs {
-		err = applyVinsVouts(trans, t, vins[t.TxID], vouts[t.TxID])
+		err = s.applyVinsVouts(trans, t, vins[t.TxID], vouts[t.TxID])
 		if err != nil {
 			return err
 		}
@@ -281,10 +324,16 @@ func ApplyVinsVoutBulk(txs []*tx.Transaction, vins map[string][]*tx.TransactionV
 	return nil
 }
 
-func applyVinsVouts(trans *sql.Tx, t *tx.Transaction, vins []*tx.TransactionVin, vouts []*tx.TransactionVout) error {
+func (s *sqlStorage) applyVinsVouts(trans *txn, t *tx.Transaction, vins []*tx.TransactionVin, vouts []*tx.TransactionVout) error {
 	cachedVinVouts := []*tx.TransactionVout{}
 
-	if err := handleVins(t.BlockIndex, trans, vins, &cachedVinVouts); err != nil {
+	// GAS shared by NEO holders till this block, the bonus of NEO outputs is kept as the difference of its shares.
+	accumulated, err := gas.Accumulated(t.BlockIndex, s.txSysFee.amountFunc(s))
+	if err != nil {
+		return err
+	}
+
+	if err := s.handleVins(t.BlockIndex, accumulated, trans, vins, &cachedVinVouts); err != nil {
 		return err
 	}
 
@@ -305,7 +354,7 @@ func applyVinsVouts(trans *sql.Tx, t *tx.Transaction, vins []*tx.TransactionVin,
 		}
 	}
 
-	if err := handleVouts(t.BlockIndex, t.BlockTime, trans, vouts); err != nil {
+	if err := handleVouts(t.BlockIndex, t.BlockTime, accumulated, trans, vouts); err != nil {
 		return err
 	}
 
@@ -324,7 +373,7 @@ func applyVinsVouts(trans *sql.Tx, t *tx.Transaction, vins []*tx.TransactionVin,
 		return err
 	}
 
-	err := updateCounter(trans, "last_tx_pk", int64(t.ID))
+	err = updateCounter(trans, "last_tx_pk", int64(t.ID))
 	if err != nil {
 		return err
 	}
@@ -332,92 +381,44 @@ func applyVinsVouts(trans *sql.Tx, t *tx.Transaction, vins []*tx.TransactionVin,
 	return nil
 }
 
-// ApplyVinsVouts process transaction and update related db table info.
-func ApplyVinsVouts(t *tx.Transaction, vins []*tx.TransactionVin, vouts []*tx.TransactionVout) error {
-	return transact(func(trans *sql.Tx) error {
-		cachedVinVouts := []*tx.TransactionVout{}
-
-		if err := handleVins(t.BlockIndex, trans, vins, &cachedVinVouts); err != nil {
-			return err
-		}
-
-		assetIDs, addrAssetPair := countTxInfo(cachedVinVouts, vouts)
-
-		// Sort keys of addrAssetPair to avoid potential deadlock.
-		var addrs []string
-		for k := range addrAssetPair {
-			addrs = append(addrs, k)
-		}
-		// Sort address to avoid potential deadlock.
-		sort.Strings(addrs)
-
-		for _, addr := range addrs {
-			// Update address table.
-			if err := updateAddrInfo(trans, t.BlockTime, t.TxID, addr, asset.ASSET); err != nil {
-				return err
-			}
-		}
-
-		if err := handleVouts(t.BlockIndex, t.BlockTime, trans, vouts); err != nil {
-			return err
-		}
-
-		if t.Type == "ClaimTransaction" {
-			if err := handleClaimTx(trans, vouts); err != nil {
-				return err
-			}
-		}
-		if t.Type == "IssueTransaction" {
-			if err := handleIssueTx(trans, vouts); err != nil {
-				return err
-			}
-		}
-
-		if err := updateTxInfo(trans, t.BlockTime, t.TxID, addrs, assetIDs, addrAssetPair); err != nil {
-			return err
-		}
-
-		err := updateCounter(trans, "last_tx_pk", int64(t.ID))
-		if err != nil {
-			return err
-		}
-
-		return nil
+func (s *sqlStorage) ApplyVinsVouts(t *tx.Transaction, vins []*tx.TransactionVin, vouts []*tx.TransactionVout) error {
+	return s.transact(func(trans *txn) error {
+		return s.applyVinsVouts(trans, t, vins, vouts)
 	})
 }
 
-func handleClaimTx(tx *sql.Tx, vouts []*tx.TransactionVout) error {
-	gas := big.NewFloat(0)
+func handleClaimTx(tx *txn, vouts []*tx.TransactionVout) error {
+	gas := amount.Zero
 
 	for _, vout := range vouts {
 		if vout.AssetID == asset.GASAssetID {
-			gas = new(big.Float).Add(gas, vout.Value)
+			gas = gas.Add(vout.Value)
 		}
 	}
 
-	query := fmt.Sprintf("UPDATE `asset` SET `available` = `available` + %.8f WHERE `asset_id` = '%s' LIMIT 1", gas, asset.GASAssetID)
-	if _, err := tx.Exec(query); err != nil {
+	const query = "UPDATE `asset` SET `available` = `available` + ? WHERE `asset_id` = ? LIMIT 1"
+	if _, err := tx.Exec(query, decimalArg(gas), asset.GASAssetID); err != nil {
 		return err
 	}
 
 	return nil
 }
 
-func handleIssueTx(tx *sql.Tx, vouts []*tx.TransactionVout) error {
-	issued := make(map[string]*big.Float)
+func handleIssueTx(tx *txn, vouts []*tx.TransactionVout) error {
+	issued := make(map[string]amount.Amount)
 
 	for _, vout := range vouts {
 		if vout.AssetID != asset.GASAssetID {
 			if _, ok := issued[vout.AssetID]; !ok {
 				issued[vout.AssetID] = vout.Value
 			} else {
-				issued[vout.AssetID] = new(big.Float).Add(issued[vout.AssetID], vout.Value)
+				issued[vout.AssetID] = issued[vout.AssetID].Add(vout.Value)
 			}
 		}
 	}
 	for assetID, increment := range issued {
-		query := fmt.Sprintf("UPDATE `asset` SET `available` = `available` + %.8f WHERE `asset_id` = '%s' LIMIT 1", increment, assetID)
-		if _, err := tx.Exec(query); err != nil {
+		const query = "UPDATE `asset` SET `available` = `available` + ? WHERE `asset_id` = ? LIMIT 1"
+		if _, err := tx.Exec(query, decimalArg(increment), assetID); err != nil {
 			return err
 		}
 	}
@@ -448,7 +449,7 @@ func countTxInfo(cachedVinVouts []*tx.TransactionVout, vouts []*tx.TransactionVo
 	return assetIDs, addrAssetPair
 }
 
-func updateTxInfo(tx *sql.Tx, blockTime uint64, txID string, addrs []string, assetIDs map[string]bool, addrAssetPair map[string]map[string]bool) error {
+func updateTxInfo(tx *txn, blockTime uint64, txID string, addrs []string, assetIDs map[string]bool, addrAssetPair map[string]map[string]bool) error {
 	for _, addr := range addrs {
 		// Add new AddrTx record.
 		const insertAddrTx = "INSERT INTO `addr_tx` (`txid`, `address`, `block_time`, `asset_type`) VALUES (?, ?, ?, ?)"
@@ -475,17 +476,25 @@ func updateTxInfo(tx *sql.Tx, blockTime uint64, txID string, addrs []string, ass
 	return nil
 }
 
-// GetVout returns vouts of a transaction.
-func GetVout(txID string, n uint16) (*tx.TransactionVout, error) {
+const voutQuery = "SELECT `txid`, `n`, `asset_id`, `value`, `address` FROM `tx_vout` WHERE `txid` = ? AND `n` = ?"
+
+func (s *sqlStorage) GetVout(txID string, n uint16) (*tx.TransactionVout, error) {
+	return scanVout(s.queryRow(voutQuery, txID, n))
+}
+
+// getVout is GetVout reading through the transaction, which sees its own changes.
+func getVout(trans *txn, txID string, n uint16) (*tx.TransactionVout, error) {
+	return scanVout(trans.QueryRow(voutQuery, txID, n))
+}
+
+func scanVout(row *sql.Row) (*tx.TransactionVout, error) {
 	vout := new(tx.TransactionVout)
-	valueStr := ""
-	const query = "SELECT `txid`, `n`, `asset_id`, `value`, `address` FROM `tx_vout` WHERE `txid` = ? AND `n` = ?"
-	err := db.QueryRow(query, txID, n).Scan(
+	err := row.Scan(
 		// &vout.ID,
 		&vout.TxID,
 		&vout.N,
 		&vout.AssetID,
-		&valueStr,
+		&vout.Value,
 		&vout.Address,
 	)
 	if err != nil && err != sql.ErrNoRows {
@@ -496,22 +505,88 @@ func GetVout(txID string, n uint16) (*tx.TransactionVout, error) {
 		return nil, nil
 	}
 
-	vout.Value = util.StrToBigFloat(valueStr)
 	return vout, nil
 }
 
-// GetHighestTxPk returns maximum pk of tx.
-func GetHighestTxPk() uint {
+func (s *sqlStorage) GetHighestTxPk() uint {
 	var pk uint
 	const query = "SELECT `id` FROM `tx` WHERE EXISTS (SELECT `id` FROM `tx_vin` WHERE `from`=`tx`.`txid` LIMIT 1) OR EXISTS (SELECT `id` FROM `tx_vout` WHERE `txid`=`tx`.`txid` LIMIT 1) ORDER BY `id` DESC LIMIT 1"
-	err := db.QueryRow(query).Scan(&pk)
+	err := s.queryRow(query).Scan(&pk)
 	if err != nil && err != sql.ErrNoRows {
-		if !connErr(err) {
+		if !s.connErr(err) {
 			panic(err)
 		}
-		reconnect()
-		return GetHighestTxPk()
+		s.reconnect()
+		return s.GetHighestTxPk()
 	}
 
 	return pk
 }
+
+func (s *sqlStorage) GetTx(txID string) (*tx.Transaction, error) {
+	const query = "SELECT `id`, `block_index`, `block_time`, `txid`, `size`, `type`, `version`, `sys_fee`, `net_fee`, `nonce`, `script`, `gas` FROM `tx` WHERE `txid` = ? LIMIT 1"
+	rows, err := s.query(query, txID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	if !rows.Next() {
+		return nil, rows.Err()
+	}
+
+	var t tx.Transaction
+
+	err = rows.Scan(
+		&t.ID,
+		&t.BlockIndex,
+		&t.BlockTime,
+		&t.TxID,
+		&t.Size,
+		&t.Type,
+		&t.Version,
+		&t.SysFee,
+		&t.NetFee,
+		&t.Nonce,
+		&t.Script,
+		&t.Gas,
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	return &t, nil
+}
+
+func (s *sqlStorage) GetInvalidWitness(txID string) (string, error) {
+	const query = "SELECT `reason` FROM `invalid_witness` WHERE `txid` = ? LIMIT 1"
+
+	var reason string
+	err := s.queryRow(query, txID).Scan(&reason)
+	if err == sql.ErrNoRows {
+		return "", nil
+	}
+
+	return reason, err
+}
+
+func (s *sqlStorage) GetTxAttrs(txID string) ([]*tx.TransactionAttribute, error) {
+	const query = "SELECT `id`, `txid`, `usage`, `data` FROM `tx_attr` WHERE `txid` = ? ORDER BY `id` ASC"
+	rows, err := s.query(query, txID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	attrs := []*tx.TransactionAttribute{}
+
+	for rows.Next() {
+		attr := new(tx.TransactionAttribute)
+		if err := rows.Scan(&attr.ID, &attr.TxID, &attr.Usage, &attr.Data); err != nil {
+			return nil, err
+		}
+		attrs = append(attrs, attr)
+	}
+
+	return attrs, rows.Err()
+}
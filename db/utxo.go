@@ -0,0 +1,337 @@
+package db
+
+import (
+	"database/sql"
+	"squirrel/amount"
+	"squirrel/asset"
+	"squirrel/gas"
+	"squirrel/tx"
+	"sync"
+)
+
+func (s *sqlStorage) GetUnspentUTXOs(address string, assetID string) ([]*tx.UTXO, error) {
+	query := "SELECT `utxo`.`id`, `utxo`.`address`, `utxo`.`txid`, `utxo`.`n`, `utxo`.`asset_id`, `utxo`.`value`, `tx`.`block_index` FROM `utxo` INNER JOIN `tx` ON `tx`.`txid` = `utxo`.`txid` WHERE `utxo`.`address` = ? AND `utxo`.`used_in_tx` IS NULL"
+	args := []interface{}{address}
+
+	if assetID != "" {
+		query += " AND `utxo`.`asset_id` = ?"
+		args = append(args, assetID)
+	}
+
+	query += " ORDER BY `utxo`.`id` ASC"
+
+	rows, err := s.query(query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	result := []*tx.UTXO{}
+
+	for rows.Next() {
+		u := new(tx.UTXO)
+
+		err := rows.Scan(
+			&u.ID,
+			&u.Address,
+			&u.TxID,
+			&u.N,
+			&u.AssetID,
+			&u.Value,
+			&u.BlockIndex,
+		)
+		if err != nil {
+			return nil, err
+		}
+
+		result = append(result, u)
+	}
+
+	return result, rows.Err()
+}
+
+func (s *sqlStorage) GetClaimableUTXOs(address string) ([]*tx.UTXO, error) {
+	const query = "SELECT `utxo`.`id`, `utxo`.`address`, `utxo`.`txid`, `utxo`.`n`, `utxo`.`asset_id`, `utxo`.`value`, `utxo`.`used_in_tx`, `start_tx`.`block_index`, `end_tx`.`block_index` " +
+		"FROM `utxo` " +
+		"INNER JOIN `tx` AS `start_tx` ON `start_tx`.`txid` = `utxo`.`txid` " +
+		"INNER JOIN `tx` AS `end_tx` ON `end_tx`.`txid` = `utxo`.`used_in_tx` " +
+		"LEFT JOIN `tx_claims` ON `tx_claims`.`txid` = `utxo`.`txid` AND `tx_claims`.`vout` = `utxo`.`n` " +
+		"WHERE `utxo`.`address` = ? AND `utxo`.`asset_id` = ? AND `tx_claims`.`id` IS NULL " +
+		"ORDER BY `utxo`.`id` ASC"
+
+	rows, err := s.query(query, address, asset.NEOAssetID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	result := []*tx.UTXO{}
+
+	for rows.Next() {
+		u := new(tx.UTXO)
+		var usedInTx sql.NullString
+
+		err := rows.Scan(
+			&u.ID,
+			&u.Address,
+			&u.TxID,
+			&u.N,
+			&u.AssetID,
+			&u.Value,
+			&usedInTx,
+			&u.BlockIndex,
+			&u.SpentBlockIndex,
+		)
+		if err != nil {
+			return nil, err
+		}
+
+		u.UsedInTx = usedInTx.String
+		result = append(result, u)
+	}
+
+	return result, rows.Err()
+}
+
+func (s *sqlStorage) GetUnclaimedGas(address string, end uint) (*gas.Unclaimed, error) {
+	const availableQuery = "SELECT COALESCE(SUM(`utxo`.`claim_gas`), 0) " +
+		"FROM `utxo` " +
+		"LEFT JOIN `tx_claims` ON `tx_claims`.`txid` = `utxo`.`txid` AND `tx_claims`.`vout` = `utxo`.`n` " +
+		"WHERE `utxo`.`address` = ? AND `utxo`.`asset_id` = ? AND `utxo`.`used_in_tx` IS NOT NULL AND `tx_claims`.`id` IS NULL"
+
+	var available amount.Amount
+	if err := s.queryRow(availableQuery, address, asset.NEOAssetID).Scan(&available); err != nil {
+		return nil, err
+	}
+
+	const unspentQuery = "SELECT COALESCE(SUM(`value`), 0), COALESCE(SUM(`start_gas`), 0) FROM `utxo` WHERE `address` = ? AND `asset_id` = ? AND `used_in_tx` IS NULL AND `start_gas` IS NOT NULL"
+
+	var neo, startGas amount.Amount
+	if err := s.queryRow(unspentQuery, address, asset.NEOAssetID).Scan(&neo, &startGas); err != nil {
+		return nil, err
+	}
+
+	accumulated, err := gas.Accumulated(end, s.GetSysFeeAmount)
+	if err != nil {
+		return nil, err
+	}
+
+	// Outputs stored before schema version 5 are calculated one by one until they are backfilled.
+	const legacyAvailableQuery = "SELECT `utxo`.`value`, `start_tx`.`block_index`, `end_tx`.`block_index` " +
+		"FROM `utxo` " +
+		"INNER JOIN `tx` AS `start_tx` ON `start_tx`.`txid` = `utxo`.`txid` " +
+		"INNER JOIN `tx` AS `end_tx` ON `end_tx`.`txid` = `utxo`.`used_in_tx` " +
+		"LEFT JOIN `tx_claims` ON `tx_claims`.`txid` = `utxo`.`txid` AND `tx_claims`.`vout` = `utxo`.`n` " +
+		"WHERE `utxo`.`address` = ? AND `utxo`.`asset_id` = ? AND `utxo`.`claim_gas` IS NULL AND `tx_claims`.`id` IS NULL"
+	legacyAvailable, err := s.legacyBonus(end, legacyAvailableQuery, address, asset.NEOAssetID)
+	if err != nil {
+		return nil, err
+	}
+
+	const legacyUnspentQuery = "SELECT `utxo`.`value`, `tx`.`block_index`, NULL " +
+		"FROM `utxo` " +
+		"INNER JOIN `tx` ON `tx`.`txid` = `utxo`.`txid` " +
+		"WHERE `utxo`.`address` = ? AND `utxo`.`asset_id` = ? AND `utxo`.`used_in_tx` IS NULL AND `utxo`.`start_gas` IS NULL"
+	legacyUnavailable, err := s.legacyBonus(end, legacyUnspentQuery, address, asset.NEOAssetID)
+	if err != nil {
+		return nil, err
+	}
+
+	return &gas.Unclaimed{
+		Available:   available.Add(legacyAvailable).Rescale(8),
+		Unavailable: gas.Share(neo, accumulated).Sub(startGas).Add(legacyUnavailable).Rescale(8),
+	}, nil
+}
+
+// legacyBonus sums GAS bonus of NEO outputs without start_gas selected by query as value,
+// start block and spent block, unspent outputs are held till block `end` (exclusive).
+func (s *sqlStorage) legacyBonus(end uint, query string, args ...interface{}) (amount.Amount, error) {
+	rows, err := s.query(query, args...)
+	if err != nil {
+		return amount.Zero, err
+	}
+	defer rows.Close()
+
+	type output struct {
+		value amount.Amount
+		start uint
+		end   uint
+	}
+
+	// Rows are read before calculating, which queries block fees.
+	outputs := []output{}
+	for rows.Next() {
+		var o output
+		var spent sql.NullInt64
+		if err := rows.Scan(&o.value, &o.start, &spent); err != nil {
+			return amount.Zero, err
+		}
+
+		o.end = end
+		if spent.Valid {
+			o.end = uint(spent.Int64)
+		}
+		outputs = append(outputs, o)
+	}
+	if err := rows.Err(); err != nil {
+		return amount.Zero, err
+	}
+
+	sum := amount.Zero
+	for _, o := range outputs {
+		bonus, err := gas.Calculate(o.value, o.start, o.end, s.GetSysFeeAmount)
+		if err != nil {
+			return amount.Zero, err
+		}
+		sum = sum.Add(bonus.Unclaimed)
+	}
+
+	return sum, nil
+}
+
+func (s *sqlStorage) GetUTXOsWithoutGas(afterPk, endPk uint, limit int) ([]*tx.UTXO, error) {
+	const query = "SELECT `utxo`.`id`, `utxo`.`txid`, `utxo`.`n`, `utxo`.`value`, `tx`.`block_index` " +
+		"FROM `utxo` " +
+		"INNER JOIN `tx` ON `tx`.`txid` = `utxo`.`txid` " +
+		"WHERE `utxo`.`id` > ? AND `utxo`.`id` <= ? AND `utxo`.`asset_id` = ? AND `utxo`.`start_gas` IS NULL " +
+		"ORDER BY `utxo`.`id` ASC LIMIT ?"
+
+	rows, err := s.query(query, afterPk, endPk, asset.NEOAssetID, limit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	result := []*tx.UTXO{}
+	for rows.Next() {
+		u := &tx.UTXO{AssetID: asset.NEOAssetID}
+		if err := rows.Scan(&u.ID, &u.TxID, &u.N, &u.Value, &u.BlockIndex); err != nil {
+			return nil, err
+		}
+
+		result = append(result, u)
+	}
+
+	return result, rows.Err()
+}
+
+func (s *sqlStorage) BackfillUTXOGas(utxos []*tx.UTXO, nextPk uint) error {
+	return s.transact(func(trans *txn) error {
+		for _, u := range utxos {
+			accumulated, err := gas.Accumulated(u.BlockIndex, s.GetSysFeeAmount)
+			if err != nil {
+				return err
+			}
+
+			const startQuery = "UPDATE `utxo` SET `start_gas` = ? WHERE `id` = ? AND `start_gas` IS NULL LIMIT 1"
+			if _, err := trans.Exec(startQuery, decimalArg(gas.Share(u.Value, accumulated)), u.ID); err != nil {
+				return err
+			}
+
+			// The output may be spent after it was fetched, so whether it is spent is read in this transaction.
+			var spentIndex uint
+			const spentQuery = "SELECT `tx`.`block_index` FROM `utxo` INNER JOIN `tx` ON `tx`.`txid` = `utxo`.`used_in_tx` WHERE `utxo`.`id` = ? AND `utxo`.`claim_gas` IS NULL"
+			err = trans.QueryRow(spentQuery, u.ID).Scan(&spentIndex)
+			if err == sql.ErrNoRows {
+				continue
+			}
+			if err != nil {
+				return err
+			}
+
+			accumulated, err = gas.Accumulated(spentIndex, s.GetSysFeeAmount)
+			if err != nil {
+				return err
+			}
+
+			vout := &tx.TransactionVout{TxID: u.TxID, N: u.N, AssetID: u.AssetID, Value: u.Value}
+			if err := recordClaimGas(trans, vout, accumulated); err != nil {
+				return err
+			}
+		}
+
+		return updateCounter(trans, "utxo_gas_backfill_pk", int64(nextPk))
+	})
+}
+
+func (s *sqlStorage) GetSysFeeAmount(height uint) (int64, error) {
+	sysFee, ok, err := s.cumulativeSysFee(height)
+	if err != nil {
+		return 0, err
+	}
+
+	// Blocks stored before block fees were recorded may not be backfilled yet.
+	if !ok {
+		sysFee, err = s.sumSysFee(-1, height)
+		if err != nil {
+			return 0, err
+		}
+	}
+
+	return sysFee.Int().Int64(), nil
+}
+
+// sumSysFee returns the system fee of blocks after `after` till `height`, -1 sums from the genesis block.
+func (s *sqlStorage) sumSysFee(after int, height uint) (amount.Amount, error) {
+	const query = "SELECT COALESCE(SUM(`sys_fee`), 0) FROM `tx` WHERE `block_index` > ? AND `block_index` <= ?"
+	rows, err := s.query(query, after, height)
+	if err != nil {
+		return amount.Zero, err
+	}
+	defer rows.Close()
+
+	var sysFee amount.Amount
+	if rows.Next() {
+		if err := rows.Scan(&sysFee); err != nil {
+			return amount.Zero, err
+		}
+	}
+
+	return sysFee, rows.Err()
+}
+
+// sysFeeCache keeps the accumulated system fee of the highest height queried, so that the tx task,
+// which moves forward block by block, only sums system fees of new blocks not backfilled in block_fee yet.
+type sysFeeCache struct {
+	mu     sync.Mutex
+	height int
+	sysFee amount.Amount
+}
+
+// amountFunc returns a gas.SysFeeAmountFunc reading block_fee and backed by the cache,
+// heights below the cached one are summed without it.
+func (c *sysFeeCache) amountFunc(s *sqlStorage) gas.SysFeeAmountFunc {
+	return func(height uint) (int64, error) {
+		if sysFee, ok, err := s.cumulativeSysFee(height); err != nil || ok {
+			return sysFee.Int().Int64(), err
+		}
+
+		c.mu.Lock()
+		defer c.mu.Unlock()
+
+		if int(height) < c.height {
+			return s.GetSysFeeAmount(height)
+		}
+
+		sysFee, err := s.sumSysFee(c.height, height)
+		if err != nil {
+			return 0, err
+		}
+
+		c.height = int(height)
+		c.sysFee = c.sysFee.Add(sysFee)
+
+		return c.sysFee.Int().Int64(), nil
+	}
+}
+
+// reset drops the cached amount if it includes blocks above height.
+func (c *sysFeeCache) reset(height int) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if c.height > height {
+		c.height = -1
+		c.sysFee = amount.Zero
+	}
+}
@@ -0,0 +1,131 @@
+package gas
+
+import (
+	"math/big"
+	"squirrel/amount"
+)
+
+// DecrementInterval is the number of blocks before GAS generation per block decreases.
+const DecrementInterval = 2000000
+
+// GenerationAmount is the GAS generated per block in each decrement interval.
+var GenerationAmount = []int64{8, 7, 6, 5, 4, 3, 2, 1, 1, 1, 1, 1, 1, 1, 1, 1, 1, 1, 1, 1, 1, 1}
+
+// GAS has 8 decimals, all GAS generated by a block is shared by the 100000000 NEO.
+const gasDecimals = 8
+
+// SysFeeAmountFunc returns the accumulated system fee (in whole GAS) of all blocks till the given height.
+type SysFeeAmountFunc func(height uint) (int64, error)
+
+// Bonus is the GAS generated by NEO held from start height to end height.
+type Bonus struct {
+	Generated amount.Amount
+	SysFee    amount.Amount
+	Unclaimed amount.Amount
+}
+
+// Calculate returns GAS bonus of `value` NEO held from block `start` (inclusive) to block `end` (exclusive).
+func Calculate(value amount.Amount, start, end uint, sysFeeAmount SysFeeAmountFunc) (*Bonus, error) {
+	generated := generatedAmount(start, end)
+	sysFee := int64(0)
+
+	if end > start {
+		endAmount, err := sysFeeAmount(end - 1)
+		if err != nil {
+			return nil, err
+		}
+
+		startAmount := int64(0)
+		if start > 0 {
+			startAmount, err = sysFeeAmount(start - 1)
+			if err != nil {
+				return nil, err
+			}
+		}
+
+		sysFee = endAmount - startAmount
+	}
+
+	// NEO is indivisible.
+	neo := value.Int()
+
+	bonus := Bonus{
+		Generated: share(neo, generated),
+		SysFee:    share(neo, sysFee),
+	}
+	bonus.Unclaimed = bonus.Generated.Add(bonus.SysFee)
+
+	return &bonus, nil
+}
+
+// Accumulated returns GAS shared by all NEO holders from the genesis block to block `end` (exclusive),
+// generated GAS plus system fees. The bonus of NEO held from start to end is its share of
+// Accumulated(end) minus its share of Accumulated(start), which allows keeping running totals.
+func Accumulated(end uint, sysFeeAmount SysFeeAmountFunc) (int64, error) {
+	if end == 0 {
+		return 0, nil
+	}
+
+	sysFee, err := sysFeeAmount(end - 1)
+	if err != nil {
+		return 0, err
+	}
+
+	return generatedAmount(0, end) + sysFee, nil
+}
+
+// Share returns GAS of `value` NEO from gas shared by all NEO holders.
+func Share(value amount.Amount, gas int64) amount.Amount {
+	return share(value.Int(), gas)
+}
+
+// Unclaimed is GAS of an address which is not claimed yet.
+type Unclaimed struct {
+	// Available is the bonus of spent NEO, which can be claimed.
+	Available amount.Amount
+	// Unavailable is the bonus of unspent NEO, which becomes available once the NEO is spent.
+	Unavailable amount.Amount
+}
+
+// generatedAmount returns GAS generated by blocks from start (inclusive) to end (exclusive).
+func generatedAmount(start, end uint) int64 {
+	if end <= start {
+		return 0
+	}
+
+	generated := int64(0)
+	ustart := start / DecrementInterval
+
+	if ustart >= uint(len(GenerationAmount)) {
+		return 0
+	}
+
+	istart := start % DecrementInterval
+	uend := end / DecrementInterval
+	iend := end % DecrementInterval
+
+	if uend >= uint(len(GenerationAmount)) {
+		uend = uint(len(GenerationAmount))
+		iend = 0
+	}
+	if iend == 0 {
+		uend--
+		iend = DecrementInterval
+	}
+
+	for ustart < uend {
+		generated += int64(DecrementInterval-istart) * GenerationAmount[ustart]
+		ustart++
+		istart = 0
+	}
+
+	generated += int64(iend-istart) * GenerationAmount[ustart]
+
+	return generated
+}
+
+// share returns GAS of the given NEO from gas shared by all NEO holders,
+// since the total supply of NEO equals the GAS base unit, the share is exact in 8 decimals.
+func share(neo *big.Int, gas int64) amount.Amount {
+	return amount.New(new(big.Int).Mul(neo, big.NewInt(gas)), gasDecimals)
+}
@@ -0,0 +1,91 @@
+package gas
+
+import (
+	"squirrel/amount"
+	"testing"
+)
+
+func noSysFee(height uint) (int64, error) {
+	return 0, nil
+}
+
+func TestGeneratedAmount(t *testing.T) {
+	cases := []struct {
+		start, end uint
+		expected   int64
+	}{
+		{0, 0, 0},
+		{0, 1, 8},
+		{10, 20, 80},
+		{DecrementInterval - 1, DecrementInterval + 1, 8 + 7},
+		{0, 2 * DecrementInterval, 8*DecrementInterval + 7*DecrementInterval},
+		{22 * DecrementInterval, 30 * DecrementInterval, 0},
+		{22*DecrementInterval - 1, 30 * DecrementInterval, 1},
+	}
+
+	for _, c := range cases {
+		if got := generatedAmount(c.start, c.end); got != c.expected {
+			t.Errorf("generatedAmount(%d, %d) = %d, expected %d", c.start, c.end, got, c.expected)
+		}
+	}
+}
+
+func TestCalculate(t *testing.T) {
+	sysFee := func(height uint) (int64, error) {
+		// One GAS system fee per block.
+		return int64(height + 1), nil
+	}
+
+	bonus, err := Calculate(amount.NewFromInt64(100, 0), 100, 200, sysFee)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// 100 NEO * (100 blocks * 8 GAS) / 100,000,000.
+	if got := bonus.Generated.String(); got != "0.00080000" {
+		t.Errorf("Generated = %s", got)
+	}
+	// 100 NEO * 100 GAS / 100,000,000.
+	if got := bonus.SysFee.String(); got != "0.00010000" {
+		t.Errorf("SysFee = %s", got)
+	}
+	if got := bonus.Unclaimed.String(); got != "0.00090000" {
+		t.Errorf("Unclaimed = %s", got)
+	}
+
+	bonus, err = Calculate(amount.NewFromInt64(100, 0), 5, 5, noSysFee)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if bonus.Unclaimed.Sign() != 0 {
+		t.Errorf("Unclaimed of empty range = %s", bonus.Unclaimed.String())
+	}
+}
+
+func TestAccumulated(t *testing.T) {
+	sysFee := func(height uint) (int64, error) {
+		return int64(height + 1), nil
+	}
+
+	value := amount.NewFromInt64(12345, 0)
+
+	for _, r := range [][2]uint{{0, 1}, {100, 200}, {DecrementInterval - 10, DecrementInterval + 10}} {
+		bonus, err := Calculate(value, r[0], r[1], sysFee)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		start, err := Accumulated(r[0], sysFee)
+		if err != nil {
+			t.Fatal(err)
+		}
+		end, err := Accumulated(r[1], sysFee)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if got := Share(value, end).Sub(Share(value, start)); got.Cmp(bonus.Unclaimed) != 0 {
+			t.Errorf("bonus from %d to %d by accumulated GAS is %s, expected %s", r[0], r[1], got, bonus.Unclaimed)
+		}
+	}
+}
@@ -1,32 +1,86 @@
 package main
 
 import (
+	"context"
 	"flag"
+	"fmt"
 	_ "net/http/pprof"
+	"os"
+	"os/signal"
+	"squirrel/api"
 	"squirrel/config"
 	"squirrel/db"
 	"squirrel/log"
 	"squirrel/mail"
 	"squirrel/tasks"
+	"syscall"
+	"time"
 )
 
 var enableMail bool
 
 func init() {
 	flag.BoolVar(&enableMail, "mail", false, "If mail alert is enabled")
+	flag.Usage = func() {
+		fmt.Fprintf(flag.CommandLine.Output(), "Usage: %s [flags] [migrate]\n", os.Args[0])
+		fmt.Fprintf(flag.CommandLine.Output(), "  migrate\n    \tApply pending schema migrations and exit\n")
+		flag.PrintDefaults()
+	}
 }
 
 func main() {
 	flag.Parse()
+	if flag.NArg() > 1 || flag.NArg() == 1 && flag.Arg(0) != "migrate" {
+		flag.Usage()
+		os.Exit(2)
+	}
 
 	log.Init()
 	config.Load(true)
+	// Schema migrations are applied by db.Init.
 	db.Init()
+	if flag.Arg(0) == "migrate" {
+		return
+	}
+
 	mail.Init(enableMail)
 
 	defer mail.AlertIfErr()
 
-	tasks.Run()
+	signals := make(chan os.Signal, 2)
+	signal.Notify(signals, syscall.SIGINT, syscall.SIGTERM)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	tasks.Run(ctx)
+	api.Run()
+
+	sig := <-signals
+	log.Printf("Received %v, waiting for tasks to persist pending data, send again to force exit\n", sig)
+	cancel()
+
+	shutdownAPI()
+
+	stopped := make(chan struct{})
+	go func() {
+		tasks.Wait()
+		close(stopped)
+	}()
+
+	select {
+	case <-stopped:
+		log.Printf("All tasks stopped at a consistent checkpoint, block height: %d\n", db.GetLastHeight())
+	case sig := <-signals:
+		log.Error.Printf("Received %v again, exit before tasks stopped\n", sig)
+		os.Exit(1)
+	}
+}
+
+// shutdownAPI waits a while for active api requests.
+func shutdownAPI() {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
 
-	select {}
+	if err := api.Shutdown(ctx); err != nil {
+		log.Error.Printf("Failed to shutdown http api: %v\n", err)
+	}
 }
@@ -0,0 +1,552 @@
+// Package migrations records the schema version in the database
+// and applies forward migrations to bring the schema up to date.
+package migrations
+
+import (
+	"database/sql"
+	"fmt"
+	"squirrel/asset"
+	"squirrel/log"
+	"strings"
+	"time"
+)
+
+type migration struct {
+	version     uint
+	description string
+	stmts       func(driver string) []string
+}
+
+// migrations are applied in order, a released migration must never be changed,
+// schema changes are made by appending a new one.
+var migrations = []migration{
+	{1, "baseline schema", baseline},
+	{2, "store nep5 transfer values as decimals", nep5TxDecimalValue},
+	{3, "record transactions with invalid witnesses", invalidWitness},
+	{4, "record verification contracts of addresses", addrContract},
+	{5, "record GAS bonus of NEO outputs", utxoGas},
+	{6, "record fees of blocks", blockFee},
+	{7, "record daily balances of global assets in one partitioned table", addrAssetBalance},
+	{8, "record balance history of nep5 assets", nep5Balance},
+	{9, "backfill GAS bonus of NEO outputs stored before version 5", utxoGasBackfill},
+	{10, "record where nep5 balance history starts", nep5BalanceStart},
+	{11, "record claim transactions of claimed outputs", txClaimsFrom},
+	{12, "record total supply history of nep5 assets", nep5TotalSupply},
+	{13, "store sqlite amounts as text to keep their precision", sqliteTextAmounts},
+}
+
+// Latest returns the schema version expected by this build.
+func Latest() uint {
+	return migrations[len(migrations)-1].version
+}
+
+// Current returns the schema version of the database, 0 means the database is empty.
+// Databases created by hand before migrations were introduced are at version 1.
+func Current(conn *sql.DB, driver string) (uint, error) {
+	exists, err := tableExists(conn, driver, "schema_version")
+	if err != nil {
+		return 0, err
+	}
+
+	if exists {
+		return recordedVersion(conn)
+	}
+
+	legacy, err := tableExists(conn, driver, "counter")
+	if err != nil || !legacy {
+		return 0, err
+	}
+
+	return 1, nil
+}
+
+// Up applies all pending migrations and returns the resulting version.
+// It refuses to touch a database whose schema is newer than this build.
+func Up(conn *sql.DB, driver string) (uint, error) {
+	current, err := Current(conn, driver)
+	if err != nil {
+		return 0, err
+	}
+
+	if current > Latest() {
+		return current, fmt.Errorf("database schema version %d is newer than version %d supported by this build", current, Latest())
+	}
+
+	if err := createVersionTable(conn, driver, current); err != nil {
+		return current, err
+	}
+
+	for _, m := range migrations {
+		if m.version <= current {
+			continue
+		}
+
+		log.Printf("Applying schema migration %d: %s\n", m.version, m.description)
+		if err := apply(conn, driver, m); err != nil {
+			return current, fmt.Errorf("schema migration %d failed: %v", m.version, err)
+		}
+
+		current = m.version
+	}
+
+	return current, nil
+}
+
+// apply runs the migration in a transaction. MySQL commits DDL statements implicitly,
+// so a failed migration may be partially applied there and has to be cleaned up by hand.
+func apply(conn *sql.DB, driver string, m migration) error {
+	tx, err := conn.Begin()
+	if err != nil {
+		return err
+	}
+
+	for _, stmt := range m.stmts(driver) {
+		if _, err := tx.Exec(stmt); err != nil {
+			tx.Rollback()
+			return err
+		}
+	}
+
+	if err := recordVersion(tx, m.version); err != nil {
+		tx.Rollback()
+		return err
+	}
+
+	return tx.Commit()
+}
+
+// createVersionTable creates the schema_version table if not exists,
+// the version of a legacy database is recorded without running its migrations.
+func createVersionTable(conn *sql.DB, driver string, current uint) error {
+	exists, err := tableExists(conn, driver, "schema_version")
+	if err != nil || exists {
+		return err
+	}
+
+	tx, err := conn.Begin()
+	if err != nil {
+		return err
+	}
+
+	const query = "CREATE TABLE schema_version (version integer not null primary key, applied_at bigint not null)"
+	if _, err := tx.Exec(query); err != nil {
+		tx.Rollback()
+		return err
+	}
+
+	if current > 0 {
+		log.Printf("Recording existing schema as version %d\n", current)
+		if err := recordVersion(tx, current); err != nil {
+			tx.Rollback()
+			return err
+		}
+	}
+
+	return tx.Commit()
+}
+
+func recordVersion(tx *sql.Tx, version uint) error {
+	query := fmt.Sprintf("INSERT INTO schema_version (version, applied_at) VALUES (%d, %d)", version, time.Now().Unix())
+	_, err := tx.Exec(query)
+	return err
+}
+
+func recordedVersion(conn *sql.DB) (uint, error) {
+	var version uint
+	err := conn.QueryRow("SELECT COALESCE(MAX(version), 0) FROM schema_version").Scan(&version)
+	return version, err
+}
+
+func tableExists(conn *sql.DB, driver string, table string) (bool, error) {
+	var query string
+
+	switch driver {
+	case "mysql":
+		query = mysqlTableExistsQuery
+	case "postgres":
+		query = postgresTableExistsQuery
+	case "sqlite3":
+		query = sqliteTableExistsQuery
+	default:
+		return false, fmt.Errorf("unsupported database driver: %s", driver)
+	}
+
+	var cnt int
+	if err := conn.QueryRow(query, table).Scan(&cnt); err != nil {
+		return false, err
+	}
+
+	return cnt > 0, nil
+}
+
+func baseline(driver string) []string {
+	switch driver {
+	case "mysql":
+		return mysqlBaseline()
+	case "postgres":
+		return postgresBaseline()
+	default:
+		return sqliteBaseline()
+	}
+}
+
+func nep5TxDecimalValue(driver string) []string {
+	switch driver {
+	case "mysql":
+		return []string{"ALTER TABLE nep5_tx MODIFY value decimal(35, 8) not null"}
+	case "postgres":
+		return []string{"ALTER TABLE nep5_tx ALTER COLUMN value TYPE numeric(35, 8)"}
+	default:
+		return sqliteNep5TxDecimalValue()
+	}
+}
+
+func invalidWitness(driver string) []string {
+	var table string
+	switch driver {
+	case "mysql":
+		table = `CREATE TABLE invalid_witness (
+			id          int unsigned auto_increment primary key,
+			txid        char(66) not null,
+			block_index int unsigned not null,
+			reason      text not null
+		) ENGINE = InnoDB DEFAULT CHARSET = utf8mb4`
+	case "postgres":
+		table = `CREATE TABLE invalid_witness (
+			id          bigserial primary key,
+			txid        varchar(66) not null,
+			block_index bigint not null,
+			reason      text not null
+		)`
+	default:
+		table = `CREATE TABLE invalid_witness (
+			id          integer primary key autoincrement,
+			txid        text not null,
+			block_index integer not null,
+			reason      text not null
+		)`
+	}
+
+	return []string{
+		table,
+		`CREATE INDEX idx_invalid_witness_txid ON invalid_witness(txid)`,
+		`CREATE INDEX idx_invalid_witness_block_index ON invalid_witness(block_index)`,
+	}
+}
+
+func addrContract(driver string) []string {
+	var table string
+	switch driver {
+	case "mysql":
+		table = `CREATE TABLE addr_contract (
+			id          int unsigned auto_increment primary key,
+			address     varchar(128) not null,
+			type        varchar(16) not null,
+			threshold   int unsigned not null,
+			public_keys text not null,
+			script      text not null,
+			txid        char(66) not null,
+			block_index int unsigned not null
+		) ENGINE = InnoDB DEFAULT CHARSET = utf8mb4`
+	case "postgres":
+		table = `CREATE TABLE addr_contract (
+			id          bigserial primary key,
+			address     varchar(128) not null,
+			type        varchar(16) not null,
+			threshold   bigint not null,
+			public_keys text not null,
+			script      text not null,
+			txid        varchar(66) not null,
+			block_index bigint not null
+		)`
+	default:
+		table = `CREATE TABLE addr_contract (
+			id          integer primary key autoincrement,
+			address     text not null,
+			type        text not null,
+			threshold   integer not null,
+			public_keys text not null,
+			script      text not null,
+			txid        text not null,
+			block_index integer not null
+		)`
+	}
+
+	return []string{
+		table,
+		`CREATE UNIQUE INDEX uk_addr_contract_address ON addr_contract(address)`,
+		`CREATE INDEX idx_addr_contract_block_index ON addr_contract(block_index)`,
+	}
+}
+
+// utxoGas adds the share of accumulated GAS at the start and the claimable bonus of NEO outputs,
+// both are null for other assets and for outputs stored before this migration.
+func utxoGas(driver string) []string {
+	var columnType string
+	switch driver {
+	case "mysql":
+		columnType = "decimal(35, 8)"
+	case "postgres":
+		columnType = "numeric(35, 8)"
+	default:
+		columnType = "numeric"
+	}
+
+	return []string{
+		"ALTER TABLE utxo ADD COLUMN start_gas " + columnType + " null",
+		"ALTER TABLE utxo ADD COLUMN claim_gas " + columnType + " null",
+	}
+}
+
+// blockFee creates the block_fee table, blocks stored before this migration
+// are filled by a backfill task whose position is kept in counter.
+func blockFee(driver string) []string {
+	var table, column string
+	switch driver {
+	case "mysql":
+		table = `CREATE TABLE block_fee (
+			id                 int unsigned auto_increment primary key,
+			block_index        int unsigned not null,
+			sys_fee            decimal(35, 8) not null,
+			net_fee            decimal(35, 8) not null,
+			cumulative_sys_fee decimal(35, 8) not null
+		) ENGINE = InnoDB DEFAULT CHARSET = utf8mb4`
+		column = "ALTER TABLE counter ADD COLUMN block_fee_backfill_index int unsigned not null default 0"
+	case "postgres":
+		table = `CREATE TABLE block_fee (
+			id                 bigserial primary key,
+			block_index        bigint not null,
+			sys_fee            numeric(35, 8) not null,
+			net_fee            numeric(35, 8) not null,
+			cumulative_sys_fee numeric(35, 8) not null
+		)`
+		column = "ALTER TABLE counter ADD COLUMN block_fee_backfill_index bigint not null default 0"
+	default:
+		table = `CREATE TABLE block_fee (
+			id                 integer primary key autoincrement,
+			block_index        integer not null,
+			sys_fee            numeric not null,
+			net_fee            numeric not null,
+			cumulative_sys_fee numeric not null
+		)`
+		column = "ALTER TABLE counter ADD COLUMN block_fee_backfill_index integer not null default 0"
+	}
+
+	return []string{
+		table,
+		`CREATE UNIQUE INDEX uk_block_fee_block_index ON block_fee(block_index)`,
+		column,
+	}
+}
+
+// addrAssetBalancePartitions is the number of hash partitions of addr_asset_balance.
+const addrAssetBalancePartitions = 16
+
+// addrAssetBalance replaces the daily GAS balance tables sharded by address suffix with addr_asset_balance,
+// which is partitioned by address. Balances are derived data, they are recorded again from the first transaction.
+func addrAssetBalance(driver string) []string {
+	var stmts []string
+	switch driver {
+	case "mysql":
+		stmts = []string{
+			fmt.Sprintf(`CREATE TABLE addr_asset_balance (
+			id       int unsigned auto_increment,
+			address  varchar(128) not null,
+			asset_id char(66) not null,
+			date     date not null,
+			balance  decimal(35, 8) not null,
+			primary key (id, address)
+		) ENGINE = InnoDB DEFAULT CHARSET = utf8mb4 PARTITION BY KEY(address) PARTITIONS %d`, addrAssetBalancePartitions),
+			"ALTER TABLE counter ADD COLUMN last_tx_pk_asset_balance int unsigned not null default 0",
+		}
+	case "postgres":
+		stmts = []string{
+			`CREATE TABLE addr_asset_balance (
+			id       bigserial,
+			address  varchar(128) not null,
+			asset_id varchar(66) not null,
+			date     date not null,
+			balance  numeric(35, 8) not null,
+			primary key (id, address)
+		) PARTITION BY HASH (address)`,
+			"ALTER TABLE counter ADD COLUMN last_tx_pk_asset_balance bigint not null default 0",
+		}
+		for i := 0; i < addrAssetBalancePartitions; i++ {
+			stmts = append(stmts, fmt.Sprintf("CREATE TABLE addr_asset_balance_p%d PARTITION OF addr_asset_balance FOR VALUES WITH (MODULUS %d, REMAINDER %d)",
+				i, addrAssetBalancePartitions, i))
+		}
+	default:
+		// SQLite has no partitioning.
+		stmts = []string{
+			`CREATE TABLE addr_asset_balance (
+			id       integer primary key autoincrement,
+			address  text not null,
+			asset_id text not null,
+			date     date not null,
+			balance  numeric not null
+		)`,
+			"ALTER TABLE counter ADD COLUMN last_tx_pk_asset_balance integer not null default 0",
+		}
+	}
+
+	stmts = append(stmts, `CREATE UNIQUE INDEX uk_addr_asset_balance_address_asset_date ON addr_asset_balance(address, asset_id, date)`)
+	for _, table := range addrGasBalanceTables() {
+		stmts = append(stmts, "DROP TABLE "+table)
+	}
+
+	return stmts
+}
+
+func nep5Balance(driver string) []string {
+	var table string
+	switch driver {
+	case "mysql":
+		table = `CREATE TABLE nep5_balance (
+			id          int unsigned auto_increment primary key,
+			address     varchar(128) not null,
+			asset_id    char(40) not null,
+			block_index int unsigned not null,
+			block_time  int unsigned not null,
+			balance     decimal(35, 8) not null
+		) ENGINE = InnoDB DEFAULT CHARSET = utf8mb4`
+	case "postgres":
+		table = `CREATE TABLE nep5_balance (
+			id          bigserial primary key,
+			address     varchar(128) not null,
+			asset_id    varchar(40) not null,
+			block_index bigint not null,
+			block_time  bigint not null,
+			balance     numeric(35, 8) not null
+		)`
+	default:
+		table = `CREATE TABLE nep5_balance (
+			id          integer primary key autoincrement,
+			address     text not null,
+			asset_id    text not null,
+			block_index integer not null,
+			block_time  integer not null,
+			balance     numeric not null
+		)`
+	}
+
+	return []string{
+		table,
+		`CREATE INDEX idx_nep5_balance_address_asset_block ON nep5_balance(address, asset_id, block_index)`,
+		`CREATE INDEX idx_nep5_balance_block_index ON nep5_balance(block_index)`,
+	}
+}
+
+// utxoGasBackfill adds the position of the task filling start_gas and claim_gas of NEO outputs
+// stored before version 5, outputs up to utxo_gas_backfill_end are filled.
+func utxoGasBackfill(driver string) []string {
+	var columnType string
+	switch driver {
+	case "mysql":
+		columnType = "int unsigned"
+	case "postgres":
+		columnType = "bigint"
+	default:
+		columnType = "integer"
+	}
+
+	return []string{
+		"ALTER TABLE counter ADD COLUMN utxo_gas_backfill_pk " + columnType + " not null default 0",
+		"ALTER TABLE counter ADD COLUMN utxo_gas_backfill_end " + columnType + " not null default 0",
+		fmt.Sprintf("UPDATE counter SET utxo_gas_backfill_end = COALESCE((SELECT MAX(id) FROM utxo WHERE asset_id = '%s' AND start_gas IS NULL), 0)", asset.NEOAssetID),
+	}
+}
+
+// nep5BalanceStart records the first block whose nep5 balance changes are all in nep5_balance,
+// which is the block after the last transaction handled by the nep5 task before version 8.
+func nep5BalanceStart(driver string) []string {
+	var columnType string
+	switch driver {
+	case "mysql":
+		columnType = "int unsigned"
+	case "postgres":
+		columnType = "bigint"
+	default:
+		columnType = "integer"
+	}
+
+	return []string{
+		"ALTER TABLE counter ADD COLUMN nep5_balance_start_index " + columnType + " not null default 0",
+		"UPDATE counter SET nep5_balance_start_index = COALESCE((SELECT tx.block_index + 1 FROM tx WHERE tx.id = counter.last_tx_pk_for_nep5), 0)",
+	}
+}
+
+// txClaimsFrom records the claim transaction of each claimed output, so that claims can be removed
+// when the transaction is rolled back. Claims stored before this migration have an empty from.
+func txClaimsFrom(driver string) []string {
+	column, columnType := `"from"`, "text"
+	switch driver {
+	case "mysql":
+		column, columnType = "`from`", "char(66)"
+	case "postgres":
+		columnType = "varchar(66)"
+	}
+
+	return []string{
+		"ALTER TABLE tx_claims ADD COLUMN " + column + " " + columnType + " not null default ''",
+		"CREATE INDEX idx_tx_claims_from ON tx_claims(" + column + ")",
+	}
+}
+
+// nep5TotalSupply creates the nep5_total_supply table, which records total supplies of nep5 assets
+// after blocks changing them, so that they can be restored when blocks are rolled back.
+// Assets changed before this migration have no history until their next change.
+func nep5TotalSupply(driver string) []string {
+	var table string
+	switch driver {
+	case "mysql":
+		table = `CREATE TABLE nep5_total_supply (
+			id           int unsigned auto_increment primary key,
+			asset_id     char(40) not null,
+			block_index  int unsigned not null,
+			total_supply decimal(35, 8) not null
+		) ENGINE = InnoDB DEFAULT CHARSET = utf8mb4`
+	case "postgres":
+		table = `CREATE TABLE nep5_total_supply (
+			id           bigserial primary key,
+			asset_id     varchar(40) not null,
+			block_index  bigint not null,
+			total_supply numeric(35, 8) not null
+		)`
+	default:
+		table = `CREATE TABLE nep5_total_supply (
+			id           integer primary key autoincrement,
+			asset_id     text not null,
+			block_index  integer not null,
+			total_supply text not null
+		)`
+	}
+
+	return []string{
+		table,
+		`CREATE INDEX idx_nep5_total_supply_asset_block ON nep5_total_supply(asset_id, block_index)`,
+		`CREATE INDEX idx_nep5_total_supply_block_index ON nep5_total_supply(block_index)`,
+	}
+}
+
+// sqliteTextAmounts changes amount columns of SQLite from numeric to text,
+// numeric affinity turns decimals into floating point values and loses precision.
+// MySQL and PostgreSQL store amounts as exact decimals already.
+func sqliteTextAmounts(driver string) []string {
+	switch driver {
+	case "mysql", "postgres":
+		return nil
+	default:
+		return sqliteTextAmountTables()
+	}
+}
+
+// addrGasBalanceTables returns names of daily GAS balance tables of the baseline schema,
+// which are sharded by the last character of address and replaced by addr_asset_balance.
+func addrGasBalanceTables() []string {
+	tables := []string{}
+	for _, suffix := range strings.Split("abcdefghijklmnopqrstuvwxyz0123456789", "") {
+		tables = append(tables, "addr_gas_balance_"+suffix)
+	}
+
+	return tables
+}
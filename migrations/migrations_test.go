@@ -0,0 +1,191 @@
+package migrations
+
+import (
+	"database/sql"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"squirrel/log"
+	"strings"
+	"testing"
+
+	_ "github.com/mattn/go-sqlite3"
+)
+
+func openTestDB(t *testing.T) (*sql.DB, func()) {
+	log.Init()
+
+	dir, err := ioutil.TempDir("", "squirrel")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	conn, err := sql.Open("sqlite3", "file:"+filepath.Join(dir, "test.db"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return conn, func() {
+		conn.Close()
+		os.RemoveAll(dir)
+		os.Remove("error.log")
+	}
+}
+
+func TestUp(t *testing.T) {
+	conn, cleanup := openTestDB(t)
+	defer cleanup()
+
+	for i := 0; i < 2; i++ {
+		version, err := Up(conn, "sqlite3")
+		if err != nil {
+			t.Fatal(err)
+		}
+		if version != Latest() {
+			t.Fatalf("Up returns version %d, expected %d", version, Latest())
+		}
+	}
+
+	var cnt int
+	if err := conn.QueryRow("SELECT COUNT(*) FROM schema_version").Scan(&cnt); err != nil {
+		t.Fatal(err)
+	}
+	if cnt != len(migrations) {
+		t.Fatalf("%d versions recorded, expected %d", cnt, len(migrations))
+	}
+}
+
+func TestUpLegacy(t *testing.T) {
+	conn, cleanup := openTestDB(t)
+	defer cleanup()
+
+	// A database created by hand has no schema_version table.
+	if _, err := conn.Exec("CREATE TABLE counter (id integer primary key)"); err != nil {
+		t.Fatal(err)
+	}
+
+	version, err := Current(conn, "sqlite3")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if version != 1 {
+		t.Fatalf("Current returns version %d for legacy database", version)
+	}
+
+	if err := createVersionTable(conn, "sqlite3", version); err != nil {
+		t.Fatal(err)
+	}
+	if version, err = recordedVersion(conn); err != nil || version != 1 {
+		t.Fatalf("recordedVersion returns %d, %v", version, err)
+	}
+}
+
+func TestUpNewerSchema(t *testing.T) {
+	conn, cleanup := openTestDB(t)
+	defer cleanup()
+
+	if _, err := Up(conn, "sqlite3"); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := conn.Exec("INSERT INTO schema_version (version, applied_at) VALUES (?, 0)", Latest()+1); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := Up(conn, "sqlite3"); err == nil {
+		t.Fatal("Up accepts newer schema")
+	}
+}
+
+func TestMySQLQuoting(t *testing.T) {
+	for _, m := range migrations {
+		for _, stmt := range m.stmts("mysql") {
+			if strings.Contains(stmt, `"`) {
+				t.Fatalf("identifier of migration %d not quoted with backticks: %s", m.version, stmt)
+			}
+		}
+	}
+}
+
+func TestNep5TxDecimalValue(t *testing.T) {
+	conn, cleanup := openTestDB(t)
+	defer cleanup()
+
+	if err := createVersionTable(conn, "sqlite3", 0); err != nil {
+		t.Fatal(err)
+	}
+	if err := apply(conn, "sqlite3", migrations[0]); err != nil {
+		t.Fatal(err)
+	}
+
+	const insert = `INSERT INTO nep5_tx (txid, asset_id, "from", "to", value, block_index, block_time) VALUES ('0x01', 'a', 'b', 'c', 12.5, 1, 2)`
+	if _, err := conn.Exec(insert); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := Up(conn, "sqlite3"); err != nil {
+		t.Fatal(err)
+	}
+
+	var value string
+	if err := conn.QueryRow("SELECT value FROM nep5_tx WHERE txid = '0x01'").Scan(&value); err != nil {
+		t.Fatal(err)
+	}
+	if value != "12.5" {
+		t.Fatalf("nep5_tx value migrated as %s", value)
+	}
+}
+
+func TestSQLiteTextAmounts(t *testing.T) {
+	conn, cleanup := openTestDB(t)
+	defer cleanup()
+
+	if err := createVersionTable(conn, "sqlite3", 0); err != nil {
+		t.Fatal(err)
+	}
+	for _, m := range migrations[:12] {
+		if err := apply(conn, "sqlite3", m); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	inserts := []string{
+		`INSERT INTO utxo (address, txid, n, asset_id, value, start_gas) VALUES ('a', '0x01', 0, 'neo', 100, 0.00012345)`,
+		`INSERT INTO block_fee (block_index, sys_fee, net_fee, cumulative_sys_fee) VALUES (1, 10, 0.1, 1e20)`,
+	}
+	for _, insert := range inserts {
+		if _, err := conn.Exec(insert); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	if _, err := Up(conn, "sqlite3"); err != nil {
+		t.Fatal(err)
+	}
+
+	var value, startGas string
+	var claimGas sql.NullString
+	if err := conn.QueryRow("SELECT value, start_gas, claim_gas FROM utxo WHERE txid = '0x01'").Scan(&value, &startGas, &claimGas); err != nil {
+		t.Fatal(err)
+	}
+	if value != "100" || startGas != "0.00012345" || claimGas.Valid {
+		t.Fatalf("utxo amounts migrated as %s, %s, %v", value, startGas, claimGas)
+	}
+
+	var sysFee, netFee, cumulative, typ string
+	const query = "SELECT sys_fee, net_fee, cumulative_sys_fee, typeof(net_fee) FROM block_fee WHERE block_index = 1"
+	if err := conn.QueryRow(query).Scan(&sysFee, &netFee, &cumulative, &typ); err != nil {
+		t.Fatal(err)
+	}
+	if sysFee != "10" || netFee != "0.1" || cumulative != "100000000000000000000" || typ != "text" {
+		t.Fatalf("block_fee amounts migrated as %s, %s, %s of type %s", sysFee, netFee, cumulative, typ)
+	}
+
+	// Indexes are recreated with the tables.
+	var cnt int
+	if err := conn.QueryRow("SELECT COUNT(*) FROM sqlite_master WHERE type = 'index' AND tbl_name = 'utxo' AND sql IS NOT NULL").Scan(&cnt); err != nil {
+		t.Fatal(err)
+	}
+	if cnt != 4 {
+		t.Fatalf("%d indexes on utxo after migration, expected 4", cnt)
+	}
+}
@@ -0,0 +1,250 @@
+package migrations
+
+import (
+	"fmt"
+	"strings"
+)
+
+const mysqlTableExistsQuery = "SELECT COUNT(*) FROM information_schema.tables WHERE table_schema = DATABASE() AND table_name = ?"
+
+// mysqlBaseline returns the schema as it was before migrations were introduced.
+// The database has to be created beforehand with
+// "create database mainnet character set utf8mb4 collate utf8mb4_bin",
+// READ-COMMITTED isolation and ROW binlog format are recommended.
+func mysqlBaseline() []string {
+	stmts := []string{
+		`CREATE TABLE addr_asset (
+			id                    int unsigned auto_increment primary key,
+			address               varchar(128) not null,
+			asset_id              char(66) not null,
+			balance               decimal(35, 8) not null,
+			transactions          bigint unsigned not null,
+			last_transaction_time bigint unsigned not null
+		) ENGINE = InnoDB DEFAULT CHARSET = utf8mb4`,
+		`CREATE INDEX addr_asset_asset_id_balance_index ON addr_asset(asset_id, balance)`,
+		`CREATE UNIQUE INDEX addr_asset_address_asset_id_uindex ON addr_asset(address, asset_id)`,
+		`CREATE TABLE addr_tx (
+			id         int unsigned auto_increment primary key,
+			txid       char(66) not null,
+			address    varchar(128) not null,
+			block_time bigint unsigned not null,
+			asset_type varchar(16) not null
+		) ENGINE = InnoDB DEFAULT CHARSET = utf8mb4`,
+		`CREATE UNIQUE INDEX addr_tx_address_asset_type_txid_uindex ON addr_tx(address, asset_type, txid)`,
+		`CREATE INDEX addr_tx_txid ON addr_tx(txid)`,
+		`CREATE INDEX addr_tx_address ON addr_tx(address)`,
+		`CREATE TABLE address (
+			id                    int unsigned auto_increment primary key,
+			address               varchar(128) not null,
+			created_at            bigint unsigned not null,
+			last_transaction_time bigint unsigned not null,
+			trans_asset           bigint unsigned not null,
+			trans_nep5            bigint unsigned not null
+		) ENGINE = InnoDB DEFAULT CHARSET = utf8mb4`,
+		`CREATE UNIQUE INDEX uk_address ON address(address)`,
+		`CREATE TABLE asset (
+			id           int unsigned auto_increment primary key,
+			block_index  int unsigned not null,
+			block_time   bigint unsigned not null,
+			version      int unsigned not null,
+			asset_id     char(66) not null,
+			type         varchar(32) not null,
+			name         varchar(128) not null,
+			amount       decimal(35, 8) not null,
+			available    decimal(35, 8) not null,
+			"precision"  tinyint unsigned not null,
+			owner        char(66) not null,
+			admin        char(34) not null,
+			issuer       char(66) not null,
+			expiration   bigint unsigned not null,
+			frozen       tinyint(1) not null,
+			addresses    bigint unsigned not null,
+			transactions bigint unsigned not null
+		) ENGINE = InnoDB DEFAULT CHARSET = utf8mb4`,
+		`CREATE INDEX idx_asset_asset_id ON asset(asset_id)`,
+		`CREATE INDEX idx_asset_time ON asset(block_time)`,
+		`CREATE TABLE asset_tx (
+			id       int unsigned auto_increment primary key,
+			address  char(34) not null,
+			asset_id char(66) not null,
+			txid     char(66) not null
+		) ENGINE = InnoDB DEFAULT CHARSET = utf8mb4`,
+		`CREATE INDEX idx_asset_tx_address_asset_id ON asset_tx(address, asset_id)`,
+		`CREATE UNIQUE INDEX idx_asset_tx_address_asset_id_txid ON asset_tx(address, asset_id, txid)`,
+		`CREATE TABLE block (
+			id                  int unsigned auto_increment primary key,
+			hash                char(66) not null,
+			size                int not null,
+			version             int unsigned not null,
+			previousblockhash   char(66) not null,
+			merkleroot          char(66) not null,
+			time                bigint unsigned not null,
+			"index"             int unsigned not null,
+			nonce               char(16) not null,
+			nextconsensus       char(34) not null,
+			script_invocation   text not null,
+			script_verification text not null,
+			nextblockhash       char(66) not null
+		) ENGINE = InnoDB DEFAULT CHARSET = utf8mb4`,
+		`CREATE INDEX idx_block_hash ON block(hash)`,
+		`CREATE UNIQUE INDEX idx_block_index ON block("index")`,
+		`CREATE INDEX idx_block_time ON block(time)`,
+		`CREATE TABLE counter (
+			id                     int unsigned auto_increment primary key,
+			last_block_index       int not null,
+			last_tx_pk             int unsigned not null,
+			last_asset_tx_pk       int unsigned not null,
+			last_tx_pk_for_nep5    int unsigned not null,
+			app_log_idx            int not null,
+			nep5_tx_pk_for_addr_tx int unsigned not null,
+			last_tx_pk_gas_balance int unsigned not null,
+			cnt_tx_reg             int unsigned not null,
+			cnt_tx_miner           int unsigned not null,
+			cnt_tx_issue           int unsigned not null,
+			cnt_tx_invocation      int unsigned not null,
+			cnt_tx_contract        int unsigned not null,
+			cnt_tx_claim           int unsigned not null,
+			cnt_tx_publish         int unsigned not null,
+			cnt_tx_enrollment      int unsigned not null
+		) ENGINE = InnoDB DEFAULT CHARSET = utf8mb4`,
+		`CREATE TABLE nep5 (
+			id                int unsigned auto_increment primary key,
+			asset_id          char(40) not null,
+			admin_address     char(40) not null,
+			name              varchar(128) not null,
+			symbol            varchar(16) not null,
+			decimals          tinyint unsigned not null,
+			total_supply      decimal(35, 8) not null,
+			txid              char(66) not null,
+			block_index       int unsigned not null,
+			block_time        bigint unsigned not null,
+			addresses         bigint unsigned not null,
+			holding_addresses bigint unsigned not null,
+			transfers         bigint unsigned not null,
+			visible           tinyint(1) default 1 not null
+		) ENGINE = InnoDB DEFAULT CHARSET = utf8mb4`,
+		`CREATE INDEX idx_nep5_txid ON nep5(txid)`,
+		`CREATE TABLE nep5_reg_info (
+			id             int unsigned auto_increment primary key,
+			nep5_id        int unsigned not null,
+			name           varchar(255) not null,
+			version        varchar(255) not null,
+			author         varchar(255) not null,
+			email          varchar(255) not null,
+			description    varchar(255) not null,
+			need_storage   tinyint(1) not null,
+			parameter_list varchar(255) not null,
+			return_type    varchar(255) not null
+		) ENGINE = InnoDB DEFAULT CHARSET = utf8mb4`,
+		`CREATE INDEX idx_nep5_id ON nep5_reg_info(nep5_id)`,
+		`CREATE TABLE nep5_tx (
+			id          int unsigned auto_increment primary key,
+			txid        char(66) not null,
+			asset_id    char(40) not null,
+			"from"      varchar(128) not null,
+			"to"        varchar(128) not null,
+			value       double not null,
+			block_index int unsigned not null,
+			block_time  bigint unsigned not null
+		) ENGINE = InnoDB DEFAULT CHARSET = utf8mb4`,
+		`CREATE INDEX idx_nep5_tx_asset_id ON nep5_tx(asset_id)`,
+		`CREATE INDEX idx_nep5_tx_from ON nep5_tx("from")`,
+		`CREATE INDEX idx_nep5_tx_to ON nep5_tx("to")`,
+		`CREATE INDEX idx_nep5_tx_txid ON nep5_tx(txid)`,
+		`CREATE TABLE nep5_migrate (
+			id           int unsigned auto_increment primary key,
+			old_asset_id char(40) not null,
+			new_asset_id char(40) not null,
+			migrate_txid char(66) not null
+		) ENGINE = InnoDB DEFAULT CHARSET = utf8mb4`,
+		`CREATE TABLE tx (
+			id          int unsigned auto_increment primary key,
+			block_index int unsigned not null,
+			block_time  bigint unsigned not null,
+			txid        char(66) not null,
+			size        int unsigned not null,
+			type        varchar(32) not null,
+			version     int unsigned not null,
+			sys_fee     decimal(27, 8) not null,
+			net_fee     decimal(27, 8) not null,
+			nonce       bigint not null,
+			script      text not null,
+			gas         decimal(27, 8) not null
+		) ENGINE = InnoDB DEFAULT CHARSET = utf8mb4`,
+		`CREATE INDEX idx_tx_block_index ON tx(block_index)`,
+		`CREATE INDEX idx_tx_txid ON tx(txid)`,
+		`CREATE INDEX idx_tx_type ON tx(type)`,
+		`CREATE TABLE tx_attr (
+			id      int unsigned auto_increment primary key,
+			txid    char(66) not null,
+			"usage" varchar(32) not null,
+			data    mediumtext not null
+		) ENGINE = InnoDB DEFAULT CHARSET = utf8mb4`,
+		`CREATE INDEX idx_tx_attr_txid ON tx_attr(txid)`,
+		`CREATE INDEX idx_tx_attr_usage ON tx_attr("usage")`,
+		`CREATE TABLE tx_claims (
+			id   int unsigned auto_increment primary key,
+			txid char(66) not null,
+			vout int unsigned not null
+		) ENGINE = InnoDB DEFAULT CHARSET = utf8mb4`,
+		`CREATE INDEX idx_tx_claims_txid ON tx_claims(txid)`,
+		`CREATE TABLE tx_scripts (
+			id           int unsigned auto_increment primary key,
+			txid         char(66) not null,
+			invocation   text not null,
+			verification text not null
+		) ENGINE = InnoDB DEFAULT CHARSET = utf8mb4`,
+		`CREATE INDEX idx_tx_scripts_txid ON tx_scripts(txid)`,
+		`CREATE TABLE tx_vin (
+			id     int unsigned auto_increment primary key,
+			"from" char(66) not null,
+			txid   char(66) not null,
+			vout   int unsigned not null
+		) ENGINE = InnoDB DEFAULT CHARSET = utf8mb4`,
+		`CREATE INDEX idx_tx_vin_from ON tx_vin("from")`,
+		`CREATE INDEX idx_tx_vin_txid ON tx_vin(txid)`,
+		`CREATE TABLE tx_vout (
+			id       int unsigned auto_increment primary key,
+			txid     char(66) not null,
+			n        int unsigned not null,
+			asset_id char(66) not null,
+			value    decimal(35, 8) not null,
+			address  char(34) not null
+		) ENGINE = InnoDB DEFAULT CHARSET = utf8mb4`,
+		`CREATE INDEX idx_tx_vout_address ON tx_vout(address)`,
+		`CREATE INDEX idx_tx_vout_asset_id ON tx_vout(asset_id)`,
+		`CREATE INDEX idx_tx_vout_txid ON tx_vout(txid)`,
+		`CREATE TABLE utxo (
+			id         int unsigned auto_increment primary key,
+			address    char(34) not null,
+			txid       char(66) not null,
+			n          int unsigned not null,
+			asset_id   char(66) not null,
+			value      decimal(35, 8) not null,
+			used_in_tx char(66)
+		) ENGINE = InnoDB DEFAULT CHARSET = utf8mb4`,
+		`CREATE INDEX idx_utxo_address ON utxo(address)`,
+		`CREATE INDEX idx_utxo_asset_id ON utxo(asset_id)`,
+		`CREATE INDEX idx_utxo_txid ON utxo(txid)`,
+		`CREATE INDEX idx_utxo_used_in_tx ON utxo(used_in_tx)`,
+	}
+
+	for _, table := range addrGasBalanceTables() {
+		stmts = append(stmts,
+			fmt.Sprintf(`CREATE TABLE %s (
+			id      int unsigned auto_increment primary key,
+			address char(34) not null,
+			date    date not null,
+			balance decimal(35, 8) not null
+		) ENGINE = InnoDB DEFAULT CHARSET = utf8mb4`, table),
+			fmt.Sprintf("CREATE INDEX idx_address_date ON %s(address, date)", table),
+		)
+	}
+
+	// Identifiers are written in double quotes so that statements can be raw strings.
+	for i, stmt := range stmts {
+		stmts[i] = strings.Replace(stmt, `"`, "`", -1)
+	}
+
+	return stmts
+}
@@ -0,0 +1,240 @@
+package migrations
+
+import "fmt"
+
+const postgresTableExistsQuery = "SELECT COUNT(*) FROM information_schema.tables WHERE table_schema = current_schema() AND table_name = $1"
+
+// postgresBaseline returns the PostgreSQL counterpart of mysqlBaseline.
+// The database has to be created beforehand with "create database mainnet encoding 'UTF8'".
+func postgresBaseline() []string {
+	stmts := []string{
+		`CREATE TABLE addr_asset (
+			id                    bigserial primary key,
+			address               varchar(128) not null,
+			asset_id              varchar(66) not null,
+			balance               numeric(35, 8) not null,
+			transactions          bigint not null,
+			last_transaction_time bigint not null
+		)`,
+		`CREATE INDEX addr_asset_asset_id_balance_index ON addr_asset(asset_id, balance)`,
+		`CREATE UNIQUE INDEX addr_asset_address_asset_id_uindex ON addr_asset(address, asset_id)`,
+		`CREATE TABLE addr_tx (
+			id         bigserial primary key,
+			txid       varchar(66) not null,
+			address    varchar(128) not null,
+			block_time bigint not null,
+			asset_type varchar(16) not null
+		)`,
+		`CREATE UNIQUE INDEX addr_tx_address_asset_type_txid_uindex ON addr_tx(address, asset_type, txid)`,
+		`CREATE INDEX addr_tx_txid ON addr_tx(txid)`,
+		`CREATE INDEX addr_tx_address ON addr_tx(address)`,
+		`CREATE TABLE address (
+			id                    bigserial primary key,
+			address               varchar(128) not null,
+			created_at            bigint not null,
+			last_transaction_time bigint not null,
+			trans_asset           bigint not null,
+			trans_nep5            bigint not null
+		)`,
+		`CREATE UNIQUE INDEX uk_address ON address(address)`,
+		`CREATE TABLE asset (
+			id           bigserial primary key,
+			block_index  bigint not null,
+			block_time   bigint not null,
+			version      bigint not null,
+			asset_id     varchar(66) not null,
+			type         varchar(32) not null,
+			name         varchar(128) not null,
+			amount       numeric(35, 8) not null,
+			available    numeric(35, 8) not null,
+			"precision"  smallint not null,
+			owner        varchar(66) not null,
+			admin        varchar(34) not null,
+			issuer       varchar(66) not null,
+			expiration   bigint not null,
+			frozen       boolean not null,
+			addresses    bigint not null,
+			transactions bigint not null
+		)`,
+		`CREATE INDEX idx_asset_asset_id ON asset(asset_id)`,
+		`CREATE INDEX idx_asset_time ON asset(block_time)`,
+		`CREATE TABLE asset_tx (
+			id       bigserial primary key,
+			address  varchar(34) not null,
+			asset_id varchar(66) not null,
+			txid     varchar(66) not null
+		)`,
+		`CREATE INDEX idx_asset_tx_address_asset_id ON asset_tx(address, asset_id)`,
+		`CREATE UNIQUE INDEX idx_asset_tx_address_asset_id_txid ON asset_tx(address, asset_id, txid)`,
+		`CREATE TABLE block (
+			id                  bigserial primary key,
+			hash                varchar(66) not null,
+			size                integer not null,
+			version             bigint not null,
+			previousblockhash   varchar(66) not null,
+			merkleroot          varchar(66) not null,
+			time                bigint not null,
+			"index"             bigint not null,
+			nonce               varchar(16) not null,
+			nextconsensus       varchar(34) not null,
+			script_invocation   text not null,
+			script_verification text not null,
+			nextblockhash       varchar(66) not null
+		)`,
+		`CREATE INDEX idx_block_hash ON block(hash)`,
+		`CREATE UNIQUE INDEX idx_block_index ON block("index")`,
+		`CREATE INDEX idx_block_time ON block(time)`,
+		`CREATE TABLE counter (
+			id                     bigserial primary key,
+			last_block_index       integer not null,
+			last_tx_pk             bigint not null,
+			last_asset_tx_pk       bigint not null,
+			last_tx_pk_for_nep5    bigint not null,
+			app_log_idx            integer not null,
+			nep5_tx_pk_for_addr_tx bigint not null,
+			last_tx_pk_gas_balance bigint not null,
+			cnt_tx_reg             bigint not null,
+			cnt_tx_miner           bigint not null,
+			cnt_tx_issue           bigint not null,
+			cnt_tx_invocation      bigint not null,
+			cnt_tx_contract        bigint not null,
+			cnt_tx_claim           bigint not null,
+			cnt_tx_publish         bigint not null,
+			cnt_tx_enrollment      bigint not null
+		)`,
+		`CREATE TABLE nep5 (
+			id                bigserial primary key,
+			asset_id          varchar(40) not null,
+			admin_address     varchar(40) not null,
+			name              varchar(128) not null,
+			symbol            varchar(16) not null,
+			decimals          smallint not null,
+			total_supply      numeric(35, 8) not null,
+			txid              varchar(66) not null,
+			block_index       bigint not null,
+			block_time        bigint not null,
+			addresses         bigint not null,
+			holding_addresses bigint not null,
+			transfers         bigint not null,
+			visible           boolean default true not null
+		)`,
+		`CREATE INDEX idx_nep5_txid ON nep5(txid)`,
+		`CREATE TABLE nep5_reg_info (
+			id             bigserial primary key,
+			nep5_id        bigint not null,
+			name           varchar(255) not null,
+			version        varchar(255) not null,
+			author         varchar(255) not null,
+			email          varchar(255) not null,
+			description    varchar(255) not null,
+			need_storage   boolean not null,
+			parameter_list varchar(255) not null,
+			return_type    varchar(255) not null
+		)`,
+		`CREATE INDEX idx_nep5_id ON nep5_reg_info(nep5_id)`,
+		`CREATE TABLE nep5_tx (
+			id          bigserial primary key,
+			txid        varchar(66) not null,
+			asset_id    varchar(40) not null,
+			"from"      varchar(128) not null,
+			"to"        varchar(128) not null,
+			value       double precision not null,
+			block_index bigint not null,
+			block_time  bigint not null
+		)`,
+		`CREATE INDEX idx_nep5_tx_asset_id ON nep5_tx(asset_id)`,
+		`CREATE INDEX idx_nep5_tx_from ON nep5_tx("from")`,
+		`CREATE INDEX idx_nep5_tx_to ON nep5_tx("to")`,
+		`CREATE INDEX idx_nep5_tx_txid ON nep5_tx(txid)`,
+		`CREATE TABLE nep5_migrate (
+			id           bigserial primary key,
+			old_asset_id varchar(40) not null,
+			new_asset_id varchar(40) not null,
+			migrate_txid varchar(66) not null
+		)`,
+		`CREATE TABLE tx (
+			id          bigserial primary key,
+			block_index bigint not null,
+			block_time  bigint not null,
+			txid        varchar(66) not null,
+			size        bigint not null,
+			type        varchar(32) not null,
+			version     bigint not null,
+			sys_fee     numeric(27, 8) not null,
+			net_fee     numeric(27, 8) not null,
+			nonce       bigint not null,
+			script      text not null,
+			gas         numeric(27, 8) not null
+		)`,
+		`CREATE INDEX idx_tx_block_index ON tx(block_index)`,
+		`CREATE INDEX idx_tx_txid ON tx(txid)`,
+		`CREATE INDEX idx_tx_type ON tx(type)`,
+		`CREATE TABLE tx_attr (
+			id      bigserial primary key,
+			txid    varchar(66) not null,
+			"usage" varchar(32) not null,
+			data    text not null
+		)`,
+		`CREATE INDEX idx_tx_attr_txid ON tx_attr(txid)`,
+		`CREATE INDEX idx_tx_attr_usage ON tx_attr("usage")`,
+		`CREATE TABLE tx_claims (
+			id   bigserial primary key,
+			txid varchar(66) not null,
+			vout bigint not null
+		)`,
+		`CREATE INDEX idx_tx_claims_txid ON tx_claims(txid)`,
+		`CREATE TABLE tx_scripts (
+			id           bigserial primary key,
+			txid         varchar(66) not null,
+			invocation   text not null,
+			verification text not null
+		)`,
+		`CREATE INDEX idx_tx_scripts_txid ON tx_scripts(txid)`,
+		`CREATE TABLE tx_vin (
+			id     bigserial primary key,
+			"from" varchar(66) not null,
+			txid   varchar(66) not null,
+			vout   bigint not null
+		)`,
+		`CREATE INDEX idx_tx_vin_from ON tx_vin("from")`,
+		`CREATE INDEX idx_tx_vin_txid ON tx_vin(txid)`,
+		`CREATE TABLE tx_vout (
+			id       bigserial primary key,
+			txid     varchar(66) not null,
+			n        bigint not null,
+			asset_id varchar(66) not null,
+			value    numeric(35, 8) not null,
+			address  varchar(34) not null
+		)`,
+		`CREATE INDEX idx_tx_vout_address ON tx_vout(address)`,
+		`CREATE INDEX idx_tx_vout_asset_id ON tx_vout(asset_id)`,
+		`CREATE INDEX idx_tx_vout_txid ON tx_vout(txid)`,
+		`CREATE TABLE utxo (
+			id         bigserial primary key,
+			address    varchar(34) not null,
+			txid       varchar(66) not null,
+			n          bigint not null,
+			asset_id   varchar(66) not null,
+			value      numeric(35, 8) not null,
+			used_in_tx varchar(66)
+		)`,
+		`CREATE INDEX idx_utxo_address ON utxo(address)`,
+		`CREATE INDEX idx_utxo_asset_id ON utxo(asset_id)`,
+		`CREATE INDEX idx_utxo_txid ON utxo(txid)`,
+		`CREATE INDEX idx_utxo_used_in_tx ON utxo(used_in_tx)`,
+	}
+
+	for _, table := range addrGasBalanceTables() {
+		stmts = append(stmts,
+			fmt.Sprintf(`CREATE TABLE %s (
+			id      bigserial primary key,
+			address varchar(34) not null,
+			date    date not null,
+			balance numeric(35, 8) not null
+		)`, table),
+			fmt.Sprintf("CREATE INDEX idx_%s_address_date ON %s(address, date)", table, table),
+		)
+	}
+
+	return stmts
+}
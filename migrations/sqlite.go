@@ -0,0 +1,476 @@
+package migrations
+
+import (
+	"fmt"
+	"strings"
+)
+
+const sqliteTableExistsQuery = "SELECT COUNT(*) FROM sqlite_master WHERE type = 'table' AND name = ?"
+
+// sqliteBaseline returns the SQLite counterpart of mysqlBaseline.
+func sqliteBaseline() []string {
+	stmts := []string{
+		`CREATE TABLE addr_asset (
+			id                    integer primary key autoincrement,
+			address               text not null,
+			asset_id              text not null,
+			balance               numeric not null,
+			transactions          integer not null,
+			last_transaction_time integer not null
+		)`,
+		`CREATE INDEX addr_asset_asset_id_balance_index ON addr_asset(asset_id, balance)`,
+		`CREATE UNIQUE INDEX addr_asset_address_asset_id_uindex ON addr_asset(address, asset_id)`,
+		`CREATE TABLE addr_tx (
+			id         integer primary key autoincrement,
+			txid       text not null,
+			address    text not null,
+			block_time integer not null,
+			asset_type text not null
+		)`,
+		`CREATE UNIQUE INDEX addr_tx_address_asset_type_txid_uindex ON addr_tx(address, asset_type, txid)`,
+		`CREATE INDEX addr_tx_txid ON addr_tx(txid)`,
+		`CREATE INDEX addr_tx_address ON addr_tx(address)`,
+		`CREATE TABLE address (
+			id                    integer primary key autoincrement,
+			address               text not null,
+			created_at            integer not null,
+			last_transaction_time integer not null,
+			trans_asset           integer not null,
+			trans_nep5            integer not null
+		)`,
+		`CREATE UNIQUE INDEX uk_address ON address(address)`,
+		`CREATE TABLE asset (
+			id           integer primary key autoincrement,
+			block_index  integer not null,
+			block_time   integer not null,
+			version      integer not null,
+			asset_id     text not null,
+			type         text not null,
+			name         text not null,
+			amount       numeric not null,
+			available    numeric not null,
+			"precision"  integer not null,
+			owner        text not null,
+			admin        text not null,
+			issuer       text not null,
+			expiration   integer not null,
+			frozen       integer not null,
+			addresses    integer not null,
+			transactions integer not null
+		)`,
+		`CREATE INDEX idx_asset_asset_id ON asset(asset_id)`,
+		`CREATE INDEX idx_asset_time ON asset(block_time)`,
+		`CREATE TABLE asset_tx (
+			id       integer primary key autoincrement,
+			address  text not null,
+			asset_id text not null,
+			txid     text not null
+		)`,
+		`CREATE INDEX idx_asset_tx_address_asset_id ON asset_tx(address, asset_id)`,
+		`CREATE UNIQUE INDEX idx_asset_tx_address_asset_id_txid ON asset_tx(address, asset_id, txid)`,
+		`CREATE TABLE block (
+			id                  integer primary key autoincrement,
+			hash                text not null,
+			size                integer not null,
+			version             integer not null,
+			previousblockhash   text not null,
+			merkleroot          text not null,
+			time                integer not null,
+			"index"             integer not null,
+			nonce               text not null,
+			nextconsensus       text not null,
+			script_invocation   text not null,
+			script_verification text not null,
+			nextblockhash       text not null
+		)`,
+		`CREATE INDEX idx_block_hash ON block(hash)`,
+		`CREATE UNIQUE INDEX idx_block_index ON block("index")`,
+		`CREATE INDEX idx_block_time ON block(time)`,
+		`CREATE TABLE counter (
+			id                     integer primary key autoincrement,
+			last_block_index       integer not null,
+			last_tx_pk             integer not null,
+			last_asset_tx_pk       integer not null,
+			last_tx_pk_for_nep5    integer not null,
+			app_log_idx            integer not null,
+			nep5_tx_pk_for_addr_tx integer not null,
+			last_tx_pk_gas_balance integer not null,
+			cnt_tx_reg             integer not null,
+			cnt_tx_miner           integer not null,
+			cnt_tx_issue           integer not null,
+			cnt_tx_invocation      integer not null,
+			cnt_tx_contract        integer not null,
+			cnt_tx_claim           integer not null,
+			cnt_tx_publish         integer not null,
+			cnt_tx_enrollment      integer not null
+		)`,
+		`CREATE TABLE nep5 (
+			id                integer primary key autoincrement,
+			asset_id          text not null,
+			admin_address     text not null,
+			name              text not null,
+			symbol            text not null,
+			decimals          integer not null,
+			total_supply      numeric not null,
+			txid              text not null,
+			block_index       integer not null,
+			block_time        integer not null,
+			addresses         integer not null,
+			holding_addresses integer not null,
+			transfers         integer not null,
+			visible           integer default 1 not null
+		)`,
+		`CREATE INDEX idx_nep5_txid ON nep5(txid)`,
+		`CREATE TABLE nep5_reg_info (
+			id             integer primary key autoincrement,
+			nep5_id        integer not null,
+			name           text not null,
+			version        text not null,
+			author         text not null,
+			email          text not null,
+			description    text not null,
+			need_storage   integer not null,
+			parameter_list text not null,
+			return_type    text not null
+		)`,
+		`CREATE INDEX idx_nep5_id ON nep5_reg_info(nep5_id)`,
+		`CREATE TABLE nep5_tx (
+			id          integer primary key autoincrement,
+			txid        text not null,
+			asset_id    text not null,
+			"from"      text not null,
+			"to"        text not null,
+			value       real not null,
+			block_index integer not null,
+			block_time  integer not null
+		)`,
+		`CREATE INDEX idx_nep5_tx_asset_id ON nep5_tx(asset_id)`,
+		`CREATE INDEX idx_nep5_tx_from ON nep5_tx("from")`,
+		`CREATE INDEX idx_nep5_tx_to ON nep5_tx("to")`,
+		`CREATE INDEX idx_nep5_tx_txid ON nep5_tx(txid)`,
+		`CREATE TABLE nep5_migrate (
+			id           integer primary key autoincrement,
+			old_asset_id text not null,
+			new_asset_id text not null,
+			migrate_txid text not null
+		)`,
+		`CREATE TABLE tx (
+			id          integer primary key autoincrement,
+			block_index integer not null,
+			block_time  integer not null,
+			txid        text not null,
+			size        integer not null,
+			type        text not null,
+			version     integer not null,
+			sys_fee     numeric not null,
+			net_fee     numeric not null,
+			nonce       integer not null,
+			script      text not null,
+			gas         numeric not null
+		)`,
+		`CREATE INDEX idx_tx_block_index ON tx(block_index)`,
+		`CREATE INDEX idx_tx_txid ON tx(txid)`,
+		`CREATE INDEX idx_tx_type ON tx(type)`,
+		`CREATE TABLE tx_attr (
+			id      integer primary key autoincrement,
+			txid    text not null,
+			"usage" text not null,
+			data    text not null
+		)`,
+		`CREATE INDEX idx_tx_attr_txid ON tx_attr(txid)`,
+		`CREATE INDEX idx_tx_attr_usage ON tx_attr("usage")`,
+		`CREATE TABLE tx_claims (
+			id   integer primary key autoincrement,
+			txid text not null,
+			vout integer not null
+		)`,
+		`CREATE INDEX idx_tx_claims_txid ON tx_claims(txid)`,
+		`CREATE TABLE tx_scripts (
+			id           integer primary key autoincrement,
+			txid         text not null,
+			invocation   text not null,
+			verification text not null
+		)`,
+		`CREATE INDEX idx_tx_scripts_txid ON tx_scripts(txid)`,
+		`CREATE TABLE tx_vin (
+			id     integer primary key autoincrement,
+			"from" text not null,
+			txid   text not null,
+			vout   integer not null
+		)`,
+		`CREATE INDEX idx_tx_vin_from ON tx_vin("from")`,
+		`CREATE INDEX idx_tx_vin_txid ON tx_vin(txid)`,
+		`CREATE TABLE tx_vout (
+			id       integer primary key autoincrement,
+			txid     text not null,
+			n        integer not null,
+			asset_id text not null,
+			value    numeric not null,
+			address  text not null
+		)`,
+		`CREATE INDEX idx_tx_vout_address ON tx_vout(address)`,
+		`CREATE INDEX idx_tx_vout_asset_id ON tx_vout(asset_id)`,
+		`CREATE INDEX idx_tx_vout_txid ON tx_vout(txid)`,
+		`CREATE TABLE utxo (
+			id         integer primary key autoincrement,
+			address    text not null,
+			txid       text not null,
+			n          integer not null,
+			asset_id   text not null,
+			value      numeric not null,
+			used_in_tx text
+		)`,
+		`CREATE INDEX idx_utxo_address ON utxo(address)`,
+		`CREATE INDEX idx_utxo_asset_id ON utxo(asset_id)`,
+		`CREATE INDEX idx_utxo_txid ON utxo(txid)`,
+		`CREATE INDEX idx_utxo_used_in_tx ON utxo(used_in_tx)`,
+	}
+
+	for _, table := range addrGasBalanceTables() {
+		stmts = append(stmts,
+			fmt.Sprintf(`CREATE TABLE %s (
+			id      integer primary key autoincrement,
+			address text not null,
+			date    date not null,
+			balance numeric not null
+		)`, table),
+			fmt.Sprintf("CREATE INDEX idx_%s_address_date ON %s(address, date)", table, table),
+		)
+	}
+
+	return stmts
+}
+
+// sqliteNep5TxDecimalValue rebuilds nep5_tx with a numeric value column,
+// since SQLite can not change the type of a column in place.
+func sqliteNep5TxDecimalValue() []string {
+	return []string{
+		`CREATE TABLE nep5_tx_new (
+			id          integer primary key autoincrement,
+			txid        text not null,
+			asset_id    text not null,
+			"from"      text not null,
+			"to"        text not null,
+			value       numeric not null,
+			block_index integer not null,
+			block_time  integer not null
+		)`,
+		`INSERT INTO nep5_tx_new (id, txid, asset_id, "from", "to", value, block_index, block_time)
+			SELECT id, txid, asset_id, "from", "to", value, block_index, block_time FROM nep5_tx`,
+		`DROP TABLE nep5_tx`,
+		`ALTER TABLE nep5_tx_new RENAME TO nep5_tx`,
+		`CREATE INDEX idx_nep5_tx_asset_id ON nep5_tx(asset_id)`,
+		`CREATE INDEX idx_nep5_tx_from ON nep5_tx("from")`,
+		`CREATE INDEX idx_nep5_tx_to ON nep5_tx("to")`,
+		`CREATE INDEX idx_nep5_tx_txid ON nep5_tx(txid)`,
+	}
+}
+
+// sqliteTextAmountTables rebuilds every table holding amounts with text amount columns.
+// Integers are kept as they are, floating point values are written with at most 8 decimals.
+func sqliteTextAmountTables() []string {
+	stmts := []string{}
+
+	stmts = append(stmts, sqliteRebuild("addr_asset", `(
+			id                    integer primary key autoincrement,
+			address               text not null,
+			asset_id              text not null,
+			balance               text not null,
+			transactions          integer not null,
+			last_transaction_time integer not null
+		)`,
+		[]string{"id", "address", "asset_id", "balance", "transactions", "last_transaction_time"},
+		[]string{"balance"},
+		`CREATE INDEX addr_asset_asset_id_balance_index ON addr_asset(asset_id, balance)`,
+		`CREATE UNIQUE INDEX addr_asset_address_asset_id_uindex ON addr_asset(address, asset_id)`,
+	)...)
+
+	stmts = append(stmts, sqliteRebuild("asset", `(
+			id           integer primary key autoincrement,
+			block_index  integer not null,
+			block_time   integer not null,
+			version      integer not null,
+			asset_id     text not null,
+			type         text not null,
+			name         text not null,
+			amount       text not null,
+			available    text not null,
+			"precision"  integer not null,
+			owner        text not null,
+			admin        text not null,
+			issuer       text not null,
+			expiration   integer not null,
+			frozen       integer not null,
+			addresses    integer not null,
+			transactions integer not null
+		)`,
+		[]string{"id", "block_index", "block_time", "version", "asset_id", "type", "name", "amount", "available",
+			`"precision"`, "owner", "admin", "issuer", "expiration", "frozen", "addresses", "transactions"},
+		[]string{"amount", "available"},
+		`CREATE INDEX idx_asset_asset_id ON asset(asset_id)`,
+		`CREATE INDEX idx_asset_time ON asset(block_time)`,
+	)...)
+
+	stmts = append(stmts, sqliteRebuild("nep5", `(
+			id                integer primary key autoincrement,
+			asset_id          text not null,
+			admin_address     text not null,
+			name              text not null,
+			symbol            text not null,
+			decimals          integer not null,
+			total_supply      text not null,
+			txid              text not null,
+			block_index       integer not null,
+			block_time        integer not null,
+			addresses         integer not null,
+			holding_addresses integer not null,
+			transfers         integer not null,
+			visible           integer default 1 not null
+		)`,
+		[]string{"id", "asset_id", "admin_address", "name", "symbol", "decimals", "total_supply", "txid",
+			"block_index", "block_time", "addresses", "holding_addresses", "transfers", "visible"},
+		[]string{"total_supply"},
+		`CREATE INDEX idx_nep5_txid ON nep5(txid)`,
+	)...)
+
+	stmts = append(stmts, sqliteRebuild("tx", `(
+			id          integer primary key autoincrement,
+			block_index integer not null,
+			block_time  integer not null,
+			txid        text not null,
+			size        integer not null,
+			type        text not null,
+			version     integer not null,
+			sys_fee     text not null,
+			net_fee     text not null,
+			nonce       integer not null,
+			script      text not null,
+			gas         text not null
+		)`,
+		[]string{"id", "block_index", "block_time", "txid", "size", "type", "version", "sys_fee", "net_fee",
+			"nonce", "script", "gas"},
+		[]string{"sys_fee", "net_fee", "gas"},
+		`CREATE INDEX idx_tx_block_index ON tx(block_index)`,
+		`CREATE INDEX idx_tx_txid ON tx(txid)`,
+		`CREATE INDEX idx_tx_type ON tx(type)`,
+	)...)
+
+	stmts = append(stmts, sqliteRebuild("tx_vout", `(
+			id       integer primary key autoincrement,
+			txid     text not null,
+			n        integer not null,
+			asset_id text not null,
+			value    text not null,
+			address  text not null
+		)`,
+		[]string{"id", "txid", "n", "asset_id", "value", "address"},
+		[]string{"value"},
+		`CREATE INDEX idx_tx_vout_address ON tx_vout(address)`,
+		`CREATE INDEX idx_tx_vout_asset_id ON tx_vout(asset_id)`,
+		`CREATE INDEX idx_tx_vout_txid ON tx_vout(txid)`,
+	)...)
+
+	stmts = append(stmts, sqliteRebuild("utxo", `(
+			id         integer primary key autoincrement,
+			address    text not null,
+			txid       text not null,
+			n          integer not null,
+			asset_id   text not null,
+			value      text not null,
+			used_in_tx text,
+			start_gas  text null,
+			claim_gas  text null
+		)`,
+		[]string{"id", "address", "txid", "n", "asset_id", "value", "used_in_tx", "start_gas", "claim_gas"},
+		[]string{"value", "start_gas", "claim_gas"},
+		`CREATE INDEX idx_utxo_address ON utxo(address)`,
+		`CREATE INDEX idx_utxo_asset_id ON utxo(asset_id)`,
+		`CREATE INDEX idx_utxo_txid ON utxo(txid)`,
+		`CREATE INDEX idx_utxo_used_in_tx ON utxo(used_in_tx)`,
+	)...)
+
+	stmts = append(stmts, sqliteRebuild("nep5_tx", `(
+			id          integer primary key autoincrement,
+			txid        text not null,
+			asset_id    text not null,
+			"from"      text not null,
+			"to"        text not null,
+			value       text not null,
+			block_index integer not null,
+			block_time  integer not null
+		)`,
+		[]string{"id", "txid", "asset_id", `"from"`, `"to"`, "value", "block_index", "block_time"},
+		[]string{"value"},
+		`CREATE INDEX idx_nep5_tx_asset_id ON nep5_tx(asset_id)`,
+		`CREATE INDEX idx_nep5_tx_from ON nep5_tx("from")`,
+		`CREATE INDEX idx_nep5_tx_to ON nep5_tx("to")`,
+		`CREATE INDEX idx_nep5_tx_txid ON nep5_tx(txid)`,
+	)...)
+
+	stmts = append(stmts, sqliteRebuild("block_fee", `(
+			id                 integer primary key autoincrement,
+			block_index        integer not null,
+			sys_fee            text not null,
+			net_fee            text not null,
+			cumulative_sys_fee text not null
+		)`,
+		[]string{"id", "block_index", "sys_fee", "net_fee", "cumulative_sys_fee"},
+		[]string{"sys_fee", "net_fee", "cumulative_sys_fee"},
+		`CREATE UNIQUE INDEX uk_block_fee_block_index ON block_fee(block_index)`,
+	)...)
+
+	stmts = append(stmts, sqliteRebuild("addr_asset_balance", `(
+			id       integer primary key autoincrement,
+			address  text not null,
+			asset_id text not null,
+			date     date not null,
+			balance  text not null
+		)`,
+		[]string{"id", "address", "asset_id", "date", "balance"},
+		[]string{"balance"},
+		`CREATE UNIQUE INDEX uk_addr_asset_balance_address_asset_date ON addr_asset_balance(address, asset_id, date)`,
+	)...)
+
+	stmts = append(stmts, sqliteRebuild("nep5_balance", `(
+			id          integer primary key autoincrement,
+			address     text not null,
+			asset_id    text not null,
+			block_index integer not null,
+			block_time  integer not null,
+			balance     text not null
+		)`,
+		[]string{"id", "address", "asset_id", "block_index", "block_time", "balance"},
+		[]string{"balance"},
+		`CREATE INDEX idx_nep5_balance_address_asset_block ON nep5_balance(address, asset_id, block_index)`,
+		`CREATE INDEX idx_nep5_balance_block_index ON nep5_balance(block_index)`,
+	)...)
+
+	return stmts
+}
+
+// sqliteRebuild copies table into a new one created with the given columns definition,
+// converting amounts to text, then replaces the table and recreates its indexes.
+func sqliteRebuild(table string, definition string, columns []string, amounts []string, indexes ...string) []string {
+	values := make([]string, len(columns))
+	copy(values, columns)
+	for i, column := range columns {
+		for _, a := range amounts {
+			if column == a {
+				values[i] = fmt.Sprintf(
+					"CASE typeof(%s) WHEN 'integer' THEN CAST(%s AS TEXT) "+
+						"WHEN 'real' THEN rtrim(rtrim(printf('%%.8f', %s), '0'), '.') ELSE %s END",
+					a, a, a, a)
+			}
+		}
+	}
+
+	stmts := []string{
+		fmt.Sprintf("CREATE TABLE %s_new %s", table, definition),
+		fmt.Sprintf("INSERT INTO %s_new (%s) SELECT %s FROM %s",
+			table, strings.Join(columns, ", "), strings.Join(values, ", "), table),
+		fmt.Sprintf("DROP TABLE %s", table),
+		fmt.Sprintf("ALTER TABLE %s_new RENAME TO %s", table, table),
+	}
+
+	return append(stmts, indexes...)
+}
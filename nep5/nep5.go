@@ -2,7 +2,7 @@ package nep5
 
 import (
 	"encoding/hex"
-	"math/big"
+	"squirrel/amount"
 	"squirrel/smartcontract"
 )
 
@@ -14,7 +14,7 @@ type Nep5 struct {
 	Name             string
 	Symbol           string
 	Decimals         uint8
-	TotalSupply      *big.Float
+	TotalSupply      amount.Amount
 	TxID             string
 	BlockIndex       uint
 	BlockTime        uint64
@@ -42,7 +42,7 @@ type Transaction struct {
 	AssetID    string
 	From       string
 	To         string
-	Value      *big.Float
+	Value      amount.Amount
 	BlockIndex uint
 	BlockTime  uint64
 }
@@ -53,36 +53,28 @@ type Tx struct {
 	TxID  string
 	From  string
 	To    string
-	Value *big.Float
+	Value amount.Amount
 }
 
-// GetNep5RegInfo extracts op codes from stack,
-// and returns nep5 reg info if stack valid.
-func GetNep5RegInfo(opCodeDataStack *smartcontract.DataStack) ([]byte, *RegInfo, bool) {
-	if len(*opCodeDataStack) < 9 {
-		return nil, nil, false
-	}
-
-	for {
-		if len(*opCodeDataStack) == 9 {
-			break
-		}
-
-		opCodeDataStack.PopData()
-	}
+// nep5Methods are the methods every nep5 contract implements besides transfer and balanceOf.
+var nep5Methods = []string{"totalSupply", "name", "symbol", "decimals"}
 
-	scriptBytes := opCodeDataStack.PopData() // Contract Script.
+// IsNep5Script reports whether the contract script dispatches the methods of nep5.
+func IsNep5Script(script []byte) bool {
+	instrs, _ := smartcontract.Disassemble(script)
+	return smartcontract.PushesConstants(instrs, nep5Methods...)
+}
 
-	regInfo := RegInfo{
-		ParameterList: hex.EncodeToString(opCodeDataStack.PopData()),
-		ReturnType:    hex.EncodeToString(opCodeDataStack.PopData()),
-		NeedStorage:   opCodeDataStack.PopData()[0] == 0x01,
-		Name:          string(opCodeDataStack.PopData()),
-		Version:       string(opCodeDataStack.PopData()),
-		Author:        string(opCodeDataStack.PopData()),
-		Email:         string(opCodeDataStack.PopData()),
-		Description:   string(opCodeDataStack.PopData()),
+// GetNep5RegInfo returns the registration info of the deployed contract.
+func GetNep5RegInfo(contract *smartcontract.Contract) *RegInfo {
+	return &RegInfo{
+		ParameterList: hex.EncodeToString(contract.ParameterList),
+		ReturnType:    hex.EncodeToString([]byte{contract.ReturnType}),
+		NeedStorage:   contract.NeedStorage(),
+		Name:          contract.Name,
+		Version:       contract.Version,
+		Author:        contract.Author,
+		Email:         contract.Email,
+		Description:   contract.Description,
 	}
-
-	return scriptBytes, &regInfo, true
 }
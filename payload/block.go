@@ -0,0 +1,65 @@
+// Package payload decodes NEO2 serialized blocks returned by getblock in non-verbose mode.
+package payload
+
+import (
+	"encoding/hex"
+	"fmt"
+	"squirrel/rpc"
+	"squirrel/util"
+)
+
+// DecodeBlock decodes the serialized block into the structure of verbose getblock responses.
+// Hashes are computed locally, system fees of transactions are computed from fees.
+// Net fees depend on the referenced outputs and are left zero, the block is marked as Decoded.
+func DecodeBlock(data []byte, fees SystemFees) (*rpc.RawBlock, error) {
+	r := newReader(data)
+	b := rpc.RawBlock{Decoded: true}
+
+	b.Version = uint(r.readUint32())
+	b.PreviousBlockHash = r.readHash()
+	b.MerkleRoot = r.readHash()
+	b.Time = uint64(r.readUint32())
+	b.Index = uint(r.readUint32())
+	b.Nonce = fmt.Sprintf("%016x", r.readUint64())
+	b.NextConsensus = r.readScriptHash()
+	headerSize := r.pos
+
+	// Blocks have exactly one witness.
+	if n := r.readByte(); r.err == nil && n != 1 {
+		return nil, fmt.Errorf("block has %d witnesses", n)
+	}
+	b.Script.Invocation = hex.EncodeToString(r.readVarBytes())
+	b.Script.Verification = hex.EncodeToString(r.readVarBytes())
+
+	for i, cnt := 0, r.readCount(); i < cnt; i++ {
+		trans := readTx(r, fees)
+		if r.err != nil {
+			return nil, fmt.Errorf("failed to decode transaction %d of block %d: %v", i, b.Index, r.err)
+		}
+
+		b.Tx = append(b.Tx, trans)
+	}
+
+	if r.err != nil {
+		return nil, fmt.Errorf("failed to decode block: %v", r.err)
+	}
+
+	if r.pos != len(data) {
+		return nil, fmt.Errorf("%d bytes left after block %d", len(data)-r.pos, b.Index)
+	}
+
+	b.Hash = encodeHash(util.Hash256(data[:headerSize]))
+	b.Size = len(data)
+
+	return &b, nil
+}
+
+// DecodeBlockHex is like DecodeBlock but takes the hex string of the serialized block.
+func DecodeBlockHex(str string, fees SystemFees) (*rpc.RawBlock, error) {
+	data, err := hex.DecodeString(str)
+	if err != nil {
+		return nil, err
+	}
+
+	return DecodeBlock(data, fees)
+}
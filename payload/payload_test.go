@@ -0,0 +1,268 @@
+package payload
+
+import (
+	"encoding/hex"
+	"squirrel/amount"
+	"squirrel/asset"
+	"squirrel/rpc"
+	"squirrel/util"
+	"testing"
+)
+
+// genesisBlock is the serialized mainnet block 0.
+const genesisBlock = "000000000000000000000000000000000000000000000000000000000000000000000000f41bc036e39b0d6b0579c851c6fde83af802fa4e57bec0bc3365eae3abf43f8065fc8857000000001dac2b7c0000000059e75d652b5d3827bf04c165bbe9ef95cca4bf55010001510400001dac2b7c00000000400000455b7b226c616e67223a227a682d434e222c226e616d65223a22e5b08fe89a81e882a1227d2c7b226c616e67223a22656e222c226e616d65223a22416e745368617265227d5d0000c16ff28623000000da1745e9b549bd0bfa1a569971c77eba30cd5a4b00000000400001445b7b226c616e67223a227a682d434e222c226e616d65223a22e5b08fe89a81e5b881227d2c7b226c616e67223a22656e222c226e616d65223a22416e74436f696e227d5d0000c16ff286230008009f7fd096d37ed2c0e3f7f0cfc924beef4ffceb680000000001000000019b7cffdaa674beae0f930ebe6085af9093e5fe56b34a5c220ccdcf6efc336fc50000c16ff28623005fa99d93303775fe50ca119c327759313eccfa1c01000151"
+
+func TestDecodeGenesisBlock(t *testing.T) {
+	b, err := DecodeBlockHex(genesisBlock, MainNetSystemFees)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if b.Hash != "0xd42561e3d30e15be6400b6df2f328e02d2bf6354c41dce433bc57687c82144bf" {
+		t.Errorf("block hash = %s", b.Hash)
+	}
+	if b.MerkleRoot != "0x803ff4abe3ea6533bcc0be574efa02f83ae8fdc651c879056b0d9be336c01bf4" {
+		t.Errorf("merkle root = %s", b.MerkleRoot)
+	}
+	if b.Index != 0 || b.Time != 1468595301 || b.Size != 401 || b.Nonce != "000000007c2bac1d" {
+		t.Errorf("block header decoded as %+v", b)
+	}
+	if b.NextConsensus != "APyEx5f4Zm4oCHwFWiSTaph1fPBxZacYVR" || b.Script.Verification != "51" || !b.Decoded {
+		t.Errorf("block header decoded as %+v", b)
+	}
+
+	expected := []struct {
+		txID  string
+		txTyp string
+		size  uint
+	}{
+		{"0xfb5bd72b2d6792d75dc2f1084ffa9e9f70ca85543c717a6b13d9959b452a57d6", "MinerTransaction", 10},
+		{asset.NEOAssetID, "RegisterTransaction", 107},
+		{asset.GASAssetID, "RegisterTransaction", 106},
+		{"0x3631f66024ca6f5b033d7e0809eb993443374830025af904fb51b0334f127cda", "IssueTransaction", 69},
+	}
+
+	if len(b.Tx) != len(expected) {
+		t.Fatalf("%d transactions decoded, expected %d", len(b.Tx), len(expected))
+	}
+
+	for i, e := range expected {
+		trans := b.Tx[i]
+		if trans.TxID != e.txID || trans.Type != e.txTyp || trans.Size != e.size {
+			t.Errorf("tx %d decoded as (%s, %s, %d), expected (%s, %s, %d)", i, trans.TxID, trans.Type, trans.Size, e.txID, e.txTyp, e.size)
+		}
+		if !trans.SysFee.IsZero() {
+			t.Errorf("tx %d sys fee = %s", i, trans.SysFee)
+		}
+	}
+
+	if nonce := b.Tx[0].Nonce; nonce != 2083236893 {
+		t.Errorf("miner nonce = %d", nonce)
+	}
+
+	neo := b.Tx[1].Asset
+	if neo.Type != "GoverningToken" || neo.Name[1].Name != "AntShare" || neo.Owner != "00" || neo.Precision != 0 {
+		t.Errorf("NEO asset decoded as %+v", neo)
+	}
+	if neo.Amount.String() != "100000000.00000000" || neo.Admin != "Abf2qMs1pzQb8kYk9RuxtUb9jtRKJVuBJt" {
+		t.Errorf("NEO asset decoded as %+v", neo)
+	}
+
+	issue := b.Tx[3]
+	if len(issue.Vout) != 1 || issue.Vout[0].Asset != asset.NEOAssetID || issue.Vout[0].Address != "AQVh2pG732YvtNaxEGkQUei3YA4cvo7d2i" {
+		t.Errorf("issue outputs decoded as %+v", issue.Vout)
+	}
+	if len(issue.Scripts) != 1 || issue.Scripts[0].Verification != "51" {
+		t.Errorf("issue witnesses decoded as %+v", issue.Scripts)
+	}
+}
+
+func TestDecodeInvalidBlock(t *testing.T) {
+	data, _ := hex.DecodeString(genesisBlock)
+
+	cases := [][]byte{
+		nil,
+		data[:80],
+		data[:len(data)-1],
+		append(append([]byte{}, data...), 0),
+	}
+
+	for _, c := range cases {
+		if _, err := DecodeBlock(c, MainNetSystemFees); err == nil {
+			t.Errorf("DecodeBlock succeeds on %d bytes", len(c))
+		}
+	}
+}
+
+func TestSystemFee(t *testing.T) {
+	fees := SystemFees{"publishtransaction": 5}
+
+	// Publish transaction version 0 with an empty script.
+	data, _ := hex.DecodeString("d000" + "00" + "00" + "ff" + "0000000000" + "00000000")
+	trans := readTx(newReader(data), fees)
+	if trans.Type != "PublishTransaction" || trans.SysFee.String() != "5.00000000" {
+		t.Errorf("publish transaction decoded as %+v", trans)
+	}
+
+	// Invocation transaction version 1 pays the attached gas.
+	data, _ = hex.DecodeString("d101" + "0151" + "00e1f50500000000" + "00000000")
+	trans = readTx(newReader(data), fees)
+	if trans.Type != "InvocationTransaction" || trans.Script != "51" || trans.SysFee.String() != "1.00000000" {
+		t.Errorf("invocation transaction decoded as %+v", trans)
+	}
+}
+
+func TestVerify(t *testing.T) {
+	decode := func() *rpc.RawBlock {
+		b, err := DecodeBlockHex(genesisBlock, MainNetSystemFees)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return b
+	}
+
+	if err := Verify(decode()); err != nil {
+		t.Fatalf("decoded genesis block: %v", err)
+	}
+
+	// Verbose blocks are checked against their fields.
+	verbose := decode()
+	verbose.Decoded = false
+	if err := Verify(verbose); err != nil {
+		t.Fatalf("verbose genesis block: %v", err)
+	}
+
+	tampered := []func(b *rpc.RawBlock){
+		func(b *rpc.RawBlock) { b.Tx[3].Vout[0].Value = amount.MustParse("1") },
+		func(b *rpc.RawBlock) { b.Tx[0].Nonce++ },
+		func(b *rpc.RawBlock) { b.Tx = b.Tx[:3] },
+		func(b *rpc.RawBlock) { b.Tx[1].TxID = b.Tx[2].TxID },
+		func(b *rpc.RawBlock) { b.Nonce = "000000007c2bac1e" },
+		func(b *rpc.RawBlock) { b.Time++ },
+		func(b *rpc.RawBlock) { b.NextConsensus = "AQVh2pG732YvtNaxEGkQUei3YA4cvo7d2i" },
+	}
+
+	for i, tamper := range tampered {
+		b := decode()
+		b.Decoded = false
+		tamper(b)
+		if err := Verify(b); err == nil {
+			t.Errorf("tampered block %d is verified", i)
+		}
+	}
+}
+
+// Serialized mainnet transactions with the fields returned by getrawtransaction of a node.
+const (
+	// stateTx votes for a validator, it is signed by a single signature.
+	stateTx = "900001482103c089d7122b840a4935234e82e26ae5efd0c2acb627239dc9f207311337b6f2c10a5265676973746572656401010001cb4184f0a96e72656c1fbdd4f75cca567519e909fd43cefcec13d6c6abcb92a1000001e72d286979ee6cb1b7e65dfddfb2e384100b8d148e7758de42e4168b71792c6000b8fb050109000071f9cf7f0ec74ec0b0f28a92b12e1081574c0af00141408780d7b3c0aadc5398153df5e2f1cf159db21b8b0f34d3994d865433f79fafac41683783c48aef510b67660e3157b701b9ca4dd9946a385d578fba7dd26f4849232103c089d7122b840a4935234e82e26ae5efd0c2acb627239dc9f207311337b6f2c1ac"
+	// enrollmentTx registers a validator, it is signed by a single signature.
+	enrollmentTx = "200002ff8ac54687f36bbc31a91b730cc385da8af0b581f2d59d82b5cfef824fd271f60001d3d3b7028d61fea3b7803fda3d7f0a1f7262d38e5e1c8987b0313e0a94574151000001e72d286979ee6cb1b7e65dfddfb2e384100b8d148e7758de42e4168b71792c60005441d11600000050ac4949596f5b62fef7be4d1c3e494e6048ed4a01414079d78189d591097b17657a62240c93595e8233dc81157ea2cd477813f09a11fd72845e6bd97c5a3dda125985ea3d5feca387e9933649a9a671a69ab3f6301df6232102ff8ac54687f36bbc31a91b730cc385da8af0b581f2d59d82b5cfef824fd271f6ac"
+	// contractTx transfers NEO, its witness is left out so it ends with an empty witness list.
+	contractTx = "80000001888da99f8f497fd65c4325786a09511159c279af4e7eb532e9edd628c87cc1ee0000019b7cffdaa674beae0f930ebe6085af9093e5fe56b34a5c220ccdcf6efc336fc50082167010000000a8666b4830229d6a1a9b80f6088059191c122d2b00"
+)
+
+func TestDecodeTransactions(t *testing.T) {
+	cases := []struct {
+		data    string
+		txID    string
+		txType  string
+		size    uint
+		sysFee  string
+		vin     rpc.RawTxVin
+		vout    rpc.RawTxVout
+		scripts int
+	}{
+		{
+			stateTx, "0x8abf5ebdb9a8223b12109513647f45bd3c0a6cf1a6346d56684cff71ba308724", "StateTransaction", 251, "0.00000000",
+			rpc.RawTxVin{TxID: "0xa192cbabc6d613ecfcce43fd09e9197556ca5cf7d4bd1f6c65726ea9f08441cb", Vout: 0},
+			rpc.RawTxVout{N: 0, Asset: asset.GASAssetID, Value: amount.MustParse("99000"), Address: "ASAXGKEmUjpDCQz4tgHQ54yu5RxgvsGpxP"},
+			1,
+		},
+		{
+			enrollmentTx, "0x988832f693785dcbcb8d5a0e9d5d22002adcbfb1eb6bbeebf8c494fff580e147", "EnrollmentTransaction", 235, "1000.00000000",
+			rpc.RawTxVin{TxID: "0x514157940a3e31b087891c5e8ed362721f0a7f3dda3f80b7a3fe618d02b7d3d3", Vout: 0},
+			rpc.RawTxVout{N: 0, Asset: asset.GASAssetID, Value: amount.MustParse("980"), Address: "AP8S6WFD9cJmz6C2MkTBZYqJ5c7fuH71hJ"},
+			1,
+		},
+		{
+			contractTx, "0xbdf6cc3b9af12a7565bda80933a75ee8cef1bc771d0d58effc08e4c8b436da79", "ContractTransaction", 100, "0.00000000",
+			rpc.RawTxVin{TxID: "0xeec17cc828d6ede932b57e4eaf79c2591151096a7825435cd67f498f9fa98d88", Vout: 0},
+			rpc.RawTxVout{N: 0, Asset: asset.NEOAssetID, Value: amount.MustParse("706"), Address: "AX8HrvkgUQn4A1im3PCekWhExxyqQqe2de"},
+			0,
+		},
+	}
+
+	for _, c := range cases {
+		data, _ := hex.DecodeString(c.data)
+		r := newReader(data)
+		trans := readTx(r, MainNetSystemFees)
+		if r.err != nil || r.pos != len(data) {
+			t.Fatalf("%s: read %d of %d bytes: %v", c.txType, r.pos, len(data), r.err)
+		}
+
+		if trans.TxID != c.txID || trans.Type != c.txType || trans.Size != c.size || trans.SysFee.String() != c.sysFee {
+			t.Errorf("%s decoded as (%s, %s, %d, %s)", c.txType, trans.TxID, trans.Type, trans.Size, trans.SysFee)
+		}
+		if len(trans.Vin) != 1 || trans.Vin[0] != c.vin {
+			t.Errorf("%s inputs decoded as %+v", c.txType, trans.Vin)
+		}
+		if len(trans.Vout) != 1 || trans.Vout[0].Asset != c.vout.Asset || trans.Vout[0].Value.Cmp(c.vout.Value) != 0 || trans.Vout[0].Address != c.vout.Address {
+			t.Errorf("%s outputs decoded as %+v", c.txType, trans.Vout)
+		}
+		if len(trans.Scripts) != c.scripts {
+			t.Errorf("%s has %d witnesses, expected %d", c.txType, len(trans.Scripts), c.scripts)
+		}
+
+		// The id is recomputed from the verbose form unless the type can not be encoded.
+		verbose := trans
+		verbose.Unsigned = nil
+		txID, err := TxID(&verbose)
+		switch {
+		case c.txType == "StateTransaction":
+			if err != ErrNotEncodable {
+				t.Errorf("TxID of %s returns error %v", c.txType, err)
+			}
+		case err != nil || txID != c.txID:
+			t.Errorf("TxID of %s = (%s, %v)", c.txType, txID, err)
+		}
+	}
+
+	enrollment := readTx(newReader(mustDecodeHex(enrollmentTx)), MainNetSystemFees)
+	if enrollment.PublicKey != "02ff8ac54687f36bbc31a91b730cc385da8af0b581f2d59d82b5cfef824fd271f6" {
+		t.Errorf("enrollment public key = %s", enrollment.PublicKey)
+	}
+}
+
+// TestDecodeClaimTransaction checks a claim transaction built for the test, it is not taken from a chain.
+func TestDecodeClaimTransaction(t *testing.T) {
+	data := "0200" +
+		"01" + "d6572a459b95d9136b7a713c5485ca709f9efa4f08f1c25dd792672d2bd75bfb" + "0000" +
+		"00" + "00" +
+		"01" + "e72d286979ee6cb1b7e65dfddfb2e384100b8d148e7758de42e4168b71792c60" + "00e1f50500000000" + "5fa99d93303775fe50ca119c327759313eccfa1c" +
+		"00"
+
+	trans := readTx(newReader(mustDecodeHex(data)), MainNetSystemFees)
+	if trans.Type != "ClaimTransaction" || len(trans.Claims) != 1 || len(trans.Vin) != 0 {
+		t.Fatalf("claim transaction decoded as %+v", trans)
+	}
+	if claim := trans.Claims[0]; claim.TxID != "0xfb5bd72b2d6792d75dc2f1084ffa9e9f70ca85543c717a6b13d9959b452a57d6" || claim.Vout != 0 {
+		t.Errorf("claim decoded as %+v", claim)
+	}
+	if vout := trans.Vout[0]; vout.Value.String() != "1.00000000" || vout.Address != "AQVh2pG732YvtNaxEGkQUei3YA4cvo7d2i" {
+		t.Errorf("claim output decoded as %+v", vout)
+	}
+
+	trans.Unsigned = nil
+	if txID, err := TxID(&trans); err != nil || txID != encodeHash(util.Hash256(mustDecodeHex(data[:len(data)-2]))) {
+		t.Errorf("TxID of claim transaction = (%s, %v)", txID, err)
+	}
+}
+
+func mustDecodeHex(s string) []byte {
+	data, err := hex.DecodeString(s)
+	if err != nil {
+		panic(err)
+	}
+	return data
+}
@@ -0,0 +1,166 @@
+package payload
+
+import (
+	"encoding/binary"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"squirrel/util"
+)
+
+// errUnexpectedEnd is returned when the data ends in the middle of a field.
+var errUnexpectedEnd = errors.New("unexpected end of data")
+
+// reader reads little-endian fields of serialized data,
+// the first error is kept and makes all following reads return zero values.
+type reader struct {
+	data []byte
+	pos  int
+	err  error
+}
+
+func newReader(data []byte) *reader {
+	return &reader{data: data}
+}
+
+func (r *reader) fail(err error) {
+	if r.err == nil {
+		r.err = err
+	}
+}
+
+func (r *reader) readBytes(n int) []byte {
+	if r.err != nil {
+		return nil
+	}
+
+	if n < 0 || len(r.data)-r.pos < n {
+		r.fail(errUnexpectedEnd)
+		return nil
+	}
+
+	b := r.data[r.pos : r.pos+n]
+	r.pos += n
+	return b
+}
+
+func (r *reader) readByte() byte {
+	b := r.readBytes(1)
+	if b == nil {
+		return 0
+	}
+
+	return b[0]
+}
+
+func (r *reader) readBool() bool {
+	return r.readByte() != 0
+}
+
+func (r *reader) readUint16() uint16 {
+	b := r.readBytes(2)
+	if b == nil {
+		return 0
+	}
+
+	return binary.LittleEndian.Uint16(b)
+}
+
+func (r *reader) readUint32() uint32 {
+	b := r.readBytes(4)
+	if b == nil {
+		return 0
+	}
+
+	return binary.LittleEndian.Uint32(b)
+}
+
+func (r *reader) readUint64() uint64 {
+	b := r.readBytes(8)
+	if b == nil {
+		return 0
+	}
+
+	return binary.LittleEndian.Uint64(b)
+}
+
+// readVarUint reads a variable length integer which is not greater than max.
+func (r *reader) readVarUint(max uint64) uint64 {
+	var v uint64
+
+	switch prefix := r.readByte(); prefix {
+	case 0xfd:
+		v = uint64(r.readUint16())
+	case 0xfe:
+		v = uint64(r.readUint32())
+	case 0xff:
+		v = r.readUint64()
+	default:
+		v = uint64(prefix)
+	}
+
+	if v > max {
+		r.fail(fmt.Errorf("variable length integer %d exceeds %d", v, max))
+		return 0
+	}
+
+	return v
+}
+
+// readCount reads the length of a list, which can not be longer than the remaining data.
+func (r *reader) readCount() int {
+	return int(r.readVarUint(uint64(len(r.data) - r.pos)))
+}
+
+func (r *reader) readVarBytes() []byte {
+	return r.readBytes(r.readCount())
+}
+
+func (r *reader) readVarString() string {
+	return string(r.readVarBytes())
+}
+
+// readHash reads a 32 bytes hash and returns it in the "0x" prefixed big-endian form used by rpc servers.
+func (r *reader) readHash() string {
+	b := r.readBytes(32)
+	if b == nil {
+		return ""
+	}
+
+	return encodeHash(b)
+}
+
+// readScriptHash reads a 20 bytes script hash and returns its address.
+func (r *reader) readScriptHash() string {
+	return util.GetAddressFromScriptHash(r.readBytes(20))
+}
+
+// readECPoint reads an encoded public key and returns it in compressed hex form,
+// the point at infinity is "00".
+func (r *reader) readECPoint() string {
+	prefix := r.readByte()
+
+	var x []byte
+	switch prefix {
+	case 0x00:
+		return "00"
+	case 0x02, 0x03:
+		x = r.readBytes(32)
+	case 0x04:
+		b := r.readBytes(64)
+		if b == nil {
+			return ""
+		}
+		x = b[:32]
+		prefix = 0x02 | b[63]&1
+	default:
+		r.fail(fmt.Errorf("invalid public key prefix: %#02x", prefix))
+		return ""
+	}
+
+	if x == nil {
+		return ""
+	}
+
+	return hex.EncodeToString(append([]byte{prefix}, x...))
+}
@@ -0,0 +1,278 @@
+package payload
+
+import (
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"squirrel/amount"
+	"squirrel/asset"
+	"squirrel/rpc"
+	"squirrel/util"
+	"strings"
+)
+
+// Transaction type bytes of NEO2.
+const (
+	minerType      = 0x00
+	issueType      = 0x01
+	claimType      = 0x02
+	enrollmentType = 0x20
+	registerType   = 0x40
+	contractType   = 0x80
+	stateType      = 0x90
+	publishType    = 0xd0
+	invocationType = 0xd1
+)
+
+var typeNames = map[byte]string{
+	minerType:      "MinerTransaction",
+	issueType:      "IssueTransaction",
+	claimType:      "ClaimTransaction",
+	enrollmentType: "EnrollmentTransaction",
+	registerType:   "RegisterTransaction",
+	contractType:   "ContractTransaction",
+	stateType:      "StateTransaction",
+	publishType:    "PublishTransaction",
+	invocationType: "InvocationTransaction",
+}
+
+var assetTypeNames = map[byte]string{
+	0x00: "GoverningToken",
+	0x01: "UtilityToken",
+	0x08: "Currency",
+	0x40: "CreditFlag",
+	0x80: "DutyFlag",
+	0x90: "Share",
+	0x98: "Invoice",
+	0x60: "Token",
+}
+
+// SystemFees are the system fees in GAS of transaction types, keyed by case-insensitive type names.
+// Types not listed are free, except InvocationTransaction which pays the gas it attaches.
+type SystemFees map[string]int64
+
+func (fees SystemFees) get(typeName string) int64 {
+	if fee, ok := fees[typeName]; ok {
+		return fee
+	}
+
+	// Keys of config files are lower cased.
+	for name, fee := range fees {
+		if strings.EqualFold(name, typeName) {
+			return fee
+		}
+	}
+
+	return 0
+}
+
+// MainNetSystemFees are the system fees of NEO2 mainnet, other networks may configure their own.
+var MainNetSystemFees = SystemFees{
+	"EnrollmentTransaction": 1000,
+	"IssueTransaction":      500,
+	"PublishTransaction":    500,
+	"RegisterTransaction":   10000,
+}
+
+func readTx(r *reader, fees SystemFees) rpc.RawTx {
+	start := r.pos
+	txType := r.readByte()
+
+	name, ok := typeNames[txType]
+	if !ok {
+		r.fail(fmt.Errorf("unknown transaction type: %#02x", txType))
+		return rpc.RawTx{}
+	}
+
+	trans := rpc.RawTx{
+		Type:    name,
+		Version: uint(r.readByte()),
+		SysFee:  amount.NewFromInt64(0, 8),
+		NetFee:  amount.NewFromInt64(0, 8),
+		Gas:     amount.NewFromInt64(0, 8),
+	}
+
+	readExclusiveData(r, txType, &trans)
+	readAttributes(r, &trans)
+
+	for i, cnt := 0, r.readCount(); i < cnt; i++ {
+		txID := r.readHash()
+		trans.Vin = append(trans.Vin, rpc.RawTxVin{TxID: txID, Vout: r.readUint16()})
+	}
+
+	for i, cnt := 0, r.readCount(); i < cnt; i++ {
+		vout := rpc.RawTxVout{N: uint16(i), Asset: r.readHash()}
+		vout.Value = amount.NewFromInt64(int64(r.readUint64()), 8)
+		vout.Address = r.readScriptHash()
+		trans.Vout = append(trans.Vout, vout)
+	}
+
+	unsigned := r.pos
+	trans.Scripts = readWitnesses(r)
+	if r.err != nil {
+		return rpc.RawTx{}
+	}
+
+	trans.Unsigned = r.data[start:unsigned]
+	trans.TxID = encodeHash(util.Hash256(trans.Unsigned))
+	trans.Size = uint(r.pos - start)
+	trans.SysFee = systemFee(txType, &trans, fees)
+
+	return trans
+}
+
+func readExclusiveData(r *reader, txType byte, trans *rpc.RawTx) {
+	switch txType {
+	case minerType:
+		trans.Nonce = int64(r.readUint32())
+	case claimType:
+		for i, cnt := 0, r.readCount(); i < cnt; i++ {
+			txID := r.readHash()
+			trans.Claims = append(trans.Claims, rpc.RawTxClaim{TxID: txID, Vout: r.readUint16()})
+		}
+	case enrollmentType:
+		trans.PublicKey = r.readECPoint()
+	case registerType:
+		trans.Asset = readAsset(r)
+	case stateType:
+		for i, cnt := 0, r.readCount(); i < cnt; i++ {
+			r.readByte()
+			r.readVarBytes()
+			r.readVarString()
+			r.readVarBytes()
+		}
+	case publishType:
+		trans.Script = hex.EncodeToString(r.readVarBytes())
+		r.readVarBytes()
+		r.readByte()
+		if trans.Version >= 1 {
+			r.readBool()
+		}
+		for i := 0; i < 5; i++ {
+			r.readVarString()
+		}
+	case invocationType:
+		trans.Script = hex.EncodeToString(r.readVarBytes())
+		if trans.Version >= 1 {
+			trans.Gas = amount.NewFromInt64(int64(r.readUint64()), 8)
+		}
+	}
+}
+
+func readAsset(r *reader) *rpc.RawTxAsset {
+	assetType := r.readByte()
+	typeName, ok := assetTypeNames[assetType]
+	if !ok {
+		r.fail(fmt.Errorf("unknown asset type: %#02x", assetType))
+		return nil
+	}
+
+	a := rpc.RawTxAsset{Type: typeName}
+
+	// Names are JSON arrays of localized names, a plain name is kept as it is.
+	name := r.readVarString()
+	if err := json.Unmarshal([]byte(name), &a.Name); err != nil {
+		a.Name = []rpc.RawTxAssetName{{Name: name}}
+	}
+
+	a.Amount = amount.NewFromInt64(int64(r.readUint64()), 8)
+	a.Precision = r.readByte()
+	a.Owner = r.readECPoint()
+	a.Admin = r.readScriptHash()
+
+	return &a
+}
+
+var attrUsageNames = map[byte]string{
+	0x00: "ContractHash",
+	0x02: "ECDH02",
+	0x03: "ECDH03",
+	0x20: "Script",
+	0x30: "Vote",
+	0x81: "DescriptionUrl",
+	0x90: "Description",
+}
+
+func attrUsageName(usage byte) (string, bool) {
+	switch {
+	case usage >= 0xa1 && usage <= 0xaf:
+		return fmt.Sprintf("Hash%d", usage-0xa0), true
+	case usage == 0xf0:
+		return "Remark", true
+	case usage > 0xf0:
+		return fmt.Sprintf("Remark%d", usage-0xf0), true
+	}
+
+	name, ok := attrUsageNames[usage]
+	return name, ok
+}
+
+func readAttributes(r *reader, trans *rpc.RawTx) {
+	for i, cnt := 0, r.readCount(); i < cnt; i++ {
+		usage := r.readByte()
+		name, ok := attrUsageName(usage)
+		if !ok {
+			r.fail(fmt.Errorf("unknown transaction attribute usage: %#02x", usage))
+			return
+		}
+
+		var data []byte
+		switch {
+		case usage == 0x00 || usage == 0x30 || usage >= 0xa1 && usage <= 0xaf:
+			data = r.readBytes(32)
+		case usage == 0x02 || usage == 0x03:
+			data = append([]byte{usage}, r.readBytes(32)...)
+		case usage == 0x20:
+			data = r.readBytes(20)
+		case usage == 0x81:
+			data = r.readBytes(int(r.readByte()))
+		default:
+			data = r.readVarBytes()
+		}
+
+		trans.Attributes = append(trans.Attributes, rpc.RawTxAttribute{Usage: name, Data: hex.EncodeToString(data)})
+	}
+}
+
+func readWitnesses(r *reader) []rpc.RawTxScript {
+	scripts := []rpc.RawTxScript{}
+
+	for i, cnt := 0, r.readCount(); i < cnt; i++ {
+		invocation := r.readVarBytes()
+		verification := r.readVarBytes()
+		scripts = append(scripts, rpc.RawTxScript{
+			Invocation:   hex.EncodeToString(invocation),
+			Verification: hex.EncodeToString(verification),
+		})
+	}
+
+	return scripts
+}
+
+// systemFee returns the system fee of the transaction like NEO2 nodes do.
+func systemFee(txType byte, trans *rpc.RawTx, fees SystemFees) amount.Amount {
+	switch txType {
+	case invocationType:
+		return trans.Gas
+	case registerType:
+		if trans.Asset.Type == "GoverningToken" || trans.Asset.Type == "UtilityToken" {
+			return amount.NewFromInt64(0, 8)
+		}
+	case issueType:
+		if trans.Version >= 1 || issuesSystemAssets(trans) {
+			return amount.NewFromInt64(0, 8)
+		}
+	}
+
+	return amount.NewFromInt64(fees.get(trans.Type)*100000000, 8)
+}
+
+func issuesSystemAssets(trans *rpc.RawTx) bool {
+	for _, vout := range trans.Vout {
+		if vout.Asset != asset.NEOAssetID && vout.Asset != asset.GASAssetID {
+			return false
+		}
+	}
+
+	return true
+}
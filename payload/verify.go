@@ -0,0 +1,233 @@
+package payload
+
+import (
+	"errors"
+	"fmt"
+	"squirrel/rpc"
+	"squirrel/util"
+	"strconv"
+)
+
+// ErrNotEncodable is returned for transactions whose serialized form
+// can not be rebuilt from verbose rpc responses.
+var ErrNotEncodable = errors.New("transaction can not be encoded from its verbose form")
+
+var (
+	typeBytes  = make(map[string]byte)
+	attrUsages = make(map[string]byte)
+)
+
+func init() {
+	for b, name := range typeNames {
+		typeBytes[name] = b
+	}
+
+	for usage := 0; usage <= 0xff; usage++ {
+		if name, ok := attrUsageName(byte(usage)); ok {
+			attrUsages[name] = byte(usage)
+		}
+	}
+}
+
+// Verify checks that transaction ids, the merkle root and the hash of the block are consistent with its content.
+// Register, publish and state transactions of verbose blocks lack fields of their serialized form,
+// their ids are only covered by the merkle root.
+func Verify(b *rpc.RawBlock) error {
+	hashes := make([][]byte, len(b.Tx))
+
+	for i := range b.Tx {
+		trans := &b.Tx[i]
+
+		// Hashes of decoded blocks are computed locally.
+		if !b.Decoded {
+			txID, err := TxID(trans)
+			if err != nil && err != ErrNotEncodable {
+				return fmt.Errorf("transaction %s: %v", trans.TxID, err)
+			}
+			if err == nil && txID != trans.TxID {
+				return fmt.Errorf("transaction id %s does not match its content %s", trans.TxID, txID)
+			}
+		}
+
+		hash, err := decodeHash(trans.TxID)
+		if err != nil {
+			return fmt.Errorf("transaction %d: %v", i, err)
+		}
+		hashes[i] = hash
+	}
+
+	if root := encodeHash(MerkleRoot(hashes)); root != b.MerkleRoot {
+		return fmt.Errorf("merkle root %s does not match transactions %s", b.MerkleRoot, root)
+	}
+
+	if b.Decoded {
+		return nil
+	}
+
+	hash, err := BlockHash(b)
+	if err != nil {
+		return err
+	}
+
+	if hash != b.Hash {
+		return fmt.Errorf("block hash %s does not match its header %s", b.Hash, hash)
+	}
+
+	return nil
+}
+
+// MerkleRoot returns the merkle root of serialized transaction hashes,
+// the last hash of a level with odd number of hashes is paired with itself.
+func MerkleRoot(hashes [][]byte) []byte {
+	if len(hashes) == 0 {
+		return make([]byte, 32)
+	}
+
+	level := hashes
+	for len(level) > 1 {
+		next := make([][]byte, 0, (len(level)+1)/2)
+		for i := 0; i < len(level); i += 2 {
+			right := level[i]
+			if i+1 < len(level) {
+				right = level[i+1]
+			}
+
+			pair := append(append([]byte{}, level[i]...), right...)
+			next = append(next, util.Hash256(pair))
+		}
+		level = next
+	}
+
+	return level[0]
+}
+
+// BlockHash returns the hash of the block header.
+func BlockHash(b *rpc.RawBlock) (string, error) {
+	nonce, err := strconv.ParseUint(b.Nonce, 16, 64)
+	if err != nil {
+		return "", fmt.Errorf("invalid block nonce %q", b.Nonce)
+	}
+
+	w := writer{}
+	w.writeUint32(uint32(b.Version))
+	w.writeHash(b.PreviousBlockHash)
+	w.writeHash(b.MerkleRoot)
+	w.writeUint32(uint32(b.Time))
+	w.writeUint32(uint32(b.Index))
+	w.writeUint64(nonce)
+	w.writeScriptHash(b.NextConsensus)
+
+	if w.err != nil {
+		return "", w.err
+	}
+
+	return encodeHash(util.Hash256(w.buf.Bytes())), nil
+}
+
+// TxID returns the id of the transaction computed from its content.
+func TxID(trans *rpc.RawTx) (string, error) {
+	data, err := encodeUnsigned(trans)
+	if err != nil {
+		return "", err
+	}
+
+	return encodeHash(util.Hash256(data)), nil
+}
+
+// SignedData returns the serialized transaction without witnesses, which is signed by witnesses.
+func SignedData(trans *rpc.RawTx) ([]byte, error) {
+	if trans.Unsigned != nil {
+		return trans.Unsigned, nil
+	}
+
+	return encodeUnsigned(trans)
+}
+
+func encodeUnsigned(trans *rpc.RawTx) ([]byte, error) {
+	txType, ok := typeBytes[trans.Type]
+	if !ok {
+		return nil, fmt.Errorf("unknown transaction type %q", trans.Type)
+	}
+
+	w := writer{}
+	w.writeByte(txType)
+	w.writeByte(byte(trans.Version))
+
+	switch txType {
+	case registerType, publishType, stateType:
+		return nil, ErrNotEncodable
+	case minerType:
+		w.writeUint32(uint32(trans.Nonce))
+	case claimType:
+		w.writeVarUint(uint64(len(trans.Claims)))
+		for _, claim := range trans.Claims {
+			w.writeHash(claim.TxID)
+			w.writeUint16(claim.Vout)
+		}
+	case enrollmentType:
+		w.writeHex(trans.PublicKey, 33)
+	case invocationType:
+		w.writeVarHex(trans.Script)
+		if trans.Version >= 1 {
+			w.writeFixed8(trans.Gas)
+		}
+	}
+
+	writeAttributes(&w, trans.Attributes)
+
+	w.writeVarUint(uint64(len(trans.Vin)))
+	for _, vin := range trans.Vin {
+		w.writeHash(vin.TxID)
+		w.writeUint16(vin.Vout)
+	}
+
+	w.writeVarUint(uint64(len(trans.Vout)))
+	for _, vout := range trans.Vout {
+		w.writeHash(vout.Asset)
+		w.writeFixed8(vout.Value)
+		w.writeScriptHash(vout.Address)
+	}
+
+	if w.err != nil {
+		return nil, w.err
+	}
+
+	return w.buf.Bytes(), nil
+}
+
+func writeAttributes(w *writer, attrs []rpc.RawTxAttribute) {
+	w.writeVarUint(uint64(len(attrs)))
+
+	for _, attr := range attrs {
+		usage, ok := attrUsages[attr.Usage]
+		if !ok {
+			w.fail(fmt.Errorf("unknown transaction attribute usage %q", attr.Usage))
+			return
+		}
+
+		w.writeByte(usage)
+
+		switch {
+		case usage == 0x00 || usage == 0x30 || usage >= 0xa1 && usage <= 0xaf:
+			w.writeHex(attr.Data, 32)
+		case usage == 0x02 || usage == 0x03:
+			// Data of ECDH attributes starts with the usage byte.
+			if len(attr.Data) != 66 {
+				w.fail(fmt.Errorf("invalid %s attribute data %q", attr.Usage, attr.Data))
+				return
+			}
+			w.writeHex(attr.Data[2:], 32)
+		case usage == 0x20:
+			w.writeHex(attr.Data, 20)
+		case usage == 0x81:
+			if len(attr.Data) > 0xff*2 {
+				w.fail(fmt.Errorf("%s attribute is too long", attr.Usage))
+				return
+			}
+			w.writeByte(byte(len(attr.Data) / 2))
+			w.writeHex(attr.Data, -1)
+		default:
+			w.writeVarHex(attr.Data)
+		}
+	}
+}
@@ -0,0 +1,162 @@
+package payload
+
+import (
+	"bytes"
+	"encoding/binary"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"squirrel/amount"
+	"squirrel/util"
+	"strings"
+)
+
+// writer writes little-endian fields of serialized data,
+// the first error is kept and makes all following writes no-ops.
+type writer struct {
+	buf bytes.Buffer
+	err error
+}
+
+func (w *writer) fail(err error) {
+	if w.err == nil {
+		w.err = err
+	}
+}
+
+func (w *writer) writeBytes(b []byte) {
+	if w.err == nil {
+		w.buf.Write(b)
+	}
+}
+
+func (w *writer) writeByte(b byte) {
+	w.writeBytes([]byte{b})
+}
+
+func (w *writer) writeUint16(v uint16) {
+	b := make([]byte, 2)
+	binary.LittleEndian.PutUint16(b, v)
+	w.writeBytes(b)
+}
+
+func (w *writer) writeUint32(v uint32) {
+	b := make([]byte, 4)
+	binary.LittleEndian.PutUint32(b, v)
+	w.writeBytes(b)
+}
+
+func (w *writer) writeUint64(v uint64) {
+	b := make([]byte, 8)
+	binary.LittleEndian.PutUint64(b, v)
+	w.writeBytes(b)
+}
+
+func (w *writer) writeVarUint(v uint64) {
+	switch {
+	case v < 0xfd:
+		w.writeByte(byte(v))
+	case v <= 0xffff:
+		w.writeByte(0xfd)
+		w.writeUint16(uint16(v))
+	case v <= 0xffffffff:
+		w.writeByte(0xfe)
+		w.writeUint32(uint32(v))
+	default:
+		w.writeByte(0xff)
+		w.writeUint64(v)
+	}
+}
+
+func (w *writer) writeVarBytes(b []byte) {
+	w.writeVarUint(uint64(len(b)))
+	w.writeBytes(b)
+}
+
+// writeHex writes the hex string, its length in bytes must be size unless size is negative.
+func (w *writer) writeHex(str string, size int) {
+	b, err := hex.DecodeString(str)
+	if err != nil {
+		w.fail(err)
+		return
+	}
+
+	if size >= 0 && len(b) != size {
+		w.fail(fmt.Errorf("%q is not %d bytes", str, size))
+		return
+	}
+
+	w.writeBytes(b)
+}
+
+// writeVarHex writes the hex string as variable length bytes.
+func (w *writer) writeVarHex(str string) {
+	b, err := hex.DecodeString(str)
+	if err != nil {
+		w.fail(err)
+		return
+	}
+
+	w.writeVarBytes(b)
+}
+
+// writeHash writes a "0x" prefixed big-endian hash in its serialized form.
+func (w *writer) writeHash(hash string) {
+	b, err := decodeHash(hash)
+	if err != nil {
+		w.fail(err)
+		return
+	}
+
+	w.writeBytes(b)
+}
+
+// writeScriptHash writes the script hash of the address.
+func (w *writer) writeScriptHash(address string) {
+	if !util.AddressValid(address) {
+		w.fail(fmt.Errorf("invalid address %q", address))
+		return
+	}
+
+	b, err := util.DecodeBase58(address)
+	if err != nil || len(b) != 25 {
+		w.fail(fmt.Errorf("invalid address %q", address))
+		return
+	}
+
+	w.writeBytes(b[1:21])
+}
+
+// writeFixed8 writes the amount as base units of 8 decimals.
+func (w *writer) writeFixed8(a amount.Amount) {
+	units := a.Rescale(8)
+	if units.Cmp(a) != 0 || !units.Units().IsInt64() {
+		w.fail(fmt.Errorf("amount %s is not a fixed8 number", a))
+		return
+	}
+
+	w.writeUint64(uint64(units.Units().Int64()))
+}
+
+// decodeHash returns the serialized form of a "0x" prefixed big-endian hash.
+func decodeHash(hash string) ([]byte, error) {
+	if !strings.HasPrefix(hash, "0x") {
+		return nil, fmt.Errorf("invalid hash %q", hash)
+	}
+
+	b, err := hex.DecodeString(hash[2:])
+	if err != nil {
+		return nil, err
+	}
+
+	if len(b) != 32 {
+		return nil, errors.New("hash is not 32 bytes")
+	}
+
+	return util.ReverseBytes(b), nil
+}
+
+// encodeHash returns the "0x" prefixed big-endian form of a serialized hash.
+func encodeHash(b []byte) string {
+	return "0x" + hex.EncodeToString(util.ReverseBytes(b))
+}
@@ -0,0 +1,172 @@
+package rpc
+
+import (
+	"encoding/json"
+	"fmt"
+	"squirrel/log"
+	"sync"
+	"time"
+)
+
+// invalidRequestCode is the JSON-RPC error code of nodes which do not accept batches.
+const invalidRequestCode = -32600
+
+var (
+	// noBatch records servers which rejected batch requests,
+	// calls to them are sent one by one.
+	noBatch     = make(map[string]bool)
+	noBatchLock sync.Mutex
+)
+
+// batchCall sends all requests of the same method in one batch to a server whose height is at least minHeight,
+// the result of reqs[i] is decoded into targets[i] from the server sources[i] and errs[i] is not nil if the i-th call failed.
+// Requests are sent one by one if no server is high enough, the server rejects batches
+// or its response is not a batch.
+func batchCall(minHeight int, reqs []request, targets []interface{}) (errs []error, sources []string) {
+	errs = make([]error, len(reqs))
+	sources = make([]string, len(reqs))
+	if len(reqs) == 0 {
+		return errs, sources
+	}
+
+	body, err := json.Marshal(reqs)
+	if err != nil {
+		panic(err)
+	}
+
+	var (
+		url      string
+		respBody []byte
+	)
+
+	for {
+		var ok bool
+		url, ok = getServer(minHeight, reqs[0].Method)
+		if !ok || batchRejected(url) {
+			// Single calls wait for servers or return nil blocks as usual.
+			return callEach(minHeight, reqs, targets)
+		}
+
+		respBody, err = postTo(url, "batch", body)
+		if err == nil {
+			break
+		}
+
+		time.Sleep(50 * time.Millisecond)
+	}
+
+	items := []json.RawMessage{}
+	if err := json.Unmarshal(respBody, &items); err != nil {
+		// Only an explicit invalid request error means batches are not supported,
+		// anything else like an error page of a proxy is a failure of this call.
+		header := responseHeader{}
+		if json.Unmarshal(respBody, &header) == nil && header.Error != nil && header.Error.Code == invalidRequestCode {
+			log.Printf("Rpc server %s rejects batch requests, fall back to single calls\n", url)
+			rejectBatch(url)
+		} else {
+			callErrors.WithLabelValues("batch", url, "decode").Inc()
+			log.Error.Printf("Invalid batch response from %s: %v\n", url, err)
+			serverUnavailable(url)
+		}
+
+		return callEach(minHeight, reqs, targets)
+	}
+
+	indexes := make(map[int64]int, len(reqs))
+	for i, req := range reqs {
+		indexes[req.ID] = i
+	}
+
+	answered := make([]bool, len(reqs))
+	for _, raw := range items {
+		if err := decodeBatchItem(raw, indexes, targets, errs, answered); err != nil {
+			callErrors.WithLabelValues("batch", url, "decode").Inc()
+			log.Error.Printf("Invalid batch response item from %s: %v\n", url, err)
+		}
+	}
+
+	for i, req := range reqs {
+		sources[i] = url
+		if !answered[i] {
+			errs[i] = fmt.Errorf("no response of %s request %d from %s", req.Method, req.ID, url)
+		}
+	}
+
+	return errs, sources
+}
+
+// decodeBatchItem decodes one response of the batch into the target of its request id.
+func decodeBatchItem(raw json.RawMessage, indexes map[int64]int, targets []interface{}, errs []error, answered []bool) error {
+	header := responseHeader{}
+	if err := json.Unmarshal(raw, &header); err != nil {
+		return err
+	}
+
+	if header.ID == nil {
+		return fmt.Errorf("response without id")
+	}
+
+	i, ok := indexes[*header.ID]
+	if !ok || answered[i] {
+		return fmt.Errorf("unexpected response id %d", *header.ID)
+	}
+	answered[i] = true
+
+	if header.Error != nil {
+		errs[i] = header.Error
+		return nil
+	}
+
+	if err := json.Unmarshal(raw, targets[i]); err != nil {
+		errs[i] = err
+		return err
+	}
+
+	return nil
+}
+
+// callEach sends the requests of a batch one by one.
+func callEach(minHeight int, reqs []request, targets []interface{}) ([]error, []string) {
+	errs := make([]error, len(reqs))
+	sources := make([]string, len(reqs))
+
+	for i, req := range reqs {
+		height := minHeight
+		if req.Method == "getblock" && len(req.Params) > 0 {
+			// Blocks requested by hash keep the height of the batch.
+			if index, ok := req.Params[0].(int); ok {
+				height = index
+			}
+		}
+
+		url, respBody := post(height, req.Method, req.body())
+		sources[i] = url
+		if respBody == nil {
+			errs[i] = fmt.Errorf("no server has block %d", height)
+			continue
+		}
+
+		if err := req.check(respBody); err != nil {
+			errs[i] = err
+			continue
+		}
+
+		errs[i] = json.Unmarshal(respBody, targets[i])
+	}
+
+	return errs, sources
+}
+
+func batchRejected(url string) bool {
+	noBatchLock.Lock()
+	defer noBatchLock.Unlock()
+
+	return noBatch[url]
+}
+
+func rejectBatch(url string) {
+	noBatchLock.Lock()
+	defer noBatchLock.Unlock()
+
+	noBatch[url] = true
+}
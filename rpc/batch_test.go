@@ -0,0 +1,185 @@
+package rpc
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"squirrel/log"
+	"strings"
+	"testing"
+)
+
+// newHashServer answers getblockhash calls, block 2 is unknown.
+// Batches are rejected like old nodes do if rejectBatch is set.
+func newHashServer(rejectBatch bool) *httptest.Server {
+	answer := func(req request) map[string]interface{} {
+		resp := map[string]interface{}{"jsonrpc": "2.0", "id": req.ID}
+		index := int(req.Params[0].(float64))
+		if index == 2 {
+			resp["error"] = RPCError{Code: -100, Message: "Unknown block"}
+		} else {
+			resp["result"] = fmt.Sprintf("hash%d", index)
+		}
+		return resp
+	}
+
+	return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := ioutil.ReadAll(r.Body)
+
+		if body[0] != '[' {
+			req := request{}
+			json.Unmarshal(body, &req)
+			json.NewEncoder(w).Encode(answer(req))
+			return
+		}
+
+		if rejectBatch {
+			json.NewEncoder(w).Encode(map[string]interface{}{
+				"jsonrpc": "2.0",
+				"id":      nil,
+				"error":   RPCError{Code: -32600, Message: "Invalid Request"},
+			})
+			return
+		}
+
+		reqs := []request{}
+		json.Unmarshal(body, &reqs)
+
+		// Responses of a batch may be returned in any order.
+		resps := []map[string]interface{}{}
+		for i := len(reqs) - 1; i >= 0; i-- {
+			resps = append(resps, answer(reqs[i]))
+		}
+		json.NewEncoder(w).Encode(resps)
+	}))
+}
+
+func TestBatchCall(t *testing.T) {
+	log.Init()
+	defer os.Remove("error.log")
+
+	for _, reject := range []bool{false, true} {
+		s := newHashServer(reject)
+
+		pool.setHeights(map[string]int{s.URL: 10})
+
+		reqs := make([]request, 4)
+		responses := make([]BlockHashResponse, len(reqs))
+		targets := make([]interface{}, len(reqs))
+		for i := range reqs {
+			reqs[i] = newRequest("getblockhash", i)
+			targets[i] = &responses[i]
+		}
+
+		errs, _ := batchCall(0, reqs, targets)
+		for i := range reqs {
+			if i == 2 {
+				if _, ok := errs[i].(*RPCError); !ok {
+					t.Errorf("reject=%v: error of block 2 is %v, expected rpc error", reject, errs[i])
+				}
+				continue
+			}
+
+			if errs[i] != nil || responses[i].Result != fmt.Sprintf("hash%d", i) {
+				t.Errorf("reject=%v: block %d returns (%s, %v)", reject, i, responses[i].Result, errs[i])
+			}
+		}
+
+		if batchRejected(s.URL) != reject {
+			t.Errorf("reject=%v: server is recorded as rejecting batches: %v", reject, batchRejected(s.URL))
+		}
+
+		s.Close()
+	}
+}
+
+func TestBatchCallBadGateway(t *testing.T) {
+	log.Init()
+	defer os.Remove("error.log")
+
+	hashes := newHashServer(false)
+	defer hashes.Close()
+
+	// A proxy in front of the node fails the batch with an error page.
+	s := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := ioutil.ReadAll(r.Body)
+		if body[0] == '[' {
+			w.WriteHeader(http.StatusBadGateway)
+			w.Write([]byte("<html><body>502 Bad Gateway</body></html>"))
+			return
+		}
+
+		resp, err := http.Post(hashes.URL, "application/json", bytes.NewReader(body))
+		if err != nil {
+			t.Error(err)
+			return
+		}
+		defer resp.Body.Close()
+		io.Copy(w, resp.Body)
+	}))
+	defer s.Close()
+
+	pool.setHeights(map[string]int{s.URL: 10})
+
+	reqs := []request{newRequest("getblockhash", 0), newRequest("getblockhash", 1)}
+	responses := make([]BlockHashResponse, len(reqs))
+	targets := []interface{}{&responses[0], &responses[1]}
+
+	errs, _ := batchCall(0, reqs, targets)
+	for i := range reqs {
+		if errs[i] != nil || responses[i].Result != fmt.Sprintf("hash%d", i) {
+			t.Errorf("block %d returns (%s, %v)", i, responses[i].Result, errs[i])
+		}
+	}
+
+	if batchRejected(s.URL) {
+		t.Error("server is recorded as rejecting batches after a bad gateway response")
+	}
+}
+
+func TestCallEachBlockParams(t *testing.T) {
+	log.Init()
+	defer os.Remove("error.log")
+
+	// The server echoes the first parameter.
+	s := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		req := request{}
+		json.NewDecoder(r.Body).Decode(&req)
+		json.NewEncoder(w).Encode(map[string]interface{}{"jsonrpc": "2.0", "id": req.ID, "result": req.Params[0]})
+	}))
+	defer s.Close()
+
+	pool.setHeights(map[string]int{s.URL: 10})
+
+	// Blocks may be requested by hash or by an index decoded from json.
+	reqs := []request{
+		newRequest("getblock", "0x"+strings.Repeat("ab", 32), 1),
+		newRequest("getblock", float64(3), 1),
+		newRequest("getblock", 5, 1),
+	}
+	responses := make([]struct {
+		Result interface{} `json:"result"`
+	}, len(reqs))
+	targets := make([]interface{}, len(reqs))
+	for i := range reqs {
+		targets[i] = &responses[i]
+	}
+
+	errs, _ := callEach(0, reqs, targets)
+	for i, req := range reqs {
+		if errs[i] != nil || fmt.Sprint(responses[i].Result) != fmt.Sprint(req.Params[0]) {
+			t.Errorf("request %v returns (%v, %v)", req.Params, responses[i].Result, errs[i])
+		}
+	}
+
+	// Blocks above the highest server are not requested.
+	errs, _ = callEach(0, []request{newRequest("getblock", 11, 1)}, targets[:1])
+	if errs[0] == nil {
+		t.Error("block above the highest server is requested")
+	}
+}
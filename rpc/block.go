@@ -2,13 +2,13 @@ package rpc
 
 // BlockCountRespponse returns block height of chain.
 type BlockCountRespponse struct {
-	jsonRPCResponse
+	JSONRPCResponse
 	Result int `json:"result"`
 }
 
 // BlockResponse returns full block data of a specific index.
 type BlockResponse struct {
-	jsonRPCResponse
+	JSONRPCResponse
 	Result *RawBlock `json:"result"`
 }
 
@@ -29,15 +29,104 @@ type RawBlock struct {
 	}
 	Tx            []RawTx
 	NextBlockHash string `json:"nextblockhash"`
+
+	// Source is the url of the rpc server which returned the block.
+	Source string `json:"-"`
+
+	// Decoded reports that the block is decoded from its serialized form,
+	// net fees of its transactions are unknown until referenced outputs are resolved.
+	Decoded bool `json:"-"`
 }
 
 // DownloadBlock from rpc server.
 func DownloadBlock(index int) *RawBlock {
-	params := []interface{}{index, 1}
-	args := getRPCRequestBody("getblock", params)
-
 	respData := BlockResponse{}
-	rpcCall(index, args, &respData)
+	url := call(index, newRequest("getblock", index, 1), &respData)
+
+	if respData.Result != nil {
+		respData.Result.Source = url
+	}
+
+	return respData.Result
+}
+
+// DownloadBlocks downloads count blocks from start in one batch request,
+// blocks higher than all rpc servers are nil. Failed items are downloaded again one by one.
+func DownloadBlocks(start, count int) []*RawBlock {
+	reqs := make([]request, count)
+	responses := make([]BlockResponse, count)
+	targets := make([]interface{}, count)
+	for i := range reqs {
+		reqs[i] = newRequest("getblock", start+i, 1)
+		targets[i] = &responses[i]
+	}
+
+	errs, sources := batchCall(start, reqs, targets)
+
+	blocks := make([]*RawBlock, count)
+	for i, err := range errs {
+		if err != nil || responses[i].Result == nil {
+			blocks[i] = DownloadBlock(start + i)
+			continue
+		}
+
+		blocks[i] = responses[i].Result
+		blocks[i].Source = sources[i]
+	}
+
+	return blocks
+}
+
+// RawBlockResponse returns hex string of a serialized block.
+type RawBlockResponse struct {
+	JSONRPCResponse
+	Result string `json:"result"`
+}
+
+// DownloadRawBlock downloads the serialized block from rpc server and returns it with the server url,
+// the block is empty if it does not exist.
+func DownloadRawBlock(index int) (string, string) {
+	respData := RawBlockResponse{}
+	url := call(index, newRequest("getblock", index, 0), &respData)
+
+	return respData.Result, url
+}
+
+// DownloadRawBlocks is like DownloadBlocks but downloads serialized blocks, sources are urls of their servers.
+func DownloadRawBlocks(start, count int) (blocks []string, sources []string) {
+	reqs := make([]request, count)
+	responses := make([]RawBlockResponse, count)
+	targets := make([]interface{}, count)
+	for i := range reqs {
+		reqs[i] = newRequest("getblock", start+i, 0)
+		targets[i] = &responses[i]
+	}
+
+	errs, sources := batchCall(start, reqs, targets)
+
+	blocks = make([]string, count)
+	for i, err := range errs {
+		if err != nil || responses[i].Result == "" {
+			blocks[i], sources[i] = DownloadRawBlock(start + i)
+			continue
+		}
+
+		blocks[i] = responses[i].Result
+	}
+
+	return blocks, sources
+}
+
+// BlockHashResponse returns hash of a specific block.
+type BlockHashResponse struct {
+	JSONRPCResponse
+	Result string `json:"result"`
+}
+
+// GetBlockHash returns hash of the block at the given index.
+func GetBlockHash(index int) string {
+	respData := BlockHashResponse{}
+	rpcCall(index, newRequest("getblockhash", index), &respData)
 
 	return respData.Result
 }
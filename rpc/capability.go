@@ -0,0 +1,109 @@
+package rpc
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"squirrel/log"
+	"squirrel/mail"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	// probeInterval is the interval of checking methods supported by each rpc server.
+	probeInterval = 10 * time.Minute
+	// methodNotFound is the JSON-RPC error code of unsupported methods.
+	methodNotFound = -32601
+)
+
+// optionalMethods may be disabled or provided by plugins on neo nodes,
+// they are only sent to servers which answered the probe request with other than methodNotFound.
+// getapplicationlog requires the ApplicationLogs plugin.
+var optionalMethods = map[string][]interface{}{
+	"getapplicationlog": {"0x" + strings.Repeat("0", 64)},
+	"invokescript":      {"00"},
+	"getnep5balances":   {"AQVh2pG732YvtNaxEGkQUei3YA4cvo7d2i"},
+}
+
+// ErrMethodUnavailable is returned by calls which give up instead of waiting while no rpc server supports their method.
+var ErrMethodUnavailable = errors.New("no rpc server supports the method")
+
+// isOptional reports if the method is only sent to servers supporting it.
+func isOptional(method string) bool {
+	_, ok := optionalMethods[method]
+	return ok
+}
+
+// probeCapabilities checks methods supported by reachable servers which have not been probed recently,
+// and sends alerts if no server supports some method.
+func probeCapabilities() {
+	var wg sync.WaitGroup
+
+	for _, url := range pool.unprobed(probeInterval) {
+		wg.Add(1)
+		go func(url string) {
+			defer wg.Done()
+
+			methods, err := probeMethods(url)
+			if err != nil {
+				log.Error.Printf("Failed to probe methods of rpc server %s: %v\n", url, err)
+				return
+			}
+
+			for method := range optionalMethods {
+				if !methods[method] {
+					log.Printf("Rpc server %s does not support %s\n", url, method)
+				}
+			}
+			pool.setMethods(url, methods)
+		}(url)
+	}
+
+	wg.Wait()
+
+	missing, recovered := pool.missingMethods()
+	for _, method := range missing {
+		msg := fmt.Sprintf("No rpc server supports %s", method)
+		log.Error.Println(msg)
+		mail.SendNotify("Rpc Method Unavailable", msg)
+	}
+	for _, method := range recovered {
+		log.Printf("Rpc method %s is available again\n", method)
+	}
+}
+
+// probeMethods returns optional methods supported by the server.
+func probeMethods(url string) (map[string]bool, error) {
+	methods := make(map[string]bool)
+
+	for method, params := range optionalMethods {
+		req := newRequest(method, params...)
+		resp, err := client.Post(url, "application/json", bytes.NewBuffer(req.body()))
+		if err != nil {
+			return nil, err
+		}
+
+		body, err := ioutil.ReadAll(resp.Body)
+		resp.Body.Close()
+		if err != nil {
+			return nil, err
+		}
+
+		// Errors other than methodNotFound are expected for the fake params.
+		err = req.check(body)
+		if rpcErr, ok := err.(*RPCError); ok {
+			methods[method] = rpcErr.Code != methodNotFound
+			continue
+		}
+		if err != nil {
+			return nil, err
+		}
+
+		methods[method] = true
+	}
+
+	return methods, nil
+}
@@ -0,0 +1,59 @@
+package rpc
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestProbeMethods(t *testing.T) {
+	// The server has no ApplicationLogs plugin.
+	s := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		req := request{}
+		json.NewDecoder(r.Body).Decode(&req)
+
+		resp := map[string]interface{}{"jsonrpc": "2.0", "id": req.ID}
+		switch req.Method {
+		case "getapplicationlog":
+			resp["error"] = RPCError{Code: methodNotFound, Message: "Method not found"}
+		case "getnep5balances":
+			resp["error"] = RPCError{Code: -2146233033, Message: "Invalid address"}
+		default:
+			resp["result"] = map[string]interface{}{"state": "HALT"}
+		}
+		json.NewEncoder(w).Encode(resp)
+	}))
+	defer s.Close()
+
+	methods, err := probeMethods(s.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if methods["getapplicationlog"] || !methods["invokescript"] || !methods["getnep5balances"] {
+		t.Fatalf("probeMethods returns %v", methods)
+	}
+}
+
+func TestPickCapableServer(t *testing.T) {
+	p := newServerPool(func() float64 { return 0 })
+	p.setHeights(map[string]int{"http://plain": 10, "http://applog": 10})
+	p.setMethods("http://plain", map[string]bool{"invokescript": true})
+	p.setMethods("http://applog", map[string]bool{"getapplicationlog": true, "invokescript": true})
+
+	for i := 0; i < 100; i++ {
+		if url, ok := p.pick(0, "getapplicationlog"); !ok || url != "http://applog" {
+			t.Fatalf("pick returns (%s, %v) for getapplicationlog", url, ok)
+		}
+	}
+
+	if p.capable("getnep5balances") {
+		t.Fatal("getnep5balances is capable without supporting servers")
+	}
+	if missing, _ := p.missingMethods(); len(missing) != 1 || missing[0] != "getnep5balances" {
+		t.Fatalf("missingMethods returns %v", missing)
+	}
+	if missing, _ := p.missingMethods(); len(missing) != 0 {
+		t.Fatalf("missing methods are reported again: %v", missing)
+	}
+}
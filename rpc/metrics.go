@@ -0,0 +1,73 @@
+package rpc
+
+import (
+	"squirrel/metrics"
+
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+var (
+	callDuration = prometheus.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: metrics.Namespace,
+		Subsystem: "rpc",
+		Name:      "call_duration_seconds",
+		Help:      "Latency of successful rpc calls.",
+		Buckets:   []float64{.005, .01, .025, .05, .1, .25, .5, 1, 2.5, 5, 10, 20},
+	}, []string{"method"})
+
+	callErrors = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: metrics.Namespace,
+		Subsystem: "rpc",
+		Name:      "call_errors_total",
+		Help:      "Failed rpc calls by method and server, kind is request, decode, id or invalid.",
+	}, []string{"method", "url", "kind"})
+)
+
+func init() {
+	metrics.MustRegister(
+		poolCollector{},
+		callDuration,
+		callErrors,
+		prometheus.NewGaugeFunc(prometheus.GaugeOpts{
+			Namespace: metrics.Namespace,
+			Subsystem: "rpc",
+			Name:      "best_height",
+			Help:      "Highest block height of all rpc servers.",
+		}, func() float64 { return float64(BestHeight.Get()) }),
+	)
+}
+
+var (
+	serverHeightDesc = newServerDesc("server_height", "Block height of each rpc server, -1 if unavailable.")
+	latencyDesc      = newServerDesc("server_latency_seconds", "Rolling average latency of each rpc server.")
+	errorRateDesc    = newServerDesc("server_error_rate", "Rolling rate of failed requests of each rpc server.")
+	circuitOpenDesc  = newServerDesc("server_circuit_open", "1 if requests to the rpc server are suspended after repeated failures.")
+)
+
+func newServerDesc(name, help string) *prometheus.Desc {
+	return prometheus.NewDesc(prometheus.BuildFQName(metrics.Namespace, "rpc", name), help, []string{"url"}, nil)
+}
+
+// poolCollector reads the state of the server pool when metrics are scraped.
+type poolCollector struct{}
+
+func (poolCollector) Describe(ch chan<- *prometheus.Desc) {
+	ch <- serverHeightDesc
+	ch <- latencyDesc
+	ch <- errorRateDesc
+	ch <- circuitOpenDesc
+}
+
+func (poolCollector) Collect(ch chan<- prometheus.Metric) {
+	for _, s := range pool.statuses() {
+		circuitOpen := 0.0
+		if s.CircuitOpen {
+			circuitOpen = 1
+		}
+
+		ch <- prometheus.MustNewConstMetric(serverHeightDesc, prometheus.GaugeValue, float64(s.Height), s.URL)
+		ch <- prometheus.MustNewConstMetric(latencyDesc, prometheus.GaugeValue, s.Latency.Seconds(), s.URL)
+		ch <- prometheus.MustNewConstMetric(errorRateDesc, prometheus.GaugeValue, s.ErrorRate, s.URL)
+		ch <- prometheus.MustNewConstMetric(circuitOpenDesc, prometheus.GaugeValue, circuitOpen, s.URL)
+	}
+}
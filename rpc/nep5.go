@@ -1,15 +1,14 @@
 package rpc
 
 import (
-	"math/big"
-	"math/rand"
+	"context"
+	"squirrel/amount"
 	"squirrel/log"
-	"time"
 )
 
 // ApplicationLogResponse is the struct of returning data from 'getapplicationlog' rpc call.
 type ApplicationLogResponse struct {
-	jsonRPCResponse
+	JSONRPCResponse
 	Result *RawApplicationLogResult `json:"result"`
 }
 
@@ -24,7 +23,7 @@ type RawApplicationLogExecution struct {
 	Trigger       string             `json:"trigger"`
 	Contract      string             `json:"contract"`
 	VMState       string             `json:"vmstate"`
-	GasConsumed   *big.Float         `json:"gas_consumed"`
+	GasConsumed   amount.Amount      `json:"gas_consumed"`
 	Stack         interface{}        `json:"stack"`
 	Notifications []RawNotifications `json:"notifications"`
 }
@@ -35,35 +34,66 @@ type RawNotifications struct {
 	State    *RawState `json:"state"`
 }
 
-// GetApplicationLog returns application log of nep5 transaction.
-func GetApplicationLog(blockIndex int, txID string) *RawApplicationLogResult {
-	params := []interface{}{txID}
-	const method = "getapplicationlog"
-	args := getRPCRequestBody(method, params)
+// GetApplicationLog returns application log of nep5 transaction, the request is retried with growing delays
+// until it succeeds. It returns ErrMethodUnavailable if no rpc server supports getapplicationlog,
+// or the error of ctx if ctx is done before the log is received.
+func GetApplicationLog(ctx context.Context, blockIndex int, txID string) (*RawApplicationLogResult, error) {
+	req := newRequest("getapplicationlog", txID)
+	delay := minRetryDelay
 
-	respData := ApplicationLogResponse{}
-	rpcCall(blockIndex, args, &respData)
+	for {
+		if !pool.capable(req.Method) {
+			return nil, ErrMethodUnavailable
+		}
 
-	if respData.Result != nil {
-		return respData.Result
-	}
+		respData := ApplicationLogResponse{}
+		if callOnce(blockIndex, req, &respData) && respData.Result != nil {
+			return respData.Result, nil
+		}
 
-	retryTime := uint(0)
-	delay := 0
+		log.Printf("Can not get application log of %s\nWaiting for %v before retry\n", txID, delay)
 
-	for {
-		retryTime++
-		if delay < 10*1000 {
-			delay = rand.Intn(1<<retryTime) + 1000
+		var err error
+		if delay, err = waitRetryContext(ctx, delay); err != nil {
+			return nil, err
 		}
+	}
+}
 
-		log.Printf("Can not get application log of %s\n", txID)
-		log.Printf("Delay for %d msecs and try to connect again. RetryTime=%d\n", delay, retryTime)
+// GetApplicationLogs returns application logs of the given transactions in one batch request,
+// minHeight is the highest block of these transactions. Failed items are requested again one by one.
+// Errors are the same as those of GetApplicationLog.
+func GetApplicationLogs(ctx context.Context, minHeight int, txIDs []string) ([]*RawApplicationLogResult, error) {
+	results := make([]*RawApplicationLogResult, len(txIDs))
+	if len(txIDs) == 0 {
+		return results, nil
+	}
+	if !pool.capable("getapplicationlog") {
+		return nil, ErrMethodUnavailable
+	}
+
+	reqs := make([]request, len(txIDs))
+	responses := make([]ApplicationLogResponse, len(txIDs))
+	targets := make([]interface{}, len(txIDs))
+	for i, txID := range txIDs {
+		reqs[i] = newRequest("getapplicationlog", txID)
+		targets[i] = &responses[i]
+	}
+
+	errs, _ := batchCall(minHeight, reqs, targets)
 
-		time.Sleep(time.Duration(delay) * time.Millisecond)
-		rpcCall(blockIndex, args, &respData)
-		if respData.Result != nil {
-			return respData.Result
+	for i, err := range errs {
+		if err == nil && responses[i].Result != nil {
+			results[i] = responses[i].Result
+			continue
 		}
+
+		result, err := GetApplicationLog(ctx, minHeight, txIDs[i])
+		if err != nil {
+			return nil, err
+		}
+		results[i] = result
 	}
+
+	return results, nil
 }
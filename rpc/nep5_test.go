@@ -0,0 +1,76 @@
+package rpc
+
+import (
+	"context"
+	"encoding/json"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"squirrel/log"
+	"testing"
+	"time"
+)
+
+func TestGetApplicationLogs(t *testing.T) {
+	log.Init()
+	defer os.Remove("error.log")
+
+	// Only the log of 0x01 is known to the server.
+	answer := func(req request) map[string]interface{} {
+		resp := map[string]interface{}{"jsonrpc": "2.0", "id": req.ID}
+		if req.Params[0] == "0x01" {
+			resp["result"] = RawApplicationLogResult{TxID: "0x01"}
+		} else {
+			resp["error"] = RPCError{Code: -100, Message: "Unknown transaction"}
+		}
+		return resp
+	}
+
+	s := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := ioutil.ReadAll(r.Body)
+
+		if body[0] != '[' {
+			req := request{}
+			json.Unmarshal(body, &req)
+			json.NewEncoder(w).Encode(answer(req))
+			return
+		}
+
+		reqs := []request{}
+		json.Unmarshal(body, &reqs)
+
+		resps := []map[string]interface{}{}
+		for _, req := range reqs {
+			resps = append(resps, answer(req))
+		}
+		json.NewEncoder(w).Encode(resps)
+	}))
+	defer s.Close()
+
+	pool.setHeights(map[string]int{s.URL: 10})
+	pool.setMethods(s.URL, map[string]bool{})
+
+	ctx := context.Background()
+	if _, err := GetApplicationLogs(ctx, 0, []string{"0x01"}); err != ErrMethodUnavailable {
+		t.Fatalf("GetApplicationLogs without capable servers returns %v", err)
+	}
+
+	pool.setMethods(s.URL, map[string]bool{"getapplicationlog": true})
+	logs, err := GetApplicationLogs(ctx, 0, []string{"0x01"})
+	if err != nil || len(logs) != 1 || logs[0].TxID != "0x01" {
+		t.Fatalf("GetApplicationLogs returns (%v, %v)", logs, err)
+	}
+
+	// Retries of a missing log stop when ctx is done.
+	ctx, cancel := context.WithTimeout(ctx, 100*time.Millisecond)
+	defer cancel()
+
+	start := time.Now()
+	if _, err := GetApplicationLog(ctx, 0, "0x02"); err != context.DeadlineExceeded {
+		t.Fatalf("GetApplicationLog after ctx is done returns %v", err)
+	}
+	if elapsed := time.Since(start); elapsed >= minRetryDelay {
+		t.Fatalf("GetApplicationLog returns after %v, ctx is ignored", elapsed)
+	}
+}
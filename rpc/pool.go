@@ -0,0 +1,354 @@
+package rpc
+
+import (
+	"math"
+	"math/rand"
+	"sort"
+	"sync"
+	"time"
+)
+
+const (
+	// breakerThreshold is the number of consecutive failures opening the circuit of a server.
+	breakerThreshold = 3
+	// minBackoff and maxBackoff bound the time a server is skipped after its circuit opens,
+	// the backoff doubles every time a trial request fails.
+	minBackoff = time.Second
+	maxBackoff = time.Minute
+
+	// referenceLatency is assumed for servers without latency samples.
+	referenceLatency = 100 * time.Millisecond
+	// latencyDecay and errorDecay are weights of the latest sample in rolling averages.
+	latencyDecay = 0.2
+	errorDecay   = 0.1
+	// minErrorFactor keeps servers with high error rates selectable at a low rate.
+	minErrorFactor = 0.01
+)
+
+// ServerStatus is a snapshot of an rpc server in the pool.
+type ServerStatus struct {
+	URL    string
+	Height int
+	// Latency is the rolling average latency of successful requests.
+	Latency time.Duration
+	// ErrorRate is the rolling rate of failed requests, between 0 and 1.
+	ErrorRate float64
+	// Failures is the number of consecutive failures.
+	Failures    int
+	CircuitOpen bool
+	// RetryAt is the time a trial request is allowed if the circuit is open.
+	RetryAt time.Time
+	// Methods are the supported optional methods, empty until probed.
+	Methods  []string
+	ProbedAt time.Time
+}
+
+type serverState struct {
+	ServerStatus
+	backoff time.Duration
+	// trial is set while the single request allowed by an expired open circuit is in flight.
+	trial bool
+}
+
+// serverPool selects rpc servers by height, latency and error rate,
+// servers failing repeatedly are skipped until their backoff expires.
+type serverPool struct {
+	mu      sync.Mutex
+	servers map[string]*serverState
+	// missing records optional methods no server supports.
+	missing map[string]bool
+	// latencyWeight is the exponent of latency in selection weights, 0 ignores latency.
+	latencyWeight func() float64
+	now           func() time.Time
+}
+
+func newServerPool(latencyWeight func() float64) *serverPool {
+	return &serverPool{
+		servers:       make(map[string]*serverState),
+		missing:       make(map[string]bool),
+		latencyWeight: latencyWeight,
+		now:           time.Now,
+	}
+}
+
+// setHeights replaces the servers of the pool, statistics of remaining servers are kept.
+func (p *serverPool) setHeights(heights map[string]int) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	for url := range p.servers {
+		if _, ok := heights[url]; !ok {
+			delete(p.servers, url)
+		}
+	}
+
+	for url, height := range heights {
+		s, ok := p.servers[url]
+		if !ok {
+			s = &serverState{ServerStatus: ServerStatus{URL: url}}
+			p.servers[url] = s
+		}
+		s.Height = height
+	}
+}
+
+// pick returns a server whose height is at least minHeight and supports the method, chosen randomly by weight.
+func (p *serverPool) pick(minHeight int, method string) (string, bool) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	now := p.now()
+	candidates := []*serverState{}
+	weights := []float64{}
+	total := 0.0
+
+	for _, s := range p.sorted() {
+		if s.Height < minHeight || !s.available(now) || !s.supports(method) {
+			continue
+		}
+
+		w := s.weight(p.latencyWeight())
+		candidates = append(candidates, s)
+		weights = append(weights, w)
+		total += w
+	}
+
+	if len(candidates) == 0 {
+		return "", false
+	}
+
+	chosen := candidates[len(candidates)-1]
+	r := rand.Float64() * total
+	for i, w := range weights {
+		if r < w {
+			chosen = candidates[i]
+			break
+		}
+		r -= w
+	}
+
+	if chosen.CircuitOpen {
+		chosen.trial = true
+	}
+
+	return chosen.URL, true
+}
+
+// succeeded records a successful request to the server, its circuit is closed.
+// Latency is not sampled if it is 0.
+func (p *serverPool) succeeded(url string, latency time.Duration) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	s, ok := p.servers[url]
+	if !ok {
+		return
+	}
+
+	if s.Latency == 0 {
+		s.Latency = latency
+	} else if latency > 0 {
+		s.Latency = time.Duration((1-latencyDecay)*float64(s.Latency) + latencyDecay*float64(latency))
+	}
+
+	s.ErrorRate *= 1 - errorDecay
+	s.Failures = 0
+	s.CircuitOpen = false
+	s.backoff = 0
+	s.trial = false
+}
+
+// failed records a failed request to the server and opens its circuit after repeated failures.
+func (p *serverPool) failed(url string) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	s, ok := p.servers[url]
+	if !ok {
+		return
+	}
+
+	s.ErrorRate = (1-errorDecay)*s.ErrorRate + errorDecay
+	s.Failures++
+	s.trial = false
+
+	if s.Failures < breakerThreshold {
+		return
+	}
+
+	p.open(s)
+}
+
+// reject records invalid data returned by the server, its circuit is opened at once.
+func (p *serverPool) reject(url string) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	s, ok := p.servers[url]
+	if !ok {
+		return
+	}
+
+	s.ErrorRate = (1-errorDecay)*s.ErrorRate + errorDecay
+	if s.Failures < breakerThreshold {
+		s.Failures = breakerThreshold
+	}
+	s.trial = false
+
+	p.open(s)
+}
+
+// open opens the circuit of the server with a doubled backoff, p.mu must be held.
+func (p *serverPool) open(s *serverState) {
+	s.backoff *= 2
+	if s.backoff < minBackoff {
+		s.backoff = minBackoff
+	}
+	if s.backoff > maxBackoff {
+		s.backoff = maxBackoff
+	}
+
+	s.CircuitOpen = true
+	s.RetryAt = p.now().Add(s.backoff)
+}
+
+// capable reports if any server supports the method, regardless of its height and circuit.
+func (p *serverPool) capable(method string) bool {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	for _, s := range p.servers {
+		if s.supports(method) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// unprobed returns reachable servers whose methods are not probed within the interval.
+func (p *serverPool) unprobed(interval time.Duration) []string {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	urls := []string{}
+	for _, s := range p.sorted() {
+		if s.Height >= 0 && p.now().Sub(s.ProbedAt) >= interval {
+			urls = append(urls, s.URL)
+		}
+	}
+
+	return urls
+}
+
+// setMethods records optional methods supported by the server.
+func (p *serverPool) setMethods(url string, methods map[string]bool) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	s, ok := p.servers[url]
+	if !ok {
+		return
+	}
+
+	supported := []string{}
+	for method, ok := range methods {
+		if ok {
+			supported = append(supported, method)
+		}
+	}
+	sort.Strings(supported)
+
+	s.Methods = supported
+	s.ProbedAt = p.now()
+}
+
+// missingMethods returns optional methods which became unsupported by all servers,
+// and those supported again since the last call.
+func (p *serverPool) missingMethods() (missing, recovered []string) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	for method := range optionalMethods {
+		capable := false
+		for _, s := range p.servers {
+			capable = capable || s.supports(method)
+		}
+
+		switch {
+		case !capable && !p.missing[method]:
+			p.missing[method] = true
+			missing = append(missing, method)
+		case capable && p.missing[method]:
+			delete(p.missing, method)
+			recovered = append(recovered, method)
+		}
+	}
+
+	sort.Strings(missing)
+	sort.Strings(recovered)
+	return missing, recovered
+}
+
+func (p *serverPool) statuses() []ServerStatus {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	result := []ServerStatus{}
+	for _, s := range p.sorted() {
+		result = append(result, s.ServerStatus)
+	}
+
+	return result
+}
+
+// sorted returns servers ordered by url, so that selection only depends on random numbers.
+func (p *serverPool) sorted() []*serverState {
+	servers := make([]*serverState, 0, len(p.servers))
+	for _, s := range p.servers {
+		servers = append(servers, s)
+	}
+
+	sort.Slice(servers, func(i, j int) bool {
+		return servers[i].URL < servers[j].URL
+	})
+
+	return servers
+}
+
+// available reports if requests may be sent to the server,
+// an open circuit allows one trial request after its backoff.
+func (s *serverState) available(now time.Time) bool {
+	if !s.CircuitOpen {
+		return true
+	}
+
+	return !s.trial && !now.Before(s.RetryAt)
+}
+
+// supports reports if the server supports the method, methods other than optional ones are always supported.
+func (s *serverState) supports(method string) bool {
+	if !isOptional(method) {
+		return true
+	}
+
+	for _, m := range s.Methods {
+		if m == method {
+			return true
+		}
+	}
+
+	return false
+}
+
+func (s *serverState) weight(latencyWeight float64) float64 {
+	latency := s.Latency
+	if latency <= 0 {
+		latency = referenceLatency
+	}
+
+	latencyFactor := math.Pow(float64(referenceLatency)/float64(latency), latencyWeight)
+	errorFactor := math.Max(1-s.ErrorRate, minErrorFactor)
+
+	return latencyFactor * errorFactor
+}
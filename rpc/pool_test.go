@@ -0,0 +1,90 @@
+package rpc
+
+import (
+	"testing"
+	"time"
+)
+
+func TestPoolCircuitBreaker(t *testing.T) {
+	now := time.Unix(1500000000, 0)
+	p := newServerPool(func() float64 { return 0 })
+	p.now = func() time.Time { return now }
+	p.setHeights(map[string]int{"http://a": 10})
+
+	for i := 0; i < breakerThreshold-1; i++ {
+		p.failed("http://a")
+	}
+	if _, ok := p.pick(0, "getblock"); !ok {
+		t.Fatal("circuit opens before threshold")
+	}
+
+	p.failed("http://a")
+	if _, ok := p.pick(0, "getblock"); ok {
+		t.Fatal("server is picked while circuit is open")
+	}
+
+	// One trial request is allowed after the backoff.
+	now = now.Add(minBackoff)
+	if _, ok := p.pick(0, "getblock"); !ok {
+		t.Fatal("no trial request after backoff")
+	}
+	if _, ok := p.pick(0, "getblock"); ok {
+		t.Fatal("second request is allowed while the trial is in flight")
+	}
+
+	// A failed trial doubles the backoff.
+	p.failed("http://a")
+	now = now.Add(minBackoff)
+	if _, ok := p.pick(0, "getblock"); ok {
+		t.Fatal("server is picked before the doubled backoff")
+	}
+	now = now.Add(minBackoff)
+	if _, ok := p.pick(0, "getblock"); !ok {
+		t.Fatal("no trial request after the doubled backoff")
+	}
+
+	p.succeeded("http://a", 10*time.Millisecond)
+	status := p.statuses()[0]
+	if status.CircuitOpen || status.Failures != 0 || status.Latency != 10*time.Millisecond {
+		t.Fatalf("unexpected status after success %+v", status)
+	}
+}
+
+func TestPoolRejectOpensCircuit(t *testing.T) {
+	now := time.Unix(1500000000, 0)
+	p := newServerPool(func() float64 { return 0 })
+	p.now = func() time.Time { return now }
+	p.setHeights(map[string]int{"http://a": 10, "http://b": 10})
+
+	p.reject("http://a")
+	for i := 0; i < 100; i++ {
+		if url, _ := p.pick(0, "getblock"); url != "http://b" {
+			t.Fatalf("%s is picked after it is rejected", url)
+		}
+	}
+
+	status := p.statuses()[0]
+	if !status.CircuitOpen || status.Failures != breakerThreshold {
+		t.Fatalf("unexpected status after reject %+v", status)
+	}
+}
+
+func TestPoolPrefersLowLatency(t *testing.T) {
+	p := newServerPool(func() float64 { return 1 })
+	p.setHeights(map[string]int{"http://fast": 10, "http://slow": 10, "http://behind": 5})
+	p.succeeded("http://fast", 10*time.Millisecond)
+	p.succeeded("http://slow", 100*time.Millisecond)
+
+	picks := map[string]int{}
+	for i := 0; i < 1000; i++ {
+		url, _ := p.pick(8, "getblock")
+		picks[url]++
+	}
+
+	if picks["http://behind"] != 0 {
+		t.Fatalf("server below minHeight is picked %d times", picks["http://behind"])
+	}
+	if picks["http://fast"] < 800 || picks["http://slow"] == 0 {
+		t.Fatalf("unexpected selection %v, expected about 10:1", picks)
+	}
+}
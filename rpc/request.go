@@ -0,0 +1,76 @@
+package rpc
+
+import (
+	"encoding/json"
+	"fmt"
+	"sync/atomic"
+)
+
+// lastRequestID is the id of the latest request, ids increase monotonically.
+var lastRequestID int64
+
+// request is a JSON-RPC 2.0 request, params may be of any type encodable by encoding/json.
+type request struct {
+	JSONRPC string        `json:"jsonrpc"`
+	Method  string        `json:"method"`
+	Params  []interface{} `json:"params"`
+	ID      int64         `json:"id"`
+}
+
+// responseHeader is the common part of responses used to check them before decoding results.
+type responseHeader struct {
+	ID    *int64    `json:"id"`
+	Error *RPCError `json:"error"`
+}
+
+// RPCError is the error object of a failed JSON-RPC call.
+type RPCError struct {
+	Code    int    `json:"code"`
+	Message string `json:"message"`
+}
+
+func (e *RPCError) Error() string {
+	return fmt.Sprintf("rpc error %d: %s", e.Code, e.Message)
+}
+
+// newRequest returns a request of the method with a new id.
+func newRequest(method string, params ...interface{}) request {
+	if params == nil {
+		params = []interface{}{}
+	}
+
+	return request{
+		JSONRPC: "2.0",
+		Method:  method,
+		Params:  params,
+		ID:      atomic.AddInt64(&lastRequestID, 1),
+	}
+}
+
+// body returns the json encoded request.
+func (r request) body() []byte {
+	data, err := json.Marshal(r)
+	if err != nil {
+		panic(fmt.Errorf("failed to encode %s request: %v", r.Method, err))
+	}
+
+	return data
+}
+
+// check returns the error of the response, or an error if the response does not answer the request.
+func (r request) check(resp []byte) error {
+	header := responseHeader{}
+	if err := json.Unmarshal(resp, &header); err != nil {
+		return err
+	}
+
+	if header.Error != nil {
+		return header.Error
+	}
+
+	if header.ID == nil || *header.ID != r.ID {
+		return fmt.Errorf("response id does not match request id %d of %s", r.ID, r.Method)
+	}
+
+	return nil
+}
@@ -0,0 +1,60 @@
+package rpc
+
+import (
+	"encoding/json"
+	"testing"
+)
+
+func TestRequestBody(t *testing.T) {
+	first := newRequest("getblockcount")
+	req := newRequest("invokefunction", `a"b\c`, true, []interface{}{map[string]interface{}{"type": "Hash160", "value": "x"}})
+
+	if req.ID <= first.ID {
+		t.Fatalf("request id %d is not greater than %d", req.ID, first.ID)
+	}
+
+	decoded := struct {
+		JSONRPC string            `json:"jsonrpc"`
+		Method  string            `json:"method"`
+		Params  []json.RawMessage `json:"params"`
+		ID      int64             `json:"id"`
+	}{}
+	if err := json.Unmarshal(req.body(), &decoded); err != nil {
+		t.Fatalf("invalid request body %s: %v", req.body(), err)
+	}
+	if decoded.JSONRPC != "2.0" || decoded.Method != "invokefunction" || decoded.ID != req.ID || len(decoded.Params) != 3 {
+		t.Fatalf("request decoded as %+v", decoded)
+	}
+
+	var str string
+	if err := json.Unmarshal(decoded.Params[0], &str); err != nil || str != `a"b\c` {
+		t.Fatalf("string param decoded as %q", str)
+	}
+
+	if body := string(first.body()); body != `{"jsonrpc":"2.0","method":"getblockcount","params":[],"id":`+jsonInt(first.ID)+`}` {
+		t.Fatalf("request without params encoded as %s", body)
+	}
+}
+
+func TestRequestCheck(t *testing.T) {
+	req := newRequest("getblockcount")
+	id := jsonInt(req.ID)
+
+	if err := req.check([]byte(`{"jsonrpc":"2.0","id":` + id + `,"result":1}`)); err != nil {
+		t.Fatalf("check of matched response: %v", err)
+	}
+	if err := req.check([]byte(`{"jsonrpc":"2.0","id":` + jsonInt(req.ID+1) + `,"result":1}`)); err == nil {
+		t.Fatal("check of response with another id succeeds")
+	}
+	if err := req.check([]byte(`{"jsonrpc":"2.0","result":1}`)); err == nil {
+		t.Fatal("check of response without id succeeds")
+	}
+	if _, ok := req.check([]byte(`{"jsonrpc":"2.0","id":` + id + `,"error":{"code":-100,"message":"Unknown"}}`)).(*RPCError); !ok {
+		t.Fatal("check of failed response does not return rpc error")
+	}
+}
+
+func jsonInt(v int64) string {
+	data, _ := json.Marshal(v)
+	return string(data)
+}
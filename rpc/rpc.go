@@ -1,111 +1,193 @@
 package rpc
 
 import (
+	"context"
 	"encoding/json"
 	"errors"
 	"fmt"
 	"net/http"
 	"squirrel/log"
-	"strings"
 	"time"
 
 	eParser "github.com/go-errors/errors"
 	"github.com/valyala/fasthttp"
 )
 
+const (
+	// minRetryDelay and maxRetryDelay bound the delay between retries while no server can answer a request.
+	minRetryDelay = 3 * time.Second
+	maxRetryDelay = time.Minute
+)
+
 var (
-	client = &http.Client{Timeout: 20 * time.Second}
+	client     = &http.Client{Timeout: 20 * time.Second}
+	httpClient = &fasthttp.Client{}
 )
 
-// JsonRPCResponse returns rpc response data.
-type jsonRPCResponse struct {
+// JSONRPCResponse is the common part of all rpc responses.
+type JSONRPCResponse struct {
 	JSONRPC string `json:"jsonrpc"`
 	ID      int    `json:"id"`
 }
 
-func getRPCRequestBody(method string, params []interface{}) string {
-	p := ""
-
-	for _, param := range params {
-		switch param.(type) {
-		case int8, uint8,
-			int16, uint16,
-			int, uint,
-			int32, uint32,
-			int64, uint64:
-			p += fmt.Sprintf("%d, ", param)
-		case string:
-			p += fmt.Sprintf("\"%s\", ", param)
-		default:
-			err := fmt.Errorf("the RPC parameter type must be integer or string. current type=%T, value=%v", param, param)
-			panic(err)
-		}
-	}
+func rpcCall(minHeight int, req request, target interface{}) {
+	call(minHeight, req, target)
+}
 
-	if p != "" {
-		p = p[:len(p)-2]
-	}
+// call sends the request to a server whose height is at least minHeight, decodes the response into target
+// and returns the server url. The result of target is left empty if the call fails or no server has the requested block.
+func call(minHeight int, req request, target interface{}) string {
+	body := req.body()
 
-	body := `{
-		"jsonrpc": "2.0",
-		"method": "` + method + `",
-		"params": [
-			` + p + `
-		],
-		"id": 1
-	}
-	`
-	return body
-}
+	for {
+		url, respBody := post(minHeight, req.Method, body)
+		if respBody == nil {
+			return ""
+		}
 
-func rpcCall(minHeight int, params string, target interface{}) {
-	call(minHeight, params, target)
-}
+		err := req.check(respBody)
+		if _, failed := err.(*RPCError); err != nil && !failed {
+			// The response is not for this request, ask other servers.
+			callErrors.WithLabelValues(req.Method, url, "id").Inc()
+			log.Error.Printf("Invalid response from %s: %v\n", url, err)
+			serverUnavailable(url)
+			time.Sleep(50 * time.Millisecond)
+			continue
+		}
 
-func call(minHeight int, params string, target interface{}) {
-	requestBody := []byte(params)
-	resp := fasthttp.AcquireResponse()
-	req := fasthttp.AcquireRequest()
-	req.Header.SetMethod("POST")
-	req.SetBody(requestBody)
+		if err := json.Unmarshal(respBody, target); err != nil {
+			callErrors.WithLabelValues(req.Method, url, "decode").Inc()
+			log.Error.Println(errors.New(eParser.Wrap(err, 0).ErrorStack()))
+			log.Error.Printf("Request body: %s\n", body)
+			log.Error.Printf("Response: %s\n", respBody)
+		}
 
-	client := &fasthttp.Client{}
+		return url
+	}
+}
 
+// post sends the request body to a server whose height is at least minHeight,
+// retrying on other servers until it succeeds. It returns the server url with the response body,
+// the body is nil if no server has the block requested by getblock.
+// While all servers are down or none of them supports the method it waits with growing delays.
+func post(minHeight int, method string, body []byte) (string, []byte) {
+	delay := minRetryDelay
 	for {
-		url, ok := getServer(minHeight)
+		url, ok := getServer(minHeight, method)
 		if !ok {
-			if strings.Contains(params, `"getblock"`) {
+			if !pool.capable(method) {
+				// Servers are not probed yet or none of them supports the method,
+				// probeCapabilities alerts once until the method is available again.
+				log.Printf("No rpc server supports %s\nWaiting for %v before retry\n", method, delay)
+				delay = waitRetry(delay)
+				continue
+			}
+			if method == "getblock" {
 				// Exceed the highest block index, return nil target.
-				return
+				return "", nil
 			}
-			delay := 3
-			fmt.Printf("No server's height higher than or equal to %d\nWaiting for %d seconds before retry\n", minHeight, delay)
-			time.Sleep(time.Duration(delay) * time.Second)
+			fmt.Printf("No server's height higher than or equal to %d\nWaiting for %v before retry\n", minHeight, delay)
+			delay = waitRetry(delay)
 			PrintServerStatus()
 			continue
 		}
 
-		req.SetRequestURI(url)
-		err := client.Do(req, resp)
+		respBody, err := postTo(url, method, body)
 		if err != nil {
-			log.Error.Println(err)
-			serverUnavailable(url)
 			time.Sleep(50 * time.Millisecond)
 			continue
 		}
 
-		break
-
+		return url, respBody
 	}
+}
 
-	bodyBytes := resp.Body()
+// callOnce sends the request to a server whose height is at least minHeight without retrying,
+// it reports whether the response is decoded into target.
+func callOnce(minHeight int, req request, target interface{}) bool {
+	url, ok := getServer(minHeight, req.Method)
+	if !ok {
+		return false
+	}
 
-	err := json.Unmarshal(bodyBytes, target)
+	respBody, err := postTo(url, req.Method, req.body())
 	if err != nil {
-		log.Error.Println(errors.New(eParser.Wrap(err, 0).ErrorStack()))
-		log.Error.Printf("Request body: %v\n", string(requestBody))
-		log.Error.Printf("Response: %v\n", string(bodyBytes))
+		return false
 	}
+
+	if err := req.check(respBody); err != nil {
+		if _, failed := err.(*RPCError); !failed {
+			callErrors.WithLabelValues(req.Method, url, "id").Inc()
+			serverUnavailable(url)
+		}
+		log.Error.Printf("Failed to call %s on %s: %v\n", req.Method, url, err)
+		return false
+	}
+
+	if err := json.Unmarshal(respBody, target); err != nil {
+		callErrors.WithLabelValues(req.Method, url, "decode").Inc()
+		log.Error.Printf("Invalid response of %s from %s: %v\n", req.Method, url, err)
+		return false
+	}
+
+	return true
+}
+
+// waitRetry sleeps for the delay and returns the doubled delay of the next retry.
+func waitRetry(delay time.Duration) time.Duration {
+	time.Sleep(delay)
+	return nextRetryDelay(delay)
+}
+
+// waitRetryContext is like waitRetry, but it returns the error of ctx if ctx is done before the delay passes.
+func waitRetryContext(ctx context.Context, delay time.Duration) (time.Duration, error) {
+	select {
+	case <-ctx.Done():
+		return delay, ctx.Err()
+	case <-time.After(delay):
+		return nextRetryDelay(delay), nil
+	}
+}
+
+func nextRetryDelay(delay time.Duration) time.Duration {
+	delay *= 2
+	if delay > maxRetryDelay {
+		delay = maxRetryDelay
+	}
+	return delay
+}
+
+// postTo sends the request body to the given server and returns the response body,
+// the result is recorded in the server pool and method labels metrics of the request.
+func postTo(url string, method string, requestBody []byte) ([]byte, error) {
+	resp := fasthttp.AcquireResponse()
+	req := fasthttp.AcquireRequest()
+	defer fasthttp.ReleaseResponse(resp)
+	defer fasthttp.ReleaseRequest(req)
+
+	req.Header.SetMethod("POST")
+	req.SetBody(requestBody)
+	req.SetRequestURI(url)
+
+	start := time.Now()
+	if err := httpClient.Do(req, resp); err != nil {
+		callErrors.WithLabelValues(method, url, "request").Inc()
+		log.Error.Println(err)
+		pool.failed(url)
+		return nil, err
+	}
+
+	latency := time.Since(start)
+	callDuration.WithLabelValues(method).Observe(latency.Seconds())
+
+	// Batches take longer than single calls, they are not latency samples.
+	if method == "batch" {
+		latency = 0
+	}
+	pool.succeeded(url, latency)
+
+	// The response is released on return.
+	return append([]byte(nil), resp.Body()...), nil
 }
 
 // func call(minHeight int, params string, target interface{}) {
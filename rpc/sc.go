@@ -1,20 +1,20 @@
 package rpc
 
 import (
-	"math/big"
+	"squirrel/amount"
 )
 
 // SmartContractResponse is the struct of returning data from 'invokescript' rpc call.
 type SmartContractResponse struct {
-	jsonRPCResponse
+	JSONRPCResponse
 	Result *RawSmartContractCallResult `json:"result"`
 }
 
 // RawSmartContractCallResult is the inner struct of struct 'SmartContractResponse'.
 type RawSmartContractCallResult struct {
-	Script      string     `json:"script"`
-	State       string     `json:"state"`
-	GasConsumed *big.Float `json:"gas_consumed"`
+	Script      string        `json:"script"`
+	State       string        `json:"state"`
+	GasConsumed amount.Amount `json:"gas_consumed"`
 	Stack       []RawStack
 }
 
@@ -57,11 +57,8 @@ type RawStack struct {
 
 // SmartContractRPCCall returns result of 'invokescript' rpc call.
 func SmartContractRPCCall(minHeight int, scripts string) *RawSmartContractCallResult {
-	params := []interface{}{scripts}
-	args := getRPCRequestBody("invokescript", params)
-
 	respData := SmartContractResponse{}
-	rpcCall(minHeight, args, &respData)
+	rpcCall(minHeight, newRequest("invokescript", scripts), &respData)
 
 	return respData.Result
 }
@@ -2,24 +2,21 @@ package rpc
 
 import (
 	"bytes"
+	"context"
 	"encoding/json"
 	"fmt"
-	"math/rand"
+	"io/ioutil"
 	"squirrel/config"
 	"squirrel/mail"
 	"squirrel/util"
-	"strings"
-	"sync"
 	"time"
 )
 
 var (
-	// servers stores all neo rpc urls with its height
-	// For those (temporarily)unaccessable servers,
-	// their height will be set to -1.
+	// pool holds all neo rpc servers with their heights and request statistics.
+	// For those (temporarily)unaccessable servers, their height will be set to -1.
 	// These servers' heights will be refreshed timely.
-	servers map[string]int
-	sLock   sync.Mutex
+	pool = newServerPool(config.GetRPCLatencyWeight)
 
 	// BestHeight indicates current highest height.
 	BestHeight util.SafeCounter
@@ -31,66 +28,54 @@ type ServerInfo struct {
 	height int
 }
 
-// getServer randomly returns one of rpc servers whose height higher than minHeight.
-func getServer(minHeight int) (string, bool) {
+// getServer returns one of rpc servers whose height higher than minHeight and supporting the method,
+// servers with lower latency and error rate are preferred.
+// It returns false if no server is available, callers check pool.capable to tell
+// if the method is unsupported by all servers or no server is high enough.
+func getServer(minHeight int, method string) (string, bool) {
 	if minHeight < 0 {
 		err := fmt.Errorf("minHeight(%d) cannot lower than zero", minHeight)
 		panic(err)
 	}
 
-	sLock.Lock()
-	defer sLock.Unlock()
-
-	// Suppose all servers are qualified.
-	candidates := []string{}
-
-	for url, height := range servers {
-		if height >= int(minHeight) {
-			// Always select localhost rpc server if valid.
-			if strings.Contains(url, "127.0.0.1") ||
-				strings.Contains(url, "localhost") {
-				candidates = append(candidates, url)
-			}
-
-			candidates = append(candidates, url)
-		}
-	}
-
-	l := len(candidates)
-	if l == 0 {
-		return "", false
-	}
-
-	return candidates[rand.Intn(l)], true
+	return pool.pick(minHeight, method)
 }
 
+// serverUnavailable records a failed request to the server.
 func serverUnavailable(url string) {
-	sLock.Lock()
-	defer sLock.Unlock()
+	pool.failed(url)
+}
 
-	// Incase server changed(e.g., reloaded dut to config file change).
-	if _, ok := servers[url]; ok {
-		servers[url] = -1
-	}
+// RejectServer records that the server returned invalid data of method,
+// requests are sent to other servers until its circuit is closed again.
+func RejectServer(url string, method string) {
+	callErrors.WithLabelValues(method, url, "invalid").Inc()
+	pool.reject(url)
 }
 
-func PrintServerStatus() {
-	sLock.Lock()
-	defer sLock.Unlock()
+// Servers returns status of all rpc servers.
+func Servers() []ServerStatus {
+	return pool.statuses()
+}
 
-	for host, height := range servers {
-		fmt.Printf("%s: %d\n", host, height)
+func PrintServerStatus() {
+	for _, s := range pool.statuses() {
+		fmt.Printf("%s: %d, latency %v, error rate %.2f, circuit open %v\n", s.URL, s.Height, s.Latency, s.ErrorRate, s.CircuitOpen)
 	}
 }
 
-// TraceBestHeight starts a.
-func TraceBestHeight() {
+// TraceBestHeight refreshes heights of rpc servers until ctx is done.
+func TraceBestHeight(ctx context.Context) {
 	defer mail.AlertIfErr()
 
 	for {
 		RefreshServers()
 
-		time.Sleep(3 * time.Second)
+		select {
+		case <-ctx.Done():
+			return
+		case <-time.After(3 * time.Second):
+		}
 	}
 }
 
@@ -99,9 +84,9 @@ func RefreshServers() int {
 	// It takes time to get heights.
 	serverInfos := getHeights()
 
-	sLock.Lock()
+	pool.setHeights(serverInfos)
+	probeCapabilities()
 
-	servers = serverInfos
 	bestHeight := 0
 	for _, height := range serverInfos {
 		if bestHeight < height {
@@ -110,8 +95,6 @@ func RefreshServers() int {
 	}
 	BestHeight.Set(bestHeight)
 
-	sLock.Unlock()
-
 	return bestHeight
 }
 
@@ -147,16 +130,44 @@ func getHeights() map[string]int {
 
 // getHeightFrom returns current block index of the given rpc server.
 func getHeightFrom(url string) (int, error) {
-	params := []interface{}{}
-	args := getRPCRequestBody("getblockcount", params)
+	req := newRequest("getblockcount")
 
-	respData := BlockCountRespponse{}
-	resp, err := client.Post(url, "application/json", bytes.NewBuffer([]byte(args)))
+	start := time.Now()
+	resp, err := client.Post(url, "application/json", bytes.NewBuffer(req.body()))
 	if err != nil {
+		callErrors.WithLabelValues(req.Method, url, "request").Inc()
+		pool.failed(url)
 		return -1, err
 	}
 	defer resp.Body.Close()
 
-	json.NewDecoder(resp.Body).Decode(&respData)
+	body, err := ioutil.ReadAll(resp.Body)
+	if err == nil {
+		err = req.check(body)
+	}
+	if err != nil {
+		callErrors.WithLabelValues(req.Method, url, "decode").Inc()
+		pool.failed(url)
+		return -1, err
+	}
+	pool.succeeded(url, time.Since(start))
+
+	respData := BlockCountRespponse{}
+	if err := json.Unmarshal(body, &respData); err != nil {
+		return -1, err
+	}
+
 	return respData.Result - 1, nil
 }
+
+// AvailableServers returns the number of rpc servers whose height is known and circuit is closed.
+func AvailableServers() int {
+	cnt := 0
+	for _, s := range pool.statuses() {
+		if s.Height >= 0 && !s.CircuitOpen {
+			cnt++
+		}
+	}
+
+	return cnt
+}
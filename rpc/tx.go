@@ -1,50 +1,76 @@
 package rpc
 
-import "math/big"
+import "squirrel/amount"
 
 // RawTx is the transaction part of block data.
 type RawTx struct {
-	TxID       string `json:"txid"`
-	Size       uint   `json:"size"`
-	Type       string `json:"type"`
-	Version    uint   `json:"version"`
-	Attributes []struct {
-		Usage string `json:"usage"`
-		Data  string `json:"data"`
-	}
-	Vin []struct {
-		TxID string `json:"txid"`
-		Vout uint16 `json:"vout"`
-	}
-
-	Vout []struct {
-		N       uint16     `json:"n"`
-		Asset   string     `json:"asset"`
-		Value   *big.Float `json:"value"`
-		Address string     `json:"address"`
-	}
-	SysFee  *big.Float `json:"sys_fee"`
-	NetFee  *big.Float `json:"net_fee"`
-	Scripts []struct {
-		Invocation   string `json:"invocation"`
-		Verification string `json:"verification"`
-	}
-	Asset struct {
-		Type string `json:"type"`
-		Name []struct {
-			Lang string `json:"lang"`
-			Name string `json:"name"`
-		}
-		Amount    *big.Float `json:"amount"`
-		Precision uint8      `json:"precision"`
-		Owner     string     `json:"owner"`
-		Admin     string     `json:"admin"`
-	}
-	Claims []struct {
-		TxID string `json:"txid"`
-		Vout uint16 `json:"vout"`
-	}
-	Script string     `json:"script"`
-	Nonce  int64      `json:"nonce"`
-	Gas    *big.Float `json:"gas"`
+	TxID       string           `json:"txid"`
+	Size       uint             `json:"size"`
+	Type       string           `json:"type"`
+	Version    uint             `json:"version"`
+	Attributes []RawTxAttribute `json:"attributes"`
+	Vin        []RawTxVin       `json:"vin"`
+	Vout       []RawTxVout      `json:"vout"`
+	SysFee     amount.Amount    `json:"sys_fee"`
+	NetFee     amount.Amount    `json:"net_fee"`
+	Scripts    []RawTxScript    `json:"scripts"`
+	Asset      *RawTxAsset      `json:"asset,omitempty"`
+	Claims     []RawTxClaim     `json:"claims,omitempty"`
+	Script     string           `json:"script,omitempty"`
+	Nonce      int64            `json:"nonce,omitempty"`
+	Gas        amount.Amount    `json:"gas,omitempty"`
+	PublicKey  string           `json:"pubkey,omitempty"`
+
+	// Unsigned is the serialized transaction without witnesses, it is only kept for decoded blocks.
+	Unsigned []byte `json:"-"`
+	// InvalidWitness is the reason why a witness of the transaction does not verify.
+	InvalidWitness string `json:"-"`
+}
+
+// RawTxAttribute is the attribute part of raw transaction.
+type RawTxAttribute struct {
+	Usage string `json:"usage"`
+	Data  string `json:"data"`
+}
+
+// RawTxVin is the input part of raw transaction.
+type RawTxVin struct {
+	TxID string `json:"txid"`
+	Vout uint16 `json:"vout"`
+}
+
+// RawTxVout is the output part of raw transaction.
+type RawTxVout struct {
+	N       uint16        `json:"n"`
+	Asset   string        `json:"asset"`
+	Value   amount.Amount `json:"value"`
+	Address string        `json:"address"`
+}
+
+// RawTxScript is the witness part of raw transaction.
+type RawTxScript struct {
+	Invocation   string `json:"invocation"`
+	Verification string `json:"verification"`
+}
+
+// RawTxAsset is the asset part of register transaction.
+type RawTxAsset struct {
+	Type      string           `json:"type"`
+	Name      []RawTxAssetName `json:"name"`
+	Amount    amount.Amount    `json:"amount"`
+	Precision uint8            `json:"precision"`
+	Owner     string           `json:"owner"`
+	Admin     string           `json:"admin"`
+}
+
+// RawTxAssetName is a localized name of registered asset.
+type RawTxAssetName struct {
+	Lang string `json:"lang"`
+	Name string `json:"name"`
+}
+
+// RawTxClaim is the claimed reference part of claim transaction.
+type RawTxClaim struct {
+	TxID string `json:"txid"`
+	Vout uint16 `json:"vout"`
 }
@@ -1,68 +1,66 @@
 package smartcontract
 
 import (
-	"bytes"
-	"encoding/binary"
 	"encoding/hex"
 	"encoding/json"
-	"fmt"
-	"math"
-	"math/big"
+	"squirrel/amount"
 	"squirrel/log"
-	"strings"
 
 	"squirrel/asset"
 	"squirrel/util"
 )
 
-// AssetFingerPrint is used to identify if a script is asset registration script.
-const AssetFingerPrint = "68104e656f2e41737365742e437265617465"
+// Interop services registering assets, including the name of the AntShares era.
+var assetCreateServices = []string{"Neo.Asset.Create", "AntShares.Asset.Create"}
 
-// GetAssetInfo parses script content and return asset struct.
+// GetAssetInfo parses an invocation script ending with a Neo.Asset.Create call and returns the asset,
+// it returns nil for other scripts.
 func GetAssetInfo(script string) *asset.Asset {
-	if !strings.HasSuffix(script, AssetFingerPrint) {
-		log.Printf("Can not get asset info from script: %s. Scripts format not match.", script)
+	instrs, err := DisassembleHex(script)
+	if err != nil || len(instrs) == 0 || SysCallIndex(instrs[len(instrs)-1:], assetCreateServices...) == -1 {
 		return nil
 	}
 
-	dataStack := ReadScript(script)
-	if len(*dataStack) == 0 {
+	// Arguments are popped in the order of asset type, name, amount, precision, owner, admin and issuer.
+	args, ok := PushedArgs(instrs, len(instrs)-1, 7)
+	if !ok {
+		log.Printf("Can not get asset info from script: %s. Arguments are not constants.", script)
 		return nil
 	}
 
-	dataStack.PopData()
+	assetType := getAssetType(args[0])
+	name := getAssetName(args[1])
+	precision := getAssetPrecision(args[3])
 
 	asset := asset.Asset{
 		// BlockIndex
 		// Time
 		// Version
 		// AssetID
-		Type:      getAssetType(dataStack.PopData()),
-		Name:      getAssetName(dataStack.PopData()),
-		Amount:    getAssetAmount(dataStack.PopData()),
-		Available: big.NewFloat(0),
-		Precision: getAssetPrecision(dataStack.PopData()),
-		Owner:     getAssetOwner(dataStack.PopData()),
-		Admin:     getAssetAdmin(dataStack.PopData()),
-		Issuer:    getAssetIssuer(dataStack.PopData()),
+		Type:      assetType,
+		Name:      name,
+		Amount:    getAssetAmount(args[2], precision),
+		Available: amount.Zero,
+		Precision: precision,
+		Owner:     getAssetOwner(args[4]),
+		Admin:     getAssetAdmin(args[5]),
+		Issuer:    getAssetIssuer(args[6]),
 		// Expiration
 		Frozen: false,
 		// Addresses
 		// Transactions
 	}
 
-	asset.Amount = new(big.Float).Quo(asset.Amount, big.NewFloat(math.Pow10(int(asset.Precision))))
-
 	return &asset
 }
 
 func getAssetType(data []byte) string {
-	val, err := binary.ReadUvarint(bytes.NewBuffer(data))
-	if err != nil {
-		panic(fmt.Errorf("can not convert asset type from data: %s: %s", hex.EncodeToString(data), err))
+	val := util.BytesToBigInt(data)
+	if !val.IsInt64() {
+		return "Unknown"
 	}
 
-	switch val {
+	switch val.Int64() {
 	case 0x40:
 		return "CreditFlag"
 	case 0x80:
@@ -99,13 +97,12 @@ func getAssetName(data []byte) string {
 	return name[0].Name
 }
 
-func getAssetAmount(data []byte) *big.Float {
-	amount := util.BytesToBigFloat(data)
-	return amount
+func getAssetAmount(data []byte, precision uint8) amount.Amount {
+	return amount.New(util.BytesToBigInt(data), precision)
 }
 
 func getAssetPrecision(data []byte) uint8 {
-	return uint8(util.GetValueFromBytes(data))
+	return uint8(util.BytesToBigInt(data).Uint64())
 }
 
 func getAssetOwner(data []byte) string {
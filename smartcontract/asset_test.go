@@ -0,0 +1,47 @@
+package smartcontract
+
+import (
+	"bytes"
+	"encoding/hex"
+	"math/big"
+	"squirrel/amount"
+	"squirrel/util"
+	"testing"
+)
+
+func TestGetAssetInfo(t *testing.T) {
+	owner, _ := hex.DecodeString("024c7b7fb6c310fccf1ba33b082519d82964ea93868d676662d4a59ad548df0e7d")
+	admin := bytes.Repeat([]byte{0x01}, 20)
+	issuer := bytes.Repeat([]byte{0x02}, 20)
+
+	sb := scriptBuilder{}
+	sb.EmitPushBytes(issuer)
+	sb.EmitPushBytes(admin)
+	sb.EmitPushBytes(owner)
+	sb.EmitPush(8)
+	sb.EmitPushBytes(util.ReverseBytes(big.NewInt(100000000000).Bytes()))
+	sb.EmitPushBytes([]byte(`[{"lang":"en","name":"Test"}]`))
+	sb.EmitPushBytes([]byte{0x60})
+	sb.Emit(byte(OpSysCall))
+	sb.b.WriteByte(byte(len(assetCreateServices[0])))
+	sb.b.WriteString(assetCreateServices[0])
+	script := hex.EncodeToString(sb.b.Bytes())
+
+	a := GetAssetInfo(script)
+	if a == nil {
+		t.Fatal("asset is not parsed")
+	}
+
+	if a.Type != "Token" || a.Name != "Test" || a.Precision != 8 ||
+		a.Amount.Cmp(amount.NewFromInt64(1000, 0)) != 0 ||
+		a.Owner != hex.EncodeToString(owner) ||
+		a.Admin != util.GetAddressFromScriptHash(admin) ||
+		a.Issuer != util.GetAddressFromScriptHash(issuer) {
+		t.Fatalf("unexpected asset %+v", a)
+	}
+
+	// Without the call it is not an asset registration.
+	if GetAssetInfo(script[:len(script)-2*(len(assetCreateServices[0])+2)]) != nil {
+		t.Error("asset is parsed from script without Neo.Asset.Create")
+	}
+}
@@ -0,0 +1,217 @@
+package smartcontract
+
+import (
+	"bytes"
+	"squirrel/util"
+)
+
+// Interop services deploying contracts, including names of the AntShares era.
+var (
+	contractCreateServices  = []string{"Neo.Contract.Create", "AntShares.Contract.Create"}
+	contractMigrateServices = []string{"Neo.Contract.Migrate", "AntShares.Contract.Migrate"}
+)
+
+// Call is a call of a contract with a static script hash by APPCALL or TAILCALL.
+type Call struct {
+	ScriptHash []byte
+	// Method is the constant pushed right before the call, nil if there is none.
+	Method []byte
+	// Args are the elements of the array packed before the method, nil unless they are constants.
+	Args [][]byte
+}
+
+// Calls returns the contract calls of the instructions in script order.
+func Calls(instrs []Instruction) []Call {
+	calls := []Call{}
+
+	for idx, instr := range instrs {
+		if instr.OpCode != OpAppCall && instr.OpCode != OpTailCall {
+			continue
+		}
+
+		scriptHash, ok := instr.CallTarget()
+		if !ok {
+			continue
+		}
+
+		call := Call{ScriptHash: scriptHash}
+		if idx > 0 {
+			if method, ok := instrs[idx-1].PushData(); ok {
+				call.Method = method
+				call.Args = packedArgs(instrs, idx-1)
+			}
+		}
+
+		calls = append(calls, call)
+	}
+
+	return calls
+}
+
+// packedArgs returns the elements of the array packed by PUSH n, PACK right before instruction idx.
+func packedArgs(instrs []Instruction, idx int) [][]byte {
+	if idx < 2 || instrs[idx-1].OpCode != OpPack {
+		return nil
+	}
+
+	data, ok := instrs[idx-2].PushData()
+	if !ok {
+		return nil
+	}
+
+	count, ok := smallInt(data)
+	if !ok {
+		return nil
+	}
+
+	args, ok := PushedArgs(instrs, idx-2, count)
+	if !ok {
+		return nil
+	}
+
+	return args
+}
+
+// PushedArgs returns the constants pushed by the n instructions right before instruction idx,
+// the nearest first, which is the order they are popped by the instruction.
+func PushedArgs(instrs []Instruction, idx int, n int) ([][]byte, bool) {
+	if n < 0 || n > idx || idx > len(instrs) {
+		return nil, false
+	}
+
+	args := make([][]byte, n)
+	for i := 0; i < n; i++ {
+		data, ok := instrs[idx-1-i].PushData()
+		if !ok {
+			return nil, false
+		}
+		args[i] = data
+	}
+
+	return args, true
+}
+
+// SysCallIndex returns the index of the first instruction calling one of the interop services, or -1.
+func SysCallIndex(instrs []Instruction, services ...string) int {
+	for idx, instr := range instrs {
+		name, ok := instr.SysCall()
+		if !ok {
+			continue
+		}
+
+		for _, service := range services {
+			if name == service {
+				return idx
+			}
+		}
+	}
+
+	return -1
+}
+
+// PushesConstants reports whether each of the constants is pushed by one of the instructions.
+func PushesConstants(instrs []Instruction, constants ...string) bool {
+	for _, constant := range constants {
+		found := false
+		for _, instr := range instrs {
+			if data, ok := instr.PushData(); ok && bytes.Equal(data, []byte(constant)) {
+				found = true
+				break
+			}
+		}
+
+		if !found {
+			return false
+		}
+	}
+
+	return true
+}
+
+// Contract holds the arguments of Neo.Contract.Create and Neo.Contract.Migrate.
+type Contract struct {
+	Script        []byte
+	ParameterList []byte
+	ReturnType    byte
+	Properties    byte
+	Name          string
+	Version       string
+	Author        string
+	Email         string
+	Description   string
+}
+
+// ParseContract returns the contract of the arguments of Neo.Contract.Create, the first popped first.
+func ParseContract(args [][]byte) (*Contract, bool) {
+	if len(args) != 9 || len(args[0]) == 0 {
+		return nil, false
+	}
+
+	returnType, ok := smallInt(args[2])
+	if !ok || returnType > 0xff {
+		return nil, false
+	}
+
+	properties, ok := smallInt(args[3])
+	if !ok || properties > 0xff {
+		return nil, false
+	}
+
+	return &Contract{
+		Script:        args[0],
+		ParameterList: args[1],
+		ReturnType:    byte(returnType),
+		Properties:    byte(properties),
+		Name:          string(args[4]),
+		Version:       string(args[5]),
+		Author:        string(args[6]),
+		Email:         string(args[7]),
+		Description:   string(args[8]),
+	}, true
+}
+
+// CreatedContract returns the contract deployed by Neo.Contract.Create in the instructions.
+func CreatedContract(instrs []Instruction) (*Contract, bool) {
+	idx := SysCallIndex(instrs, contractCreateServices...)
+	if idx == -1 {
+		return nil, false
+	}
+
+	args, ok := PushedArgs(instrs, idx, 9)
+	if !ok {
+		return nil, false
+	}
+
+	return ParseContract(args)
+}
+
+// NeedStorage reports whether the contract has storage.
+func (c *Contract) NeedStorage() bool {
+	return c.Properties&0x01 != 0
+}
+
+// Instructions returns the disassembled script of the contract,
+// instructions after a part which can not be disassembled are omitted.
+func (c *Contract) Instructions() []Instruction {
+	instrs, _ := Disassemble(c.Script)
+	return instrs
+}
+
+// Migratable reports whether the contract calls Neo.Contract.Migrate.
+func (c *Contract) Migratable() bool {
+	return SysCallIndex(c.Instructions(), contractMigrateServices...) != -1
+}
+
+// smallInt returns the non-negative integer of little-endian data which fits in an int32.
+func smallInt(data []byte) (int, bool) {
+	if len(data) > 0 && data[len(data)-1]&0x80 != 0 {
+		return 0, false
+	}
+
+	value := util.BytesToBigInt(data)
+	if value.BitLen() > 31 {
+		return 0, false
+	}
+
+	return int(value.Int64()), true
+}
@@ -0,0 +1,252 @@
+package smartcontract
+
+import "fmt"
+
+// OpCode is an instruction code of the NEO2 virtual machine.
+type OpCode byte
+
+// OpCodes of the NEO2 virtual machine.
+const (
+	OpPush0       OpCode = 0x00
+	OpPushBytes1  OpCode = 0x01
+	OpPushBytes33 OpCode = 0x21
+	OpPushBytes75 OpCode = 0x4B
+	OpPushData1   OpCode = 0x4C
+	OpPushData2   OpCode = 0x4D
+	OpPushData4   OpCode = 0x4E
+	OpPushM1      OpCode = 0x4F
+	OpPush1       OpCode = 0x51
+	OpPush16      OpCode = 0x60
+
+	OpNop      OpCode = 0x61
+	OpJmp      OpCode = 0x62
+	OpJmpIf    OpCode = 0x63
+	OpJmpIfNot OpCode = 0x64
+	OpCall     OpCode = 0x65
+	OpRet      OpCode = 0x66
+	OpAppCall  OpCode = 0x67
+	OpSysCall  OpCode = 0x68
+	OpTailCall OpCode = 0x69
+
+	OpDupFromAltStack OpCode = 0x6A
+	OpToAltStack      OpCode = 0x6B
+	OpFromAltStack    OpCode = 0x6C
+	OpXDrop           OpCode = 0x6D
+	OpXSwap           OpCode = 0x72
+	OpXTuck           OpCode = 0x73
+	OpDepth           OpCode = 0x74
+	OpDrop            OpCode = 0x75
+	OpDup             OpCode = 0x76
+	OpNip             OpCode = 0x77
+	OpOver            OpCode = 0x78
+	OpPick            OpCode = 0x79
+	OpRoll            OpCode = 0x7A
+	OpRot             OpCode = 0x7B
+	OpSwap            OpCode = 0x7C
+	OpTuck            OpCode = 0x7D
+
+	OpCat    OpCode = 0x7E
+	OpSubStr OpCode = 0x7F
+	OpLeft   OpCode = 0x80
+	OpRight  OpCode = 0x81
+	OpSize   OpCode = 0x82
+
+	OpInvert OpCode = 0x83
+	OpAnd    OpCode = 0x84
+	OpOr     OpCode = 0x85
+	OpXor    OpCode = 0x86
+	OpEqual  OpCode = 0x87
+
+	OpInc         OpCode = 0x8B
+	OpDec         OpCode = 0x8C
+	OpSign        OpCode = 0x8D
+	OpNegate      OpCode = 0x8F
+	OpAbs         OpCode = 0x90
+	OpNot         OpCode = 0x91
+	OpNz          OpCode = 0x92
+	OpAdd         OpCode = 0x93
+	OpSub         OpCode = 0x94
+	OpMul         OpCode = 0x95
+	OpDiv         OpCode = 0x96
+	OpMod         OpCode = 0x97
+	OpShl         OpCode = 0x98
+	OpShr         OpCode = 0x99
+	OpBoolAnd     OpCode = 0x9A
+	OpBoolOr      OpCode = 0x9B
+	OpNumEqual    OpCode = 0x9C
+	OpNumNotEqual OpCode = 0x9E
+	OpLt          OpCode = 0x9F
+	OpGt          OpCode = 0xA0
+	OpLte         OpCode = 0xA1
+	OpGte         OpCode = 0xA2
+	OpMin         OpCode = 0xA3
+	OpMax         OpCode = 0xA4
+	OpWithin      OpCode = 0xA5
+
+	OpSha1          OpCode = 0xA7
+	OpSha256        OpCode = 0xA8
+	OpHash160       OpCode = 0xA9
+	OpHash256       OpCode = 0xAA
+	OpCheckSig      OpCode = 0xAC
+	OpVerify        OpCode = 0xAD
+	OpCheckMultiSig OpCode = 0xAE
+
+	OpArraySize OpCode = 0xC0
+	OpPack      OpCode = 0xC1
+	OpUnpack    OpCode = 0xC2
+	OpPickItem  OpCode = 0xC3
+	OpSetItem   OpCode = 0xC4
+	OpNewArray  OpCode = 0xC5
+	OpNewStruct OpCode = 0xC6
+	OpNewMap    OpCode = 0xC7
+	OpAppend    OpCode = 0xC8
+	OpReverse   OpCode = 0xC9
+	OpRemove    OpCode = 0xCA
+	OpHasKey    OpCode = 0xCB
+	OpKeys      OpCode = 0xCC
+	OpValues    OpCode = 0xCD
+
+	OpCallI   OpCode = 0xE0
+	OpCallE   OpCode = 0xE1
+	OpCallED  OpCode = 0xE2
+	OpCallET  OpCode = 0xE3
+	OpCallEDT OpCode = 0xE4
+
+	OpThrow      OpCode = 0xF0
+	OpThrowIfNot OpCode = 0xF1
+)
+
+var opNames = map[OpCode]string{
+	OpPush0:     "PUSH0",
+	OpPushData1: "PUSHDATA1",
+	OpPushData2: "PUSHDATA2",
+	OpPushData4: "PUSHDATA4",
+	OpPushM1:    "PUSHM1",
+
+	OpNop:      "NOP",
+	OpJmp:      "JMP",
+	OpJmpIf:    "JMPIF",
+	OpJmpIfNot: "JMPIFNOT",
+	OpCall:     "CALL",
+	OpRet:      "RET",
+	OpAppCall:  "APPCALL",
+	OpSysCall:  "SYSCALL",
+	OpTailCall: "TAILCALL",
+
+	OpDupFromAltStack: "DUPFROMALTSTACK",
+	OpToAltStack:      "TOALTSTACK",
+	OpFromAltStack:    "FROMALTSTACK",
+	OpXDrop:           "XDROP",
+	OpXSwap:           "XSWAP",
+	OpXTuck:           "XTUCK",
+	OpDepth:           "DEPTH",
+	OpDrop:            "DROP",
+	OpDup:             "DUP",
+	OpNip:             "NIP",
+	OpOver:            "OVER",
+	OpPick:            "PICK",
+	OpRoll:            "ROLL",
+	OpRot:             "ROT",
+	OpSwap:            "SWAP",
+	OpTuck:            "TUCK",
+
+	OpCat:    "CAT",
+	OpSubStr: "SUBSTR",
+	OpLeft:   "LEFT",
+	OpRight:  "RIGHT",
+	OpSize:   "SIZE",
+
+	OpInvert: "INVERT",
+	OpAnd:    "AND",
+	OpOr:     "OR",
+	OpXor:    "XOR",
+	OpEqual:  "EQUAL",
+
+	OpInc:         "INC",
+	OpDec:         "DEC",
+	OpSign:        "SIGN",
+	OpNegate:      "NEGATE",
+	OpAbs:         "ABS",
+	OpNot:         "NOT",
+	OpNz:          "NZ",
+	OpAdd:         "ADD",
+	OpSub:         "SUB",
+	OpMul:         "MUL",
+	OpDiv:         "DIV",
+	OpMod:         "MOD",
+	OpShl:         "SHL",
+	OpShr:         "SHR",
+	OpBoolAnd:     "BOOLAND",
+	OpBoolOr:      "BOOLOR",
+	OpNumEqual:    "NUMEQUAL",
+	OpNumNotEqual: "NUMNOTEQUAL",
+	OpLt:          "LT",
+	OpGt:          "GT",
+	OpLte:         "LTE",
+	OpGte:         "GTE",
+	OpMin:         "MIN",
+	OpMax:         "MAX",
+	OpWithin:      "WITHIN",
+
+	OpSha1:          "SHA1",
+	OpSha256:        "SHA256",
+	OpHash160:       "HASH160",
+	OpHash256:       "HASH256",
+	OpCheckSig:      "CHECKSIG",
+	OpVerify:        "VERIFY",
+	OpCheckMultiSig: "CHECKMULTISIG",
+
+	OpArraySize: "ARRAYSIZE",
+	OpPack:      "PACK",
+	OpUnpack:    "UNPACK",
+	OpPickItem:  "PICKITEM",
+	OpSetItem:   "SETITEM",
+	OpNewArray:  "NEWARRAY",
+	OpNewStruct: "NEWSTRUCT",
+	OpNewMap:    "NEWMAP",
+	OpAppend:    "APPEND",
+	OpReverse:   "REVERSE",
+	OpRemove:    "REMOVE",
+	OpHasKey:    "HASKEY",
+	OpKeys:      "KEYS",
+	OpValues:    "VALUES",
+
+	OpCallI:   "CALL_I",
+	OpCallE:   "CALL_E",
+	OpCallED:  "CALL_ED",
+	OpCallET:  "CALL_ET",
+	OpCallEDT: "CALL_EDT",
+
+	OpThrow:      "THROW",
+	OpThrowIfNot: "THROWIFNOT",
+}
+
+// Valid reports whether op is an opcode of the NEO2 virtual machine.
+func (op OpCode) Valid() bool {
+	if op.isPushBytes() || op >= OpPush1 && op <= OpPush16 {
+		return true
+	}
+
+	_, ok := opNames[op]
+	return ok
+}
+
+// String returns the name of the opcode.
+func (op OpCode) String() string {
+	switch {
+	case op.isPushBytes():
+		return fmt.Sprintf("PUSHBYTES%d", op)
+	case op >= OpPush1 && op <= OpPush16:
+		return fmt.Sprintf("PUSH%d", op-OpPush1+1)
+	}
+
+	if name, ok := opNames[op]; ok {
+		return name
+	}
+
+	return fmt.Sprintf("UNKNOWN(%#02x)", byte(op))
+}
+
+func (op OpCode) isPushBytes() bool {
+	return op >= OpPushBytes1 && op <= OpPushBytes75
+}
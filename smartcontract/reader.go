@@ -1,215 +1,234 @@
 package smartcontract
 
 import (
+	"bytes"
 	"encoding/binary"
 	"encoding/hex"
 	"fmt"
+	"squirrel/util"
 )
 
+// maxSysCallNameLength is the longest interop service name accepted by NEO2 nodes.
+const maxSysCallNameLength = 252
+
 type scriptContext struct {
-	Position uint64
+	Position int
 	Context  []byte
 }
 
-// OpCodeData stores opcode with its data.
-type OpCodeData struct {
-	OpCode byte
-	Data   []byte
-}
+func (sc *scriptContext) readBytes(length int) ([]byte, error) {
+	if length < 0 || sc.Position+length > len(sc.Context) {
+		return nil, fmt.Errorf("failed to read %d bytes at %d", length, sc.Position)
+	}
 
-// DataStack is the stack of opcodes with its data(opcode without data is omitted).
-// Stack direction: bottom(0) ---> top(N)
-type DataStack []*OpCodeData
+	data := sc.Context[sc.Position : sc.Position+length]
+	sc.Position += length
+	return data, nil
+}
 
-// Copy returns a deep copied data stack.
-func (d *DataStack) Copy() *DataStack {
-	newD := DataStack{}
-	for _, opCodeData := range *d {
-		newD.push(opCodeData.OpCode, opCodeData.Data)
+func (sc *scriptContext) readLength(size int) (int, error) {
+	data, err := sc.readBytes(size)
+	if err != nil {
+		return 0, err
 	}
 
-	return &newD
+	switch size {
+	case 1:
+		return int(data[0]), nil
+	case 2:
+		return int(binary.LittleEndian.Uint16(data)), nil
+	default:
+		return int(binary.LittleEndian.Uint32(data)), nil
+	}
 }
 
-func (d *DataStack) push(opCode byte, data []byte) {
-	item := &OpCodeData{opCode, data}
-	*d = append(*d, item)
+func (sc *scriptContext) readVarBytes() ([]byte, error) {
+	prefix, err := sc.readLength(1)
+	if err != nil {
+		return nil, err
+	}
+
+	length := prefix
+	switch prefix {
+	case 0xFD:
+		length, err = sc.readLength(2)
+	case 0xFE, 0xFF:
+		// Longer lengths exceed any limit of operands.
+		length, err = sc.readLength(4)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	return sc.readBytes(length)
 }
 
-// PopData pops item data value from top.
-func (d *DataStack) PopData() []byte {
-	_, data := (*d).PopItem()
-	return data
+// Instruction is a disassembled instruction of a script.
+type Instruction struct {
+	// Offset is the position of the opcode in the script.
+	Offset int
+	OpCode OpCode
+	// Operand is the data following the opcode without its length prefix,
+	// which is the pushed data of PUSHBYTES and PUSHDATA and the service name of SYSCALL.
+	Operand []byte
+	// Size is the encoded size of the instruction including its opcode.
+	Size int
 }
 
-// PopItem pops item with value from top.
-func (d *DataStack) PopItem() (byte, []byte) {
-	if len(*d) == 0 {
-		panic("Can not pop item from stack. Stack is empty.")
-	}
+// Disassemble decodes all instructions of the script.
+// Instructions decoded before an unknown opcode or a truncated operand are returned with the error.
+func Disassemble(script []byte) ([]Instruction, error) {
+	context := scriptContext{0, script}
+	instructions := []Instruction{}
 
-	item := (*d)[len(*d)-1]
-	*d = (*d)[:len(*d)-1]
-	return item.OpCode, item.Data
-}
+	for context.Position < len(script) {
+		instr := Instruction{
+			Offset: context.Position,
+			OpCode: OpCode(script[context.Position]),
+		}
+		context.Position++
 
-func (sc *scriptContext) readNextOpCode() (byte, bool) {
-	if sc.Position+1 >= uint64(len(sc.Context)) {
-		return 0, false
+		operand, err := readOperand(instr.OpCode, &context)
+		if err != nil {
+			return instructions, fmt.Errorf("%s at %d: %v", instr.OpCode, instr.Offset, err)
+		}
+
+		instr.Operand = operand
+		instr.Size = context.Position - instr.Offset
+		instructions = append(instructions, instr)
 	}
-	opCode := sc.Context[sc.Position]
-	sc.Position++
-	return opCode, true
-}
 
-func (sc *scriptContext) readByte() byte {
-	data := sc.Context[sc.Position]
-	sc.Position++
-	return data
+	return instructions, nil
 }
 
-func (sc *scriptContext) readUint16() uint16 {
-	data := sc.Context[sc.Position : sc.Position+2]
-	sc.Position += 2
-	return binary.LittleEndian.Uint16(data)
+// DisassembleHex is like Disassemble but takes the hex string of the script.
+func DisassembleHex(script string) ([]Instruction, error) {
+	data, err := hex.DecodeString(script)
+	if err != nil {
+		return nil, err
+	}
+
+	return Disassemble(data)
 }
 
-func (sc *scriptContext) readUint32() uint32 {
-	data := sc.Context[sc.Position : sc.Position+4]
-	sc.Position += 4
-	return binary.LittleEndian.Uint32(data)
+func readOperand(op OpCode, context *scriptContext) ([]byte, error) {
+	switch {
+	case op.isPushBytes():
+		return context.readBytes(int(op))
+	case op == OpPushData1 || op == OpPushData2 || op == OpPushData4:
+		length, err := context.readLength(1 << (op - OpPushData1))
+		if err != nil {
+			return nil, err
+		}
+		return context.readBytes(length)
+	case op == OpJmp || op == OpJmpIf || op == OpJmpIfNot || op == OpCall:
+		return context.readBytes(2)
+	case op == OpAppCall || op == OpTailCall:
+		return context.readBytes(20)
+	case op == OpSysCall:
+		name, err := context.readVarBytes()
+		if err != nil {
+			return nil, err
+		}
+		if len(name) > maxSysCallNameLength {
+			return nil, fmt.Errorf("service name of %d bytes", len(name))
+		}
+		return name, nil
+	case op == OpCallI:
+		// Return value count, parameter count and jump offset.
+		return context.readBytes(4)
+	case op == OpCallE || op == OpCallET:
+		// Return value count, parameter count and script hash.
+		return context.readBytes(22)
+	case op == OpCallED || op == OpCallEDT:
+		return context.readBytes(2)
+	case op.Valid():
+		return nil, nil
+	default:
+		return nil, fmt.Errorf("unsupported opCode: %#02x", byte(op))
+	}
 }
 
-func (sc *scriptContext) readUint64() uint64 {
-	data := sc.Context[sc.Position : sc.Position+8]
-	sc.Position += 8
-	return binary.LittleEndian.Uint64(data)
+// IsPush reports whether the instruction only pushes a constant.
+func (i Instruction) IsPush() bool {
+	return i.OpCode <= OpPush16 && i.OpCode != 0x50
 }
 
-func (sc *scriptContext) readBytes(byteLength uint64) ([]byte, error) {
-	if sc.Position+byteLength > uint64(len(sc.Context)) {
-		return nil, fmt.Errorf("failed to read bytes")
+// PushData returns the byte array pushed by the instruction,
+// integers are in the little-endian form of the virtual machine.
+func (i Instruction) PushData() ([]byte, bool) {
+	switch {
+	case i.OpCode == OpPush0:
+		return []byte{}, true
+	case i.OpCode == OpPushM1:
+		return []byte{0xFF}, true
+	case i.OpCode >= OpPush1 && i.OpCode <= OpPush16:
+		return []byte{byte(i.OpCode - OpPush1 + 1)}, true
+	case i.IsPush():
+		return i.Operand, true
 	}
-	data := sc.Context[sc.Position : sc.Position+byteLength]
-	sc.Position += byteLength
-	return data, nil
-}
 
-func (sc *scriptContext) readVarBytes() ([]byte, error) {
-	length := sc.readVarInt()
-	data, err := sc.readBytes(length)
-	return data, err
+	return nil, false
 }
 
-func (sc *scriptContext) readVarInt() uint64 {
-	prefix := sc.readByte()
-	if prefix == 0xFD {
-		return uint64(sc.readUint16())
-	} else if prefix == 0xFE {
-		return uint64(sc.readUint32())
-	} else if prefix == 0xFF {
-		return sc.readUint64()
+// SysCall returns the interop service name called by SYSCALL.
+func (i Instruction) SysCall() (string, bool) {
+	if i.OpCode != OpSysCall {
+		return "", false
 	}
-	return uint64(prefix)
-}
 
-// ReadScript reads script string and extract stack of opcode with data.
-func ReadScript(script string) *DataStack {
-	bytes, _ := hex.DecodeString(script)
-	context := scriptContext{0, bytes}
-	stack := DataStack{}
+	return string(i.Operand), true
+}
 
-	for {
-		opCode, ok := context.readNextOpCode()
-		if !ok {
-			break
-		}
+// CallTarget returns the script hash called by APPCALL, TAILCALL, CALL_E and CALL_ET,
+// it is false for other instructions and for dynamic calls which take the script hash from the stack.
+func (i Instruction) CallTarget() ([]byte, bool) {
+	var scriptHash []byte
+	switch i.OpCode {
+	case OpAppCall, OpTailCall:
+		scriptHash = i.Operand
+	case OpCallE, OpCallET:
+		scriptHash = i.Operand[2:]
+	default:
+		return nil, false
+	}
 
-		if opCode == 0x66 {
-			return &stack
-		}
+	if bytes.Equal(scriptHash, make([]byte, 20)) {
+		return nil, false
+	}
 
-		data, err := getDataFromScript(opCode, &context)
-		if err != nil {
-			return &stack
-		}
+	return scriptHash, true
+}
 
-		if data != nil {
-			stack.push(opCode, data)
-		}
+// JumpTarget returns the script offset which JMP, JMPIF, JMPIFNOT, CALL and CALL_I jump to.
+func (i Instruction) JumpTarget() (int, bool) {
+	switch i.OpCode {
+	case OpJmp, OpJmpIf, OpJmpIfNot, OpCall:
+		return i.Offset + int(int16(binary.LittleEndian.Uint16(i.Operand))), true
+	case OpCallI:
+		return i.Offset + int(int16(binary.LittleEndian.Uint16(i.Operand[2:]))), true
 	}
 
-	return &stack
+	return 0, false
 }
 
-func getDataFromScript(opCode byte, context *scriptContext) ([]byte, error) {
-	switch {
-	case opCode == 0x00:
-		return []byte{0}, nil
-	case opCode >= 0x01 && opCode <= 0x4B:
-		data, err := context.readBytes(uint64(opCode))
-		return data, err
-	case opCode == 0x4C:
-		dataLen := context.readByte()
-		data, err := context.readBytes(uint64(dataLen))
-		return data, err
-	case opCode == 0x4D:
-		dataLen := context.readUint16()
-		data, err := context.readBytes(uint64(dataLen))
-		return data, err
-	case opCode == 0x4E:
-		dataLen := context.readUint32()
-		data, err := context.readBytes(uint64(dataLen))
-		return data, err
-	case opCode == 0x4F:
-		return []byte{0xFF, 0xFF}, nil
-	case opCode >= 0x51 && opCode <= 0x60:
-		data := opCode - 0x51 + 1
-		return []byte{data}, nil
-	case opCode == 0x61:
-		return nil, nil
-	case opCode >= 0x62 && opCode <= 0x64:
-		value := context.readUint16()
-		data := make([]byte, 2)
-		binary.LittleEndian.PutUint16(data, value)
-		return data, nil
-	case opCode == 0x65:
-		data, err := context.readBytes(2)
-		return data, err
-	case opCode == 0x66:
-		return nil, nil
-	case opCode == 0x67:
-		data, err := context.readBytes(20)
-		return data, err
-	case opCode == 0x68:
-		data, err := context.readVarBytes()
-		return data, err
-	case opCode == 0x69:
-		data, err := context.readBytes(20)
-		return data, err
-	case opCode >= 0x6A && opCode <= 0x6D:
-		return nil, nil
-	case opCode >= 0x72 && opCode <= 0x7F:
-		return nil, nil
-	case opCode >= 0x80 && opCode <= 0x87:
-		return nil, nil
-	case opCode >= 0x8B && opCode <= 0x8D:
-		return nil, nil
-	case opCode == 0x8F:
-		return nil, nil
-	case opCode >= 0x90 && opCode <= 0x9C:
-		return nil, nil
-	case opCode >= 0x9E && opCode <= 0x9F:
-		return nil, nil
-	case opCode >= 0xA0 && opCode <= 0xAA:
-		return nil, nil
-	case opCode == 0xAC || opCode == 0xAE:
-		return nil, nil
-	case opCode >= 0xC0 && opCode <= 0xCD:
-		return nil, nil
-	case opCode >= 0xF0 && opCode <= 0xF1:
-		return nil, nil
-	default:
-		return nil, fmt.Errorf("unsupported opCode: %#02x", opCode)
+// String returns the instruction in assembly form prefixed by its offset.
+func (i Instruction) String() string {
+	var operand string
+
+	if target, ok := i.JumpTarget(); ok {
+		operand = fmt.Sprintf("%04x", target)
+	} else if scriptHash, ok := i.CallTarget(); ok {
+		operand = "0x" + util.GetAssetIDFromScriptHash(scriptHash)
+	} else if name, ok := i.SysCall(); ok {
+		operand = name
+	} else if len(i.Operand) > 0 {
+		operand = hex.EncodeToString(i.Operand)
+	}
+
+	if operand == "" {
+		return fmt.Sprintf("%04x %s", i.Offset, i.OpCode)
 	}
+
+	return fmt.Sprintf("%04x %s %s", i.Offset, i.OpCode, operand)
 }
@@ -0,0 +1,194 @@
+package smartcontract
+
+import (
+	"bytes"
+	"encoding/hex"
+	"strings"
+	"testing"
+)
+
+func TestDisassemble(t *testing.T) {
+	scriptHash := bytes.Repeat([]byte{0x11}, 20)
+
+	sb := scriptBuilder{}
+	sb.EmitPushBytes(bytes.Repeat([]byte{0xaa}, 0x50))
+	sb.EmitPush(0)
+	sb.Emit(byte(OpJmpIfNot))
+	sb.b.Write([]byte{0x05, 0x00})
+	sb.Emit(byte(OpSysCall))
+	sb.b.Write(append([]byte{16}, "Neo.Runtime.Spam"...))
+	sb.EmitAppCall(scriptHash)
+	sb.Emit(byte(OpTailCall))
+	sb.b.Write(make([]byte, 20))
+	sb.Emit(byte(OpCallI))
+	sb.b.Write([]byte{0x01, 0x02, 0xfe, 0xff})
+	sb.Emit(byte(OpCallE))
+	sb.b.Write(append([]byte{0x01, 0x02}, scriptHash...))
+	sb.Emit(byte(OpRet))
+
+	instrs, err := Disassemble(sb.b.Bytes())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	expected := []string{
+		"0000 PUSHDATA1 " + strings.Repeat("aa", 0x50),
+		"0052 PUSH0",
+		"0053 JMPIFNOT 0058",
+		"0056 SYSCALL Neo.Runtime.Spam",
+		"0068 APPCALL 0x" + strings.Repeat("11", 20),
+		"007d TAILCALL " + strings.Repeat("00", 20),
+		"0092 CALL_I 0090",
+		"0097 CALL_E 0x" + strings.Repeat("11", 20),
+		"00ae RET",
+	}
+
+	if len(instrs) != len(expected) {
+		t.Fatalf("%d instructions, expected %d: %v", len(instrs), len(expected), instrs)
+	}
+
+	for i, instr := range instrs {
+		if instr.String() != expected[i] {
+			t.Errorf("instruction %d is %q, expected %q", i, instr, expected[i])
+		}
+	}
+
+	if _, ok := instrs[5].CallTarget(); ok {
+		t.Error("TAILCALL with zero script hash has a static target")
+	}
+
+	if data, ok := instrs[1].PushData(); !ok || len(data) != 0 {
+		t.Errorf("PUSH0 pushes %x", data)
+	}
+}
+
+func TestDisassembleAllOpCodes(t *testing.T) {
+	valid := 0
+
+	for op := 0; op <= 0xff; op++ {
+		// Operands of the longest fixed size are zero.
+		script := append([]byte{byte(op)}, make([]byte, 0xff)...)
+		instrs, err := Disassemble(script)
+
+		if !OpCode(op).Valid() {
+			if err == nil || len(instrs) != 0 {
+				t.Errorf("%#02x disassembles", op)
+			}
+			continue
+		}
+
+		valid++
+		if len(instrs) == 0 || instrs[0].OpCode != OpCode(op) {
+			t.Errorf("%#02x is not disassembled: %v", op, err)
+			continue
+		}
+		if strings.HasPrefix(instrs[0].OpCode.String(), "UNKNOWN") {
+			t.Errorf("%#02x has no name", op)
+		}
+	}
+
+	if valid != 184 {
+		t.Errorf("%d valid opcodes, expected 184", valid)
+	}
+}
+
+func TestDisassembleInvalid(t *testing.T) {
+	for _, script := range []string{
+		"51520f",         // PUSHBYTES15 without data
+		"51524d0100",     // PUSHDATA2 without data
+		"5152680b4e656f", // SYSCALL with truncated name
+		"515250",         // unknown opcode
+		"5152e1010200",   // CALL_E without script hash
+	} {
+		instrs, err := DisassembleHex(script)
+		if err == nil {
+			t.Errorf("%s disassembles", script)
+		}
+		if len(instrs) != 2 {
+			t.Errorf("%s: %d instructions before the error", script, len(instrs))
+		}
+	}
+}
+
+func TestCalls(t *testing.T) {
+	scriptHash, _ := hex.DecodeString("9b7cffdaa674beae0f930ebe6085af9093e5fe56")
+	addr := bytes.Repeat([]byte{0x22}, 20)
+
+	scsb := ScriptBuilder{
+		ScriptHash: scriptHash,
+		Method:     "balanceOf",
+		Params:     [][]byte{addr},
+	}
+	// PUSH0 and a dynamic call follow the static one.
+	script := scsb.GetScript() + "0067" + strings.Repeat("00", 20)
+
+	instrs, err := DisassembleHex(script)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	calls := Calls(instrs)
+	if len(calls) != 1 {
+		t.Fatalf("%d calls, expected 1", len(calls))
+	}
+
+	call := calls[0]
+	if !bytes.Equal(call.ScriptHash, scriptHash) || string(call.Method) != "balanceOf" {
+		t.Fatalf("unexpected call %x %q", call.ScriptHash, call.Method)
+	}
+	if len(call.Args) != 1 || !bytes.Equal(call.Args[0], addr) {
+		t.Fatalf("unexpected arguments %x", call.Args)
+	}
+}
+
+func TestCreatedContract(t *testing.T) {
+	sb := scriptBuilder{}
+	sb.EmitPushBytes([]byte("description"))
+	sb.EmitPushBytes([]byte("email"))
+	sb.EmitPushBytes([]byte("author"))
+	sb.EmitPushBytes([]byte("1.0"))
+	sb.EmitPushBytes([]byte("Token"))
+	sb.EmitPush(3)
+	sb.EmitPushBytes([]byte{0x05})
+	sb.EmitPushBytes([]byte{0x07, 0x10})
+
+	contract := scriptBuilder{}
+	contract.EmitPushBytes([]byte("decimals"))
+	contract.Emit(byte(OpSysCall))
+	contract.b.WriteByte(byte(len(contractMigrateServices[0])))
+	contract.b.WriteString(contractMigrateServices[0])
+	sb.EmitPushBytes(contract.b.Bytes())
+
+	sb.Emit(byte(OpSysCall))
+	sb.b.WriteByte(byte(len(contractCreateServices[0])))
+	sb.b.WriteString(contractCreateServices[0])
+
+	instrs, err := Disassemble(sb.b.Bytes())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	c, ok := CreatedContract(instrs)
+	if !ok {
+		t.Fatal("contract is not found")
+	}
+
+	if !bytes.Equal(c.Script, contract.b.Bytes()) ||
+		hex.EncodeToString(c.ParameterList) != "0710" ||
+		c.ReturnType != 0x05 ||
+		!c.NeedStorage() ||
+		c.Name != "Token" || c.Version != "1.0" || c.Author != "author" || c.Email != "email" || c.Description != "description" {
+		t.Fatalf("unexpected contract %+v", c)
+	}
+
+	if !c.Migratable() {
+		t.Error("contract is not migratable")
+	}
+	if !PushesConstants(c.Instructions(), "decimals") || PushesConstants(c.Instructions(), "decimals", "symbol") {
+		t.Error("unexpected constants of contract")
+	}
+
+	if _, ok := CreatedContract(instrs[1:]); ok {
+		t.Error("contract with 8 arguments is found")
+	}
+}
@@ -0,0 +1,84 @@
+package smartcontract
+
+// Types of verification contracts.
+const (
+	// SignatureContract is verified by one signature of its public key.
+	SignatureContract = "signature"
+	// MultiSigContract is verified by signatures of m of its n public keys.
+	MultiSigContract = "multisig"
+	// CustomContract is any other script, it is verified by executing it.
+	CustomContract = "custom"
+)
+
+// maxMultiSigKeys is the largest number of public keys of multi-signature contracts accepted by NEO2 nodes.
+const maxMultiSigKeys = 1024
+
+// VerificationContract is a classified verification script.
+type VerificationContract struct {
+	Type string
+	// Threshold is the number of required signatures, zero for custom contracts.
+	Threshold int
+	// PublicKeys are the compressed public keys in script order, nil for custom contracts.
+	PublicKeys [][]byte
+}
+
+// ClassifyVerification recognizes the standard signature and multi-signature contracts
+// created by NEO2 wallets, every other script is a custom contract.
+func ClassifyVerification(script []byte) VerificationContract {
+	instrs, err := Disassemble(script)
+	if err != nil {
+		return VerificationContract{Type: CustomContract}
+	}
+
+	// PUSHBYTES33 <public key> CHECKSIG.
+	if len(instrs) == 2 && instrs[0].OpCode == OpPushBytes33 && instrs[1].OpCode == OpCheckSig {
+		return VerificationContract{
+			Type:       SignatureContract,
+			Threshold:  1,
+			PublicKeys: [][]byte{instrs[0].Operand},
+		}
+	}
+
+	// PUSH m, PUSHBYTES33 <public key> * n, PUSH n, CHECKMULTISIG.
+	n := len(instrs) - 3
+	if n < 1 || n > maxMultiSigKeys || instrs[len(instrs)-1].OpCode != OpCheckMultiSig {
+		return VerificationContract{Type: CustomContract}
+	}
+
+	threshold, ok := pushedInt(instrs[0])
+	if !ok || threshold < 1 || threshold > n {
+		return VerificationContract{Type: CustomContract}
+	}
+
+	if count, ok := pushedInt(instrs[n+1]); !ok || count != n {
+		return VerificationContract{Type: CustomContract}
+	}
+
+	keys := make([][]byte, n)
+	for i := range keys {
+		if instrs[i+1].OpCode != OpPushBytes33 {
+			return VerificationContract{Type: CustomContract}
+		}
+		keys[i] = instrs[i+1].Operand
+	}
+
+	return VerificationContract{
+		Type:       MultiSigContract,
+		Threshold:  threshold,
+		PublicKeys: keys,
+	}
+}
+
+// IsStandard reports whether the contract is a signature or multi-signature contract.
+func (c VerificationContract) IsStandard() bool {
+	return c.Type == SignatureContract || c.Type == MultiSigContract
+}
+
+func pushedInt(instr Instruction) (int, bool) {
+	data, ok := instr.PushData()
+	if !ok {
+		return 0, false
+	}
+
+	return smallInt(data)
+}
@@ -0,0 +1,53 @@
+package smartcontract
+
+import (
+	"encoding/hex"
+	"strings"
+	"testing"
+)
+
+func TestClassifyVerification(t *testing.T) {
+	key1 := "024c7b7fb6c310fccf1ba33b082519d82964ea93868d676662d4a59ad548df0e7d"
+	key2 := "02aaec38470f6aad0042c6e877cfd8087d2676b0f516fddd362801b9bd3936399e"
+	key3 := "03b209fd4f53a7170ea4444e0cb0a6bb6a53c2bd016926989cf85f9b0fba17a70c"
+	keys := "21" + key1 + "21" + key2 + "21" + key3
+
+	tests := []struct {
+		script     string
+		typ        string
+		threshold  int
+		publicKeys []string
+	}{
+		{"21" + key1 + "ac", SignatureContract, 1, []string{key1}},
+		{"52" + keys + "53ae", MultiSigContract, 2, []string{key1, key2, key3}},
+		// Thresholds may be pushed as bytes.
+		{"0103" + keys + "53ae", MultiSigContract, 3, []string{key1, key2, key3}},
+		{"54" + keys + "53ae", CustomContract, 0, nil},
+		{"00" + keys + "53ae", CustomContract, 0, nil},
+		{"52" + keys + "52ae", CustomContract, 0, nil},
+		{"52" + keys + "51" + "53ae", CustomContract, 0, nil},
+		{"21" + key1 + "ad", CustomContract, 0, nil},
+		{"21" + key1 + "ac" + "51", CustomContract, 0, nil},
+		{"51", CustomContract, 0, nil},
+		{"", CustomContract, 0, nil},
+		{"21" + key1[:10], CustomContract, 0, nil},
+	}
+
+	for _, test := range tests {
+		script, _ := hex.DecodeString(test.script)
+		c := ClassifyVerification(script)
+
+		publicKeys := []string{}
+		for _, key := range c.PublicKeys {
+			publicKeys = append(publicKeys, hex.EncodeToString(key))
+		}
+
+		if c.Type != test.typ || c.Threshold != test.threshold ||
+			strings.Join(publicKeys, ",") != strings.Join(test.publicKeys, ",") {
+			t.Errorf("%s is classified as %s %d %v", test.script, c.Type, c.Threshold, publicKeys)
+		}
+		if c.IsStandard() != (test.typ != CustomContract) {
+			t.Errorf("%s: IsStandard is %v", test.script, c.IsStandard())
+		}
+	}
+}
@@ -11,82 +11,47 @@ WHERE `id` = 1;
 package tasks
 
 import (
+	"context"
 	"fmt"
-	"math/big"
 	"squirrel/db"
-	"squirrel/log"
-	"squirrel/mail"
 	"squirrel/tx"
-	"time"
 )
 
-const assetTxChanSize = 5000
+// assetTxTask records which assets addresses transferred in each transaction.
+type assetTxTask struct{}
 
-var (
-	// AssetTxMaxPkShouldRefresh indicates if highest tx pk should be refreshed.
-	AssetTxMaxPkShouldRefresh bool
-	assetProgress             = Progress{}
-	maxTxPKforAssetTx         uint
-)
-
-func startAssetTxTask() {
-	assetTxChan := make(chan *txInfo, assetTxChanSize)
-
-	go fetchAssetTx(assetTxChan)
-	go handleAssetTx(assetTxChan)
+func (assetTxTask) Name() string {
+	return "asset_tx"
 }
 
-func fetchAssetTx(assetTxChan chan<- *txInfo) {
-	defer mail.AlertIfErr()
-
-	nextPK := db.GetLastAssetTxPkCounter() + 1
+func (assetTxTask) Cursor() uint {
+	return db.GetLastAssetTxPkCounter()
+}
 
-	for {
-		txs := db.GetTxs(nextPK, 50, "")
-		if len(txs) == 0 {
-			// log.Printf("Waiting for new transactions...\n")
-			time.Sleep(2 * time.Second)
-			continue
-		}
+func (assetTxTask) Fetch(_ context.Context, cursor uint) (interface{}, uint) {
+	return fetchTxInfos(cursor, 50)
+}
 
-		nextPK = txs[len(txs)-1].ID + 1
-		txIDs := []string{}
+func (assetTxTask) Apply(batch interface{}) {
+	infos := batch.([]txInfo)
 
-		for _, tx := range txs {
-			txIDs = append(txIDs, tx.TxID)
+	withChainLock(func() {
+		records := []tx.AddrAssetIDTx{}
+		for i := range infos {
+			if !txRemoved(infos[i].tx.ID) {
+				records = processAssetTx(records, &infos[i])
+			}
 		}
 
-		vinMap, voutMap, err := db.GetVinVout(txIDs)
+		err := db.RecordAddrAssetIDTx(records, int64(infos[len(infos)-1].tx.ID))
 		if err != nil {
 			panic(err)
 		}
-
-		for _, tx := range txs {
-			assetTxChan <- &txInfo{
-				tx:    tx,
-				vins:  vinMap[tx.TxID],
-				vouts: voutMap[tx.TxID],
-			}
-		}
-	}
+	})
 }
 
-func handleAssetTx(assetTxChan <-chan *txInfo) {
-	defer mail.AlertIfErr()
-
-	records := []tx.AddrAssetIDTx{}
-	maxPK := uint64(0)
-
-	for {
-		select {
-		case t := <-assetTxChan:
-			maxPK = uint64(t.tx.ID)
-			records = processAssetTx(records, t)
-		case <-time.After(2 * time.Second):
-			recordAddrAssetIDTx(records, int64(maxPK))
-			records = records[:0]
-		}
-	}
+func (assetTxTask) Highest() uint {
+	return db.GetHighestTxPk()
 }
 
 func processAssetTx(records []tx.AddrAssetIDTx, t *txInfo) []tx.AddrAssetIDTx {
@@ -131,69 +96,5 @@ func processAssetTx(records []tx.AddrAssetIDTx, t *txInfo) []tx.AddrAssetIDTx {
 		}
 	}
 
-	if len(records) == 0 {
-		return nil
-	}
-
-	if len(records) >= 100 {
-		recordAddrAssetIDTx(records, int64(t.tx.ID))
-		return nil
-	}
-
 	return records
 }
-
-func recordAddrAssetIDTx(records []tx.AddrAssetIDTx, maxPK int64) {
-	if len(records) == 0 {
-		return
-	}
-
-	err := db.RecordAddrAssetIDTx(records, maxPK)
-	if err != nil {
-		panic(err)
-	}
-
-	showAssetTxProgress(uint(maxPK))
-}
-
-func showAssetTxProgress(currentTxPk uint) {
-	if maxTxPKforAssetTx == 0 || AssetTxMaxPkShouldRefresh {
-		AssetTxMaxPkShouldRefresh = false
-		maxTxPKforAssetTx = db.GetHighestTxPk()
-	}
-
-	now := time.Now()
-	if assetProgress.LastOutputTime == (time.Time{}) {
-		assetProgress.LastOutputTime = now
-	}
-	if currentTxPk < maxTxPKforAssetTx && now.Sub(assetProgress.LastOutputTime) < time.Second {
-		return
-	}
-
-	GetEstimatedRemainingTime(int64(currentTxPk), int64(maxTxPKforAssetTx), &assetProgress)
-	if assetProgress.Percentage.Cmp(big.NewFloat(100)) == 0 &&
-		bProgress.Finished {
-		assetProgress.Finished = true
-	}
-
-	log.Printf("%sProgress of asset tx: %d/%d, %.4f%%\n",
-		assetProgress.RemainingTimeStr,
-		currentTxPk,
-		maxTxPKforAssetTx,
-		assetProgress.Percentage)
-	assetProgress.LastOutputTime = now
-
-	// Send mail if fully synced.
-	if assetProgress.Finished && !assetProgress.MailSent {
-		assetProgress.MailSent = true
-
-		// If sync lasts shortly, do not send mail.
-		if time.Since(assetProgress.InitTime) < time.Minute*5 {
-			return
-		}
-
-		msg := fmt.Sprintf("Init time: %v\nEnd Time: %v\n", assetProgress.InitTime, time.Now())
-
-		mail.SendNotify("Transactions Fully Synced", msg)
-	}
-}
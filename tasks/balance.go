@@ -0,0 +1,84 @@
+/*
+To restart this task from beginning, execute the following sqls:
+
+DELETE FROM `addr_asset_balance` WHERE LENGTH(`asset_id`) = 66;
+UPDATE `counter` SET `last_tx_pk_asset_balance` = 0 WHERE `id` = 1;
+
+*/
+
+package tasks
+
+import (
+	"context"
+	"squirrel/amount"
+	"squirrel/config"
+	"squirrel/db"
+	"squirrel/tx"
+)
+
+// balanceTask records daily balances of addresses in the global assets configured by balance_assets.
+type balanceTask struct{}
+
+func (balanceTask) Name() string {
+	return "asset_balance"
+}
+
+func (balanceTask) Cursor() uint {
+	return db.GetLastTxPkForAssetBalance()
+}
+
+func (balanceTask) Fetch(_ context.Context, cursor uint) (interface{}, uint) {
+	return fetchTxInfos(cursor, 500)
+}
+
+func (balanceTask) Apply(batch interface{}) {
+	assets := make(map[string]bool)
+	for _, id := range config.GetBalanceAssets() {
+		assets[id] = true
+	}
+
+	for _, info := range batch.([]txInfo) {
+		withChainLock(func() {
+			if txRemoved(info.tx.ID) {
+				return
+			}
+
+			changes := getBalanceChanges(info, assets)
+			if len(changes) == 0 {
+				return
+			}
+
+			if err := db.ApplyAddrAssetBalances(info.tx, changes); err != nil {
+				panic(err)
+			}
+		})
+	}
+}
+
+func (balanceTask) Highest() uint {
+	return db.GetHighestTxPk()
+}
+
+// getBalanceChanges returns balance changes of addresses in the given assets made by the transaction.
+func getBalanceChanges(info txInfo, assets map[string]bool) map[string]map[string]amount.Amount {
+	spent := []*tx.TransactionVout{}
+	for _, vin := range info.vins {
+		vinVout, err := db.GetVout(vin.TxID, vin.Vout)
+		if err != nil {
+			panic(err)
+		}
+
+		if vinVout != nil && assets[vinVout.AssetID] {
+			spent = append(spent, vinVout)
+		}
+	}
+
+	vouts := []*tx.TransactionVout{}
+	for _, vout := range info.vouts {
+		if assets[vout.AssetID] {
+			vouts = append(vouts, vout)
+		}
+	}
+
+	return tx.BalanceChanges(spent, vouts)
+}
@@ -1,21 +1,30 @@
 package tasks
 
 import (
+	"context"
 	"fmt"
 	"math/big"
 	"squirrel/block"
 	"squirrel/buffer"
+	"squirrel/config"
 	"squirrel/db"
 	"squirrel/log"
 	"squirrel/mail"
+	"squirrel/payload"
 	"squirrel/rpc"
 	"squirrel/tx"
 	"squirrel/util"
 	"time"
 )
 
-// bufferSize is the capacity of pending blocks waiting to be persisted to db.
-const bufferSize = 5000
+const (
+	// bufferSize is the capacity of pending blocks waiting to be persisted to db.
+	bufferSize = 5000
+	// blockBatchSize is the maximum number of blocks downloaded in one batch request.
+	blockBatchSize = 10
+	// verifyAttempts is the number of times an invalid block is downloaded again.
+	verifyAttempts = 3
+)
 
 var (
 	// bestRPCHeight util.SafeCounter.
@@ -25,11 +34,12 @@ var (
 	blockChannel chan *rpc.RawBlock
 )
 
-func fetchBlock() {
+func fetchBlock(ctx context.Context) {
 	worker.add()
 	log.Printf("Create new worker to fetch blocks\n")
 
-	nextHeight := blockBuffer.GetNextPending()
+	count := downloadCount(blockBuffer.GetHighest() + 1)
+	nextHeight := blockBuffer.GetNextPending(count)
 	waited := 0
 
 	defer func() {
@@ -39,7 +49,7 @@ func fetchBlock() {
 
 	defer mail.AlertIfErr()
 
-	for {
+	for ctx.Err() == nil {
 		// Control size of the blockBuffer.
 		if blockBuffer.Size() > bufferSize {
 			time.Sleep(time.Millisecond * 20)
@@ -47,7 +57,7 @@ func fetchBlock() {
 		}
 
 		// If fully synchronized.
-		if worker.num() == 1 && nextHeight == blockBuffer.GetHighest()+1 {
+		if worker.num() == 1 && nextHeight == blockBuffer.GetHighest()+1 && count == 1 {
 			time.Sleep(time.Second)
 			waited++
 			log.Printf("Waiting for block index: %d(%s)\n", nextHeight, util.SecondsToHuman(uint64(waited)))
@@ -56,38 +66,147 @@ func fetchBlock() {
 			// }
 		}
 
-		b := rpc.DownloadBlock(nextHeight)
+		epoch := blockBuffer.Epoch()
+		downloaded := 0
+		for _, b := range downloadBlocks(nextHeight, count) {
+			if b != nil {
+				blockBuffer.Put(b, epoch)
+				downloaded++
+			}
+		}
 
 		// Beyond the latest block.
-		if b == nil {
+		if downloaded < count {
 			if worker.shouldQuit() {
 				return
 			}
 
 			// Get the correct next pending block.
 			nextHeight = blockBuffer.GetHighest() + 1
+			count = 1
 			continue
 		}
 
 		waited = 0
-		blockBuffer.Put(b)
 
 		if worker.num() == 1 {
 			nextHeight = blockBuffer.GetHighest() + 1
+			count = downloadCount(nextHeight)
+		} else {
+			count = downloadCount(blockBuffer.GetHighest() + 1)
+			nextHeight = blockBuffer.GetNextPending(count)
+		}
+	}
+}
+
+// downloadCount returns the number of blocks downloaded in one batch request from the given height,
+// blocks above the best rpc height are not requested in batches.
+func downloadCount(height int) int {
+	count := rpc.BestHeight.Get() - height + 1
+	if count > blockBatchSize {
+		count = blockBatchSize
+	}
+	if count < 1 {
+		count = 1
+	}
+
+	return count
+}
+
+// downloadBlocks downloads count blocks from start and verifies them,
+// blocks higher than all rpc servers are nil.
+func downloadBlocks(start, count int) []*rpc.RawBlock {
+	blocks := fetchBlocks(start, count)
+
+	for i, b := range blocks {
+		if b != nil && !verified(b) {
+			blocks[i] = refetchBlock(start + i)
+		}
+
+		if blocks[i] != nil && config.GetVerifyWitnesses() {
+			checkWitnesses(blocks[i])
+		}
+	}
+
+	return blocks
+}
+
+// verified checks that the block is consistent with its hashes,
+// the server which returned an inconsistent block is rejected.
+func verified(b *rpc.RawBlock) bool {
+	err := payload.Verify(b)
+	if err == nil {
+		return true
+	}
+
+	log.Error.Printf("Invalid block %d from %s: %v\n", b.Index, b.Source, err)
+	rpc.RejectServer(b.Source, "getblock")
+	return false
+}
+
+// refetchBlock downloads the block again from other servers after an invalid one was rejected,
+// it is nil if no valid block is returned, the block is then downloaded again later.
+func refetchBlock(height int) *rpc.RawBlock {
+	for i := 0; i < verifyAttempts; i++ {
+		b := fetchBlocks(height, 1)[0]
+		if b == nil || verified(b) {
+			return b
+		}
+	}
+
+	return nil
+}
+
+// fetchBlocks downloads count blocks from start in the configured encoding.
+func fetchBlocks(start, count int) []*rpc.RawBlock {
+	if config.GetBlockEncoding() != "binary" {
+		return rpc.DownloadBlocks(start, count)
+	}
+
+	fees := payload.SystemFees(config.GetSystemFees())
+
+	blocks := make([]*rpc.RawBlock, count)
+	data, sources := rpc.DownloadRawBlocks(start, count)
+	for i := range data {
+		if data[i] == "" {
+			continue
+		}
+
+		b, err := payload.DecodeBlockHex(data[i], fees)
+		if err != nil {
+			// Fall back to the verbose block from another server.
+			log.Error.Printf("Failed to decode block %d from %s: %v\n", start+i, sources[i], err)
+			rpc.RejectServer(sources[i], "getblock")
+			b = rpc.DownloadBlock(start + i)
 		} else {
-			nextHeight = blockBuffer.GetNextPending()
+			b.Source = sources[i]
 		}
+
+		blocks[i] = b
 	}
+
+	return blocks
 }
 
-func arrangeBlock(dbHeight int, queue chan<- *rpc.RawBlock) {
+// arrangeBlock queues buffered blocks in order, queue is closed when ctx is done.
+func arrangeBlock(ctx context.Context, dbHeight int, queue chan<- *rpc.RawBlock) {
 	defer mail.AlertIfErr()
+	defer close(queue)
 
 	const sleepTime = 20
 	height := dbHeight + 1
 	delay := 0
 
 	for {
+		select {
+		case <-ctx.Done():
+			return
+		case h := <-resyncChan:
+			height = h + 1
+			delay = 0
+		default:
+		}
+
 		if b, ok := blockBuffer.Pop(height); ok {
 			queue <- b
 			height++
@@ -117,30 +236,66 @@ func arrangeBlock(dbHeight int, queue chan<- *rpc.RawBlock) {
 func getMissingBlock(height int) {
 	log.Printf("Try fetching given block of height: %d\n", height)
 
-	b := rpc.DownloadBlock(height)
+	epoch := blockBuffer.Epoch()
+	b := downloadBlocks(height, 1)[0]
 	if b != nil {
-		blockBuffer.Put(b)
+		blockBuffer.Put(b, epoch)
 	}
 }
 
-func storeBlock(ch <-chan *rpc.RawBlock) {
+func storeBlock(dbHeight int, ch <-chan *rpc.RawBlock) {
 	defer mail.AlertIfErr()
 
 	const size = 15
 	rawBlocks := []*rpc.RawBlock{}
+	nextIndex := uint(dbHeight + 1)
 
 	for block := range ch {
+		// Blocks queued before a resync may be out of sequence.
+		if block.Index != nextIndex {
+			continue
+		}
+
+		nextIndex++
 		rawBlocks = append(rawBlocks, block)
 		if block.Index%size == 0 ||
 			int(block.Index) == blockBuffer.GetHighest() {
-			store(rawBlocks)
+			nextIndex = uint(store(rawBlocks) + 1)
 			rawBlocks = nil
 		}
 	}
+
+	// The queue is closed on shutdown, persist what has been received.
+	if len(rawBlocks) > 0 {
+		store(rawBlocks)
+	}
+
+	log.Printf("Block storage stopped at height %d\n", db.GetLastHeight())
+}
+
+// store persists blocks linked to the stored chain and returns the highest stored index.
+func store(rawBlocks []*rpc.RawBlock) int {
+	linked := getLinkedBlocks(rawBlocks)
+	if linked < len(rawBlocks) {
+		height := int(rawBlocks[linked].Index) - 1
+		if linked == 0 {
+			height = handleChainBreak(rawBlocks[0])
+		} else {
+			persist(rawBlocks[:linked])
+		}
+
+		resync(height)
+		return height
+	}
+
+	persist(rawBlocks)
+	return int(rawBlocks[len(rawBlocks)-1].Index)
 }
 
-func store(rawBlocks []*rpc.RawBlock) {
+func persist(rawBlocks []*rpc.RawBlock) {
 	maxIndex := int(rawBlocks[len(rawBlocks)-1].Index)
+	linkBlocks(rawBlocks)
+	resolveNetFees(rawBlocks)
 	blocks := block.ParseBlocks(rawBlocks)
 	txBulk := tx.ParseTxs(rawBlocks)
 
@@ -149,11 +304,11 @@ func store(rawBlocks []*rpc.RawBlock) {
 		panic(err)
 	}
 
-	// Auxiliary signal for tx task.
-	TxMaxPkShouldRefresh = true
-	AssetTxMaxPkShouldRefresh = true
-	Nep5MaxPkShouldRefresh = true
-	gasMaxPkShouldRefresh = true
+	// Highest positions of tasks are changed.
+	taskManager.refreshHighest()
+	storedHeight.Set(maxIndex)
+	chainLinked()
+	pruneRemovedTxs()
 
 	bestHeight := rpc.BestHeight.Get()
 
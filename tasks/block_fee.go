@@ -0,0 +1,87 @@
+/*
+Fees of new blocks are recorded when they are stored,
+this task fills fees of blocks stored before the block_fee table existed.
+
+To restart this task from beginning, execute the following sqls:
+
+DELETE FROM `block_fee` WHERE `block_index` < (SELECT `block_fee_backfill_index` FROM `counter` WHERE `id` = 1);
+UPDATE `counter` SET `block_fee_backfill_index` = 0 WHERE `id` = 1;
+
+*/
+
+package tasks
+
+import (
+	"context"
+	"fmt"
+	"squirrel/db"
+	"squirrel/tx"
+)
+
+// blockFeeBatchSize is the number of blocks whose fees are backfilled in one batch.
+const blockFeeBatchSize = 1000
+
+type blockFeeBatch struct {
+	start uint
+	next  uint
+	fees  []*tx.BlockFee
+}
+
+// blockFeeTask backfills fees of blocks stored before fees were recorded.
+type blockFeeTask struct{}
+
+func (blockFeeTask) Name() string {
+	return "block_fee"
+}
+
+func (blockFeeTask) Cursor() uint {
+	return db.GetBlockFeeBackfillIndex()
+}
+
+func (blockFeeTask) Fetch(_ context.Context, cursor uint) (interface{}, uint) {
+	end := blockFeeBackfillEnd()
+	if end <= cursor {
+		return nil, cursor
+	}
+
+	next := cursor + blockFeeBatchSize
+	if next > end {
+		next = end
+	}
+
+	fees, err := db.GetBlockFees(cursor, next)
+	if err != nil {
+		panic(err)
+	}
+
+	return blockFeeBatch{start: cursor, next: next, fees: fees}, next
+}
+
+func (blockFeeTask) Apply(batch interface{}) {
+	b := batch.(blockFeeBatch)
+
+	withChainLock(func() {
+		// The backfill index is moved back if the blocks were rolled back after fetching,
+		// fail so that the task restarts from there.
+		if db.GetBlockFeeBackfillIndex() != b.start || int(b.next) > db.GetLastHeight()+1 {
+			panic(fmt.Errorf("fees of blocks from %d to %d are rolled back", b.start, b.next))
+		}
+
+		if err := db.BackfillBlockFees(b.fees, b.next); err != nil {
+			panic(err)
+		}
+	})
+}
+
+func (blockFeeTask) Highest() uint {
+	return blockFeeBackfillEnd()
+}
+
+func blockFeeBackfillEnd() uint {
+	end, err := db.GetBlockFeeBackfillEnd()
+	if err != nil {
+		panic(err)
+	}
+
+	return end
+}
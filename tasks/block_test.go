@@ -0,0 +1,37 @@
+package tasks
+
+import (
+	"context"
+	"squirrel/buffer"
+	"squirrel/rpc"
+	"testing"
+	"time"
+)
+
+func TestArrangeBlockStopsOnCancel(t *testing.T) {
+	blockBuffer = buffer.NewBuffer(-1)
+	for i := uint(0); i < 2; i++ {
+		blockBuffer.Put(&rpc.RawBlock{Index: i}, blockBuffer.Epoch())
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	queue := make(chan *rpc.RawBlock, 2)
+	go arrangeBlock(ctx, -1, queue)
+
+	for i := uint(0); i < 2; i++ {
+		if b := <-queue; b.Index != i {
+			t.Fatalf("block %d queued, expected %d", b.Index, i)
+		}
+	}
+
+	cancel()
+
+	select {
+	case b, ok := <-queue:
+		if ok {
+			t.Fatalf("block %d queued after cancel", b.Index)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("queue is not closed after cancel")
+	}
+}
@@ -1,33 +1,55 @@
 package tasks
 
 import (
+	"context"
 	"squirrel/db"
-	"squirrel/mail"
-	"time"
+	"squirrel/nep5"
 )
 
-func startUpdateCounterTask() {
-	go insertNep5AddrTxRecord()
+// nep5AddrTxTask records nep5 transfers of addresses from stored nep5 transactions.
+type nep5AddrTxTask struct{}
+
+func (nep5AddrTxTask) Name() string {
+	return "nep5_addr_tx"
+}
+
+func (nep5AddrTxTask) Cursor() uint {
+	return db.GetNep5TxPkForAddrTx()
 }
 
-func insertNep5AddrTxRecord() {
-	defer mail.AlertIfErr()
+func (nep5AddrTxTask) Fetch(_ context.Context, cursor uint) (interface{}, uint) {
+	records, err := db.GetNep5TxRecords(cursor, 100)
+	if err != nil {
+		panic(err)
+	}
+
+	if len(records) == 0 {
+		return nil, cursor
+	}
+
+	return records, records[len(records)-1].ID
+}
 
-	lastPk := db.GetNep5TxPkForAddrTx()
+func (nep5AddrTxTask) Apply(batch interface{}) {
+	records := batch.([]*nep5.Transaction)
+	lastPk := records[len(records)-1].ID
 
-	for {
-		Nep5TxRecs, err := db.GetNep5TxRecords(lastPk, 100)
+	withChainLock(func() {
+		// Drop records fetched before their transactions were rolled back.
+		valid := []*nep5.Transaction{}
+		for _, rec := range records {
+			if !txIDRemoved(rec.TxID) {
+				valid = append(valid, rec)
+			}
+		}
+
+		err := db.InsertNep5AddrTxRec(valid, lastPk)
 		if err != nil {
 			panic(err)
 		}
+	})
+}
 
-		if len(Nep5TxRecs) > 0 {
-			lastPk = Nep5TxRecs[len(Nep5TxRecs)-1].ID
-			err = db.InsertNep5AddrTxRec(Nep5TxRecs, lastPk)
-			if err != nil {
-				panic(err)
-			}
-		}
-		time.Sleep(time.Second)
-	}
+func (nep5AddrTxTask) Highest() uint {
+	return db.GetMaxNep5TxPk()
 }
@@ -0,0 +1,42 @@
+package tasks
+
+import (
+	"fmt"
+	"squirrel/rpc"
+)
+
+// Lagging returns the reasons why indexed data is not caught up with the chain.
+// It is empty if stored blocks are within maxBlockLag of the best rpc height
+// and every task is running within maxTaskLag of its highest position.
+func Lagging(maxBlockLag, maxTaskLag uint) []string {
+	reasons := []string{}
+
+	best := rpc.BestHeight.Get()
+	height := storedHeight.Get()
+	if best > height && uint(best-height) > maxBlockLag {
+		reasons = append(reasons, fmt.Sprintf("block storage is %d blocks behind best height %d", best-height, best))
+	}
+
+	for _, mt := range taskManager.tasks {
+		var highest uint
+		if err := protect(func() { highest = mt.highest() }); err != nil {
+			reasons = append(reasons, fmt.Sprintf("task %s: %v", mt.task.Name(), err))
+			continue
+		}
+
+		mt.mu.Lock()
+		status := mt.status
+		mt.mu.Unlock()
+
+		if !status.Running {
+			reasons = append(reasons, fmt.Sprintf("task %s is not running, last error: %s", status.Name, status.LastError))
+			continue
+		}
+
+		if highest > status.Cursor && highest-status.Cursor > maxTaskLag {
+			reasons = append(reasons, fmt.Sprintf("task %s is at %d, %d behind highest position %d", status.Name, status.Cursor, highest-status.Cursor, highest))
+		}
+	}
+
+	return reasons
+}
@@ -0,0 +1,44 @@
+package tasks
+
+import (
+	"squirrel/rpc"
+	"testing"
+)
+
+// idleTask is a task whose highest position is fixed.
+type idleTask struct {
+	counterTask
+	top uint
+}
+
+func (t *idleTask) Highest() uint {
+	return t.top
+}
+
+func TestLagging(t *testing.T) {
+	defer func(m *manager) { taskManager = m }(taskManager)
+	taskManager = &manager{}
+	taskManager.add(&idleTask{top: 100})
+
+	mt := taskManager.tasks[0]
+	mt.setRunning(true)
+	mt.setCursor(90)
+
+	storedHeight.Set(100)
+	rpc.BestHeight.Set(105)
+
+	if reasons := Lagging(5, 10); len(reasons) != 0 {
+		t.Fatalf("Lagging(5, 10) = %v, expected caught up", reasons)
+	}
+	if reasons := Lagging(4, 10); len(reasons) != 1 {
+		t.Fatalf("Lagging(4, 10) = %v, expected block storage behind", reasons)
+	}
+	if reasons := Lagging(5, 9); len(reasons) != 1 {
+		t.Fatalf("Lagging(5, 9) = %v, expected task behind", reasons)
+	}
+
+	mt.setRunning(false)
+	if reasons := Lagging(5, 10); len(reasons) != 1 {
+		t.Fatalf("Lagging(5, 10) = %v, expected task not running", reasons)
+	}
+}
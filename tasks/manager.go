@@ -0,0 +1,310 @@
+package tasks
+
+import (
+	"context"
+	"fmt"
+	"math/big"
+	"runtime/debug"
+	"squirrel/log"
+	"squirrel/mail"
+	"sync"
+	"time"
+)
+
+const (
+	// batchQueueSize is the number of fetched batches waiting to be applied.
+	batchQueueSize = 10
+	// idleInterval is the delay before fetching again when there is nothing new.
+	idleInterval = 2 * time.Second
+)
+
+// restartDelay is the delay before restarting a failed task.
+var restartDelay = 10 * time.Second
+
+// TaskStatus is a snapshot of a managed task.
+type TaskStatus struct {
+	Name    string
+	Running bool
+	// Cursor is the position of the last applied batch.
+	Cursor uint
+	// Highest is the highest position known to the task, 0 if unknown.
+	Highest uint
+	// Finished indicates if the task has caught up with stored blocks.
+	Finished bool
+	// Queued is the number of fetched batches waiting to be applied.
+	Queued    int
+	Restarts  uint
+	LastError string
+}
+
+type managedTask struct {
+	task Task
+
+	mu           sync.Mutex
+	status       TaskStatus
+	highestStale bool
+	// queue holds batches of the current run.
+	queue chan taskBatch
+
+	// progress is only accessed by the goroutine applying batches.
+	progress Progress
+}
+
+type taskBatch struct {
+	batch interface{}
+	next  uint
+}
+
+// manager runs tasks, restarts them on panic and reports their status.
+type manager struct {
+	tasks []*managedTask
+}
+
+var taskManager = &manager{}
+
+// Status returns status of all indexing tasks.
+func Status() []TaskStatus {
+	return taskManager.statuses()
+}
+
+func (m *manager) add(t Task) {
+	m.tasks = append(m.tasks, &managedTask{
+		task:   t,
+		status: TaskStatus{Name: t.Name()},
+	})
+}
+
+// start runs all tasks until ctx is done.
+func (m *manager) start(ctx context.Context) {
+	for _, mt := range m.tasks {
+		mt := mt
+		spawn(func() { m.run(ctx, mt) })
+	}
+}
+
+func (m *manager) statuses() []TaskStatus {
+	result := make([]TaskStatus, len(m.tasks))
+	for i, mt := range m.tasks {
+		mt.mu.Lock()
+		result[i] = mt.status
+		result[i].Queued = len(mt.queue)
+		mt.mu.Unlock()
+	}
+
+	return result
+}
+
+// refreshHighest makes tasks reload their highest position, it is called when stored blocks change.
+func (m *manager) refreshHighest() {
+	for _, mt := range m.tasks {
+		mt.mu.Lock()
+		mt.highestStale = true
+		mt.mu.Unlock()
+	}
+}
+
+// highests returns highest positions of tasks by name, they are read from storage.
+func (m *manager) highests() map[string]uint {
+	result := make(map[string]uint, len(m.tasks))
+	for _, mt := range m.tasks {
+		result[mt.task.Name()] = mt.task.Highest()
+	}
+
+	return result
+}
+
+// run restarts the task from its persisted cursor after each failure until ctx is done.
+func (m *manager) run(ctx context.Context, mt *managedTask) {
+	name := mt.task.Name()
+
+	for {
+		mt.setRunning(true)
+		err := mt.runOnce(ctx)
+		mt.setRunning(false)
+
+		if err == nil {
+			log.Printf("Task %s stopped at %d\n", name, mt.cursor())
+			return
+		}
+
+		mt.failed(err)
+		log.Error.Printf("Task %s failed: %v\n", name, err)
+		mail.SendNotify("Task Failed", fmt.Sprintf("Task %s failed: %v", name, err))
+
+		log.Printf("Restarting task %s in %v\n", name, restartDelay)
+		if !sleep(ctx, restartDelay) {
+			return
+		}
+	}
+}
+
+// runOnce fetches and applies batches from the persisted cursor,
+// it returns nil after ctx is done and all fetched batches are applied.
+func (mt *managedTask) runOnce(ctx context.Context) error {
+	var cursor uint
+	if err := protect(func() { cursor = mt.task.Cursor() }); err != nil {
+		return err
+	}
+	mt.setCursor(cursor)
+
+	fetchCtx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	batches := make(chan taskBatch, batchQueueSize)
+	fetchErr := make(chan error, 1)
+
+	mt.mu.Lock()
+	mt.queue = batches
+	mt.mu.Unlock()
+
+	go func() {
+		defer close(batches)
+		fetchErr <- protect(func() { mt.fetch(fetchCtx, cursor, batches) })
+	}()
+
+	err := protect(func() {
+		for b := range batches {
+			mt.task.Apply(b.batch)
+			mt.setCursor(b.next)
+			mt.showProgress(b.next)
+		}
+	})
+	if err != nil {
+		// Stop fetching and wait for the fetcher to quit.
+		cancel()
+		for range batches {
+		}
+		return err
+	}
+
+	return <-fetchErr
+}
+
+func (mt *managedTask) fetch(ctx context.Context, cursor uint, batches chan<- taskBatch) {
+	for ctx.Err() == nil {
+		batch, next := mt.task.Fetch(ctx, cursor)
+		if next == cursor {
+			sleep(ctx, idleInterval)
+			continue
+		}
+
+		select {
+		case batches <- taskBatch{batch: batch, next: next}:
+			cursor = next
+		case <-ctx.Done():
+			return
+		}
+	}
+}
+
+func (mt *managedTask) setRunning(running bool) {
+	mt.mu.Lock()
+	defer mt.mu.Unlock()
+
+	mt.status.Running = running
+}
+
+func (mt *managedTask) setCursor(cursor uint) {
+	mt.mu.Lock()
+	defer mt.mu.Unlock()
+
+	mt.status.Cursor = cursor
+}
+
+func (mt *managedTask) cursor() uint {
+	mt.mu.Lock()
+	defer mt.mu.Unlock()
+
+	return mt.status.Cursor
+}
+
+func (mt *managedTask) failed(err error) {
+	mt.mu.Lock()
+	defer mt.mu.Unlock()
+
+	mt.status.Restarts++
+	mt.status.LastError = err.Error()
+}
+
+// highest returns the highest position of the task, reloading it if stale.
+func (mt *managedTask) highest() uint {
+	mt.mu.Lock()
+	highest := mt.status.Highest
+	reload := mt.highestStale || highest == 0
+	mt.highestStale = false
+	mt.mu.Unlock()
+
+	if !reload {
+		return highest
+	}
+
+	highest = mt.task.Highest()
+
+	mt.mu.Lock()
+	mt.status.Highest = highest
+	mt.mu.Unlock()
+
+	return highest
+}
+
+func (mt *managedTask) showProgress(cursor uint) {
+	highest := mt.highest()
+	if highest == 0 {
+		return
+	}
+
+	name := mt.task.Name()
+	progress := &mt.progress
+
+	now := time.Now()
+	if progress.LastOutputTime == (time.Time{}) {
+		progress.LastOutputTime = now
+	}
+	if cursor < highest && now.Sub(progress.LastOutputTime) < time.Second {
+		return
+	}
+
+	GetEstimatedRemainingTime(int64(cursor), int64(highest), progress)
+	if progress.Percentage.Cmp(big.NewFloat(100)) == 0 &&
+		bProgress.Finished {
+		progress.Finished = true
+	}
+
+	mt.mu.Lock()
+	mt.status.Finished = progress.Finished
+	mt.mu.Unlock()
+
+	log.Printf("%sProgress of %s: %d/%d, %.4f%%\n",
+		progress.RemainingTimeStr,
+		name,
+		cursor,
+		highest,
+		progress.Percentage)
+	progress.LastOutputTime = now
+
+	// Send mail if fully synced.
+	if progress.Finished && !progress.MailSent {
+		progress.MailSent = true
+
+		// If sync lasts shortly, do not send mail.
+		if time.Since(progress.InitTime) < time.Minute*5 {
+			return
+		}
+
+		msg := fmt.Sprintf("Init time: %v\nEnd Time: %v\n", progress.InitTime, time.Now())
+		mail.SendNotify(fmt.Sprintf("Task %s Fully Synced", name), msg)
+	}
+}
+
+// protect runs f and returns the value it panics with as an error, the stack is logged.
+func protect(f func()) (err error) {
+	defer func() {
+		if r := recover(); r != nil {
+			log.Error.Printf("%v\n%s", r, debug.Stack())
+			err = fmt.Errorf("%v", r)
+		}
+	}()
+
+	f()
+	return nil
+}
@@ -0,0 +1,105 @@
+package tasks
+
+import (
+	"context"
+	"os"
+	"squirrel/log"
+	"sync"
+	"testing"
+	"time"
+)
+
+// counterTask applies numbers up to total, it panics the first time it applies failAt.
+type counterTask struct {
+	mu        sync.Mutex
+	total     uint
+	failAt    uint
+	failed    bool
+	persisted uint
+}
+
+func (t *counterTask) Name() string {
+	return "counter"
+}
+
+func (t *counterTask) Cursor() uint {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	return t.persisted
+}
+
+func (t *counterTask) Fetch(_ context.Context, cursor uint) (interface{}, uint) {
+	batch := []uint{}
+	for n := cursor + 1; n <= t.total && len(batch) < 3; n++ {
+		batch = append(batch, n)
+	}
+
+	if len(batch) == 0 {
+		return nil, cursor
+	}
+
+	return batch, batch[len(batch)-1]
+}
+
+func (t *counterTask) Apply(batch interface{}) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	for _, n := range batch.([]uint) {
+		if n == t.failAt && !t.failed {
+			t.failed = true
+			panic("apply failed")
+		}
+
+		if n != t.persisted+1 {
+			panic("batches applied out of order")
+		}
+		t.persisted = n
+	}
+}
+
+func (t *counterTask) Highest() uint {
+	return 0
+}
+
+func (t *counterTask) applied() uint {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	return t.persisted
+}
+
+func TestManagerRestartsFailedTask(t *testing.T) {
+	log.Init()
+	defer os.Remove("error.log")
+
+	defer func(delay time.Duration) { restartDelay = delay }(restartDelay)
+	restartDelay = 10 * time.Millisecond
+
+	task := &counterTask{total: 20, failAt: 8}
+	m := &manager{}
+	m.add(task)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	m.start(ctx)
+
+	deadline := time.Now().Add(5 * time.Second)
+	for task.applied() < task.total {
+		if time.Now().After(deadline) {
+			t.Fatalf("%d applied, expected %d", task.applied(), task.total)
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+
+	cancel()
+	Wait()
+
+	status := m.statuses()[0]
+	if status.Name != "counter" || status.Running || status.Cursor != task.total {
+		t.Fatalf("unexpected status %+v", status)
+	}
+	if status.Restarts != 1 || status.LastError != "apply failed" {
+		t.Fatalf("status %+v does not record the failure", status)
+	}
+}
@@ -0,0 +1,84 @@
+package tasks
+
+import (
+	"squirrel/metrics"
+	"squirrel/rpc"
+	"squirrel/util"
+
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// storedHeight is the highest block persisted to db.
+var storedHeight util.SafeCounter
+
+var (
+	blockHeightDesc = newDesc("block_height", "Highest block persisted to the database.")
+	blockLagDesc    = newDesc("block_lag", "Number of blocks the database is behind the best rpc height.")
+	blockBufferDesc = newDesc("block_buffer_blocks", "Number of downloaded blocks waiting to be arranged.")
+	blockQueueDesc  = newDesc("block_queue_length", "Number of arranged blocks waiting to be persisted.")
+	workersDesc     = newDesc("block_fetch_workers", "Number of goroutines downloading blocks.")
+
+	taskCursorDesc   = newDesc("task_cursor", "Position of the last applied batch of the task.", "task")
+	taskHighestDesc  = newDesc("task_highest", "Highest position known to the task, 0 if unknown.", "task")
+	taskQueueDesc    = newDesc("task_queue_length", "Number of fetched batches waiting to be applied.", "task")
+	taskRunningDesc  = newDesc("task_running", "1 if the task is running.", "task")
+	taskRestartsDesc = newDesc("task_restarts_total", "Number of restarts after the task failed.", "task")
+)
+
+// invalidWitnesses counts downloaded transactions whose witnesses do not verify.
+var invalidWitnesses = prometheus.NewCounter(prometheus.CounterOpts{
+	Namespace: metrics.Namespace,
+	Name:      "invalid_witnesses_total",
+	Help:      "Number of transactions whose witnesses do not verify.",
+})
+
+func init() {
+	metrics.MustRegister(collector{}, invalidWitnesses)
+}
+
+func newDesc(name, help string, labels ...string) *prometheus.Desc {
+	return prometheus.NewDesc(prometheus.BuildFQName(metrics.Namespace, "", name), help, labels, nil)
+}
+
+// collector reads sync positions and queue occupancy when metrics are scraped.
+type collector struct{}
+
+func (collector) Describe(ch chan<- *prometheus.Desc) {
+	for _, desc := range []*prometheus.Desc{
+		blockHeightDesc, blockLagDesc, blockBufferDesc, blockQueueDesc, workersDesc,
+		taskCursorDesc, taskHighestDesc, taskQueueDesc, taskRunningDesc, taskRestartsDesc,
+	} {
+		ch <- desc
+	}
+}
+
+func (collector) Collect(ch chan<- prometheus.Metric) {
+	height := storedHeight.Get()
+	lag := rpc.BestHeight.Get() - height
+	if lag < 0 {
+		lag = 0
+	}
+
+	gauge(ch, blockHeightDesc, float64(height))
+	gauge(ch, blockLagDesc, float64(lag))
+	gauge(ch, blockBufferDesc, float64(blockBuffer.Size()))
+	gauge(ch, blockQueueDesc, float64(len(blockChannel)))
+	gauge(ch, workersDesc, float64(worker.num()))
+
+	for _, s := range Status() {
+		running := 0.0
+		if s.Running {
+			running = 1
+		}
+
+		gauge(ch, taskCursorDesc, float64(s.Cursor), s.Name)
+		gauge(ch, taskHighestDesc, float64(s.Highest), s.Name)
+		gauge(ch, taskQueueDesc, float64(s.Queued), s.Name)
+		gauge(ch, taskRunningDesc, running, s.Name)
+		ch <- prometheus.MustNewConstMetric(taskRestartsDesc, prometheus.CounterValue, float64(s.Restarts), s.Name)
+	}
+}
+
+func gauge(ch chan<- prometheus.Metric, desc *prometheus.Desc, v float64, labels ...string) {
+	ch <- prometheus.MustNewConstMetric(desc, prometheus.GaugeValue, v, labels...)
+}
@@ -0,0 +1,36 @@
+package tasks
+
+import (
+	"io/ioutil"
+	"net/http/httptest"
+	"squirrel/metrics"
+	"squirrel/rpc"
+	"strings"
+	"testing"
+)
+
+func TestMetrics(t *testing.T) {
+	defer func(m *manager) { taskManager = m }(taskManager)
+	taskManager = &manager{}
+	taskManager.add(&counterTask{})
+	taskManager.tasks[0].setCursor(5)
+
+	storedHeight.Set(100)
+	rpc.BestHeight.Set(103)
+
+	w := httptest.NewRecorder()
+	metrics.Handler().ServeHTTP(w, httptest.NewRequest("GET", "/metrics", nil))
+	body, _ := ioutil.ReadAll(w.Body)
+
+	for _, line := range []string{
+		"squirrel_block_height 100",
+		"squirrel_block_lag 3",
+		"squirrel_rpc_best_height 103",
+		`squirrel_task_cursor{task="counter"} 5`,
+		`squirrel_task_restarts_total{task="counter"} 0`,
+	} {
+		if !strings.Contains(string(body), line+"\n") {
+			t.Errorf("metrics do not contain %q", line)
+		}
+	}
+}
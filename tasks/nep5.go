@@ -12,66 +12,30 @@ TRUNCATE TABLE `nep5`;
 TRUNCATE TABLE `nep5_reg_info`;
 TRUNCATE TABLE `nep5_tx`;
 TRUNCATE TABLE `nep5_migrate`;
+TRUNCATE TABLE `nep5_balance`;
+DELETE FROM `addr_asset_balance` WHERE LENGTH(`asset_id`) = 40;
 DELETE FROM `address` WHERE `trans_asset`=0 AND `trans_nep5`=0;
 UPDATE `counter` SET `nep5_tx_pk_for_addr_tx`=0 WHERE `id`=1;
 
-To check if rpc node has enabled smart contract log,
-check if the first nep5 transfer exists:
-mainnet:
-	txID: 0xc920b2192e74eda4ca6140510813aa40fef1767d00c152aa6f8027c24bdf14f2
-	blockIndex: 1444843
-testnet:
-	txID: 0xd355c4cf3a58859cd72c28ce362d727ed7dc2d68ae049ca9356fc0a6c21a8f45
-	blockIndex: 446369
-
-run the following curl command for mainnet(replace IP address):
-
-curl -X POST \
-  http://xxx.xxx.xxx.xxx:10332 \
-  -H 'Content-Type: application/json' \
-  -H 'cache-control: no-cache' \
-  -d '{
-    "jsonrpc": "2.0",
-    "method": "getapplicationlog",
-    "params": [
-        "0xc920b2192e74eda4ca6140510813aa40fef1767d00c152aa6f8027c24bdf14f2"
-    ],
-    "id": 1
-}'
-
-run the following curl command for testnet(replace IP address):
-
-curl -X POST \
-  http://xxx.xxx.xxx.xxx:10332 \
-  -H 'Content-Type: application/json' \
-  -H 'cache-control: no-cache' \
-  -d '{
-    "jsonrpc": "2.0",
-    "method": "getapplicationlog",
-    "params": [
-        "0xd355c4cf3a58859cd72c28ce362d727ed7dc2d68ae049ca9356fc0a6c21a8f45"
-    ],
-    "id": 1
-}'
+Application logs are only requested from rpc servers with the ApplicationLogs plugin,
+rpc servers are probed for it at startup and periodically. While none of them has the plugin,
+this task fetches nothing and tries again after a while.
 
 */
 
 package tasks
 
 import (
+	"bytes"
+	"context"
 	"encoding/hex"
 	"fmt"
-	"math"
 	"math/big"
-	"reflect"
+	"squirrel/amount"
 	"squirrel/cache"
 	"squirrel/log"
-	"squirrel/mail"
 	"squirrel/smartcontract"
-	"strconv"
 	"strings"
-	"sync"
-	"time"
 
 	"squirrel/addr"
 	"squirrel/db"
@@ -81,24 +45,12 @@ import (
 	"squirrel/util"
 )
 
-const nep5ChanSize = 5000
-
-var (
-	// Nep5MaxPkShouldRefresh indicates if highest pk should be refreshed
-	Nep5MaxPkShouldRefresh bool
-
-	// Cache decimals of nep5 asset
-	nep5AssetDecimals map[string]uint8
-	nProgress         = Progress{}
-	maxNep5PK         uint
-
-	// appLogs stores txid with its applicationlog rpc response
-	appLogs sync.Map
-)
+// nep5AssetDecimals caches decimals of nep5 assets.
+var nep5AssetDecimals map[string]uint8
 
 type nep5TxInfo struct {
 	tx           *tx.Transaction
-	dataStack    *smartcontract.DataStack
+	instrs       []smartcontract.Instruction
 	appLogResult *rpc.RawApplicationLogResult
 }
 
@@ -111,6 +63,24 @@ type nep5Store struct {
 	d interface{}
 }
 
+// txPK returns pk of the transaction this store item comes from.
+func (s *nep5Store) txPK() uint {
+	switch d := s.d.(type) {
+	case nep5AssetStore:
+		return d.tx.ID
+	case nep5TxStore:
+		return d.tx.ID
+	case nep5BalanceTSStore:
+		return d.txPK
+	case nep5CounterStore:
+		return d.txPK
+	case nep5MigrateStore:
+		return d.txPK
+	default:
+		return 0
+	}
+}
+
 type nep5AssetStore struct {
 	tx        *tx.Transaction
 	nep5      *nep5.Nep5
@@ -124,11 +94,11 @@ type nep5TxStore struct {
 	applogIdx     int
 	assetID       string
 	fromAddr      string
-	fromBalance   *big.Float
+	fromBalance   amount.Amount
 	toAddr        string
-	toBalance     *big.Float
-	transferValue *big.Float
-	totalSupply   *big.Float
+	toBalance     amount.Amount
+	transferValue amount.Amount
+	totalSupply   *amount.Amount
 }
 
 type nep5BalanceTSStore struct {
@@ -136,9 +106,9 @@ type nep5BalanceTSStore struct {
 	blockTime   uint64
 	blockIndex  uint
 	addr        string
-	balance     *big.Float
+	balance     amount.Amount
 	assetID     string
-	totalSupply *big.Float
+	totalSupply amount.Amount
 }
 
 type nep5CounterStore struct {
@@ -154,179 +124,232 @@ type nep5MigrateStore struct {
 	txID          string
 }
 
-func startNep5Task() {
+// nep5Task parses nep5 registrations, transfers and migrations from invocation transactions.
+// Transactions are parsed while fetching, so that rpc queries run ahead of storing.
+type nep5Task struct {
+	// applogIdx is the index of the last stored transfer of the first transaction to fetch,
+	// -1 if the transaction had been fully handled.
+	applogIdx int
+	// rollbacks is the number of rollbacks when nep5AssetDecimals was loaded.
+	rollbacks int
+	// appLogUnavailable is set while no rpc server supports getapplicationlog, only Fetch uses it.
+	appLogUnavailable bool
+}
+
+// nep5Stores collects store items of parsed transactions in order.
+type nep5Stores []*nep5Store
+
+func (stores *nep5Stores) add(s *nep5Store) {
+	*stores = append(*stores, s)
+}
+
+func (t *nep5Task) Name() string {
+	return "nep5"
+}
+
+func (t *nep5Task) Cursor() uint {
+	// Assets parsed but not stored before a restart must be parsed again.
+	t.rollbacks = rollbacks.Get()
 	nep5AssetDecimals = db.GetNep5AssetDecimals()
-	nep5TxChan := make(chan *nep5TxInfo, nep5ChanSize)
-	applogChan := make(chan *tx.Transaction, nep5ChanSize)
-	nep5StoreChan := make(chan *nep5Store, nep5ChanSize)
 
 	lastPk, applogIdx := db.GetLastTxPkForNep5()
+	t.applogIdx = applogIdx
 
-	go fetchNep5Tx(nep5TxChan, applogChan, lastPk, applogIdx)
-	go fetchAppLog(4, applogChan)
+	// Transfers of the last transaction are partially stored, fetch it again.
+	if applogIdx != -1 && lastPk > 0 {
+		return lastPk - 1
+	}
 
-	go handleNep5Tx(nep5TxChan, nep5StoreChan, applogIdx)
-	go handleNep5Store(nep5StoreChan)
+	return lastPk
 }
 
-func fetchNep5Tx(nep5TxChan chan<- *nep5TxInfo, applogChan chan<- *tx.Transaction, lastPk uint, applogIdx int) {
-	defer mail.AlertIfErr()
+func (t *nep5Task) Fetch(ctx context.Context, cursor uint) (interface{}, uint) {
+	// Assets registered in rolled back blocks are removed, they may be registered again.
+	if n := rollbacks.Get(); n != t.rollbacks {
+		t.rollbacks = n
+		nep5AssetDecimals = db.GetNep5AssetDecimals()
+	}
 
-	// If there are some transfers in this transaction,
-	// this variable will be the last index(starts from 0).
-	// If this variable is -1,
-	// it means CURRENT TRANSACTION HAD BEEN HANDLED(zero transfers,
-	// is nep5 trgistration transfer, or non-transfer actions),
-	// so nextTxPK should be next pk, not the current pk.
-	nextTxPK := lastPk
-	if applogIdx == -1 {
-		nextTxPK++
+	txs := db.GetInvocationTxs(cursor+1, 100)
+	if len(txs) == 0 {
+		return nil, cursor
 	}
 
-	for {
-		txs := db.GetInvocationTxs(nextTxPK, 100)
+	next := txs[len(txs)-1].ID
 
-		for i := len(txs) - 1; i >= 0; i-- {
-			// cannot be app call
-			if len(txs[i].Script) <= 42 ||
-				txs[i].TxID == "0xb00a0d7b752ba935206e1db67079c186ba38a4696d3afe28814a4834b2254cbe" {
-				txs = append(txs[:i], txs[i+1:]...)
-			}
+	for i := len(txs) - 1; i >= 0; i-- {
+		// cannot be app call
+		if len(txs[i].Script) <= 42 ||
+			txs[i].TxID == "0xb00a0d7b752ba935206e1db67079c186ba38a4696d3afe28814a4834b2254cbe" {
+			txs = append(txs[:i], txs[i+1:]...)
 		}
+	}
 
-		if len(txs) == 0 {
-			time.Sleep(2 * time.Second)
-			continue
+	appLogResults, err := getAppLogs(ctx, txs)
+	if err != nil {
+		// Nothing is fetched, it is fetched again after a while unless the task is stopping.
+		if err == rpc.ErrMethodUnavailable && !t.appLogUnavailable {
+			log.Error.Println("Application logs are unavailable, nep5 task waits for rpc servers supporting getapplicationlog")
 		}
+		t.appLogUnavailable = err == rpc.ErrMethodUnavailable
+		return nil, cursor
+	}
+	if t.appLogUnavailable {
+		log.Println("Application logs are available again")
+		t.appLogUnavailable = false
+	}
 
-		nextTxPK = txs[len(txs)-1].ID + 1
+	stores := nep5Stores{}
 
-		for _, tx := range txs {
-			applogChan <- tx
-		}
+	for i, tx := range txs {
+		// Instructions before a part which can not be disassembled are still parsed.
+		instrs, _ := smartcontract.DisassembleHex(tx.Script)
 
-		for _, tx := range txs {
-			for {
-				// Get applicationlog from map.
-				appLogResult, ok := appLogs.Load(tx.TxID)
-				if !ok {
-					time.Sleep(10 * time.Millisecond)
-					continue
-				}
+		t.parse(&stores, &nep5TxInfo{
+			tx:           tx,
+			instrs:       instrs,
+			appLogResult: appLogResults[i],
+		})
+	}
 
-				appLogs.Delete(tx.TxID)
+	return []*nep5Store(stores), next
+}
 
-				nep5Info := nep5TxInfo{
-					tx:           tx,
-					dataStack:    smartcontract.ReadScript(tx.Script),
-					appLogResult: appLogResult.(*rpc.RawApplicationLogResult),
-				}
+func (t *nep5Task) Apply(batch interface{}) {
+	for _, s := range batch.([]*nep5Store) {
+		withChainLock(func() {
+			if txRemoved(s.txPK()) {
+				return
+			}
 
-				nep5TxChan <- &nep5Info
-				break
+			switch s.t {
+			case 0:
+				handleNep5AssetStore(s)
+			case 1:
+				handleNep5TxStore(s)
+			case 2:
+				handleNep5BalanceTotalSupplyStore(s)
+			case 3:
+				handleNep5CounterStore(s)
+			case 4:
+				handleNEP5Migrate(s)
+			default:
+				err := fmt.Errorf("error nep5 store type %d: %+v", s.t, s.d)
+				panic(err)
 			}
-		}
+		})
 	}
 }
 
-func fetchAppLog(goroutines int, applogChan <-chan *tx.Transaction) {
-	defer mail.AlertIfErr()
+func (t *nep5Task) Highest() uint {
+	return db.GetMaxNonEmptyScriptTxPk()
+}
+
+// getAppLogs returns application logs of the given transactions, errors are those of rpc.GetApplicationLogs.
+func getAppLogs(ctx context.Context, txs []*tx.Transaction) ([]*rpc.RawApplicationLogResult, error) {
+	results := make([]*rpc.RawApplicationLogResult, len(txs))
+	indexes := []int{}
+	txIDs := []string{}
+	minHeight := 0
 
-	for i := 0; i < goroutines; i++ {
-		go func(ch <-chan *tx.Transaction) {
-			for tx := range ch {
-				appLogResult := rpc.GetApplicationLog(int(tx.BlockIndex), tx.TxID)
-				appLogs.Store(tx.TxID, appLogResult)
+	withChainLock(func() {
+		for i, tx := range txs {
+			// Rolled back transactions may not exist on rpc servers.
+			if txRemoved(tx.ID) {
+				results[i] = &rpc.RawApplicationLogResult{TxID: tx.TxID}
+				continue
 			}
-		}(applogChan)
-	}
-}
 
-func handleNep5Tx(nep5TxChan <-chan *nep5TxInfo, nep5StoreChan chan<- *nep5Store, applogIdx int) {
-	defer mail.AlertIfErr()
-
-	for nep5Info := range nep5TxChan {
-		tx := nep5Info.tx
-		opCodeDataStack := nep5Info.dataStack
-		appLogResult := nep5Info.appLogResult
-
-		if opCodeDataStack == nil || len(*opCodeDataStack) == 0 {
-			nep5StoreChan <- &nep5Store{
-				t: 3,
-				d: nep5CounterStore{
-					txPK:      tx.ID,
-					applogIdx: -1,
-				},
+			indexes = append(indexes, i)
+			txIDs = append(txIDs, tx.TxID)
+			if minHeight < int(tx.BlockIndex) {
+				minHeight = int(tx.BlockIndex)
 			}
-			continue
 		}
+	})
 
-		// It may be a nep5 registration transaction.
-		if applogIdx == -1 && isNep5RegistrationTx(tx.Script) {
-			handleNep5RegTx(nep5StoreChan, tx, opCodeDataStack.Copy())
-			if isNep5MigrateTx((tx.Script)) {
-				handleMigrate(opCodeDataStack, nep5StoreChan, tx)
-			}
-		} else if applogIdx == -1 && isNep5MigrateTx(tx.Script) {
-			handleMigrate(opCodeDataStack, nep5StoreChan, tx)
-		} else {
-			handleNep5NonTxCall(nep5StoreChan, tx, opCodeDataStack)
+	appLogs, err := rpc.GetApplicationLogs(ctx, minHeight, txIDs)
+	if err != nil {
+		return nil, err
+	}
+
+	for i, appLog := range appLogs {
+		results[indexes[i]] = appLog
+	}
 
-			if len(appLogResult.Executions) > 0 {
-				notifs := []rpc.RawNotifications{}
+	return results, nil
+}
 
-				for _, exec := range appLogResult.Executions {
-					if strings.Contains(exec.VMState, "FAULT") ||
-						len(exec.Notifications) == 0 {
-						continue
-					}
+// parse collects store items of the transaction.
+func (t *nep5Task) parse(stores *nep5Stores, nep5Info *nep5TxInfo) {
+	tx := nep5Info.tx
+	instrs := nep5Info.instrs
+	appLogResult := nep5Info.appLogResult
 
-					notifs = append(notifs, exec.Notifications...)
+	if len(instrs) == 0 {
+		stores.add(&nep5Store{
+			t: 3,
+			d: nep5CounterStore{
+				txPK:      tx.ID,
+				applogIdx: -1,
+			},
+		})
+		return
+	}
+
+	calls := smartcontract.Calls(instrs)
+
+	// It may be a nep5 registration or migration transaction.
+	if contract, ok := nep5Registration(instrs); ok && t.applogIdx == -1 {
+		handleNep5RegTx(stores, tx, contract)
+	} else if call, contract, ok := nep5Migration(calls); ok && t.applogIdx == -1 {
+		handleMigrate(stores, tx, call, contract)
+	} else {
+		handleNep5NonTxCall(stores, tx, calls)
+
+		if len(appLogResult.Executions) > 0 {
+			notifs := []rpc.RawNotifications{}
+
+			for _, exec := range appLogResult.Executions {
+				if strings.Contains(exec.VMState, "FAULT") ||
+					len(exec.Notifications) == 0 {
+					continue
 				}
 
-				handleNep5TxCall(nep5StoreChan, tx, notifs, applogIdx)
+				notifs = append(notifs, exec.Notifications...)
 			}
 
-			// Set applogIdx to -1 to signify these transaction has been handled.
-			applogIdx = -1
-			nep5StoreChan <- &nep5Store{
-				t: 3,
-				d: nep5CounterStore{
-					txPK:      tx.ID,
-					applogIdx: applogIdx,
-				},
-			}
+			handleNep5TxCall(stores, tx, notifs, t.applogIdx)
 		}
-	}
-}
 
-func handleMigrate(opCodeDataStack *smartcontract.DataStack, nep5StoreChan chan<- *nep5Store, tx *tx.Transaction) {
-	scriptHash := opCodeDataStack.PopData()
-	oldAssetID := util.GetAssetIDFromScriptHash(scriptHash)
-	if len(oldAssetID) != 40 {
-		nep5StoreChan <- &nep5Store{
+		// Set applogIdx to -1 to signify these transaction has been handled.
+		t.applogIdx = -1
+		stores.add(&nep5Store{
 			t: 3,
 			d: nep5CounterStore{
 				txPK:      tx.ID,
-				applogIdx: -1,
+				applogIdx: t.applogIdx,
 			},
-		}
-		return
+		})
 	}
+}
+
+func handleMigrate(stores *nep5Stores, tx *tx.Transaction, call smartcontract.Call, contract *smartcontract.Contract) {
+	oldAssetID := util.GetAssetIDFromScriptHash(call.ScriptHash)
 
-	newAssetAdmin, newAssetID, ok := handleNep5RegTx(nep5StoreChan, tx, opCodeDataStack)
+	newAssetAdmin, newAssetID, ok := handleNep5RegTx(stores, tx, contract)
 	if !ok {
-		nep5StoreChan <- &nep5Store{
+		stores.add(&nep5Store{
 			t: 3,
 			d: nep5CounterStore{
 				txPK:      tx.ID,
 				applogIdx: -1,
 			},
-		}
+		})
 		return
 	}
 
-	nep5StoreChan <- &nep5Store{
+	stores.add(&nep5Store{
 		t: 4,
 		d: nep5MigrateStore{
 			newAssetAdmin: newAssetAdmin,
@@ -335,36 +358,10 @@ func handleMigrate(opCodeDataStack *smartcontract.DataStack, nep5StoreChan chan<
 			txPK:          tx.ID,
 			txID:          tx.TxID,
 		},
-	}
-}
-
-func handleNep5Store(nep5Store <-chan *nep5Store) {
-	defer mail.AlertIfErr()
-
-	for s := range nep5Store {
-		txPK := uint(0)
-
-		switch s.t {
-		case 0:
-			txPK = handleNep5AssetStore(s)
-		case 1:
-			txPK = handleNep5TxStore(s)
-		case 2:
-			txPK = handleNep5BalanceTotalSupplyStore(s)
-		case 3:
-			txPK = handleNep5CounterStore(s)
-		case 4:
-			txPK = handleNEP5Migrate(s)
-		default:
-			err := fmt.Errorf("error nep5 store type %d: %+v", s.t, s.d)
-			panic(err)
-		}
-
-		showNep5Progress(txPK)
-	}
+	})
 }
 
-func handleNep5AssetStore(s *nep5Store) uint {
+func handleNep5AssetStore(s *nep5Store) {
 	d, ok := s.d.(nep5AssetStore)
 	if !ok {
 		err := fmt.Errorf("error nep5 store type %d: %+v", s.t, s.d)
@@ -379,11 +376,9 @@ func handleNep5AssetStore(s *nep5Store) uint {
 	if err != nil {
 		panic(err)
 	}
-
-	return d.tx.ID
 }
 
-func handleNep5TxStore(s *nep5Store) uint {
+func handleNep5TxStore(s *nep5Store) {
 	d, ok := s.d.(nep5TxStore)
 	if !ok {
 		err := fmt.Errorf("error nep5 store type %d: %+v", s.t, s.d)
@@ -402,11 +397,9 @@ func handleNep5TxStore(s *nep5Store) uint {
 	if err != nil {
 		panic(err)
 	}
-
-	return d.tx.ID
 }
 
-func handleNep5BalanceTotalSupplyStore(s *nep5Store) uint {
+func handleNep5BalanceTotalSupplyStore(s *nep5Store) {
 	d, ok := s.d.(nep5BalanceTSStore)
 	if !ok {
 		err := fmt.Errorf("error nep5 store type %d: %+v", s.t, s.d)
@@ -423,11 +416,9 @@ func handleNep5BalanceTotalSupplyStore(s *nep5Store) uint {
 	if err != nil {
 		panic(err)
 	}
-
-	return d.txPK
 }
 
-func handleNep5CounterStore(s *nep5Store) uint {
+func handleNep5CounterStore(s *nep5Store) {
 	d, ok := s.d.(nep5CounterStore)
 	if !ok {
 		err := fmt.Errorf("error nep5 store type %d: %+v", s.t, s.d)
@@ -438,22 +429,16 @@ func handleNep5CounterStore(s *nep5Store) uint {
 	if err != nil {
 		panic(err)
 	}
-
-	return d.txPK
 }
 
-func handleNep5RegTx(nep5StoreChan chan<- *nep5Store, tx *tx.Transaction, opCodeDataStack *smartcontract.DataStack) (string, string, bool) {
+func handleNep5RegTx(stores *nep5Stores, tx *tx.Transaction, contract *smartcontract.Contract) (string, string, bool) {
 	adminAddr, ok := getCallerAddr(tx)
 	if !ok {
 		return "", "", false
 	}
 
-	script, regInfo, ok := nep5.GetNep5RegInfo(opCodeDataStack)
-	if !ok {
-		return "", "", false
-	}
-
-	scriptHash := util.GetScriptHash(script)
+	regInfo := nep5.GetNep5RegInfo(contract)
+	scriptHash := util.GetScriptHash(contract.Script)
 	assetID := util.GetAssetIDFromScriptHash(scriptHash)
 	if _, ok := nep5AssetDecimals[assetID]; ok {
 		return util.GetAddressFromScriptHash(adminAddr), assetID, true
@@ -468,7 +453,7 @@ func handleNep5RegTx(nep5StoreChan chan<- *nep5Store, tx *tx.Transaction, opCode
 	// Cache total supply.
 	cache.UpdateAssetTotalSupply(nep5.AssetID, nep5.TotalSupply, atHeight)
 
-	nep5StoreChan <- &nep5Store{
+	stores.add(&nep5Store{
 		t: 0,
 		d: nep5AssetStore{
 			tx:        tx,
@@ -477,13 +462,13 @@ func handleNep5RegTx(nep5StoreChan chan<- *nep5Store, tx *tx.Transaction, opCode
 			addrAsset: addrAsset,
 			atHeight:  atHeight,
 		},
-	}
+	})
 
 	nep5AssetDecimals[nep5.AssetID] = nep5.Decimals
 	return util.GetAddressFromScriptHash(adminAddr), assetID, true
 }
 
-func handleNEP5Migrate(s *nep5Store) uint {
+func handleNEP5Migrate(s *nep5Store) {
 	d, ok := s.d.(nep5MigrateStore)
 	if !ok {
 		err := fmt.Errorf("err nep5 migrate store type %d: %+v", s.t, s.d)
@@ -494,27 +479,16 @@ func handleNEP5Migrate(s *nep5Store) uint {
 	if err != nil {
 		panic(err)
 	}
-
-	return d.txPK
 }
 
-func handleNep5NonTxCall(nep5StoreChan chan<- *nep5Store, tx *tx.Transaction, opCodeDataStack *smartcontract.DataStack) {
-	// At least two commands are required(opCode and its related data).
-	for len(*opCodeDataStack) >= 2 {
-		opCode, data := opCodeDataStack.PopItem()
-
-		if opCode != 0x67 { // APPCALL
-			continue
-		}
+func handleNep5NonTxCall(stores *nep5Stores, tx *tx.Transaction, calls []smartcontract.Call) {
+	// Calls are handled from the last one.
+	for i := len(calls) - 1; i >= 0; i-- {
+		scriptHash := calls[i].ScriptHash
+		method := calls[i].Method
 
-		scriptHash := data
-		if len(scriptHash) != 20 {
-			continue
-		}
-
-		method := opCodeDataStack.PopData()
 		// Will use 'getapplicationlog' for 'transfer' record so omit this type.
-		if len(method) == 0 || reflect.DeepEqual(method, []byte("transfer")) {
+		if len(method) == 0 || bytes.Equal(method, []byte("transfer")) {
 			continue
 		}
 
@@ -530,14 +504,14 @@ func handleNep5NonTxCall(nep5StoreChan chan<- *nep5Store, tx *tx.Transaction, op
 		}
 
 		callerBalance, ok := queryCallerBalance(tx.BlockIndex, tx.BlockTime, scriptHash, callerAddr)
-		if !ok || callerBalance.Cmp(big.NewFloat(0)) != 1 {
+		if !ok || callerBalance.Sign() != 1 {
 			continue
 		}
 
 		callerAddrStr := util.GetAddressFromScriptHash(callerAddr)
 		assetID := util.GetAssetIDFromScriptHash(scriptHash)
 
-		nep5StoreChan <- &nep5Store{
+		stores.add(&nep5Store{
 			t: 2,
 			d: nep5BalanceTSStore{
 				txPK:        tx.ID,
@@ -548,11 +522,11 @@ func handleNep5NonTxCall(nep5StoreChan chan<- *nep5Store, tx *tx.Transaction, op
 				assetID:     assetID,
 				totalSupply: totalSupply,
 			},
-		}
+		})
 	}
 }
 
-func handleNep5TxCall(nep5StoreChan chan<- *nep5Store, tx *tx.Transaction, notifs []rpc.RawNotifications, applogIdx int) {
+func handleNep5TxCall(stores *nep5Stores, tx *tx.Transaction, notifs []rpc.RawNotifications, applogIdx int) {
 	// Get all transfers.
 	for applogIdx++; applogIdx < len(notifs); applogIdx++ {
 		notification := notifs[applogIdx]
@@ -593,11 +567,11 @@ func handleNep5TxCall(nep5StoreChan chan<- *nep5Store, tx *tx.Transaction, notif
 			continue
 		}
 
-		recordNep5Transfer(nep5StoreChan, tx, assetID, fromSc, toSc, val, valType, applogIdx)
+		recordNep5Transfer(stores, tx, assetID, fromSc, toSc, val, valType, applogIdx)
 	}
 }
 
-func recordNep5Transfer(nep5StoreChan chan<- *nep5Store, tx *tx.Transaction, assetID string, fromSc string, toSc string, val string, valType string, applogIdx int) {
+func recordNep5Transfer(stores *nep5Stores, tx *tx.Transaction, assetID string, fromSc string, toSc string, val string, valType string, applogIdx int) {
 	scriptHash := util.GetScriptHashFromAssetID(assetID)
 
 	// 'From' address may be empty(when issuing an asset).
@@ -626,12 +600,14 @@ func recordNep5Transfer(nep5StoreChan chan<- *nep5Store, tx *tx.Transaction, ass
 	toBalance := balances[1]
 
 	// Handle possibility of storage injection attack.
-	var totalSupply *big.Float
+	var totalSupply *amount.Amount
 	if toSc == "746f74616c537570706c79" {
-		totalSupply, _ = queryNep5TotalSupply(tx.BlockIndex, tx.BlockTime, scriptHash)
+		if supply, ok := queryNep5TotalSupply(tx.BlockIndex, tx.BlockTime, scriptHash); ok {
+			totalSupply = &supply
+		}
 	}
 
-	nep5StoreChan <- &nep5Store{
+	stores.add(&nep5Store{
 		t: 1,
 		d: nep5TxStore{
 			tx:            tx,
@@ -644,38 +620,39 @@ func recordNep5Transfer(nep5StoreChan chan<- *nep5Store, tx *tx.Transaction, ass
 			transferValue: transferValue,
 			totalSupply:   totalSupply,
 		},
-	}
+	})
 }
 
-func getTransferValue(assetID string, val string, valType string) (*big.Float, bool) {
+func getTransferValue(assetID string, val string, valType string) (amount.Amount, bool) {
 	value, ok := extractValue(val, valType)
 	if !ok {
-		return nil, false
+		return amount.Zero, false
 	}
 
 	return getReadableValue(assetID, value), true
 }
 
-func extractValue(val interface{}, valType string) (*big.Float, bool) {
+// extractValue returns the integer value of the stack item in base units.
+func extractValue(val interface{}, valType string) (*big.Int, bool) {
 	switch valType {
 	case "Integer":
-		v, err := strconv.ParseInt(val.(string), 10, 64)
-		if err != nil {
+		v, ok := new(big.Int).SetString(val.(string), 10)
+		if !ok {
 			return nil, false
 		}
 
-		return new(big.Float).SetInt64(v), true
+		return v, true
 	case "ByteArray":
 		valueBytes, err := hex.DecodeString(val.(string))
 		if err != nil {
 			return nil, false
 		}
 
-		return util.BytesToBigFloat(valueBytes), true
+		return util.BytesToBigInt(valueBytes), true
 	case "Array":
 		arr := val.([]interface{})
 		if len(arr) == 0 {
-			return big.NewFloat(0), true
+			return new(big.Int), true
 		}
 		if len(arr) == 2 {
 			return extractValue(arr[0], arr[1].(string))
@@ -687,37 +664,55 @@ func extractValue(val interface{}, valType string) (*big.Float, bool) {
 	}
 }
 
+// getCallerAddr returns the script hash of the account which signed the transaction.
+// Witnesses of signature and multi-signature contracts are preferred over custom contracts,
+// witnesses of deployed contracts have no verification script and are skipped.
 func getCallerAddr(tx *tx.Transaction) ([]byte, bool) {
-	txScrpits, err := db.GetTxScripts(tx.TxID)
+	txScripts, err := db.GetTxScripts(tx.TxID)
 	if err != nil {
 		panic(err)
 	}
 
-	if txScrpits == nil || txScrpits[0].Verification == "" {
-		return nil, false
-	}
+	var custom []byte
+	for _, txScript := range txScripts {
+		verification, err := hex.DecodeString(txScript.Verification)
+		if err != nil || len(verification) == 0 {
+			continue
+		}
 
-	verification, _ := hex.DecodeString(txScrpits[0].Verification)
-	callerAddr := util.GetScriptHash(verification)
+		if smartcontract.ClassifyVerification(verification).IsStandard() {
+			return util.GetScriptHash(verification), true
+		}
+		if custom == nil {
+			custom = util.GetScriptHash(verification)
+		}
+	}
 
-	return callerAddr, true
+	return custom, custom != nil
 }
 
-func isNep5RegistrationTx(script string) bool {
-	if strings.Contains(script, "746f74616c537570706c79") &&
-		strings.Contains(script, "6e616d65") &&
-		strings.Contains(script, "73796d626f6c") &&
-		strings.Contains(script, "646563696d616c73") {
-		return true
+// nep5Registration returns the contract deployed by the transaction if it is a nep5 contract.
+func nep5Registration(instrs []smartcontract.Instruction) (*smartcontract.Contract, bool) {
+	contract, ok := smartcontract.CreatedContract(instrs)
+	if !ok || !nep5.IsNep5Script(contract.Script) {
+		return nil, false
 	}
 
-	return false
+	return contract, true
 }
 
-func isNep5MigrateTx(script string) bool {
-	// Neo.Contract.Migrate: 4e656f2e436f6e74726163742e4d696772617465
-	keyword := "68144e656f2e436f6e74726163742e4d696772617465"
-	return strings.Contains(script, keyword)
+// nep5Migration returns the call passing a new contract to a contract which migrates to it.
+// Scripts of called contracts are not at hand, so the new contract is expected
+// to be migratable itself like the contract it replaces.
+func nep5Migration(calls []smartcontract.Call) (smartcontract.Call, *smartcontract.Contract, bool) {
+	for i := len(calls) - 1; i >= 0; i-- {
+		contract, ok := smartcontract.ParseContract(calls[i].Args)
+		if ok && contract.Migratable() {
+			return calls[i], contract, true
+		}
+	}
+
+	return smartcontract.Call{}, nil, false
 }
 
 func queryNep5AssetInfo(tx *tx.Transaction, scriptHash []byte, addrBytes []byte) (*nep5.Nep5, *addr.Asset, uint, bool) {
@@ -747,7 +742,7 @@ func queryNep5AssetInfo(tx *tx.Transaction, scriptHash []byte, addrBytes []byte)
 	if err != nil {
 		return nil, nil, 0, false
 	}
-	name := strings.Replace(string(nameBytes), "'", "\\'", -1)
+	name := string(nameBytes)
 	if name == "" {
 		return nil, nil, 0, false
 	}
@@ -771,22 +766,19 @@ func queryNep5AssetInfo(tx *tx.Transaction, scriptHash []byte, addrBytes []byte)
 		return nil, nil, 0, false
 	}
 
-	totalSupply, ok := extractValue(result.Stack[3].Value, result.Stack[3].Type)
+	totalSupplyUnits, ok := extractValue(result.Stack[3].Value, result.Stack[3].Type)
 	if !ok {
 		return nil, nil, 0, false
 	}
-	totalSupply = new(big.Float).Quo(totalSupply, big.NewFloat(math.Pow10(int(decimals))))
+	totalSupply := amount.New(totalSupplyUnits, uint8(decimals))
 
 	adminBalanceHexStr, ok := result.Stack[4].Value.(string)
 	if !ok {
 		return nil, nil, 0, false
 	}
-	adminBalance := util.HexToBigFloat(adminBalanceHexStr)
-	if adminBalance.Cmp(big.NewFloat(0)) == 1 {
-		adminBalance = new(big.Float).Quo(adminBalance, big.NewFloat(math.Pow10(int(decimals))))
-	}
+	adminBalance := amount.New(hexToUnits(adminBalanceHexStr), uint8(decimals))
 
-	addrHasBalance := adminBalance.Cmp(big.NewFloat(0))
+	addrHasBalance := adminBalance.Sign()
 
 	nep5 := &nep5.Nep5{
 		AssetID:          assetID,
@@ -840,7 +832,7 @@ func createSCSB(scriptHash []byte, method string, params [][]byte) string {
 	return scsb.GetScript()
 }
 
-func queryCallerBalance(txBlockIndex uint, blockTime uint64, scriptHash []byte, callerAddrBytes []byte) (*big.Float, bool) {
+func queryCallerBalance(txBlockIndex uint, blockTime uint64, scriptHash []byte, callerAddrBytes []byte) (amount.Amount, bool) {
 	assetID := util.GetAssetIDFromScriptHash(scriptHash)
 	callerAddr := util.GetAddressFromScriptHash(callerAddrBytes)
 
@@ -854,7 +846,7 @@ func queryCallerBalance(txBlockIndex uint, blockTime uint64, scriptHash []byte,
 
 	decimals, ok := nep5AssetDecimals[assetID]
 	if !ok {
-		return big.NewFloat(0), false
+		return amount.Zero, false
 	}
 
 	scripts := createSCSB(scriptHash, "balanceOf", [][]byte{callerAddrBytes})
@@ -865,28 +857,29 @@ func queryCallerBalance(txBlockIndex uint, blockTime uint64, scriptHash []byte,
 		strings.Contains(result.State, "FAULT") ||
 		result.Stack == nil ||
 		len(result.Stack) == 0 {
-		return big.NewFloat(0), false
+		return amount.Zero, false
 	}
 
-	callerBalance := util.HexToBigFloat(result.Stack[0].Value.(string))
-	callerBalance = new(big.Float).Quo(callerBalance, big.NewFloat(math.Pow10(int(decimals))))
+	callerBalance := amount.New(hexToUnits(result.Stack[0].Value.(string)), decimals)
 
 	return callerBalance, true
 }
 
-func queryBalances(txBlockIndex uint, scriptHash []byte, assetID string, addrBytesList [][]byte) ([]*big.Float, bool) {
+func queryBalances(txBlockIndex uint, scriptHash []byte, assetID string, addrBytesList [][]byte) ([]amount.Amount, bool) {
 	// Check if this is a valid assetID.
 	if _, ok := nep5AssetDecimals[assetID]; !ok {
 		return nil, false
 	}
 
-	balances := make([]*big.Float, len(addrBytesList))
+	balances := make([]amount.Amount, len(addrBytesList))
+	resolved := make([]bool, len(addrBytesList))
 
 	scsb := ""
 
 	for idx, addrBytes := range addrBytesList {
 		if len(addrBytes) == 0 {
-			balances[idx] = big.NewFloat(0)
+			balances[idx] = amount.Zero
+			resolved[idx] = true
 		} else {
 			// Check cached value.
 			addr := util.GetAddressFromScriptHash(addrBytes)
@@ -894,6 +887,7 @@ func queryBalances(txBlockIndex uint, scriptHash []byte, assetID string, addrByt
 				// If cache valid.
 				if cached.BlockIndex > txBlockIndex {
 					balances[idx] = cached.Balance
+					resolved[idx] = true
 					continue
 				}
 			}
@@ -916,11 +910,11 @@ func queryBalances(txBlockIndex uint, scriptHash []byte, assetID string, addrByt
 	for i := 0; i < len(addrBytesList); i++ {
 		addrBytes := addrBytesList[i]
 
-		if len(addrBytes) == 0 || balances[i] != nil {
+		if len(addrBytes) == 0 || resolved[i] {
 			continue
 		}
 
-		balance := util.HexToBigFloat(result.Stack[idx].Value.(string))
+		balance := hexToUnits(result.Stack[idx].Value.(string))
 		balances[i] = getReadableValue(assetID, balance)
 		idx++
 	}
@@ -928,12 +922,12 @@ func queryBalances(txBlockIndex uint, scriptHash []byte, assetID string, addrByt
 	return balances, true
 }
 
-func queryNep5TotalSupply(txBlockIndex uint, blockTime uint64, scriptHash []byte) (*big.Float, bool) {
+func queryNep5TotalSupply(txBlockIndex uint, blockTime uint64, scriptHash []byte) (amount.Amount, bool) {
 	assetID := util.GetAssetIDFromScriptHash(scriptHash)
 
 	decimals, ok := nep5AssetDecimals[assetID]
 	if !ok {
-		return nil, false
+		return amount.Zero, false
 	}
 
 	// Query from cache
@@ -946,11 +940,11 @@ func queryNep5TotalSupply(txBlockIndex uint, blockTime uint64, scriptHash []byte
 	minHeight := rpc.BestHeight.Get()
 	result := rpc.SmartContractRPCCall(minHeight, totalSupplyScsb)
 	if result == nil || strings.Contains(result.State, "FAULT") {
-		return nil, false
+		return amount.Zero, false
 	}
 
 	if len(result.Stack) == 0 {
-		return nil, false
+		return amount.Zero, false
 	}
 
 	// Get first valid result
@@ -969,15 +963,16 @@ func queryNep5TotalSupply(txBlockIndex uint, blockTime uint64, scriptHash []byte
 	*/
 	ok = false
 	for _, stack := range result.Stack {
-		totalSupply, ok = extractValue(stack.Value, stack.Type)
+		var units *big.Int
+		units, ok = extractValue(stack.Value, stack.Type)
 		if ok {
-			totalSupply = new(big.Float).Quo(totalSupply, big.NewFloat(math.Pow10(int(decimals))))
+			totalSupply = amount.New(units, decimals)
 			break
 		}
 	}
 
 	if !ok {
-		return nil, false
+		return amount.Zero, false
 	}
 
 	// Update cacheed value
@@ -986,59 +981,20 @@ func queryNep5TotalSupply(txBlockIndex uint, blockTime uint64, scriptHash []byte
 	return totalSupply, true
 }
 
-func getReadableValue(assetID string, balance *big.Float) *big.Float {
-	zeroValue := big.NewFloat(0)
-	if balance.Cmp(zeroValue) == 0 {
-		return zeroValue
-	}
-
+// getReadableValue returns the amount of the given base units of nep5 asset.
+func getReadableValue(assetID string, units *big.Int) amount.Amount {
 	decimals, ok := nep5AssetDecimals[assetID]
 	if !ok {
 		panic("Failed to get decimals of nep5 asset: " + assetID)
 	}
 
-	return new(big.Float).Quo(balance, big.NewFloat(math.Pow10(int(decimals))))
+	return amount.New(units, decimals)
 }
 
-func showNep5Progress(txPk uint) {
-	if maxNep5PK == 0 || Nep5MaxPkShouldRefresh {
-		Nep5MaxPkShouldRefresh = false
-		maxNep5PK = db.GetMaxNonEmptyScriptTxPk()
-	}
-
-	now := time.Now()
-	if nProgress.LastOutputTime == (time.Time{}) {
-		nProgress.LastOutputTime = now
-	}
-	if txPk < maxNep5PK && now.Sub(nProgress.LastOutputTime) < time.Second {
-		return
-	}
-
-	GetEstimatedRemainingTime(int64(txPk), int64(maxNep5PK), &nProgress)
-	if nProgress.Percentage.Cmp(big.NewFloat(100)) == 0 &&
-		bProgress.Finished {
-		nProgress.Finished = true
-	}
-
-	log.Printf("%sProgress of nep5: %d/%d, %.4f%%\n",
-		nProgress.RemainingTimeStr,
-		txPk,
-		maxNep5PK,
-		nProgress.Percentage)
-	nProgress.LastOutputTime = now
-
-	// Send mail if fully synced
-	if nProgress.Finished && !nProgress.MailSent {
-		nProgress.MailSent = true
-
-		// If sync lasts shortly, do not send mail
-		if time.Since(nProgress.InitTime) < time.Minute*5 {
-			return
-		}
-
-		msg := fmt.Sprintf("Init time: %v\nEnd Time: %v\n", nProgress.InitTime, time.Now())
-		mail.SendNotify("NEP5 TX Fully Synced", msg)
-	}
+// hexToUnits returns the unsigned integer of little-endian hex string, invalid hex is decoded as far as possible.
+func hexToUnits(hexStr string) *big.Int {
+	data, _ := hex.DecodeString(hexStr)
+	return util.BytesToBigInt(data)
 }
 
 func getMinHeight(blockHeight uint) int {
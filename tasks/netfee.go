@@ -0,0 +1,108 @@
+package tasks
+
+import (
+	"squirrel/amount"
+	"squirrel/asset"
+	"squirrel/db"
+	"squirrel/rpc"
+)
+
+// linkBlocks sets next block hashes which are unknown to blocks decoded from their serialized form.
+func linkBlocks(rawBlocks []*rpc.RawBlock) {
+	for i := 1; i < len(rawBlocks); i++ {
+		if rawBlocks[i-1].NextBlockHash == "" {
+			rawBlocks[i-1].NextBlockHash = rawBlocks[i].Hash
+		}
+	}
+}
+
+// resolveNetFees computes net fees of transactions in decoded blocks,
+// which are GAS of referenced outputs minus GAS of outputs and the system fee.
+func resolveNetFees(rawBlocks []*rpc.RawBlock) {
+	gasOutputs := make(map[string]map[uint16]amount.Amount)
+	missing := make(map[string]bool)
+
+	for _, b := range rawBlocks {
+		for _, rawTx := range b.Tx {
+			gasOutputs[rawTx.TxID] = make(map[uint16]amount.Amount)
+			for _, vout := range rawTx.Vout {
+				if vout.Asset == asset.GASAssetID {
+					gasOutputs[rawTx.TxID][vout.N] = vout.Value
+				}
+			}
+
+			if !b.Decoded {
+				continue
+			}
+
+			for _, vin := range rawTx.Vin {
+				if _, ok := gasOutputs[vin.TxID]; !ok {
+					missing[vin.TxID] = true
+				}
+			}
+		}
+	}
+
+	if len(missing) > 0 {
+		loadGasOutputs(gasOutputs, missing)
+	}
+
+	for _, b := range rawBlocks {
+		if !b.Decoded {
+			continue
+		}
+
+		for i := range b.Tx {
+			rawTx := &b.Tx[i]
+			if rawTx.Type == "MinerTransaction" || rawTx.Type == "ClaimTransaction" {
+				continue
+			}
+
+			fee := rawTx.SysFee.Neg()
+			for _, vin := range rawTx.Vin {
+				if value, ok := gasOutputs[vin.TxID][vin.Vout]; ok {
+					fee = fee.Add(value)
+				}
+			}
+			for _, vout := range rawTx.Vout {
+				if vout.Asset == asset.GASAssetID {
+					fee = fee.Sub(vout.Value)
+				}
+			}
+
+			rawTx.NetFee = fee.Rescale(8)
+		}
+	}
+}
+
+// loadGasOutputs adds GAS outputs of the given stored transactions to gasOutputs.
+func loadGasOutputs(gasOutputs map[string]map[uint16]amount.Amount, txIDs map[string]bool) {
+	// Keep the number of query placeholders small.
+	const chunkSize = 500
+
+	ids := make([]string, 0, len(txIDs))
+	for txID := range txIDs {
+		ids = append(ids, txID)
+		gasOutputs[txID] = make(map[uint16]amount.Amount)
+	}
+
+	for start := 0; start < len(ids); start += chunkSize {
+		end := start + chunkSize
+		if end > len(ids) {
+			end = len(ids)
+		}
+
+		vouts, err := db.GetVouts(ids[start:end])
+		if err != nil {
+			panic(err)
+		}
+
+		for txID, txVouts := range vouts {
+			for _, vout := range txVouts {
+				if vout.AssetID == asset.GASAssetID {
+					gasOutputs[txID][vout.N] = vout.Value
+				}
+			}
+		}
+	}
+}
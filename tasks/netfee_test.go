@@ -0,0 +1,41 @@
+package tasks
+
+import (
+	"squirrel/amount"
+	"squirrel/asset"
+	"squirrel/rpc"
+	"testing"
+)
+
+func TestResolveNetFees(t *testing.T) {
+	gas := func(n uint16, value string) rpc.RawTxVout {
+		return rpc.RawTxVout{N: n, Asset: asset.GASAssetID, Value: amount.MustParse(value)}
+	}
+
+	claim := rpc.RawTx{TxID: "0x01", Type: "ClaimTransaction", Vout: []rpc.RawTxVout{gas(0, "10")}}
+	spend := rpc.RawTx{
+		TxID:   "0x02",
+		Type:   "InvocationTransaction",
+		Vin:    []rpc.RawTxVin{{TxID: "0x01", Vout: 0}},
+		Vout:   []rpc.RawTxVout{gas(0, "8.5"), {N: 1, Asset: asset.NEOAssetID, Value: amount.MustParse("1")}},
+		SysFee: amount.MustParse("1"),
+	}
+
+	blocks := []*rpc.RawBlock{
+		{Index: 1, Hash: "0xa", Decoded: true, Tx: []rpc.RawTx{claim}},
+		{Index: 2, Hash: "0xb", Decoded: true, Tx: []rpc.RawTx{spend}},
+	}
+
+	linkBlocks(blocks)
+	resolveNetFees(blocks)
+
+	if blocks[0].NextBlockHash != "0xb" || blocks[1].NextBlockHash != "" {
+		t.Errorf("next block hashes are (%s, %s)", blocks[0].NextBlockHash, blocks[1].NextBlockHash)
+	}
+	if fee := blocks[0].Tx[0].NetFee; !fee.IsZero() {
+		t.Errorf("claim net fee = %s", fee)
+	}
+	if fee := blocks[1].Tx[0].NetFee; fee.String() != "0.50000000" {
+		t.Errorf("invocation net fee = %s, expected 0.50000000", fee)
+	}
+}
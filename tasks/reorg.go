@@ -0,0 +1,225 @@
+package tasks
+
+import (
+	"fmt"
+	"squirrel/cache"
+	"squirrel/db"
+	"squirrel/log"
+	"squirrel/mail"
+	"squirrel/rpc"
+	"squirrel/util"
+	"sync"
+	"time"
+)
+
+var (
+	// chainLock is held for reading by tasks while they persist data derived from stored blocks,
+	// and for writing while diverged blocks are being rolled back.
+	chainLock sync.RWMutex
+
+	// removedTxPKs and removedTxIDs record transactions deleted by rollbacks,
+	// items of them still queued in task channels must be dropped.
+	removedTxPKs = make(map[uint]bool)
+	removedTxIDs = make(map[string]bool)
+	// removedHorizon holds the highest positions of tasks before rollbacks,
+	// removed transactions are forgotten once every task has passed its position.
+	removedHorizon = make(map[string]uint)
+
+	// rollbacks counts rollbacks, tasks caching stored data reload it when it changes.
+	rollbacks util.SafeCounter
+
+	// resyncChan notifies arrangeBlock to restart from the given height.
+	resyncChan = make(chan int, 1)
+
+	// storedBlockHash, remoteBlockHash and rollbackChain are replaced in tests.
+	storedBlockHash = db.GetBlockHash
+	remoteBlockHash = rpc.GetBlockHash
+	rollbackChain   = rollback
+)
+
+const (
+	// minChainBreakDelay and maxChainBreakDelay bound the delay before retrying a block
+	// which does not link to the stored chain while the break can not be resolved.
+	minChainBreakDelay = time.Second
+	maxChainBreakDelay = time.Minute
+)
+
+var (
+	// chainBreakDelay and chainBreakAlerted are only accessed by the goroutine storing blocks.
+	chainBreakDelay   = minChainBreakDelay
+	chainBreakAlerted bool
+)
+
+// withChainLock runs f while holding chainLock for reading.
+func withChainLock(f func()) {
+	chainLock.RLock()
+	defer chainLock.RUnlock()
+
+	f()
+}
+
+// txRemoved reports if the transaction of the given pk was rolled back.
+// Caller must hold chainLock.
+func txRemoved(txPK uint) bool {
+	return removedTxPKs[txPK]
+}
+
+// txIDRemoved reports if the transaction of the given txid was rolled back.
+// Caller must hold chainLock.
+func txIDRemoved(txID string) bool {
+	return removedTxIDs[txID]
+}
+
+// getLinkedBlocks returns the number of leading blocks which are
+// correctly linked to the stored chain by previous block hash.
+func getLinkedBlocks(rawBlocks []*rpc.RawBlock) int {
+	for i, b := range rawBlocks {
+		if b.Index == 0 {
+			continue
+		}
+
+		var prevHash string
+		if i == 0 {
+			prevHash = storedBlockHash(int(b.Index) - 1)
+		} else {
+			prevHash = rawBlocks[i-1].Hash
+		}
+
+		if b.PreviousBlockHash != prevHash {
+			return i
+		}
+	}
+
+	return len(rawBlocks)
+}
+
+// handleChainBreak is called when the given block does not link to the stored chain.
+// It rolls back diverged blocks if necessary and returns the height to continue from.
+// Breaks which can not be resolved are retried with increasing delays and alerted once.
+func handleChainBreak(b *rpc.RawBlock) int {
+	height := int(b.Index) - 1
+	log.Printf("Block %d(%s) does not link to stored block %d\n", b.Index, b.Hash, height)
+
+	if remoteBlockHash(height) == storedBlockHash(height) {
+		// The stored chain is canonical, the given block comes from a stale fork.
+		waitChainBreak()
+		return height
+	}
+
+	ancestor := findCommonAncestor(height - 1)
+	if ancestor < 0 {
+		msg := fmt.Sprintf("No common ancestor of block %d found between stored blocks and rpc servers", b.Index)
+		log.Error.Println(msg)
+		if !chainBreakAlerted {
+			chainBreakAlerted = true
+			mail.SendNotify("Chain Break Unresolved", msg)
+		}
+
+		waitChainBreak()
+		return height
+	}
+
+	rollbackChain(ancestor)
+	return ancestor
+}
+
+// waitChainBreak sleeps before the block is fetched again, the delay doubles until blocks link again.
+func waitChainBreak() {
+	log.Printf("Retrying in %v\n", chainBreakDelay)
+	time.Sleep(chainBreakDelay)
+
+	chainBreakDelay *= 2
+	if chainBreakDelay > maxChainBreakDelay {
+		chainBreakDelay = maxChainBreakDelay
+	}
+}
+
+// chainLinked resets the delay and alert of chain breaks, it is called when blocks are stored.
+func chainLinked() {
+	chainBreakDelay = minChainBreakDelay
+	chainBreakAlerted = false
+}
+
+// findCommonAncestor returns the highest stored block index
+// whose hash equals the one on rpc server.
+func findCommonAncestor(height int) int {
+	for ; height >= 0; height-- {
+		if remoteBlockHash(height) == storedBlockHash(height) {
+			return height
+		}
+	}
+
+	return -1
+}
+
+func rollback(height int) {
+	chainLock.Lock()
+	defer chainLock.Unlock()
+
+	dbHeight := db.GetLastHeight()
+	log.Printf("Chain reorganization detected, rolling back blocks from %d to %d\n", dbHeight, height)
+
+	// Items fetched before the rollback are at or below the current highest positions.
+	for name, highest := range taskManager.highests() {
+		if highest > removedHorizon[name] {
+			removedHorizon[name] = highest
+		}
+	}
+
+	removed, err := db.RollbackBlocks(height)
+	if err != nil {
+		panic(err)
+	}
+
+	for _, t := range removed {
+		removedTxPKs[t.ID] = true
+		removedTxIDs[t.TxID] = true
+	}
+
+	// Balances and total supplies in cache may be changed by the rollback.
+	cache.LoadAddrAssetInfo(db.GetAddrAssetInfo())
+	cache.ResetAssetTotalSupply(uint(height))
+	rollbacks.Add(1)
+
+	// Highest positions of tasks are changed.
+	taskManager.refreshHighest()
+	storedHeight.Set(height)
+
+	msg := fmt.Sprintf("Rolled back blocks from %d to %d, %d transactions removed", dbHeight, height, len(removed))
+	log.Println(msg)
+	mail.SendNotify("Chain Reorganization Detected", msg)
+}
+
+// pruneRemovedTxs forgets rolled back transactions once every task has passed its highest position
+// before the last rollback, items fetched afterwards never refer to them. It is called when blocks are stored.
+func pruneRemovedTxs() {
+	if len(removedHorizon) == 0 {
+		return
+	}
+
+	for _, status := range taskManager.statuses() {
+		if status.Cursor < removedHorizon[status.Name] {
+			return
+		}
+	}
+
+	chainLock.Lock()
+	defer chainLock.Unlock()
+
+	log.Printf("Forgetting %d rolled back transactions\n", len(removedTxPKs))
+	removedTxPKs = make(map[uint]bool)
+	removedTxIDs = make(map[string]bool)
+	removedHorizon = make(map[string]uint)
+}
+
+// resync drops all pending blocks and restarts block fetching from the given height.
+func resync(height int) {
+	blockBuffer.Reset(height)
+
+	// Replace the pending notification if there is any.
+	select {
+	case <-resyncChan:
+	default:
+	}
+	resyncChan <- height
+}
@@ -0,0 +1,123 @@
+package tasks
+
+import (
+	"fmt"
+	"os"
+	"squirrel/log"
+	"squirrel/rpc"
+	"testing"
+	"time"
+)
+
+// stubChain replaces block hash lookups and the rollback until restore is called,
+// blocks from fork on rpc servers differ from the stored ones.
+func stubChain(fork int) (rolledBack *[]int, restore func()) {
+	stored, remote, rollback := storedBlockHash, remoteBlockHash, rollbackChain
+	restore = func() {
+		storedBlockHash, remoteBlockHash, rollbackChain = stored, remote, rollback
+		chainLinked()
+	}
+
+	storedBlockHash = func(index int) string {
+		return fmt.Sprintf("stored%d", index)
+	}
+	remoteBlockHash = func(index int) string {
+		if index >= fork {
+			return fmt.Sprintf("remote%d", index)
+		}
+		return fmt.Sprintf("stored%d", index)
+	}
+
+	rolledBack = &[]int{}
+	rollbackChain = func(height int) {
+		*rolledBack = append(*rolledBack, height)
+	}
+	chainBreakDelay = time.Millisecond
+
+	return rolledBack, restore
+}
+
+func TestGetLinkedBlocks(t *testing.T) {
+	_, restore := stubChain(100)
+	defer restore()
+
+	block := func(index uint, prevHash string) *rpc.RawBlock {
+		return &rpc.RawBlock{Index: index, Hash: fmt.Sprintf("new%d", index), PreviousBlockHash: prevHash}
+	}
+
+	cases := []struct {
+		blocks   []*rpc.RawBlock
+		expected int
+	}{
+		{[]*rpc.RawBlock{block(0, ""), block(1, "new0")}, 2},
+		{[]*rpc.RawBlock{block(10, "stored9"), block(11, "new10"), block(12, "new11")}, 3},
+		{[]*rpc.RawBlock{block(10, "stored9"), block(11, "new10"), block(12, "other11")}, 2},
+		{[]*rpc.RawBlock{block(10, "remote9"), block(11, "new10")}, 0},
+	}
+	for i, c := range cases {
+		if linked := getLinkedBlocks(c.blocks); linked != c.expected {
+			t.Errorf("case %d: getLinkedBlocks = %d, expected %d", i, linked, c.expected)
+		}
+	}
+}
+
+func TestHandleChainBreak(t *testing.T) {
+	log.Init()
+	defer os.Remove("error.log")
+
+	b := &rpc.RawBlock{Index: 10, Hash: "remote10", PreviousBlockHash: "remote9"}
+
+	// The stored chain is canonical.
+	rolledBack, restore := stubChain(10)
+	if height := handleChainBreak(b); height != 9 || len(*rolledBack) != 0 {
+		t.Fatalf("handleChainBreak on stale fork = %d, rolled back to %v", height, *rolledBack)
+	}
+
+	// Blocks from 7 diverged.
+	restore()
+	rolledBack, restore = stubChain(7)
+	if height := handleChainBreak(b); height != 6 || len(*rolledBack) != 1 || (*rolledBack)[0] != 6 {
+		t.Fatalf("handleChainBreak on reorganization = %d, rolled back to %v", height, *rolledBack)
+	}
+
+	// No common ancestor, the block is retried without rolling back and alerted once.
+	restore()
+	rolledBack, restore = stubChain(0)
+	defer restore()
+	for i := 0; i < 2; i++ {
+		if height := handleChainBreak(b); height != 9 || len(*rolledBack) != 0 {
+			t.Fatalf("handleChainBreak without ancestor = %d, rolled back to %v", height, *rolledBack)
+		}
+	}
+	if !chainBreakAlerted || chainBreakDelay != 4*time.Millisecond {
+		t.Fatalf("chain break is not throttled, alerted: %v, delay: %v", chainBreakAlerted, chainBreakDelay)
+	}
+
+	chainLinked()
+	if chainBreakAlerted || chainBreakDelay != minChainBreakDelay {
+		t.Fatal("chainLinked does not reset the throttling")
+	}
+}
+
+func TestPruneRemovedTxs(t *testing.T) {
+	defer func(m *manager) { taskManager = m }(taskManager)
+	taskManager = &manager{}
+	taskManager.add(&idleTask{top: 100})
+
+	removedTxPKs[1] = true
+	removedTxIDs["0x01"] = true
+	removedHorizon["counter"] = 100
+
+	mt := taskManager.tasks[0]
+	mt.setCursor(99)
+	pruneRemovedTxs()
+	if !txRemoved(1) || !txIDRemoved("0x01") {
+		t.Fatal("removed transactions are forgotten before the task passed them")
+	}
+
+	mt.setCursor(100)
+	pruneRemovedTxs()
+	if txRemoved(1) || txIDRemoved("0x01") || len(removedHorizon) != 0 {
+		t.Fatal("removed transactions are kept after the task passed them")
+	}
+}
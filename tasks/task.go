@@ -0,0 +1,21 @@
+package tasks
+
+import "context"
+
+// Task is an indexing pipeline which consumes stored rows in pk order.
+// Fetch runs ahead of Apply in another goroutine, the two must not share unguarded state.
+type Task interface {
+	// Name identifies the task in logs and status reports.
+	Name() string
+	// Cursor loads the position persisted in counter, fetching continues after it.
+	// It is called before every (re)start of the task.
+	Cursor() uint
+	// Fetch returns the next batch after cursor and the position the batch ends at,
+	// the position equals cursor if there is nothing new or the batch can not be fetched now.
+	// ctx is done when the task is stopping.
+	Fetch(ctx context.Context, cursor uint) (batch interface{}, next uint)
+	// Apply persists the batch, the position it ends at is committed to counter with the data.
+	Apply(batch interface{})
+	// Highest returns the highest position currently available, 0 if unknown.
+	Highest() uint
+}
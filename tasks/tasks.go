@@ -1,16 +1,23 @@
 package tasks
 
 import (
+	"context"
 	"squirrel/buffer"
 	"squirrel/cache"
 	"squirrel/config"
 	"squirrel/db"
 	"squirrel/log"
 	"squirrel/rpc"
+	"sync"
+	"time"
 )
 
-// Run starts several goroutines for block storage, tx/nep5 tx storage, etc.
-func Run() {
+// running tracks goroutines which persist data, they must finish before the process exits.
+var running sync.WaitGroup
+
+// Run starts block storage and the indexing tasks managed by taskManager.
+// When ctx is done, fetchers stop and the persisting goroutines drain their queues.
+func Run(ctx context.Context) {
 	log.Printf("Init addr asset cache.")
 
 	// Init cache to speed up db queries
@@ -18,23 +25,51 @@ func Run() {
 	cache.LoadAddrAssetInfo(addrAssetInfo)
 
 	dbHeight := db.GetLastHeight()
+	storedHeight.Set(dbHeight)
 	initTask(dbHeight)
 
 	for i := 0; i < config.GetGoroutines(); i++ {
-		go fetchBlock()
+		go fetchBlock(ctx)
 	}
 
 	blockChannel = make(chan *rpc.RawBlock, bufferSize)
-	go arrangeBlock(dbHeight, blockChannel)
-	go storeBlock(blockChannel)
+	spawn(func() { arrangeBlock(ctx, dbHeight, blockChannel) })
+	spawn(func() { storeBlock(dbHeight, blockChannel) })
+
+	taskManager.add(&nep5Task{})
+	taskManager.add(txTask{})
+	taskManager.add(nep5AddrTxTask{})
+	taskManager.add(assetTxTask{})
+	taskManager.add(balanceTask{})
+	taskManager.add(blockFeeTask{})
+	taskManager.add(utxoGasTask{})
+	taskManager.start(ctx)
+
+	go rpc.TraceBestHeight(ctx)
+}
 
-	go startNep5Task()
-	go startTxTask()
-	go startUpdateCounterTask()
-	go startAssetTxTask()
-	go startGasBalanceTask()
+// Wait blocks until all tasks stopped after the context passed to Run is done.
+func Wait() {
+	running.Wait()
+}
+
+// spawn runs f in a goroutine tracked by running.
+func spawn(f func()) {
+	running.Add(1)
+	go func() {
+		defer running.Done()
+		f()
+	}()
+}
 
-	go rpc.TraceBestHeight()
+// sleep pauses for d, it returns false if ctx is done before that.
+func sleep(ctx context.Context, d time.Duration) bool {
+	select {
+	case <-ctx.Done():
+		return false
+	case <-time.After(d):
+		return true
+	}
 }
 
 func initTask(dbHeight int) {
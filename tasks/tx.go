@@ -23,22 +23,9 @@ UPDATE `address` SET `trans_asset` = 0;
 package tasks
 
 import (
-	"fmt"
-	"math/big"
+	"context"
 	"squirrel/db"
-	"squirrel/log"
-	"squirrel/mail"
 	"squirrel/tx"
-	"time"
-)
-
-const txChanSize = 5000
-
-var (
-	// TxMaxPkShouldRefresh indicates if highest tx pk should be refreshed.
-	TxMaxPkShouldRefresh bool
-	tProgress            = Progress{}
-	maxTxPK              uint
 )
 
 type txInfo struct {
@@ -47,139 +34,66 @@ type txInfo struct {
 	vouts []*tx.TransactionVout
 }
 
-func startTxTask() {
-	txChan := make(chan txInfo, txChanSize)
-	nextPK := db.GetLastTxPkCounter() + 1
+// txTask applies inputs and outputs of transactions to utxos and address balances.
+type txTask struct{}
+
+func (txTask) Name() string {
+	return "tx"
+}
+
+func (txTask) Cursor() uint {
+	return db.GetLastTxPkCounter()
+}
 
-	go fetchTx(txChan, nextPK)
-	go handleTx(txChan)
+func (txTask) Fetch(_ context.Context, cursor uint) (interface{}, uint) {
+	return fetchTxInfos(cursor, 500)
 }
 
-func fetchTx(txChan chan<- txInfo, nextPK uint) {
-	defer mail.AlertIfErr()
-
-	for {
-		txs := db.GetTxs(nextPK, 500, "")
-		if len(txs) == 0 {
-			time.Sleep(2 * time.Second)
-			continue
-		}
-
-		nextPK = txs[len(txs)-1].ID + 1
-		txIDs := []string{}
-
-		for _, tx := range txs {
-			txIDs = append(txIDs, tx.TxID)
-		}
-
-		vinMap, voutMap, err := db.GetVinVout(txIDs)
-		if err != nil {
-			panic(err)
-		}
-
-		for _, tx := range txs {
-			txChan <- txInfo{
-				tx:    tx,
-				vins:  vinMap[tx.TxID],
-				vouts: voutMap[tx.TxID],
+func (txTask) Apply(batch interface{}) {
+	for _, info := range batch.([]txInfo) {
+		withChainLock(func() {
+			if txRemoved(info.tx.ID) {
+				return
+			}
+
+			err := db.ApplyVinsVouts(info.tx, info.vins, info.vouts)
+			if err != nil {
+				panic(err)
 			}
-		}
+		})
 	}
 }
 
-func handleTx(txChan <-chan txInfo) {
-	defer mail.AlertIfErr()
-
-	// txs := []*tx.Transaction{}
-	// vins := make(map[string][]*tx.TransactionVin)
-	// vouts := make(map[string][]*tx.TransactionVout)
-
-	// for {
-	// 	select {
-	// 	case txInfo := <-txChan:
-	// 		txs = append(txs, txInfo.tx)
-	// 		vins[txInfo.tx.TxID] = txInfo.vins
-	// 		vouts[txInfo.tx.TxID] = txInfo.vouts
-
-	// 		if len(txs) >= 30 {
-	// 			err := db.ApplyVinsVouts(txs, vins, vouts)
-	// 			if err != nil {
-	// 				panic(err)
-	// 			}
-
-	// 			showTxProgress(txs[len(txs)-1].ID)
-	// 			txs = []*tx.Transaction{}
-	// 			vins = make(map[string][]*tx.TransactionVin)
-	// 			vouts = make(map[string][]*tx.TransactionVout)
-	// 		}
-
-	// 	case <-time.After(time.Second):
-	// 		if len(txs) > 0 {
-	// 			err := db.ApplyVinsVouts(txs, vins, vouts)
-	// 			if err != nil {
-	// 				panic(err)
-	// 			}
-
-	// 			showTxProgress(txs[len(txs)-1].ID)
-	// 			txs = []*tx.Transaction{}
-	// 			vins = make(map[string][]*tx.TransactionVin)
-	// 			vouts = make(map[string][]*tx.TransactionVout)
-	// 		}
-	// 	}
-	// }
-
-	for txInfo := range txChan {
-		tx := txInfo.tx
-		vins := txInfo.vins
-		vouts := txInfo.vouts
-
-		err := db.ApplyVinsVouts(tx, vins, vouts)
-		if err != nil {
-			panic(err)
-		}
-
-		showTxProgress(tx.ID)
-	}
+func (txTask) Highest() uint {
+	return db.GetHighestTxPk()
 }
 
-func showTxProgress(currentTxPk uint) {
-	if maxTxPK == 0 || TxMaxPkShouldRefresh {
-		TxMaxPkShouldRefresh = false
-		maxTxPK = db.GetHighestTxPk()
+// fetchTxInfos returns at most limit transactions with inputs or outputs after cursor,
+// together with the pk of the last one.
+func fetchTxInfos(cursor uint, limit int) ([]txInfo, uint) {
+	txs := db.GetTxs(cursor+1, limit, "")
+	if len(txs) == 0 {
+		return nil, cursor
 	}
 
-	now := time.Now()
-	if tProgress.LastOutputTime == (time.Time{}) {
-		tProgress.LastOutputTime = now
-	}
-	if currentTxPk < maxTxPK && now.Sub(tProgress.LastOutputTime) < time.Second {
-		return
+	txIDs := []string{}
+	for _, tx := range txs {
+		txIDs = append(txIDs, tx.TxID)
 	}
 
-	GetEstimatedRemainingTime(int64(currentTxPk), int64(maxTxPK), &tProgress)
-	if tProgress.Percentage.Cmp(big.NewFloat(100)) == 0 &&
-		bProgress.Finished {
-		tProgress.Finished = true
+	vinMap, voutMap, err := db.GetVinVout(txIDs)
+	if err != nil {
+		panic(err)
 	}
 
-	log.Printf("%sProgress of transactions: %d/%d, %.4f%%\n",
-		tProgress.RemainingTimeStr,
-		currentTxPk,
-		maxTxPK,
-		tProgress.Percentage)
-
-	tProgress.LastOutputTime = now
-
-	// Send mail if fully synced.
-	if tProgress.Finished && !tProgress.MailSent {
-		tProgress.MailSent = true
-
-		// If sync lasts shortly, do not send mail.
-		if time.Since(tProgress.InitTime) < time.Minute*5 {
-			return
-		}
-
-		msg := fmt.Sprintf("Init time: %v\nEnd Time: %v\n", nProgress.InitTime, time.Now())
-		mail.SendNotify("Transactions Fully Synced", msg)
+	infos := make([]txInfo, 0, len(txs))
+	for _, tx := range txs {
+		infos = append(infos, txInfo{
+			tx:    tx,
+			vins:  vinMap[tx.TxID],
+			vouts: voutMap[tx.TxID],
+		})
 	}
+
+	return infos, txs[len(txs)-1].ID
 }
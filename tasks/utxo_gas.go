@@ -0,0 +1,79 @@
+/*
+GAS bonus of NEO outputs is recorded when they are stored and spent,
+this task fills start_gas and claim_gas of outputs stored before schema version 5.
+Until then GetUnclaimedGas calculates them one by one.
+
+To restart this task from beginning, execute the following sqls:
+
+UPDATE `utxo` SET `start_gas` = NULL, `claim_gas` = NULL WHERE `id` <= (SELECT `utxo_gas_backfill_end` FROM `counter` WHERE `id` = 1);
+UPDATE `counter` SET `utxo_gas_backfill_pk` = 0 WHERE `id` = 1;
+
+*/
+
+package tasks
+
+import (
+	"context"
+	"squirrel/db"
+	"squirrel/tx"
+)
+
+// utxoGasBatchSize is the number of outputs whose GAS bonus is backfilled in one batch.
+const utxoGasBatchSize = 1000
+
+type utxoGasBatch struct {
+	utxos []*tx.UTXO
+	next  uint
+}
+
+// utxoGasTask backfills GAS bonus of NEO outputs stored before it was recorded.
+type utxoGasTask struct{}
+
+func (utxoGasTask) Name() string {
+	return "utxo_gas"
+}
+
+func (utxoGasTask) Cursor() uint {
+	pk, _ := db.GetUTXOGasBackfillPk()
+	return pk
+}
+
+func (utxoGasTask) Fetch(_ context.Context, cursor uint) (interface{}, uint) {
+	_, end := db.GetUTXOGasBackfillPk()
+	if end <= cursor {
+		return nil, cursor
+	}
+
+	// Shares of accumulated GAS are read from block fees, which are backfilled first.
+	if db.GetBlockFeeBackfillIndex() < blockFeeBackfillEnd() {
+		return nil, cursor
+	}
+
+	utxos, err := db.GetUTXOsWithoutGas(cursor, end, utxoGasBatchSize)
+	if err != nil {
+		panic(err)
+	}
+
+	next := end
+	if len(utxos) == utxoGasBatchSize {
+		next = utxos[len(utxos)-1].ID
+	}
+
+	return utxoGasBatch{utxos: utxos, next: next}, next
+}
+
+func (utxoGasTask) Apply(batch interface{}) {
+	b := batch.(utxoGasBatch)
+
+	// Blocks are not rolled back while applying, outputs spent since fetching are handled by BackfillUTXOGas.
+	withChainLock(func() {
+		if err := db.BackfillUTXOGas(b.utxos, b.next); err != nil {
+			panic(err)
+		}
+	})
+}
+
+func (utxoGasTask) Highest() uint {
+	_, end := db.GetUTXOGasBackfillPk()
+	return end
+}
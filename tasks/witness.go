@@ -0,0 +1,58 @@
+package tasks
+
+import (
+	"encoding/hex"
+	"fmt"
+	"squirrel/log"
+	"squirrel/payload"
+	"squirrel/rpc"
+	"squirrel/witness"
+)
+
+// checkWitnesses verifies signatures of standard contract witnesses in the block,
+// transactions whose witnesses do not verify are flagged with the reason.
+func checkWitnesses(b *rpc.RawBlock) {
+	for i := range b.Tx {
+		trans := &b.Tx[i]
+
+		reason := verifyWitnesses(trans)
+		if reason == "" {
+			continue
+		}
+
+		trans.InvalidWitness = reason
+		invalidWitnesses.Inc()
+		log.Printf("Transaction %s in block %d has invalid witness: %s\n", trans.TxID, b.Index, reason)
+	}
+}
+
+// verifyWitnesses returns the reason why a witness of the transaction does not verify, empty if all verified.
+// Witnesses of custom contracts and transactions which can not be serialized from verbose blocks are skipped.
+func verifyWitnesses(trans *rpc.RawTx) string {
+	data, err := payload.SignedData(trans)
+	if err == payload.ErrNotEncodable {
+		return ""
+	}
+	if err != nil {
+		return err.Error()
+	}
+
+	for i, script := range trans.Scripts {
+		invocation, err := hex.DecodeString(script.Invocation)
+		if err != nil {
+			return fmt.Sprintf("witness %d: %v", i, err)
+		}
+
+		verification, err := hex.DecodeString(script.Verification)
+		if err != nil {
+			return fmt.Sprintf("witness %d: %v", i, err)
+		}
+
+		err = witness.Verify(data, invocation, verification)
+		if err != nil && err != witness.ErrUnsupported {
+			return fmt.Sprintf("witness %d: %v", i, err)
+		}
+	}
+
+	return ""
+}
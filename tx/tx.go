@@ -1,12 +1,14 @@
 package tx
 
 import (
+	"encoding/hex"
 	"fmt"
-	"math/big"
+	"squirrel/addr"
+	"squirrel/amount"
 	"squirrel/asset"
 	"squirrel/rpc"
 	"squirrel/smartcontract"
-	"strings"
+	"squirrel/util"
 )
 
 const (
@@ -37,6 +39,12 @@ type Bulk struct {
 	TXScripts []*TransactionScripts
 	Assets    []*asset.Asset
 	Claims    []*TransactionClaims
+
+	InvalidWitnesses []*InvalidWitness
+	// AddrContracts are verification contracts of addresses, once per address.
+	AddrContracts []*addr.Contract
+	// BlockFees are fees of each block, in block order.
+	BlockFees []*BlockFee
 }
 
 // Transaction db model.
@@ -51,12 +59,12 @@ type Transaction struct {
 	// Attribute List
 	// Vin List
 	// Vout List
-	SysFee *big.Float
-	NetFee *big.Float
+	SysFee amount.Amount
+	NetFee amount.Amount
 	// Scripts
 	Nonce  int64
 	Script string
-	Gas    *big.Float
+	Gas    amount.Amount
 }
 
 // TransactionAttribute of transactions.
@@ -81,7 +89,7 @@ type TransactionVout struct {
 	TxID    string
 	N       uint16
 	AssetID string
-	Value   *big.Float
+	Value   amount.Amount
 	Address string
 }
 
@@ -96,10 +104,68 @@ type TransactionScripts struct {
 // TransactionClaims of transaction.
 type TransactionClaims struct {
 	ID   uint
+	From string
 	TxID string
 	Vout uint16
 }
 
+// InvalidWitness records a transaction whose witness does not verify.
+type InvalidWitness struct {
+	TxID       string
+	BlockIndex uint
+	Reason     string
+}
+
+// BlockFee sums fees of transactions in a block.
+type BlockFee struct {
+	BlockIndex uint
+	SysFee     amount.Amount
+	NetFee     amount.Amount
+	// CumulativeSysFee is the system fee of all blocks till this one, it is filled when stored.
+	CumulativeSysFee amount.Amount
+}
+
+// BalanceChanges returns changes of address balances by asset made by a transaction,
+// spent are the outputs referenced by its inputs.
+func BalanceChanges(spent []*TransactionVout, vouts []*TransactionVout) map[string]map[string]amount.Amount {
+	changes := make(map[string]map[string]amount.Amount)
+
+	add := func(vout *TransactionVout, value amount.Amount) {
+		if _, ok := changes[vout.Address]; !ok {
+			changes[vout.Address] = make(map[string]amount.Amount)
+		}
+
+		if change, ok := changes[vout.Address][vout.AssetID]; ok {
+			value = change.Add(value)
+		}
+		changes[vout.Address][vout.AssetID] = value
+	}
+
+	for _, vout := range spent {
+		add(vout, vout.Value.Neg())
+	}
+	for _, vout := range vouts {
+		add(vout, vout.Value)
+	}
+
+	return changes
+}
+
+// UTXO db model.
+type UTXO struct {
+	ID       uint
+	Address  string
+	TxID     string
+	N        uint16
+	AssetID  string
+	Value    amount.Amount
+	UsedInTx string
+	// BlockIndex is the height of the block containing the output.
+	BlockIndex uint
+	// SpentBlockIndex is the height of the block spending the output, 0 if unspent.
+	SpentBlockIndex uint
+}
+
 // AddrAssetIDTx is the bundle of address, asset_id and txid.
 type AddrAssetIDTx struct {
 	Address string
@@ -134,9 +200,19 @@ func typeName(txType int) string {
 // ParseTxs parses all raw transactions in raw blocks to Bulk.
 func ParseTxs(rawBlocks []*rpc.RawBlock) *Bulk {
 	txs := Bulk{}
+	contracts := make(map[string]bool)
 
 	for _, rawBlock := range rawBlocks {
+		fee := BlockFee{
+			BlockIndex: rawBlock.Index,
+			SysFee:     amount.Zero,
+			NetFee:     amount.Zero,
+		}
+
 		for _, rawTx := range rawBlock.Tx {
+			fee.SysFee = fee.SysFee.Add(rawTx.SysFee)
+			fee.NetFee = fee.NetFee.Add(rawTx.NetFee)
+
 			txs.TXs = appendTx(txs.TXs, rawBlock.Index, rawBlock.Time, &rawTx)
 			txs.TXAttrs = appendTxAttrs(txs.TXAttrs, &rawTx)
 			txs.TXVins = appendTxVin(txs.TXVins, &rawTx)
@@ -144,7 +220,18 @@ func ParseTxs(rawBlocks []*rpc.RawBlock) *Bulk {
 			txs.TXScripts = appendTxScripts(txs.TXScripts, &rawTx)
 			txs.Assets = appendAsset(rawBlock, txs.Assets, &rawTx)
 			txs.Claims = appendClaims(txs.Claims, &rawTx)
+			txs.AddrContracts = appendAddrContracts(txs.AddrContracts, contracts, rawBlock.Index, &rawTx)
+
+			if rawTx.InvalidWitness != "" {
+				txs.InvalidWitnesses = append(txs.InvalidWitnesses, &InvalidWitness{
+					TxID:       rawTx.TxID,
+					BlockIndex: rawBlock.Index,
+					Reason:     rawTx.InvalidWitness,
+				})
+			}
 		}
+
+		txs.BlockFees = append(txs.BlockFees, &fee)
 	}
 
 	return &txs
@@ -164,9 +251,6 @@ func appendTx(txs []*Transaction, blockIndex uint, blockTime uint64, rawTx *rpc.
 		Script:     rawTx.Script,
 		Gas:        rawTx.Gas,
 	}
-	if rawTx.Gas == nil {
-		trans.Gas = big.NewFloat(0)
-	}
 	txs = append(txs, &trans)
 
 	return txs
@@ -228,17 +312,15 @@ func appendAsset(rawBlock *rpc.RawBlock, assets []*asset.Asset, rawTx *rpc.RawTx
 		asset = parseAssetFromRegisterTransaction(rawBlock.Index, rawTx)
 	} else if rawTx.Type == typeName(InvocationTransaction) {
 		// Example: 0x4a629db0af0d9c7ee0e11f4f4894765f5ab2579bcc8b4a203e4c6814a9784f00(testnet).
-		if strings.HasSuffix(rawTx.Script, smartcontract.AssetFingerPrint) {
-			asset = parseAssetFromInvocationTransaction(rawTx.Script)
-			if asset == nil {
-				return assets
-			}
-
-			// Supplement the rest fields.
-			asset.Version = 0
-			asset.AssetID = rawTx.TxID
-			asset.Expiration = uint64(rawBlock.Index + 2000000)
+		asset = parseAssetFromInvocationTransaction(rawTx.Script)
+		if asset == nil {
+			return assets
 		}
+
+		// Supplement the rest fields.
+		asset.Version = 0
+		asset.AssetID = rawTx.TxID
+		asset.Expiration = uint64(rawBlock.Index + 2000000)
 	}
 
 	if asset == nil {
@@ -254,9 +336,44 @@ func appendAsset(rawBlock *rpc.RawBlock, assets []*asset.Asset, rawTx *rpc.RawTx
 	return assets
 }
 
+func appendAddrContracts(addrContracts []*addr.Contract, seen map[string]bool, blockIndex uint, rawTx *rpc.RawTx) []*addr.Contract {
+	for _, rawScript := range rawTx.Scripts {
+		script, err := hex.DecodeString(rawScript.Verification)
+		// Witnesses of deployed contracts have empty verification scripts.
+		if err != nil || len(script) == 0 {
+			continue
+		}
+
+		address := util.GetAddressFromScriptHash(util.GetScriptHash(script))
+		if seen[address] {
+			continue
+		}
+		seen[address] = true
+
+		contract := smartcontract.ClassifyVerification(script)
+		publicKeys := []string{}
+		for _, key := range contract.PublicKeys {
+			publicKeys = append(publicKeys, hex.EncodeToString(key))
+		}
+
+		addrContracts = append(addrContracts, &addr.Contract{
+			Address:    address,
+			Type:       contract.Type,
+			Threshold:  contract.Threshold,
+			PublicKeys: publicKeys,
+			Script:     rawScript.Verification,
+			TxID:       rawTx.TxID,
+			BlockIndex: blockIndex,
+		})
+	}
+
+	return addrContracts
+}
+
 func appendClaims(claims []*TransactionClaims, rawTx *rpc.RawTx) []*TransactionClaims {
 	for _, rawClaim := range rawTx.Claims {
 		claim := TransactionClaims{
+			From: rawTx.TxID,
 			TxID: rawClaim.TxID,
 			Vout: rawClaim.Vout,
 		}
@@ -272,7 +389,7 @@ func parseAssetFromRegisterTransaction(blockIndex uint, rawTx *rpc.RawTx) *asset
 		Type:       rawTx.Asset.Type,
 		Name:       rawTx.Asset.Name[0].Name,
 		Amount:     rawTx.Asset.Amount,
-		Available:  big.NewFloat(0),
+		Available:  amount.Zero,
 		Precision:  rawTx.Asset.Precision,
 		Owner:      rawTx.Asset.Owner,
 		Admin:      rawTx.Asset.Admin,
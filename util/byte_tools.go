@@ -42,16 +42,6 @@ func HexToBigInt(hexStr string) *big.Int {
 	return z
 }
 
-// HexToBigFloat returns big.Float of given hex string.
-func HexToBigFloat(hexStr string) *big.Float {
-	if len(hexStr) == 0 {
-		return big.NewFloat(0)
-	}
-
-	bytes, _ := hex.DecodeString(hexStr)
-	return BytesToBigFloat(bytes)
-}
-
 func padString(str string) string {
 	strLen := len(str)
 	if strLen >= 16 {
@@ -63,14 +53,9 @@ func padString(str string) string {
 	return "0" + str + strings.Repeat("0", 16-strLen-1)
 }
 
-// BytesToBigFloat returns big.Float of given data bytes.
-func BytesToBigFloat(data []byte) *big.Float {
-	data = ReverseBytes(data)
-	val := new(big.Float).SetInt(new(big.Int).SetBytes(data))
-	if val.Sign() == -1 {
-		return BytesToBigFloat(append(data, 0x00))
-	}
-	return val
+// BytesToBigInt returns big.Int of given little-endian data bytes.
+func BytesToBigInt(data []byte) *big.Int {
+	return new(big.Int).SetBytes(ReverseBytes(data))
 }
 
 // ReverseBytes reverses the given bytes.
@@ -81,16 +66,3 @@ func ReverseBytes(raw []byte) []byte {
 	}
 	return reversed
 }
-
-// StrToBigFloat returns big.Float of the given integer string.
-func StrToBigFloat(str string) *big.Float {
-	if len(str) == 0 {
-		return big.NewFloat(0)
-	}
-
-	val, _, err := big.ParseFloat(str, 10, 256, big.ToZero)
-	if err != nil {
-		panic(err)
-	}
-	return val
-}
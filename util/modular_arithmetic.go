@@ -37,18 +37,8 @@ func (m ModularArithmetic) Inverse(x *big.Int, p *big.Int) *big.Int {
 
 // Mul computes: (x * y) % p.
 func (m ModularArithmetic) Mul(x *big.Int, y *big.Int, p *big.Int) *big.Int {
-	var z *big.Int
-
-	n := new(big.Int).Set(x)
-	z = big.NewInt(0)
-
-	for i := 0; i < y.BitLen(); i++ {
-		if y.Bit(i) == 1 {
-			z = m.Add(z, n, p)
-		}
-		n = m.Add(n, n, p)
-	}
-
+	z := new(big.Int).Mul(x, y)
+	z.Mod(z, p)
 	return z
 }
 
@@ -0,0 +1,99 @@
+package util
+
+import (
+	"errors"
+	"math/big"
+)
+
+// NewSecp256r1 returns the secp256r1 curve used by NEO signatures.
+func NewSecp256r1() EllipticCurve {
+	e := NewEllipticCurve()
+
+	e.P, _ = new(big.Int).SetString("ffffffff00000001000000000000000000000000ffffffffffffffffffffffff", 16)
+	e.A = new(big.Int).Sub(e.P, big.NewInt(3))
+	e.B, _ = new(big.Int).SetString("5ac635d8aa3a93e7b3ebbd55769886bc651d06b0cc53b0f63bce3c3e27d2604b", 16)
+	e.N, _ = new(big.Int).SetString("ffffffff00000000ffffffffffffffffbce6faada7179e84f3b9cac2fc632551", 16)
+	e.H = big.NewInt(1)
+
+	gx, _ := new(big.Int).SetString("6b17d1f2e12c4247f8bce6e563a440f277037d812deb33a0f4a13945d898c296", 16)
+	gy, _ := new(big.Int).SetString("4fe342e2fe1a7f9b8ee7eb4a7c0f9e162bce33576b315ececbb6406837bf51f5", 16)
+	e.G = EllipticCurvePoint{X: gx, Y: gy}
+
+	return e
+}
+
+// DecodePoint decodes a compressed or uncompressed public key on EllipticCurve ec.
+func (e *EllipticCurve) DecodePoint(data []byte) (*EllipticCurvePoint, error) {
+	size := (e.P.BitLen() + 7) / 8
+
+	var point EllipticCurvePoint
+	switch {
+	case len(data) == size+1 && (data[0] == 0x02 || data[0] == 0x03):
+		point.X = new(big.Int).SetBytes(data[1:])
+
+		// y^2 = x^3 + ax + b.
+		rhs := e.ma.Add(
+			e.ma.Add(
+				e.ma.Exp(point.X, big.NewInt(3), e.P),
+				e.ma.Mul(e.A, point.X, e.P),
+				e.P,
+			),
+			e.B,
+			e.P,
+		)
+
+		y, err := e.ma.Sqrt(rhs, e.P)
+		if err != nil {
+			return nil, err
+		}
+
+		if y.Bit(0) != uint(data[0]&1) {
+			y = e.ma.Sub(e.P, y, e.P)
+		}
+		point.Y = y
+	case len(data) == 2*size+1 && data[0] == 0x04:
+		point.X = new(big.Int).SetBytes(data[1 : size+1])
+		point.Y = new(big.Int).SetBytes(data[size+1:])
+	default:
+		return nil, errors.New("invalid public key encoding")
+	}
+
+	if point.X.Cmp(e.P) >= 0 || !e.IsOnCurve(point) {
+		return nil, errors.New("public key is not on the curve")
+	}
+
+	return &point, nil
+}
+
+// VerifyECDSA verifies the ECDSA signature (r, s) of the message digest by public key Q on EllipticCurve ec.
+func (e *EllipticCurve) VerifyECDSA(Q EllipticCurvePoint, digest []byte, r, s *big.Int) bool {
+	if r.Sign() <= 0 || s.Sign() <= 0 || r.Cmp(e.N) >= 0 || s.Cmp(e.N) >= 0 {
+		return false
+	}
+
+	z := new(big.Int).SetBytes(digest)
+	if excess := len(digest)*8 - e.N.BitLen(); excess > 0 {
+		z.Rsh(z, uint(excess))
+	}
+
+	w := new(big.Int).ModInverse(s, e.N)
+	u1 := e.ma.Mul(z, w, e.N)
+	u2 := e.ma.Mul(r, w, e.N)
+
+	p1, err := e.ScalarBaseMult(u1)
+	if err != nil {
+		return false
+	}
+
+	p2, err := e.ScalarMult(u2, Q)
+	if err != nil {
+		return false
+	}
+
+	sum, err := e.Add(*p1, *p2)
+	if err != nil || e.isInfinity(*sum) {
+		return false
+	}
+
+	return new(big.Int).Mod(sum.X, e.N).Cmp(r) == 0
+}
@@ -0,0 +1,86 @@
+package util
+
+import (
+	"bytes"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"encoding/hex"
+	"math/big"
+	"testing"
+)
+
+func TestSecp256r1(t *testing.T) {
+	e := NewSecp256r1()
+
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	p, err := e.DecodePoint(compress(key.X, key.Y))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if p.X.Cmp(key.X) != 0 || p.Y.Cmp(key.Y) != 0 {
+		t.Fatalf("decompressed %s, expected (%x,%x)", p.Format(), key.X, key.Y)
+	}
+
+	digest := Sha256([]byte("squirrel"))
+	r, s, err := ecdsa.Sign(rand.Reader, key, digest)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if !e.VerifyECDSA(*p, digest, r, s) {
+		t.Fatal("valid signature is not verified")
+	}
+	if e.VerifyECDSA(*p, Sha256([]byte("squirre1")), r, s) {
+		t.Fatal("signature of another message is verified")
+	}
+	if e.VerifyECDSA(*p, digest, s, r) {
+		t.Fatal("swapped signature is verified")
+	}
+	if e.VerifyECDSA(*p, digest, r, new(big.Int).Add(s, e.N)) {
+		t.Fatal("signature out of range is verified")
+	}
+}
+
+func TestDecodePoint(t *testing.T) {
+	e := NewSecp256r1()
+
+	// A mainnet standby validator.
+	key, _ := hex.DecodeString("024c7b7fb6c310fccf1ba33b082519d82964ea93868d676662d4a59ad548df0e7d")
+	p, err := e.DecodePoint(key)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if !elliptic.P256().IsOnCurve(p.X, p.Y) || hex.EncodeToString(compress(p.X, p.Y)) != hex.EncodeToString(key) {
+		t.Fatalf("decompressed %s", p.Format())
+	}
+
+	uncompressed := elliptic.Marshal(elliptic.P256(), p.X, p.Y)
+	if q, err := e.DecodePoint(uncompressed); err != nil || q.Y.Cmp(p.Y) != 0 {
+		t.Fatalf("DecodePoint(uncompressed) returns (%v, %v)", q, err)
+	}
+
+	invalid := [][]byte{
+		key[:32],
+		append([]byte{0x02}, bytes.Repeat([]byte{0xff}, 32)...),
+		append([]byte{0x05}, key[1:]...),
+	}
+	for _, data := range invalid {
+		if _, err := e.DecodePoint(data); err == nil {
+			t.Errorf("invalid point %x is decoded", data)
+		}
+	}
+}
+
+func compress(x, y *big.Int) []byte {
+	data := make([]byte, 33)
+	data[0] = 0x02 | byte(y.Bit(0))
+	b := x.Bytes()
+	copy(data[33-len(b):], b)
+	return data
+}
@@ -0,0 +1,98 @@
+// Package witness verifies signatures of standard signature and multi-signature contracts.
+package witness
+
+import (
+	"errors"
+	"fmt"
+	"math/big"
+	"squirrel/smartcontract"
+	"squirrel/util"
+)
+
+const opPushBytes64 = 0x40
+
+// ErrUnsupported is returned for verification scripts which are not standard signature contracts,
+// they can only be verified by executing them.
+var ErrUnsupported = errors.New("verification script is not a standard signature contract")
+
+var curve = util.NewSecp256r1()
+
+// Verify checks that the invocation script of the witness carries valid signatures of signedData
+// for its verification script.
+func Verify(signedData []byte, invocation, verification []byte) error {
+	m, keys, err := parseVerification(verification)
+	if err != nil {
+		return err
+	}
+
+	sigs, err := parseInvocation(invocation)
+	if err != nil {
+		return err
+	}
+
+	if len(sigs) != m {
+		return fmt.Errorf("%d signatures for %d-of-%d contract", len(sigs), m, len(keys))
+	}
+
+	digest := util.Sha256(signedData)
+
+	// Signatures are in the order of public keys, like CHECKMULTISIG of NEO VM.
+	i, j := 0, 0
+	for i < len(sigs) && j < len(keys) {
+		ok, err := verifySignature(keys[j], digest, sigs[i])
+		if err != nil {
+			return err
+		}
+		if ok {
+			i++
+		}
+		j++
+
+		if len(sigs)-i > len(keys)-j {
+			break
+		}
+	}
+
+	if i < len(sigs) {
+		return fmt.Errorf("signature %d does not verify", i)
+	}
+
+	return nil
+}
+
+// parseVerification returns the number of required signatures and public keys of a standard contract.
+func parseVerification(script []byte) (int, [][]byte, error) {
+	contract := smartcontract.ClassifyVerification(script)
+	if !contract.IsStandard() {
+		return 0, nil, ErrUnsupported
+	}
+
+	return contract.Threshold, contract.PublicKeys, nil
+}
+
+// parseInvocation returns signatures pushed by the invocation script.
+func parseInvocation(script []byte) ([][]byte, error) {
+	sigs := [][]byte{}
+
+	for pos := 0; pos < len(script); pos += 65 {
+		if script[pos] != opPushBytes64 || pos+65 > len(script) {
+			return nil, errors.New("invocation script does not only push signatures")
+		}
+
+		sigs = append(sigs, script[pos+1:pos+65])
+	}
+
+	return sigs, nil
+}
+
+func verifySignature(key []byte, digest []byte, sig []byte) (bool, error) {
+	pub, err := curve.DecodePoint(key)
+	if err != nil {
+		return false, err
+	}
+
+	r := new(big.Int).SetBytes(sig[:32])
+	s := new(big.Int).SetBytes(sig[32:])
+
+	return curve.VerifyECDSA(*pub, digest, r, s), nil
+}
@@ -0,0 +1,234 @@
+package witness
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"encoding/hex"
+	"math/big"
+	"squirrel/smartcontract"
+	"squirrel/util"
+	"testing"
+)
+
+// Verification script of the standby validators of NEO2 mainnet.
+const validatorsScript = "552102486fd15702c4490a26703112a5cc1d0923fd697a33406bd5a1c00e0013b09a7021024c7b7fb6c310fccf1ba33b082519d82964ea93868d676662d4a59ad548df0e7d2102aaec38470f6aad0042c6e877cfd8087d2676b0f516fddd362801b9bd3936399e2103b209fd4f53a7170ea4444e0cb0a6bb6a53c2bd016926989cf85f9b0fba17a70c2103b8d9d5771d8f513aa0869b9cc8d50986403b78c6da36890638c3d46a5adce04a2102ca0e27697b9c248f6f16e085fd0061e26f44da85b58ee835c110caa5ec3ba5542102df48f60e8f3e01c48ff40b9b7f1310d7a8b2a193188befe1c2e3df740e89509357ae"
+
+func TestParseVerification(t *testing.T) {
+	script, _ := hex.DecodeString(validatorsScript)
+
+	if addr := util.GetAddressFromScriptHash(util.GetScriptHash(script)); addr != "APyEx5f4Zm4oCHwFWiSTaph1fPBxZacYVR" {
+		t.Fatalf("validators script hashes to %s", addr)
+	}
+
+	m, keys, err := parseVerification(script)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if m != 5 || len(keys) != 7 {
+		t.Fatalf("parsed %d-of-%d contract, expected 5-of-7", m, len(keys))
+	}
+	if hex.EncodeToString(keys[1]) != "024c7b7fb6c310fccf1ba33b082519d82964ea93868d676662d4a59ad548df0e7d" {
+		t.Fatalf("unexpected second key %x", keys[1])
+	}
+
+	// PUSH1 RET is not a signature contract.
+	if _, _, err := parseVerification([]byte{0x51, 0x66}); err != ErrUnsupported {
+		t.Fatalf("expected ErrUnsupported, got %v", err)
+	}
+	if err := Verify(nil, nil, []byte{0x51}); err != ErrUnsupported {
+		t.Fatalf("expected ErrUnsupported, got %v", err)
+	}
+}
+
+type testKey struct {
+	priv *ecdsa.PrivateKey
+}
+
+func newTestKey(t *testing.T) testKey {
+	priv, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return testKey{priv: priv}
+}
+
+func (k testKey) publicKey() []byte {
+	data := make([]byte, 33)
+	data[0] = 0x02 | byte(k.priv.Y.Bit(0))
+	b := k.priv.X.Bytes()
+	copy(data[33-len(b):], b)
+	return data
+}
+
+// sign returns the invocation script pushing the signature of data.
+func (k testKey) sign(t *testing.T, data []byte) []byte {
+	r, s, err := ecdsa.Sign(rand.Reader, k.priv, util.Sha256(data))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	script := make([]byte, 65)
+	script[0] = opPushBytes64
+	putInt(script[1:33], r)
+	putInt(script[33:65], s)
+	return script
+}
+
+func putInt(dst []byte, v *big.Int) {
+	b := v.Bytes()
+	copy(dst[len(dst)-len(b):], b)
+}
+
+func multiSigScript(m int, keys ...testKey) []byte {
+	script := []byte{byte(0x50 + m)}
+	for _, k := range keys {
+		script = append(script, byte(smartcontract.OpPushBytes33))
+		script = append(script, k.publicKey()...)
+	}
+	return append(script, byte(0x50+len(keys)), byte(smartcontract.OpCheckMultiSig))
+}
+
+func TestVerifySignature(t *testing.T) {
+	data := []byte("signed transaction data")
+	key := newTestKey(t)
+
+	verification := append(append([]byte{byte(smartcontract.OpPushBytes33)}, key.publicKey()...), byte(smartcontract.OpCheckSig))
+
+	if err := Verify(data, key.sign(t, data), verification); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := Verify([]byte("other data"), key.sign(t, data), verification); err == nil {
+		t.Fatal("signature of other data verifies")
+	}
+
+	other := newTestKey(t)
+	if err := Verify(data, other.sign(t, data), verification); err == nil {
+		t.Fatal("signature of other key verifies")
+	}
+
+	if err := Verify(data, nil, verification); err == nil {
+		t.Fatal("missing signature verifies")
+	}
+}
+
+func TestVerifyMultiSignature(t *testing.T) {
+	data := []byte("signed transaction data")
+	keys := []testKey{newTestKey(t), newTestKey(t), newTestKey(t)}
+	verification := multiSigScript(2, keys...)
+
+	sign := func(signers ...int) []byte {
+		script := []byte{}
+		for _, i := range signers {
+			script = append(script, keys[i].sign(t, data)...)
+		}
+		return script
+	}
+
+	for _, signers := range [][]int{{0, 1}, {0, 2}, {1, 2}} {
+		if err := Verify(data, sign(signers...), verification); err != nil {
+			t.Fatalf("signers %v: %v", signers, err)
+		}
+	}
+
+	// Signatures must follow the order of public keys.
+	if err := Verify(data, sign(2, 0), verification); err == nil {
+		t.Fatal("signatures in wrong order verify")
+	}
+
+	if err := Verify(data, sign(0), verification); err == nil {
+		t.Fatal("too few signatures verify")
+	}
+
+	if err := Verify(data, sign(0, 1, 2), verification); err == nil {
+		t.Fatal("too many signatures verify")
+	}
+}
+
+// mainnetWitnesses are unsigned data and witnesses of mainnet transactions signed by a single signature,
+// state transaction 0x8abf5ebdb9a8223b12109513647f45bd3c0a6cf1a6346d56684cff71ba308724 and
+// enrollment transaction 0x988832f693785dcbcb8d5a0e9d5d22002adcbfb1eb6bbeebf8c494fff580e147.
+var mainnetWitnesses = []struct {
+	unsigned     string
+	invocation   string
+	verification string
+}{
+	{
+		"900001482103c089d7122b840a4935234e82e26ae5efd0c2acb627239dc9f207311337b6f2c10a5265676973746572656401010001cb4184f0a96e72656c1fbdd4f75cca567519e909fd43cefcec13d6c6abcb92a1000001e72d286979ee6cb1b7e65dfddfb2e384100b8d148e7758de42e4168b71792c6000b8fb050109000071f9cf7f0ec74ec0b0f28a92b12e1081574c0af0",
+		"408780d7b3c0aadc5398153df5e2f1cf159db21b8b0f34d3994d865433f79fafac41683783c48aef510b67660e3157b701b9ca4dd9946a385d578fba7dd26f4849",
+		"2103c089d7122b840a4935234e82e26ae5efd0c2acb627239dc9f207311337b6f2c1ac",
+	},
+	{
+		"200002ff8ac54687f36bbc31a91b730cc385da8af0b581f2d59d82b5cfef824fd271f60001d3d3b7028d61fea3b7803fda3d7f0a1f7262d38e5e1c8987b0313e0a94574151000001e72d286979ee6cb1b7e65dfddfb2e384100b8d148e7758de42e4168b71792c60005441d11600000050ac4949596f5b62fef7be4d1c3e494e6048ed4a",
+		"4079d78189d591097b17657a62240c93595e8233dc81157ea2cd477813f09a11fd72845e6bd97c5a3dda125985ea3d5feca387e9933649a9a671a69ab3f6301df6",
+		"2102ff8ac54687f36bbc31a91b730cc385da8af0b581f2d59d82b5cfef824fd271f6ac",
+	},
+}
+
+func decodeHex(t *testing.T, s string) []byte {
+	data, err := hex.DecodeString(s)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return data
+}
+
+func TestVerifyMainnetSignature(t *testing.T) {
+	for i, w := range mainnetWitnesses {
+		data := decodeHex(t, w.unsigned)
+		invocation := decodeHex(t, w.invocation)
+		verification := decodeHex(t, w.verification)
+
+		if err := Verify(data, invocation, verification); err != nil {
+			t.Fatalf("witness %d: %v", i, err)
+		}
+
+		// Flipping one byte of the signed data breaks the signature.
+		data[len(data)/2] ^= 0x01
+		if err := Verify(data, invocation, verification); err == nil {
+			t.Fatalf("witness %d verifies for modified data", i)
+		}
+	}
+}
+
+// TestVerifyMainnetMultiSignature checks real signatures against multi-signature contracts of their keys.
+func TestVerifyMainnetMultiSignature(t *testing.T) {
+	// The public key pushed by a signature contract.
+	key := func(w int) []byte {
+		return decodeHex(t, mainnetWitnesses[w].verification)[1:34]
+	}
+	contract := func(m int, keys ...[]byte) []byte {
+		script := []byte{byte(0x50 + m)}
+		for _, k := range keys {
+			script = append(append(script, byte(smartcontract.OpPushBytes33)), k...)
+		}
+		return append(script, byte(0x50+len(keys)), byte(smartcontract.OpCheckMultiSig))
+	}
+
+	data := decodeHex(t, mainnetWitnesses[0].unsigned)
+	invocation := decodeHex(t, mainnetWitnesses[0].invocation)
+
+	for _, verification := range [][]byte{
+		contract(1, key(0)),
+		contract(1, key(0), key(1)),
+		contract(1, key(1), key(0)),
+	} {
+		if err := Verify(data, invocation, verification); err != nil {
+			t.Fatalf("contract %x: %v", verification, err)
+		}
+	}
+
+	if err := Verify(data, invocation, contract(1, key(1))); err == nil {
+		t.Fatal("signature verifies for another key")
+	}
+	if err := Verify(data, invocation, contract(2, key(0), key(1))); err == nil {
+		t.Fatal("one signature verifies for a 2-of-2 contract")
+	}
+
+	data[0] ^= 0x01
+	if err := Verify(data, invocation, contract(1, key(0), key(1))); err == nil {
+		t.Fatal("multi-signature verifies for modified data")
+	}
+}